@@ -0,0 +1,141 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/policy"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/providers"
+)
+
+const approvalPolicyEventType = "approval.policy"
+
+func newApprovalPolicy(cfg *config.Config) *policy.File {
+	if cfg.Approvals.PolicyFile == "" {
+		return nil
+	}
+	return policy.NewFile(cfg.Approvals.PolicyFile)
+}
+
+// applyApprovalPolicy checks a blocking permission request against the host
+// rules. An allow or deny match is recorded as an approval.policy event and
+// returned as the hook's decision. An ask match returns only the rule name
+// so the escalated request can say which rule sent it.
+func (a *Agent) applyApprovalPolicy(sessionID, provider, approvalID string, hookData map[string]any) (*providers.ApprovalDecision, string) {
+	if a.approvalPolicy == nil {
+		return nil, ""
+	}
+	rules, err := a.approvalPolicy.Policy()
+	if err != nil {
+		log.Printf("Approval policy: %v", err)
+	}
+	req := a.approvalPolicyRequest(sessionID, provider, hookData)
+	match, ok := rules.Evaluate(req)
+	if !ok {
+		return nil, ""
+	}
+	if match.Action == policy.ActionAsk {
+		return nil, match.Rule
+	}
+
+	payload := map[string]any{
+		"approval_id": approvalID,
+		"provider":    provider,
+		"tool_name":   req.ToolName,
+		"rule":        match.Rule,
+		"decision":    match.Action,
+	}
+	if req.Command != "" {
+		payload["command"] = req.Command
+	}
+	if len(req.Paths) > 0 {
+		payload["paths"] = req.Paths
+	}
+	a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
+		SessionID: sessionID,
+		EventType: approvalPolicyEventType,
+		Payload:   payload,
+	})
+	return &providers.ApprovalDecision{Decision: match.Action, Mode: "hook"}, ""
+}
+
+func (a *Agent) approvalPolicyRequest(sessionID, provider string, hookData map[string]any) policy.Request {
+	req := policy.Request{
+		Provider: provider,
+		ToolName: strings.TrimSpace(providers.ToolName(hookData)),
+	}
+	a.sessionsMu.RLock()
+	if session, ok := a.sessions[sessionID]; ok {
+		req.GroupID = session.GroupID
+		req.CWD = session.CWD
+	}
+	a.sessionsMu.RUnlock()
+	if cwd := extractHookString(hookData, "cwd"); cwd != "" {
+		req.CWD = cwd
+	}
+	req.RepoRoot = findRepoRoot(req.CWD)
+
+	input := extractToolInput(hookData)
+	if input == nil {
+		input = hookData
+	}
+	req.Command = approvalCommandText(input["command"])
+	for _, key := range []string{"file_path", "path", "notebook_path"} {
+		if path, ok := input[key].(string); ok && path != "" {
+			req.Paths = append(req.Paths, path)
+		}
+	}
+	if paths, ok := input["paths"].([]any); ok {
+		for _, path := range paths {
+			if text, ok := path.(string); ok && text != "" {
+				req.Paths = append(req.Paths, text)
+			}
+		}
+	}
+	// Codex patch approvals key their changes by file.
+	if changes, ok := input["changes"].(map[string]any); ok {
+		start := len(req.Paths)
+		for path := range changes {
+			req.Paths = append(req.Paths, path)
+		}
+		sort.Strings(req.Paths[start:])
+	}
+	return req
+}
+
+// approvalCommandText flattens a command given as a string or an argv list.
+func approvalCommandText(value any) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case []any:
+		parts := make([]string, 0, len(typed))
+		for _, part := range typed {
+			if text, ok := part.(string); ok {
+				parts = append(parts, text)
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+	return ""
+}
+
+// findRepoRoot returns the nearest directory at or above dir holding a .git
+// entry, or "" when dir is not inside a repository.
+func findRepoRoot(dir string) string {
+	if dir == "" || !filepath.IsAbs(dir) {
+		return ""
+	}
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		if isGitRepoDir(dir) {
+			return dir
+		}
+		if dir == filepath.Dir(dir) {
+			return ""
+		}
+	}
+}
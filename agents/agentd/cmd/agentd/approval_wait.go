@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/providers"
+)
+
+const (
+	approvalReminderEventType = "approval.reminder"
+	approvalExpiredEventType  = "approval.expired"
+)
+
+// approvalWait applies the session group's approval timeout, fallback and
+// reminder interval to a blocking permission request.
+func (a *Agent) approvalWait(payload providers.ClaudeHookPayload) config.ApprovalWaitConfig {
+	group := ""
+	if sessionID := a.resolveHookSessionID(payload); sessionID != "" {
+		a.sessionsMu.RLock()
+		if session, ok := a.sessions[sessionID]; ok {
+			group = session.GroupID
+		}
+		a.sessionsMu.RUnlock()
+	}
+	return a.cfg.Providers.Claude.ApprovalWait(group)
+}
+
+// handleApprovalReminder re-announces a request that is still waiting so
+// the control plane can notify again.
+func (a *Agent) handleApprovalReminder(payload providers.ClaudeHookPayload, reminder int) {
+	sessionID, provider := a.approvalWaitSession(payload)
+	if sessionID == "" {
+		return
+	}
+	event := map[string]any{
+		"approval_id": payload.ApprovalID,
+		"provider":    provider,
+		"reminder":    reminder,
+	}
+	if !payload.ExpiresAt.IsZero() {
+		event["expires_at"] = payload.ExpiresAt.Format(time.RFC3339)
+	}
+	a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
+		SessionID: sessionID,
+		EventType: approvalReminderEventType,
+		Payload:   event,
+	})
+}
+
+// handleApprovalExpired records a request whose wait ran out. An allow or
+// deny fallback has answered the hook, so the session is running again;
+// the prompt fallback leaves the question on the CLI's own dialog.
+func (a *Agent) handleApprovalExpired(payload providers.ClaudeHookPayload, fallback string) {
+	sessionID, provider := a.approvalWaitSession(payload)
+	if sessionID == "" {
+		return
+	}
+	a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
+		SessionID: sessionID,
+		EventType: approvalExpiredEventType,
+		Payload: map[string]any{
+			"approval_id": payload.ApprovalID,
+			"provider":    provider,
+			"fallback":    fallback,
+		},
+	})
+	if fallback == config.ApprovalFallbackAllow || fallback == config.ApprovalFallbackDeny {
+		a.updateSessionFromHook(sessionID, "RUNNING", "", nil)
+	}
+}
+
+func (a *Agent) approvalWaitSession(payload providers.ClaudeHookPayload) (string, string) {
+	sessionID := a.resolveHookSessionID(payload)
+	if sessionID == "" {
+		return "", ""
+	}
+	a.sessionsMu.RLock()
+	defer a.sessionsMu.RUnlock()
+	if session, ok := a.sessions[sessionID]; ok {
+		return sessionID, session.Provider
+	}
+	return sessionID, ""
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/google/uuid"
+)
+
+const broadcastInputEventType = "input.broadcast"
+
+type broadcastTarget struct {
+	sessionID string
+	paneID    string
+	err       error
+}
+
+// executeBroadcastInput types the same text into every target pane in
+// parallel. tmux synchronize-panes only spans the panes of one window, so the
+// fan-out happens here. Each target reports its own outcome; the command only
+// fails when nothing matched.
+func (a *Agent) executeBroadcastInput(cmdID string, payload json.RawMessage) (map[string]any, error) {
+	if !a.cfg.Security.AllowSendInput {
+		return nil, fmt.Errorf("broadcast_input not allowed by policy")
+	}
+	var p protocol.BroadcastInputPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	groupID := strings.TrimSpace(p.GroupID)
+	provider := strings.TrimSpace(p.Provider)
+	if len(p.SessionIDs) == 0 && groupID == "" && provider == "" {
+		return nil, commands.NewResultError("INVALID_TARGET", "session_ids, group_id, or provider is required")
+	}
+
+	targets := a.broadcastTargets(p.SessionIDs, groupID, provider)
+	if len(targets) == 0 {
+		return nil, commands.NewResultError("NO_TARGETS", "no live sessions match the broadcast target")
+	}
+
+	var wg sync.WaitGroup
+	for i := range targets {
+		if targets[i].err == nil {
+			targets[i].err = a.budgetHold(targets[i].sessionID, "", "")
+		}
+		if targets[i].err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(target *broadcastTarget) {
+			defer wg.Done()
+			target.err = a.sendInputSmart(target.paneID, p.Text, p.Enter)
+		}(&targets[i])
+	}
+	wg.Wait()
+
+	broadcastID := uuid.NewString()
+	results := make([]map[string]any, 0, len(targets))
+	delivered := 0
+	for _, target := range targets {
+		result := map[string]any{
+			"session_id": target.sessionID,
+			"ok":         target.err == nil,
+		}
+		if target.paneID != "" {
+			result["pane_id"] = target.paneID
+		}
+		if target.err != nil {
+			result["error"] = target.err.Error()
+		} else {
+			delivered++
+		}
+		results = append(results, result)
+	}
+	for _, target := range targets {
+		if target.paneID == "" {
+			continue
+		}
+		event := map[string]any{
+			"broadcast_id": broadcastID,
+			"cmd_id":       cmdID,
+			"text":         p.Text,
+			"enter":        p.Enter,
+			"target_count": len(targets),
+			"delivered":    target.err == nil,
+		}
+		if target.err != nil {
+			event["error"] = target.err.Error()
+		}
+		a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
+			SessionID: target.sessionID,
+			EventType: broadcastInputEventType,
+			Payload:   event,
+		})
+	}
+	return map[string]any{
+		"broadcast_id": broadcastID,
+		"targets":      results,
+		"delivered":    delivered,
+		"failed":       len(targets) - delivered,
+	}, nil
+}
+
+// broadcastTargets resolves a broadcast to panes, ordered by session ID.
+// GroupID and provider narrow named sessions too, as in search_panes.
+// Explicitly named sessions that cannot receive input stay in the list with
+// an error so the caller sees why.
+func (a *Agent) broadcastTargets(sessionIDs []string, groupID, provider string) []broadcastTarget {
+	a.sessionsMu.RLock()
+	defer a.sessionsMu.RUnlock()
+	matches := func(session *SessionState) bool {
+		return (groupID == "" || session.GroupID == groupID) && (provider == "" || session.Provider == provider)
+	}
+	var targets []broadcastTarget
+	if len(sessionIDs) > 0 {
+		seen := make(map[string]bool, len(sessionIDs))
+		for _, id := range sessionIDs {
+			if id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			target := broadcastTarget{sessionID: id}
+			session, ok := a.sessions[id]
+			if ok && !matches(session) {
+				continue
+			}
+			switch {
+			case !ok:
+				target.err = fmt.Errorf("session not found")
+			case session.PaneID == "" || session.Status == "DONE":
+				target.err = fmt.Errorf("session has no live pane")
+			default:
+				target.paneID = session.PaneID
+			}
+			targets = append(targets, target)
+		}
+	} else {
+		for _, session := range a.sessions {
+			if session.PaneID == "" || session.Status == "DONE" || !matches(session) {
+				continue
+			}
+			targets = append(targets, broadcastTarget{sessionID: session.ID, paneID: session.PaneID})
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].sessionID < targets[j].sessionID })
+	return targets
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/agent-command/agentd/internal/budget"
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/usage"
+)
+
+const (
+	budgetWarningEventType  = "budget.warning"
+	budgetExceededEventType = "budget.exceeded"
+	// budgetExceededDetail is the status detail of a session held by a
+	// budget. It survives status changes until the hold is lifted.
+	budgetExceededDetail = "BUDGET_EXCEEDED"
+)
+
+// newBudgetEngine installs the config-declared budgets. A bad budget is
+// logged and skipped so one typo does not take the daemon down.
+func newBudgetEngine(cfg *config.Config) *budget.Engine {
+	engine := budget.NewEngine()
+	for _, declared := range cfg.Budgets {
+		if _, err := engine.Add(budget.Budget{
+			ID:          declared.ID,
+			Scope:       declared.Scope,
+			GroupID:     declared.GroupID,
+			Unit:        declared.Unit,
+			Limit:       declared.Limit,
+			SoftPercent: declared.SoftPercent,
+			Action:      declared.Action,
+			Source:      budget.SourceConfig,
+		}); err != nil {
+			log.Printf("Skipping budget %q: %v", declared.ID, err)
+		}
+	}
+	return engine
+}
+
+func (a *Agent) executeSetBudget(payload json.RawMessage) (map[string]any, error) {
+	if a.budgets == nil {
+		return nil, fmt.Errorf("budgets are not available")
+	}
+	var p protocol.SetBudgetPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	installed, err := a.budgets.Add(budget.Budget{
+		ID:          p.BudgetID,
+		Scope:       p.Scope,
+		SessionID:   p.SessionID,
+		GroupID:     p.GroupID,
+		Unit:        p.Unit,
+		Limit:       p.Limit,
+		SoftPercent: p.SoftPercent,
+		Action:      p.Action,
+		Source:      budget.SourceCommand,
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.refreshBudgetHolds()
+	return map[string]any{"budget": installed}, nil
+}
+
+func (a *Agent) executeRemoveBudget(payload json.RawMessage) error {
+	if a.budgets == nil {
+		return fmt.Errorf("budgets are not available")
+	}
+	var p protocol.RemoveBudgetPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+	if err := a.budgets.Remove(p.BudgetID); err != nil {
+		if errors.Is(err, budget.ErrNotFound) {
+			return commands.NewResultError("BUDGET_NOT_FOUND", err.Error())
+		}
+		return err
+	}
+	a.refreshBudgetHolds()
+	return nil
+}
+
+func (a *Agent) executeListBudgets() (map[string]any, error) {
+	if a.budgets == nil {
+		return map[string]any{"budgets": []budget.Budget{}}, nil
+	}
+	return map[string]any{"budgets": a.budgets.Budgets(), "spend": a.budgets.Spend()}, nil
+}
+
+// handleSpend measures a session's new spend against the budgets and emits
+// an event for each threshold it crossed. Crossing a hard limit holds the
+// sessions the budget covers.
+func (a *Agent) handleSpend(spend usage.Spend) {
+	if a.budgets == nil {
+		return
+	}
+	for _, crossing := range a.budgets.Record(spend) {
+		eventType := budgetWarningEventType
+		event := map[string]any{
+			"budget_id": crossing.Budget.ID,
+			"scope":     crossing.Budget.Scope,
+			"unit":      crossing.Budget.Unit,
+			"limit":     crossing.Budget.Limit,
+			"spent":     crossing.Spent,
+			"threshold": crossing.Threshold,
+			"level":     crossing.Level,
+			"subject":   crossing.Subject,
+			"action":    crossing.Budget.Action,
+		}
+		if crossing.Level == budget.LevelHard {
+			eventType = budgetExceededEventType
+			event["session_ids"] = a.enforceBudget(crossing)
+		}
+		a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
+			SessionID: spend.SessionID,
+			EventType: eventType,
+			Payload:   event,
+		})
+	}
+}
+
+// enforceBudget holds the live agent sessions a budget over its hard limit
+// covers and, for the interrupt action, stops the ones mid-turn: a job is
+// stopped and a pane gets Ctrl-C. It returns the held session IDs.
+func (a *Agent) enforceBudget(crossing budget.Crossing) []string {
+	type interruptTarget struct{ sessionID, paneID string }
+	var held []string
+	var interrupts []interruptTarget
+	var updates []protocol.SessionUpsert
+
+	a.sessionsMu.Lock()
+	for _, session := range a.sessions {
+		if session.Status == "DONE" || !budgetEnforced(session.Provider) || !budgetCovers(crossing, session) {
+			continue
+		}
+		held = append(held, session.ID)
+		if session.Metadata == nil {
+			session.Metadata = map[string]any{}
+		}
+		session.Metadata["budget"] = map[string]any{
+			"budget_id": crossing.Budget.ID,
+			"scope":     crossing.Budget.Scope,
+			"unit":      crossing.Budget.Unit,
+			"limit":     crossing.Budget.Limit,
+			"spent":     crossing.Spent,
+			"action":    crossing.Budget.Action,
+		}
+		session.Metadata["status_detail"] = budgetExceededDetail
+		updates = append(updates, sessionUpsert(session))
+		if crossing.Budget.Action == budget.ActionInterrupt && (session.Status == "RUNNING" || session.Status == "WAITING_FOR_APPROVAL") {
+			interrupts = append(interrupts, interruptTarget{session.ID, session.PaneID})
+		}
+	}
+	a.sessionsMu.Unlock()
+
+	if len(updates) > 0 {
+		a.send(protocol.TypeSessionsUpsert, protocol.SessionsUpsertPayload{Sessions: updates})
+	}
+	for _, target := range interrupts {
+		a.jobsMu.Lock()
+		job := a.jobs[target.sessionID]
+		a.jobsMu.Unlock()
+		switch {
+		case job != nil:
+			job.stop("budget_exceeded", defaultJobStopGrace)
+		case target.paneID != "" && a.tmuxClient != nil:
+			if err := a.tmuxClient.SendInterrupt(target.paneID); err != nil {
+				log.Printf("Budget %s interrupt failed for %s: %v", crossing.Budget.ID, target.sessionID, err)
+			}
+		}
+	}
+	sort.Strings(held)
+	return held
+}
+
+// budgetHold returns a BUDGET_EXCEEDED error when a budget over its hard
+// limit holds prompts to sessionID. An empty sessionID checks a session
+// about to start in groupID with provider; an empty provider is checked as
+// an agent.
+func (a *Agent) budgetHold(sessionID, groupID, provider string) error {
+	if a.budgets == nil {
+		return nil
+	}
+	if sessionID == "" && provider != "" && !budgetEnforced(provider) {
+		return nil
+	}
+	if sessionID != "" {
+		a.sessionsMu.RLock()
+		session, ok := a.sessions[sessionID]
+		enforced := ok && budgetEnforced(session.Provider)
+		if ok {
+			groupID = session.GroupID
+		}
+		a.sessionsMu.RUnlock()
+		if !enforced {
+			return nil
+		}
+	}
+	exceeded := a.budgets.Exceeded(sessionID, groupID)
+	if len(exceeded) == 0 {
+		if sessionID != "" {
+			a.refreshBudgetHolds()
+		}
+		return nil
+	}
+	crossing := exceeded[0]
+	return commands.NewResultError("BUDGET_EXCEEDED", fmt.Sprintf("budget %s exceeded: %s %g of %d", crossing.Budget.ID, crossing.Budget.Unit, crossing.Spent, crossing.Budget.Limit))
+}
+
+// refreshBudgetHolds lifts the hold on sessions no longer over any budget,
+// as after a limit is raised or removed or a new day starts.
+func (a *Agent) refreshBudgetHolds() {
+	if a.budgets == nil {
+		return
+	}
+	var updates []protocol.SessionUpsert
+	a.sessionsMu.Lock()
+	for _, session := range a.sessions {
+		if session.Metadata == nil || session.Metadata["budget"] == nil {
+			continue
+		}
+		if len(a.budgets.Exceeded(session.ID, session.GroupID)) > 0 {
+			continue
+		}
+		session.Metadata["budget"] = nil
+		if session.Metadata["status_detail"] == budgetExceededDetail {
+			session.Metadata["status_detail"] = nil
+		}
+		updates = append(updates, sessionUpsert(session))
+	}
+	a.sessionsMu.Unlock()
+	if len(updates) > 0 {
+		a.send(protocol.TypeSessionsUpsert, protocol.SessionsUpsertPayload{Sessions: updates})
+	}
+}
+
+// budgetEnforced reports whether budgets hold sessions of provider. Shells
+// and unrecognized commands spend nothing agentd can see.
+func budgetEnforced(provider string) bool {
+	return provider != "" && provider != "shell" && provider != "unknown"
+}
+
+// budgetCovers reports whether session counts toward the spend that
+// crossed a budget.
+func budgetCovers(crossing budget.Crossing, session *SessionState) bool {
+	if !crossing.Budget.Applies(session.ID, session.GroupID) {
+		return false
+	}
+	switch crossing.Budget.Scope {
+	case budget.ScopeSession:
+		return session.ID == crossing.Subject
+	case budget.ScopeGroup:
+		return session.GroupID == crossing.Subject
+	}
+	return true
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/filebridge"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/recording"
+)
+
+// maxInlineCastBytes bounds a cast returned in a command result; longer
+// windows have to be published through the file bridge.
+const maxInlineCastBytes = 4 << 20
+
+func (a *Agent) executeExportCast(sessionID string, payload json.RawMessage) (map[string]any, error) {
+	if a.recorder == nil {
+		return nil, commands.NewResultError("RECORDING_DISABLED", "pane recording is not enabled on this host")
+	}
+	var p protocol.ExportCastPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+	}
+	opts := recording.CastOptions{Title: p.Title, IdleTimeLimit: p.IdleTimeLimit}
+	var err error
+	if opts.Since, err = parseRecordingTime("since", p.Since); err != nil {
+		return nil, err
+	}
+	if opts.Until, err = parseRecordingTime("until", p.Until); err != nil {
+		return nil, err
+	}
+	if opts.Title == "" {
+		opts.Title = a.castTitle(sessionID)
+	}
+	if p.Publish && a.fileBridge == nil {
+		return nil, filebridge.ErrDisabled
+	}
+
+	var cast bytes.Buffer
+	stats, err := a.recorder.ExportCast(&cast, sessionID, opts)
+	if err != nil {
+		return nil, castResultError(err)
+	}
+	name := castFileName(sessionID, stats.StartedAt)
+	result := map[string]any{
+		"name":        name,
+		"size_bytes":  cast.Len(),
+		"events":      stats.Events,
+		"duration_ms": stats.Duration.Milliseconds(),
+		"width":       stats.Cols,
+		"height":      stats.Rows,
+		"started_at":  stats.StartedAt.UTC().Format(time.RFC3339),
+	}
+	if !p.Publish {
+		if cast.Len() > maxInlineCastBytes {
+			return nil, commands.NewResultError("CAST_TOO_LARGE",
+				fmt.Sprintf("cast is %d bytes; narrow the window or publish it to the file bridge", cast.Len()))
+		}
+		result["cast"] = cast.String()
+		return result, nil
+	}
+
+	// Publish copies from a path, and the copy keeps the source's base name.
+	tempDir, err := os.MkdirTemp("", "agentd-cast-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+	tempPath := filepath.Join(tempDir, name)
+	if err := os.WriteFile(tempPath, cast.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+	path, size, err := a.fileBridge.Publish(tempPath)
+	if err != nil {
+		return nil, err
+	}
+	result["path"] = path
+	result["name"] = filepath.Base(path)
+	result["size_bytes"] = size
+	return result, nil
+}
+
+func (a *Agent) castTitle(sessionID string) string {
+	a.sessionsMu.RLock()
+	defer a.sessionsMu.RUnlock()
+	if session := a.sessions[sessionID]; session != nil && session.Title != "" {
+		return session.Title
+	}
+	return sessionID
+}
+
+func castResultError(err error) error {
+	switch {
+	case errors.Is(err, recording.ErrNotFound):
+		return commands.NewResultError("RECORDING_NOT_FOUND", err.Error())
+	case errors.Is(err, recording.ErrEmptyRange):
+		return commands.NewResultError("EMPTY_RANGE", err.Error())
+	case errors.Is(err, recording.ErrInvalidRange):
+		return commands.NewResultError("INVALID_RANGE", err.Error())
+	}
+	return err
+}
+
+func castFileName(sessionID string, startedAt time.Time) string {
+	return fmt.Sprintf("%s-%s.cast", sessionID, startedAt.UTC().Format("20060102T150405Z"))
+}
+
+// runCastCommand exports a recording straight from disk, so it works whether
+// or not the daemon is running.
+func runCastCommand(args []string) {
+	fs := flag.NewFlagSet("cast", flag.ExitOnError)
+	configPath := fs.String("config", "/etc/agentd/config.yaml", "Path to config file")
+	since := fs.String("since", "", "Start of the window: RFC3339 time or a duration ago (e.g. 2h)")
+	until := fs.String("until", "", "End of the window: RFC3339 time or a duration ago")
+	title := fs.String("title", "", "Title stored in the cast header")
+	output := fs.String("o", "", "Output file (default <session>-<start>.cast, - for stdout)")
+	idleLimit := fs.Float64("idle-limit", 0, "Cap idle gaps during playback to this many seconds")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: agentd cast [options] <session-id>")
+		fs.PrintDefaults()
+	}
+	// Accept the session before or after the flags.
+	var sessionID string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sessionID, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if sessionID == "" && fs.NArg() > 0 {
+		sessionID = fs.Arg(0)
+	}
+	if sessionID == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	recorder, err := recording.New(recording.Config{Dir: cfg.Recording.Dir, ReadOnly: true})
+	if err != nil {
+		log.Fatalf("Failed to open recordings: %v", err)
+	}
+	now := time.Now()
+	opts := recording.CastOptions{Title: *title, IdleTimeLimit: *idleLimit}
+	if opts.Since, err = parseCastFlagTime(*since, now); err != nil {
+		log.Fatalf("Invalid -since: %v", err)
+	}
+	if opts.Until, err = parseCastFlagTime(*until, now); err != nil {
+		log.Fatalf("Invalid -until: %v", err)
+	}
+	if opts.Title == "" {
+		opts.Title = sessionID
+	}
+
+	var cast bytes.Buffer
+	stats, err := recorder.ExportCast(&cast, sessionID, opts)
+	if err != nil {
+		log.Fatalf("Failed to export cast: %v", err)
+	}
+	path := *output
+	if path != "-" {
+		if path == "" {
+			path = castFileName(sessionID, stats.StartedAt)
+		}
+		if err := os.WriteFile(path, cast.Bytes(), 0644); err != nil {
+			log.Fatalf("Failed to write cast: %v", err)
+		}
+		fmt.Printf("Wrote %s (%d events, %s, %dx%d)\n", path, stats.Events, stats.Duration.Round(time.Second), stats.Cols, stats.Rows)
+		return
+	}
+	os.Stdout.Write(cast.Bytes())
+}
+
+func parseCastFlagTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		return now.Add(-ago), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
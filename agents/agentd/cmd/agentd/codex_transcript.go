@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/agent-command/agentd/internal/proc"
+)
+
+// codexStartSlack allows for the gap between the Codex process starting and
+// it writing the rollout's session_meta timestamp, and for clock rounding.
+const codexStartSlack = 2 * time.Second
+
+// resolveCodexRolloutPath finds the rollout JSONL for a Codex session. A path
+// reported by a hook wins, then a rollout held open by a process in the
+// pane, then the rollout whose session_meta matches the pane's cwd and the
+// Codex process start time.
+func (a *Agent) resolveCodexRolloutPath(session *SessionState) (string, string, error) {
+	sessionsRoot, err := a.codexTranscriptRoot()
+	if err != nil {
+		return "", "", err
+	}
+	if retained := a.transcriptPathForSession(session.ID); retained != "" {
+		if resolved, resolveErr := resolveTranscriptFile(sessionsRoot, retained); resolveErr == nil {
+			return resolved, "hook", nil
+		}
+	}
+
+	a.sessionsMu.RLock()
+	cwd := session.CWD
+	panePID := sessionPanePID(session)
+	a.sessionsMu.RUnlock()
+
+	var startedAt time.Time
+	if panePID > 0 {
+		snapshot := proc.TakeSnapshot()
+		if open := openCodexRollout(sessionsRoot, snapshot.Descendants(panePID)); open != "" {
+			return open, "process", nil
+		}
+		if pid := snapshot.FindDescendantCmd(panePID, []string{"codex"}); pid > 0 {
+			startedAt, _ = proc.StartTime(pid)
+		}
+	}
+	if strings.TrimSpace(cwd) == "" {
+		return "", "", fmt.Errorf("no Codex rollout for session")
+	}
+	if derived := findCodexRollout(sessionsRoot, filepath.Clean(cwd), startedAt); derived != "" {
+		return derived, "derived", nil
+	}
+	return "", "", fmt.Errorf("no Codex rollout for session")
+}
+
+func (a *Agent) codexTranscriptRoot() (string, error) {
+	if a.codexSessionsRoot != "" {
+		return a.codexSessionsRoot, nil
+	}
+	if codexHome := strings.TrimSpace(os.Getenv("CODEX_HOME")); codexHome != "" {
+		return filepath.Join(codexHome, "sessions"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home directory: %w", err)
+	}
+	return filepath.Join(home, ".codex", "sessions"), nil
+}
+
+func sessionPanePID(session *SessionState) int {
+	tmuxMeta, _ := session.Metadata["tmux"].(map[string]any)
+	switch pid := tmuxMeta["pane_pid"].(type) {
+	case int:
+		return pid
+	case float64:
+		return int(pid)
+	}
+	return 0
+}
+
+// openCodexRollout returns the newest rollout file under sessionsRoot that
+// one of pids has open. Codex keeps its rollout open while it runs.
+func openCodexRollout(sessionsRoot string, pids []int) string {
+	var newestPath string
+	var newestModTime int64
+	for _, pid := range pids {
+		fdDir := fmt.Sprintf("/proc/%d/fd", pid)
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !isCodexRolloutName(filepath.Base(target)) {
+				continue
+			}
+			resolved, err := resolveTranscriptFile(sessionsRoot, target)
+			if err != nil {
+				continue
+			}
+			info, err := os.Stat(resolved)
+			if err != nil {
+				continue
+			}
+			if modTime := info.ModTime().UnixNano(); newestPath == "" || modTime > newestModTime {
+				newestPath = resolved
+				newestModTime = modTime
+			}
+		}
+	}
+	return newestPath
+}
+
+func isCodexRolloutName(name string) bool {
+	return strings.HasPrefix(name, "rollout-") && strings.EqualFold(filepath.Ext(name), ".jsonl")
+}
+
+// findCodexRollout scans the YYYY/MM/DD rollout tree for files recorded in
+// cwd. With a known start time it picks the first rollout begun at or after
+// it, looking only in the days from then on, and finds none when no rollout
+// qualifies: an earlier one belongs to another session. Without a start
+// time it picks the most recently written one.
+func findCodexRollout(sessionsRoot, cwd string, startedAt time.Time) string {
+	// Day directories are named in local time; one day of margin covers
+	// timezone differences around midnight.
+	var earliestDay string
+	if !startedAt.IsZero() {
+		earliestDay = startedAt.Add(-24 * time.Hour).Format("2006/01/02")
+	}
+
+	var startedPath, newestPath string
+	var startedMetaTime time.Time
+	var newestModTime int64
+	filepath.WalkDir(sessionsRoot, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if entry != nil && entry.IsDir() && path != sessionsRoot {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			relative, _ := filepath.Rel(sessionsRoot, path)
+			relative = filepath.ToSlash(relative)
+			// Compare each year, month and day directory with the same
+			// prefix of the earliest day.
+			if earliestDay != "" && relative != "." && len(relative) <= len(earliestDay) &&
+				relative < earliestDay[:len(relative)] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !isCodexRolloutName(entry.Name()) {
+			return nil
+		}
+		resolved, err := resolveTranscriptFile(sessionsRoot, path)
+		if err != nil {
+			return nil
+		}
+		metaCWD, metaTime, ok := readCodexSessionMeta(resolved)
+		if !ok || filepath.Clean(metaCWD) != cwd {
+			return nil
+		}
+		info, err := os.Stat(resolved)
+		if err != nil {
+			return nil
+		}
+		if modTime := info.ModTime().UnixNano(); newestPath == "" || modTime > newestModTime {
+			newestPath = resolved
+			newestModTime = modTime
+		}
+		if !startedAt.IsZero() && !metaTime.IsZero() && !metaTime.Before(startedAt.Add(-codexStartSlack)) {
+			if startedPath == "" || metaTime.Before(startedMetaTime) {
+				startedPath = resolved
+				startedMetaTime = metaTime
+			}
+		}
+		return nil
+	})
+	if !startedAt.IsZero() {
+		return startedPath
+	}
+	return newestPath
+}
+
+// readCodexSessionMeta reads the cwd and start time from a rollout's first
+// line, a {"type":"session_meta","payload":{...}} record.
+func readCodexSessionMeta(path string) (string, time.Time, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	defer file.Close()
+	scanner := newTranscriptScanner(file)
+	if !scanner.Scan() {
+		return "", time.Time{}, false
+	}
+	var line struct {
+		Type    string `json:"type"`
+		Payload struct {
+			CWD       string `json:"cwd"`
+			Timestamp string `json:"timestamp"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &line); err != nil || line.Type != "session_meta" || line.Payload.CWD == "" {
+		return "", time.Time{}, false
+	}
+	startedAt, _ := time.Parse(time.RFC3339Nano, line.Payload.Timestamp)
+	return line.Payload.CWD, startedAt, true
+}
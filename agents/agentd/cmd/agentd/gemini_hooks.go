@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/providers"
+	"github.com/google/uuid"
+)
+
+// geminiHookAliases maps Gemini CLI hook events to the Claude Code names the
+// tool-event and workshop pipelines already understand.
+var geminiHookAliases = map[string]string{
+	"BeforeTool":   "PreToolUse",
+	"AfterTool":    "PostToolUse",
+	"BeforeAgent":  "UserPromptSubmit",
+	"AfterAgent":   "Stop",
+	"PreCompress":  "PreCompact",
+	"SessionStart": "SessionStart",
+	"SessionEnd":   "SessionEnd",
+	"Notification": "Notification",
+}
+
+// mapGeminiHookToStatus maps Gemini CLI hook events to a session status.
+// Gemini waits at its prompt after a turn, so AfterAgent means input, not
+// idle.
+func mapGeminiHookToStatus(hookName string, hookData map[string]any) string {
+	switch hookName {
+	case "SessionStart":
+		return "STARTING"
+	case "BeforeAgent", "BeforeModel", "BeforeTool", "AfterTool", "PreCompress":
+		return "RUNNING"
+	case "AfterAgent":
+		return "WAITING_FOR_INPUT"
+	case "SessionEnd":
+		return "DONE"
+	case "Notification":
+		if isGeminiPermissionNotification(hookData) {
+			return "WAITING_FOR_APPROVAL"
+		}
+	}
+	return ""
+}
+
+func isGeminiPermissionNotification(hookData map[string]any) bool {
+	kind := strings.ToLower(extractHookString(hookData, "notification_type", "notificationType"))
+	return strings.Contains(kind, "permission")
+}
+
+// normalizeGeminiHook rewrites a Gemini hook into the shape shared with the
+// other providers: a Claude-style hook name, the tool response as
+// tool_result, and permission notifications as a notification object.
+func normalizeGeminiHook(hookName string, hookData map[string]any) string {
+	if response, ok := hookData["tool_response"].(map[string]any); ok {
+		if _, exists := hookData["tool_result"]; !exists {
+			hookData["tool_result"] = response
+		}
+	}
+	if hookName == "Notification" {
+		if _, exists := hookData["notification"]; !exists {
+			notification := map[string]any{
+				"type": extractHookString(hookData, "notification_type", "notificationType"),
+			}
+			if isGeminiPermissionNotification(hookData) {
+				notification["type"] = "permission_prompt"
+			}
+			if message := extractHookString(hookData, "message"); message != "" {
+				notification["message"] = message
+			}
+			if details, ok := hookData["details"].(map[string]any); ok {
+				notification["details"] = details
+			}
+			hookData["notification"] = notification
+		}
+	}
+	if alias, ok := geminiHookAliases[hookName]; ok {
+		return alias
+	}
+	return hookName
+}
+
+func (a *Agent) handleGeminiHook(payload providers.ClaudeHookPayload) (*providers.ApprovalDecision, error) {
+	var hookData map[string]any
+	if err := json.Unmarshal(payload.Hook, &hookData); err != nil {
+		return nil, err
+	}
+
+	hookName := providers.HookName(hookData)
+
+	sessionID := a.resolveHookSessionID(payload)
+	if sessionID == "" {
+		a.bufferHook("gemini_cli", payload)
+		return nil, nil
+	}
+	a.markSessionReady(sessionID)
+
+	newStatus := mapGeminiHookToStatus(hookName, hookData)
+	normalizedName := normalizeGeminiHook(hookName, hookData)
+	approvalRequested := newStatus == "WAITING_FOR_APPROVAL"
+	if approvalRequested && a.sessionHasPendingApproval(sessionID) {
+		approvalRequested = false
+	}
+
+	toolName := providers.ToolName(hookData)
+	a.handleToolHookEvent(sessionID, "gemini_cli", normalizedName, hookData, toolName)
+	fallbackCwd := ""
+	a.sessionsMu.RLock()
+	if session, ok := a.sessions[sessionID]; ok {
+		fallbackCwd = session.CWD
+	}
+	a.sessionsMu.RUnlock()
+	a.handleWorkshopHookEvent(sessionID, "gemini_cli", normalizedName, hookData, fallbackCwd)
+	hookPayload := map[string]any{
+		"hook_name": hookName,
+		"hook_data": hookData,
+	}
+	if toolName != "" {
+		hookPayload["tool_name"] = toolName
+	}
+	if usage := extractUsageFromHook(hookData); usage != nil {
+		hookPayload["usage"] = usage
+	}
+	a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
+		SessionID: sessionID,
+		EventType: "gemini.hook",
+		Payload:   hookPayload,
+	})
+
+	var approvalID string
+	if approvalRequested {
+		approvalID = extractApprovalID(hookData)
+		if approvalID == "" {
+			approvalID = uuid.New().String()
+		}
+	}
+
+	if newStatus != "" {
+		a.updateSessionFromHook(sessionID, newStatus, buildStatusDetail(newStatus, normalizedName, hookData, approvalRequested), buildApprovalMetadata(approvalID, normalizedName, hookData, approvalRequested))
+	}
+
+	if approvalRequested {
+		approvalType, inputSchema := detectApprovalType(normalizedName, hookData)
+		geminiPayload := map[string]any{
+			"approval_id":   approvalID,
+			"provider":      "gemini_cli",
+			"reason":        approvalReason(normalizedName, hookData),
+			"details":       buildApprovalDetails(normalizedName, hookData),
+			"approval_type": approvalType,
+		}
+		if inputSchema != nil {
+			geminiPayload["input_schema"] = inputSchema
+		}
+		a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
+			SessionID: sessionID,
+			EventType: "approval.requested",
+			Payload:   geminiPayload,
+		})
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/rand"
+	"log"
+	"path/filepath"
+
+	"github.com/agent-command/agentd/internal/hookauth"
+	"github.com/agent-command/agentd/internal/proc"
+)
+
+const hookAuthKeyFile = "hook-auth.key"
+
+// hookKeyring loads the host key from the state directory. If it cannot be
+// read or created, a key for this run only is used; panes launched before
+// the next restart then fail to authenticate until relaunched.
+func (a *Agent) hookKeyring() *hookauth.Keyring {
+	a.hookKeysOnce.Do(func() {
+		if a.hookKeys != nil {
+			return
+		}
+		if a.cfg != nil && a.cfg.Storage.StateDir != "" {
+			keyring, err := hookauth.LoadKeyring(filepath.Join(a.cfg.Storage.StateDir, hookAuthKeyFile))
+			if err == nil {
+				a.hookKeys = keyring
+				return
+			}
+			log.Printf("Hook auth key unavailable, using a key for this run only: %v", err)
+		}
+		key := make([]byte, 32)
+		_, _ = rand.Read(key)
+		a.hookKeys = hookauth.NewKeyring(key)
+	})
+	return a.hookKeys
+}
+
+func (a *Agent) hookAuthenticator() *hookauth.Authenticator {
+	return &hookauth.Authenticator{
+		Keyring:     a.hookKeyring(),
+		Require:     a.cfg.Security.RequireHookAuth,
+		PeerSession: a.sessionForPID,
+		Exempt:      []string{"/metrics"},
+	}
+}
+
+// sessionForPID returns the managed session whose pane process is pid or
+// one of its ancestors.
+func (a *Agent) sessionForPID(pid int) string {
+	ancestors := make(map[int]struct{})
+	for _, ancestor := range proc.Ancestors(pid) {
+		ancestors[ancestor] = struct{}{}
+	}
+	if len(ancestors) == 0 {
+		return ""
+	}
+	a.sessionsMu.RLock()
+	defer a.sessionsMu.RUnlock()
+	for _, session := range a.sessions {
+		if _, ok := ancestors[sessionPanePID(session)]; ok {
+			return session.ID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/hookinstall"
+)
+
+// hookProxyDefaultTimeout is how long the hook proxy waits for agentd unless
+// AC_HOOK_TIMEOUT says otherwise.
+const hookProxyDefaultTimeout = 600
+
+func runHooksCommand(args []string) {
+	usage := func(fs *flag.FlagSet) {
+		fmt.Fprintln(fs.Output(), "Usage: agentd hooks <install|status|uninstall> [options]")
+		fs.PrintDefaults()
+	}
+	var action string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet("hooks", flag.ExitOnError)
+	configPath := fs.String("config", "/etc/agentd/config.yaml", "Path to config file")
+	scope := fs.String("scope", "user", "Settings to manage: user, project or all")
+	projectDir := fs.String("project", "", "Project directory for project settings (default current directory)")
+	providerList := fs.String("provider", "claude,codex", "Comma-separated providers to manage")
+	claudeHook := fs.String("claude-hook", "/usr/local/bin/ac-claude-hook", "Path to the Claude hook proxy")
+	codexHook := fs.String("codex-hook", "/usr/local/bin/ac-codex-hook", "Path to the Codex hook proxy")
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+	fs.Usage = func() { usage(fs) }
+	fs.Parse(args)
+
+	switch action {
+	case "install", "status", "uninstall":
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if *projectDir == "" {
+		if *projectDir, err = os.Getwd(); err != nil {
+			log.Fatalf("Failed to resolve project directory: %v", err)
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatalf("Failed to resolve home directory: %v", err)
+	}
+	targets, err := hookTargets(cfg, hookTargetOptions{
+		scope:      *scope,
+		providers:  strings.Split(*providerList, ","),
+		projectDir: *projectDir,
+		home:       home,
+		claudeHook: *claudeHook,
+		codexHook:  *codexHook,
+		env:        os.Getenv,
+	})
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	reports, drifted, err := runHooksAction(action, targets, time.Now())
+	if *jsonOutput {
+		output := map[string]any{"action": action, "targets": reports}
+		if err != nil {
+			output["error"] = err.Error()
+		}
+		outputJSON(output)
+	} else {
+		printHooksReports(action, reports)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+	if action == "status" && drifted {
+		os.Exit(1)
+	}
+}
+
+type hookTargetOptions struct {
+	scope      string
+	providers  []string
+	projectDir string
+	home       string
+	claudeHook string
+	codexHook  string
+	env        func(string) string
+}
+
+// hookTargets lists the settings files to manage and the hooks each should
+// carry, pointed at this host's hooks listener.
+func hookTargets(cfg *config.Config, opts hookTargetOptions) ([]hookinstall.Target, error) {
+	var scopes []string
+	switch opts.scope {
+	case "user", "project":
+		scopes = []string{opts.scope}
+	case "all":
+		scopes = []string{"user", "project"}
+	default:
+		return nil, fmt.Errorf("unknown scope %q: use user, project or all", opts.scope)
+	}
+
+	var targets []hookinstall.Target
+	for _, provider := range opts.providers {
+		provider = strings.TrimSpace(provider)
+		for _, scope := range scopes {
+			var path, bin string
+			switch provider {
+			case "claude":
+				bin = opts.claudeHook
+				if scope == "user" {
+					dir := opts.env("CLAUDE_CONFIG_DIR")
+					if dir == "" {
+						dir = filepath.Join(opts.home, ".claude")
+					}
+					path = filepath.Join(dir, "settings.json")
+				} else {
+					path = filepath.Join(opts.projectDir, ".claude", "settings.json")
+				}
+			case "codex":
+				bin = opts.codexHook
+				if scope == "user" {
+					dir := opts.env("CODEX_HOME")
+					if dir == "" {
+						dir = filepath.Join(opts.home, ".codex")
+					}
+					path = filepath.Join(dir, "hooks.json")
+				} else {
+					path = filepath.Join(opts.projectDir, ".codex", "hooks.json")
+				}
+			default:
+				return nil, fmt.Errorf("unknown provider %q: use claude or codex", provider)
+			}
+			targets = append(targets, hookinstall.Target{
+				Provider: provider,
+				Scope:    scope,
+				Path:     path,
+				Hooks:    providerHooks(cfg, provider, bin),
+			})
+		}
+	}
+	return targets, nil
+}
+
+// providerHooks returns the hook entries for provider. The permission hook
+// blocks until a decision arrives, so its timeouts cover the longest
+// configured approval wait.
+func providerHooks(cfg *config.Config, provider, bin string) []hookinstall.Hook {
+	command := hookProxyCommand(cfg, provider, bin, 0)
+	wait := approvalWaitSeconds(cfg)
+	permission := hookinstall.Hook{
+		Event:   "PermissionRequest",
+		Matcher: "*",
+		Command: hookProxyCommand(cfg, provider, bin, wait),
+		Timeout: wait + 30,
+	}
+	if provider == "codex" {
+		return []hookinstall.Hook{
+			{Event: "SessionStart", Command: command},
+			{Event: "PreToolUse", Matcher: "*", Command: command},
+			{Event: "PostToolUse", Matcher: "*", Command: command},
+			permission,
+			{Event: "Stop", Command: command},
+		}
+	}
+	return []hookinstall.Hook{
+		{Event: "SessionStart", Command: command},
+		{Event: "SessionEnd", Command: command},
+		{Event: "PreToolUse", Matcher: "*", Command: command},
+		{Event: "PostToolUse", Matcher: "*", Command: command},
+		permission,
+		{Event: "Notification", Matcher: "permission_prompt", Command: command},
+		{Event: "Notification", Matcher: "idle_prompt", Command: command},
+		{Event: "Stop", Command: command},
+	}
+}
+
+// approvalWaitSeconds is the longest a permission request may wait on
+// this host. A wait fallback has no bound, so a day stands in for it.
+func approvalWaitSeconds(cfg *config.Config) int {
+	claude := cfg.Providers.Claude
+	longest := 0
+	groups := []string{""}
+	for group := range claude.ApprovalGroups {
+		groups = append(groups, group)
+	}
+	for _, group := range groups {
+		wait := claude.ApprovalWait(group)
+		seconds := (wait.TimeoutMs + 999) / 1000
+		if wait.Fallback == config.ApprovalFallbackWait {
+			seconds = 24 * 60 * 60
+		}
+		if seconds > longest {
+			longest = seconds
+		}
+	}
+	return longest
+}
+
+// hookProxyCommand builds the shell command a provider runs for a hook,
+// pointing the proxy at the Unix socket when one is configured and
+// otherwise at the TCP listener. A proxyTimeout above the proxy's default
+// is passed along so the proxy outlasts the approval wait.
+func hookProxyCommand(cfg *config.Config, provider, bin string, proxyTimeout int) string {
+	claude := cfg.Providers.Claude
+	var env []string
+	if claude.HooksSocket != "" {
+		env = append(env, "AC_AGENTD_SOCKET="+shellWord(claude.HooksSocket))
+	} else {
+		env = append(env, "AC_AGENTD_URL="+shellWord(hooksBaseURL(claude.HooksHTTPListen)+"/v1/hooks/"+provider))
+	}
+	if proxyTimeout > hookProxyDefaultTimeout {
+		env = append(env, "AC_HOOK_TIMEOUT="+strconv.Itoa(proxyTimeout))
+	}
+	return strings.Join(append(env, shellWord(bin)), " ")
+}
+
+// hooksBaseURL turns a listen address into a URL a local client can reach;
+// wildcard hosts become loopback.
+func hooksBaseURL(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "http://" + listen
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+var plainShellWord = regexp.MustCompile(`^[A-Za-z0-9_./:@%+=,-]+$`)
+
+func shellWord(value string) string {
+	if plainShellWord.MatchString(value) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+type hooksReport struct {
+	Provider string              `json:"provider"`
+	Scope    string              `json:"scope"`
+	Path     string              `json:"path"`
+	Status   *hookinstall.Status `json:"status,omitempty"`
+	Result   *hookinstall.Result `json:"result,omitempty"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// runHooksAction applies action to each target. It reports whether any
+// target has drifted and stops at the first error.
+func runHooksAction(action string, targets []hookinstall.Target, now time.Time) ([]hooksReport, bool, error) {
+	reports := make([]hooksReport, 0, len(targets))
+	drifted := false
+	for _, target := range targets {
+		report := hooksReport{Provider: target.Provider, Scope: target.Scope, Path: target.Path}
+		var err error
+		switch action {
+		case "status":
+			var status hookinstall.Status
+			status, err = hookinstall.Inspect(target)
+			if err == nil {
+				report.Status = &status
+				drifted = drifted || !status.Installed()
+			}
+		case "install":
+			var result hookinstall.Result
+			result, err = hookinstall.Install(target, now)
+			report.Result = &result
+		case "uninstall":
+			var result hookinstall.Result
+			result, err = hookinstall.Uninstall(target, now)
+			report.Result = &result
+		}
+		if err != nil {
+			report.Error = err.Error()
+			report.Result = nil
+			reports = append(reports, report)
+			return reports, drifted, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, drifted, nil
+}
+
+func printHooksReports(action string, reports []hooksReport) {
+	for _, report := range reports {
+		label := fmt.Sprintf("%s (%s): %s", report.Provider, report.Scope, report.Path)
+		switch {
+		case report.Error != "":
+			fmt.Printf("%s\n  error: %s\n", label, report.Error)
+		case report.Status != nil:
+			state := "installed"
+			if !report.Status.Exists {
+				state = "no settings file"
+			} else if report.Status.Managed == 0 {
+				state = "not installed"
+			} else if len(report.Status.Drift) > 0 {
+				state = "drifted"
+			}
+			fmt.Printf("%s\n  %s\n", label, state)
+			for _, drift := range report.Status.Drift {
+				event := drift.Event
+				if drift.Matcher != "" {
+					event += " [" + drift.Matcher + "]"
+				}
+				fmt.Printf("  %-9s %s: %s\n", drift.Kind, event, drift.Command)
+			}
+		case report.Result != nil:
+			state := "unchanged"
+			if report.Result.Changed {
+				state = action + "ed"
+			}
+			fmt.Printf("%s\n  %s\n", label, state)
+			if report.Result.Backup != "" {
+				fmt.Printf("  backup: %s\n", report.Result.Backup)
+			}
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/usage"
+)
+
+// jobStream turns a headless job's JSON event stream into tool events and
+// session usage, the same messages interactive sessions get from hooks.
+// It understands Claude's stream-json output and Codex's exec --json output;
+// the two schemas use disjoint event types, so no provider switch is needed.
+type jobStream struct {
+	sessionID string
+	provider  string
+
+	// toolNames maps a tool call ID to its name so completions, which
+	// only carry the ID, pair with the right pending start.
+	toolNames map[string]string
+
+	// Claude repeats a message's usage on every content block event, so
+	// usage is kept per message ID and summed.
+	messageUsage map[string]jobUsage
+	turnUsage    jobUsage
+	costCents    *int
+	final        *jobUsage
+	lastSent     jobUsage
+	lastCost     *int
+}
+
+type jobUsage struct {
+	input      int
+	output     int
+	cacheRead  int
+	cacheWrite int
+}
+
+func newJobStream(sessionID, provider string) *jobStream {
+	return &jobStream{
+		sessionID:    sessionID,
+		provider:     provider,
+		toolNames:    make(map[string]string),
+		messageUsage: make(map[string]jobUsage),
+	}
+}
+
+func (a *Agent) handleJobStreamEvent(stream *jobStream, eventName string, evt map[string]any) {
+	switch eventName {
+	case "system":
+		a.recordSessionModel(stream.sessionID, transcriptString(evt, "model"))
+		return
+	case "assistant":
+		stream.claudeAssistant(a, evt)
+	case "user":
+		stream.claudeToolResults(a, evt)
+	case "result":
+		if usage, ok := evt["usage"].(map[string]any); ok {
+			final := claudeJobUsage(usage)
+			stream.final = &final
+		}
+		if cost, ok := evt["total_cost_usd"].(float64); ok {
+			cents := int(math.Round(cost * 100))
+			stream.costCents = &cents
+		}
+	case "item.started":
+		if item, ok := evt["item"].(map[string]any); ok {
+			stream.codexItemStarted(a, item)
+		}
+	case "item.completed":
+		if item, ok := evt["item"].(map[string]any); ok {
+			stream.codexItemCompleted(a, item)
+		}
+	case "turn.completed":
+		if usage, ok := evt["usage"].(map[string]any); ok {
+			stream.turnUsage.input += int(transcriptInt(usage, "input_tokens"))
+			stream.turnUsage.output += int(transcriptInt(usage, "output_tokens"))
+			stream.turnUsage.cacheRead += int(transcriptInt(usage, "cached_input_tokens"))
+		}
+	default:
+		return
+	}
+	stream.sendUsage(a)
+}
+
+func (s *jobStream) claudeAssistant(a *Agent, evt map[string]any) {
+	message, ok := evt["message"].(map[string]any)
+	if !ok {
+		return
+	}
+	a.recordSessionModel(s.sessionID, transcriptString(message, "model"))
+	if usage, ok := message["usage"].(map[string]any); ok {
+		s.messageUsage[transcriptString(message, "id")] = claudeJobUsage(usage)
+	}
+	content, _ := message["content"].([]any)
+	for _, item := range content {
+		block, ok := item.(map[string]any)
+		if !ok || transcriptString(block, "type") != "tool_use" {
+			continue
+		}
+		name := transcriptString(block, "name")
+		if name == "" {
+			name = "unknown"
+		}
+		if id := transcriptString(block, "id"); id != "" {
+			s.toolNames[id] = name
+		}
+		input, _ := block["input"].(map[string]any)
+		a.recordToolEventStart(s.sessionID, s.provider, name, input)
+	}
+}
+
+func (s *jobStream) claudeToolResults(a *Agent, evt map[string]any) {
+	message, ok := evt["message"].(map[string]any)
+	if !ok {
+		return
+	}
+	content, _ := message["content"].([]any)
+	for _, item := range content {
+		block, ok := item.(map[string]any)
+		if !ok || transcriptString(block, "type") != "tool_result" {
+			continue
+		}
+		id := transcriptString(block, "tool_use_id")
+		name, ok := s.toolNames[id]
+		if !ok {
+			name = "unknown"
+		}
+		delete(s.toolNames, id)
+		isError, _ := block["is_error"].(bool)
+		output := map[string]any{"output": transcriptContentText(block["content"])}
+		if isError {
+			output["is_error"] = true
+		}
+		a.recordToolEventComplete(s.sessionID, s.provider, name, output, !isError)
+	}
+}
+
+func claudeJobUsage(usage map[string]any) jobUsage {
+	return jobUsage{
+		input:      int(transcriptInt(usage, "input_tokens")),
+		output:     int(transcriptInt(usage, "output_tokens")),
+		cacheRead:  int(transcriptInt(usage, "cache_read_input_tokens")),
+		cacheWrite: int(transcriptInt(usage, "cache_creation_input_tokens")),
+	}
+}
+
+func (s *jobStream) codexItemStarted(a *Agent, item map[string]any) {
+	name, input := codexJobTool(item)
+	if name == "" {
+		return
+	}
+	if id := transcriptString(item, "id"); id != "" {
+		s.toolNames[id] = name
+	}
+	a.recordToolEventStart(s.sessionID, s.provider, name, input)
+}
+
+func (s *jobStream) codexItemCompleted(a *Agent, item map[string]any) {
+	name, _ := codexJobTool(item)
+	if name == "" {
+		return
+	}
+	delete(s.toolNames, transcriptString(item, "id"))
+
+	output := map[string]any{}
+	success := transcriptString(item, "status") != "failed"
+	switch transcriptString(item, "type") {
+	case "command_execution":
+		output["output"] = transcriptString(item, "aggregated_output")
+		if code, ok := item["exit_code"].(float64); ok {
+			output["exit_code"] = int(code)
+			success = success && code == 0
+		}
+	case "mcp_tool_call":
+		if result, ok := item["result"]; ok && result != nil {
+			output["result"] = result
+		}
+		if errValue, ok := item["error"]; ok && errValue != nil {
+			output["error"] = errValue
+			success = false
+		}
+	}
+	if status := transcriptString(item, "status"); status != "" {
+		output["status"] = status
+	}
+	a.recordToolEventComplete(s.sessionID, s.provider, name, output, success)
+}
+
+// codexJobTool names a Codex exec item the way Codex's own hooks name the
+// tool. Items that are not tool calls, such as agent messages and
+// reasoning, return an empty name.
+func codexJobTool(item map[string]any) (string, map[string]any) {
+	switch transcriptString(item, "type") {
+	case "command_execution":
+		return "shell", map[string]any{"command": item["command"]}
+	case "file_change":
+		return "apply_patch", map[string]any{"changes": item["changes"]}
+	case "mcp_tool_call":
+		server := transcriptString(item, "server")
+		tool := transcriptString(item, "tool")
+		input := map[string]any{"server": server, "tool": tool}
+		if args, ok := item["arguments"]; ok {
+			input["arguments"] = args
+		}
+		return "mcp__" + server + "__" + tool, input
+	case "web_search":
+		return "web_search", map[string]any{"query": item["query"]}
+	}
+	return "", nil
+}
+
+// totals prefers the final result usage Claude reports at the end of a run
+// and otherwise sums what has been seen so far.
+func (s *jobStream) totals() jobUsage {
+	if s.final != nil {
+		return *s.final
+	}
+	total := s.turnUsage
+	for _, usage := range s.messageUsage {
+		total.input += usage.input
+		total.output += usage.output
+		total.cacheRead += usage.cacheRead
+		total.cacheWrite += usage.cacheWrite
+	}
+	return total
+}
+
+func (s *jobStream) sendUsage(a *Agent) {
+	total := s.totals()
+	costChanged := s.costCents != nil && (s.lastCost == nil || *s.lastCost != *s.costCents)
+	if total == s.lastSent && !costChanged {
+		return
+	}
+	s.lastSent = total
+	s.lastCost = s.costCents
+
+	input := total.input
+	output := total.output
+	sum := total.input + total.output + total.cacheWrite
+	// Claude counts cache reads separately from input; Codex includes
+	// them in input_tokens.
+	billable := usage.Tokens{Input: total.input - total.cacheRead, Output: total.output, CacheRead: total.cacheRead, CacheWrite: total.cacheWrite}
+	if s.final != nil || len(s.messageUsage) > 0 {
+		sum += total.cacheRead
+		billable.Input = total.input
+	}
+	payload := protocol.SessionUsagePayload{
+		SessionID:    s.sessionID,
+		Provider:     s.provider,
+		Source:       "job",
+		InputTokens:  &input,
+		OutputTokens: &output,
+		TotalTokens:  &sum,
+		ReportedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if total.cacheRead > 0 {
+		cacheRead := total.cacheRead
+		payload.CacheReadTokens = &cacheRead
+	}
+	if total.cacheWrite > 0 {
+		cacheWrite := total.cacheWrite
+		payload.CacheWriteTokens = &cacheWrite
+	}
+	if s.costCents != nil {
+		cost := *s.costCents
+		if a.usageTracker != nil {
+			cost = a.usageTracker.RecordJobCost(s.sessionID, s.provider, float64(cost))
+		}
+		payload.EstimatedCostCents = &cost
+	} else if a.usageTracker != nil {
+		payload.EstimatedCostCents = a.usageTracker.RecordJobTokens(s.sessionID, s.provider, billable)
+	}
+	a.send(protocol.TypeSessionUsage, payload)
+}
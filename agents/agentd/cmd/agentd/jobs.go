@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/jobqueue"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/providers"
+)
+
+const (
+	defaultJobStopGrace = 5 * time.Second
+	// Stderr beyond these limits is counted, not forwarded, so a job stuck
+	// in a retry loop cannot flood the control plane.
+	maxJobStderrLines     = 1000
+	maxJobStderrLineBytes = 4 * 1024
+)
+
+// headlessJob is a running spawn_job process. The process leads its own
+// group so cancel and timeout reach the children it starts too.
+type headlessJob struct {
+	cmd       *exec.Cmd
+	startedAt time.Time
+	done      chan struct{}
+
+	mu         sync.Mutex
+	stopReason string
+	// finished is set once cmd.Wait returns. The process group ID may
+	// then belong to another process, so it is never signalled again.
+	finished bool
+}
+
+// stop signals the job's process group with SIGTERM and escalates to SIGKILL
+// when it outlives grace. Only the first stop of a running job counts.
+func (j *headlessJob) stop(reason string, grace time.Duration) bool {
+	j.mu.Lock()
+	if j.stopReason != "" || j.finished {
+		j.mu.Unlock()
+		return false
+	}
+	j.stopReason = reason
+	j.mu.Unlock()
+
+	j.signal(syscall.SIGTERM)
+	go func() {
+		select {
+		case <-j.done:
+		case <-time.After(grace):
+			j.signal(syscall.SIGKILL)
+		}
+	}()
+	return true
+}
+
+// signal sends sig to the job's process group unless the job has exited.
+func (j *headlessJob) signal(sig syscall.Signal) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.finished {
+		return
+	}
+	_ = syscall.Kill(-j.cmd.Process.Pid, sig)
+}
+
+// finish records that cmd.Wait returned and releases stop's escalation.
+func (j *headlessJob) finish() {
+	j.mu.Lock()
+	j.finished = true
+	j.mu.Unlock()
+	close(j.done)
+}
+
+func (j *headlessJob) reason() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.stopReason
+}
+
+func jobQueueLimits(cfg *config.Config) jobqueue.Limits {
+	limits := jobqueue.Limits{ByProvider: make(map[string]int)}
+	if cfg == nil {
+		return limits
+	}
+	limits.Default = cfg.Spawn.MaxConcurrentJobs
+	registry, _ := providers.NewRegistry(cfg)
+	for provider, limit := range cfg.Spawn.MaxConcurrentJobsByProvider {
+		if registry != nil {
+			if normalized := registry.Normalize(provider); normalized != "" {
+				provider = normalized
+			}
+		}
+		limits.ByProvider[provider] = limit
+	}
+	return limits
+}
+
+// headlessJobQueue returns the job queue, in memory when Run has not loaded
+// the persistent one.
+func (a *Agent) headlessJobQueue() *jobqueue.Queue {
+	a.jobQueueOnce.Do(func() {
+		if a.jobQueue == nil {
+			a.jobQueue, _ = jobqueue.Open("", jobQueueLimits(a.cfg))
+		}
+	})
+	return a.jobQueue
+}
+
+// enqueueJob registers job's session as QUEUED and starts whatever the
+// concurrency limits allow.
+func (a *Agent) enqueueJob(job jobqueue.Job) error {
+	now := time.Now().UTC()
+	a.sessionsMu.Lock()
+	a.sessions[job.SessionID] = &SessionState{
+		ID:           job.SessionID,
+		Kind:         "job",
+		Provider:     job.Provider,
+		GroupID:      job.GroupID,
+		Status:       "QUEUED",
+		CWD:          job.CWD,
+		LastActivity: now,
+		LastOutput:   now,
+		Metadata:     map[string]any{},
+	}
+	a.sessionsMu.Unlock()
+
+	if _, err := a.headlessJobQueue().Enqueue(job); err != nil {
+		a.sessionsMu.Lock()
+		delete(a.sessions, job.SessionID)
+		a.sessionsMu.Unlock()
+		return err
+	}
+	a.dispatchJobs()
+	return nil
+}
+
+// restoreQueuedJobs re-registers jobs left in the queue by a previous run.
+func (a *Agent) restoreQueuedJobs() {
+	pending := a.headlessJobQueue().Pending()
+	if len(pending) == 0 {
+		return
+	}
+	log.Printf("Restoring %d queued jobs", len(pending))
+	a.sessionsMu.Lock()
+	for _, job := range pending {
+		if _, exists := a.sessions[job.SessionID]; exists {
+			continue
+		}
+		a.sessions[job.SessionID] = &SessionState{
+			ID:           job.SessionID,
+			Kind:         "job",
+			Provider:     job.Provider,
+			GroupID:      job.GroupID,
+			Status:       "QUEUED",
+			CWD:          job.CWD,
+			LastActivity: job.EnqueuedAt,
+			LastOutput:   job.EnqueuedAt,
+			Metadata:     map[string]any{},
+		}
+	}
+	a.sessionsMu.Unlock()
+	a.dispatchJobs()
+}
+
+// dispatchJobs starts every queued job that has a free slot, then reports
+// the new queue positions. Each finished job dispatches again. A job whose
+// turn comes while a host or group budget is over its hard limit fails
+// instead of starting, as spawn_job would have refused it.
+func (a *Agent) dispatchJobs() {
+	queue := a.headlessJobQueue()
+	ready, err := queue.Next()
+	if err != nil {
+		log.Printf("Failed to dequeue jobs: %v", err)
+	}
+	for _, job := range ready {
+		a.setJobMetadata(job.SessionID, map[string]any{
+			"priority":       job.Priority,
+			"enqueued_at":    job.EnqueuedAt.Format(time.RFC3339Nano),
+			"queued_ms":      time.Since(job.EnqueuedAt).Milliseconds(),
+			"queue_position": nil,
+			"queue_length":   nil,
+		})
+		if err := a.budgetHold("", job.GroupID, job.Provider); err != nil {
+			queue.Finish(job.SessionID)
+			a.setJobMetadata(job.SessionID, map[string]any{"reason": "budget_exceeded", "error": err.Error()})
+			a.updateJobStatus(job.SessionID, "ERROR")
+			continue
+		}
+		a.updateJobStatus(job.SessionID, "STARTING")
+		go func(job jobqueue.Job) {
+			a.runHeadlessJob(job.SessionID, job.Provider, job.CWD, job.Prompt, job.Env, time.Duration(job.TimeoutMS)*time.Millisecond)
+			queue.Finish(job.SessionID)
+			a.dispatchJobs()
+		}(job)
+	}
+	a.publishQueuePositions()
+}
+
+// publishQueuePositions upserts each queued job whose position changed.
+func (a *Agent) publishQueuePositions() {
+	pending := a.headlessJobQueue().Pending()
+	var updates []protocol.SessionUpsert
+	a.sessionsMu.Lock()
+	for i, job := range pending {
+		session := a.sessions[job.SessionID]
+		if session == nil {
+			continue
+		}
+		position := i + 1
+		if current, _ := session.Metadata["job"].(map[string]any); session.Status == "QUEUED" && current["queue_position"] == position {
+			continue
+		}
+		if session.Metadata == nil {
+			session.Metadata = map[string]any{}
+		}
+		session.Status = "QUEUED"
+		session.Metadata["job"] = map[string]any{
+			"queue_position": position,
+			"queue_length":   len(pending),
+			"priority":       job.Priority,
+			"enqueued_at":    job.EnqueuedAt.Format(time.RFC3339Nano),
+		}
+		updates = append(updates, jobSessionUpsert(session))
+	}
+	a.sessionsMu.Unlock()
+	if len(updates) > 0 {
+		a.send(protocol.TypeSessionsUpsert, protocol.SessionsUpsertPayload{Sessions: updates})
+	}
+}
+
+func (a *Agent) runHeadlessJob(sessionID, provider, cwd, prompt string, env map[string]string, timeout time.Duration) {
+	if a.launchTemplates == nil {
+		a.launchTemplates = providers.NewLaunchTemplates(a.cfg)
+	}
+	spec, err := a.launchTemplates.Headless(provider, prompt, env)
+	if err != nil {
+		a.failJobStart(sessionID, err)
+		return
+	}
+	cmd, err := spec.ExecCommand(cwd)
+	if err != nil {
+		a.failJobStart(sessionID, err)
+		return
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		a.failJobStart(sessionID, err)
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		a.failJobStart(sessionID, err)
+		return
+	}
+
+	if err := cmd.Start(); err != nil {
+		a.failJobStart(sessionID, err)
+		return
+	}
+	job := &headlessJob{cmd: cmd, startedAt: time.Now().UTC(), done: make(chan struct{})}
+	a.jobsMu.Lock()
+	if a.jobs == nil {
+		a.jobs = make(map[string]*headlessJob)
+	}
+	a.jobs[sessionID] = job
+	a.jobsMu.Unlock()
+	defer func() {
+		a.jobsMu.Lock()
+		delete(a.jobs, sessionID)
+		a.jobsMu.Unlock()
+	}()
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() { job.stop("timeout", defaultJobStopGrace) })
+		defer timer.Stop()
+	}
+
+	var stderrWG sync.WaitGroup
+	stderrDropped := 0
+	stderrWG.Add(1)
+	go func() {
+		defer stderrWG.Done()
+		stderrDropped = a.forwardJobStderr(sessionID, stderr)
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	buf := make([]byte, 0, 1024*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	stream := newJobStream(sessionID, provider)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var evt map[string]any
+		if err := json.Unmarshal(line, &evt); err != nil {
+			continue
+		}
+
+		a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
+			SessionID: sessionID,
+			EventType: providerEventType(provider),
+			Payload:   evt,
+		})
+
+		eventName, _ := evt["event_type"].(string)
+		if eventName == "" {
+			eventName, _ = evt["type"].(string)
+		}
+		if eventName != "" {
+			a.handleJobStreamEvent(stream, eventName, evt)
+			switch eventName {
+			case "turn.started", "thread.started":
+				a.updateJobStatus(sessionID, "RUNNING")
+			case "turn.completed":
+				// wait for process exit to mark DONE
+			case "error":
+				a.updateJobStatus(sessionID, "ERROR")
+			}
+		}
+	}
+	// Keep draining so a job whose stdout line overflowed the scanner
+	// does not block writing.
+	_, _ = io.Copy(io.Discard, stdout)
+
+	stderrWG.Wait()
+	waitErr := cmd.Wait()
+	job.finish()
+
+	finishedAt := time.Now().UTC()
+	result := map[string]any{
+		"started_at":  job.startedAt.Format(time.RFC3339Nano),
+		"finished_at": finishedAt.Format(time.RFC3339Nano),
+		"duration_ms": finishedAt.Sub(job.startedAt).Milliseconds(),
+		"reason":      "exited",
+	}
+	if cmd.ProcessState != nil {
+		result["exit_code"] = cmd.ProcessState.ExitCode()
+		if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			result["signal"] = signalName(status.Signal())
+		}
+	}
+	if stderrDropped > 0 {
+		result["stderr_dropped_lines"] = stderrDropped
+	}
+	// A job stopped by cancel_job or its timeout did not finish its work,
+	// whatever its exit status.
+	status := "DONE"
+	if reason := job.reason(); reason != "" {
+		result["reason"] = reason
+		status = "ERROR"
+	} else if waitErr != nil {
+		status = "ERROR"
+		var exitErr *exec.ExitError
+		if !errors.As(waitErr, &exitErr) {
+			result["error"] = waitErr.Error()
+		}
+	}
+	a.setJobMetadata(sessionID, result)
+	a.updateJobStatus(sessionID, status)
+}
+
+// forwardJobStderr sends each stderr line as a job.stderr event and returns
+// how many lines were dropped over the limit.
+func (a *Agent) forwardJobStderr(sessionID string, stderr io.Reader) int {
+	reader := bufio.NewReader(stderr)
+	sent, dropped := 0, 0
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if sent < maxJobStderrLines {
+				text := truncateUTF8Bytes(trimLineEnding(line), maxJobStderrLineBytes)
+				a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
+					SessionID: sessionID,
+					EventType: "job.stderr",
+					Payload:   map[string]any{"line": text},
+				})
+				sent++
+			} else {
+				dropped++
+			}
+		}
+		if err != nil {
+			return dropped
+		}
+	}
+}
+
+func trimLineEnding(line string) string {
+	for len(line) > 0 && (line[len(line)-1] == '\n' || line[len(line)-1] == '\r') {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+func (a *Agent) failJobStart(sessionID string, err error) {
+	a.setJobMetadata(sessionID, map[string]any{
+		"reason": "start_failed",
+		"error":  err.Error(),
+	})
+	a.updateJobStatus(sessionID, "ERROR")
+}
+
+// setJobMetadata merges fields into the session's job metadata, so each
+// stage of a job adds to what earlier stages recorded. A nil value removes
+// its key.
+func (a *Agent) setJobMetadata(sessionID string, fields map[string]any) {
+	a.sessionsMu.Lock()
+	defer a.sessionsMu.Unlock()
+	session, ok := a.sessions[sessionID]
+	if !ok {
+		return
+	}
+	if session.Metadata == nil {
+		session.Metadata = map[string]any{}
+	}
+	job, _ := session.Metadata["job"].(map[string]any)
+	merged := make(map[string]any, len(job)+len(fields))
+	for key, value := range job {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = value
+	}
+	session.Metadata["job"] = merged
+}
+
+func (a *Agent) executeCancelJob(sessionID string, payload json.RawMessage) (map[string]any, error) {
+	if !a.cfg.Security.AllowKill {
+		return nil, fmt.Errorf("cancel_job not allowed by policy")
+	}
+	var p protocol.CancelJobPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+	}
+	if p.GraceMS < 0 {
+		return nil, fmt.Errorf("grace_ms must be nonnegative")
+	}
+	grace := defaultJobStopGrace
+	if p.GraceMS > 0 {
+		grace = time.Duration(p.GraceMS) * time.Millisecond
+	}
+
+	removed, err := a.headlessJobQueue().Remove(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if removed {
+		a.setJobMetadata(sessionID, map[string]any{
+			"reason":         "cancelled",
+			"queue_position": nil,
+			"queue_length":   nil,
+		})
+		a.updateJobStatus(sessionID, "ERROR")
+		a.publishQueuePositions()
+		return map[string]any{"cancelled": true, "queued": true}, nil
+	}
+
+	a.jobsMu.Lock()
+	job := a.jobs[sessionID]
+	a.jobsMu.Unlock()
+	if job == nil {
+		return nil, commands.NewResultError("JOB_NOT_RUNNING", "no running job for session")
+	}
+	if !job.stop("cancelled", grace) {
+		reason := job.reason()
+		if reason == "" {
+			reason = "exited"
+		}
+		return map[string]any{"cancelled": false, "reason": reason}, nil
+	}
+	return map[string]any{"cancelled": true, "pid": job.cmd.Process.Pid}, nil
+}
+
+func signalName(signal syscall.Signal) string {
+	switch signal {
+	case syscall.SIGHUP:
+		return "SIGHUP"
+	case syscall.SIGINT:
+		return "SIGINT"
+	case syscall.SIGQUIT:
+		return "SIGQUIT"
+	case syscall.SIGABRT:
+		return "SIGABRT"
+	case syscall.SIGKILL:
+		return "SIGKILL"
+	case syscall.SIGSEGV:
+		return "SIGSEGV"
+	case syscall.SIGPIPE:
+		return "SIGPIPE"
+	case syscall.SIGTERM:
+		return "SIGTERM"
+	}
+	return fmt.Sprintf("signal %d", int(signal))
+}
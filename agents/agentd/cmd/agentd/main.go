@@ -22,20 +22,27 @@ import (
 	"unicode/utf8"
 
 	"github.com/agent-command/agentd/internal/acp"
+	"github.com/agent-command/agentd/internal/budget"
 	"github.com/agent-command/agentd/internal/commands"
 	"github.com/agent-command/agentd/internal/config"
 	"github.com/agent-command/agentd/internal/console"
 	"github.com/agent-command/agentd/internal/filebridge"
+	"github.com/agent-command/agentd/internal/hookauth"
+	"github.com/agent-command/agentd/internal/jobqueue"
 	"github.com/agent-command/agentd/internal/metrics"
 	"github.com/agent-command/agentd/internal/orchestrator"
+	"github.com/agent-command/agentd/internal/policy"
 	"github.com/agent-command/agentd/internal/preview"
 	"github.com/agent-command/agentd/internal/proc"
 	"github.com/agent-command/agentd/internal/protocol"
 	"github.com/agent-command/agentd/internal/providers"
 	"github.com/agent-command/agentd/internal/providerusage"
 	"github.com/agent-command/agentd/internal/queue"
+	"github.com/agent-command/agentd/internal/recording"
+	"github.com/agent-command/agentd/internal/shellmarks"
 	"github.com/agent-command/agentd/internal/tmux"
 	"github.com/agent-command/agentd/internal/usage"
+	"github.com/agent-command/agentd/internal/watch"
 	"github.com/agent-command/agentd/internal/ws"
 	"github.com/google/uuid"
 )
@@ -63,12 +70,14 @@ type Agent struct {
 	forceSessionSync     bool
 	transcriptPaths      map[string]string
 	claudeProjectsRoot   string
+	codexSessionsRoot    string
 	snapshotHash         map[string]string
 	toolEventsMu         sync.Mutex
 	pendingToolEvents    map[string][]toolEventPending
 	bufferedHooksMu      sync.Mutex
 	bufferedHooks        []bufferedHook
 	capturePaneSnapshots capturePaneSnapshotCache
+	paneSearches         paneSearchCache
 
 	// Approval lifecycle - TTL cache to prevent race conditions
 	recentDecisions   map[string]time.Time
@@ -80,12 +89,52 @@ type Agent struct {
 	// Snapshot-derived provider usage (avoid duplicate emits)
 	providerUsageHash map[string]string
 	launchTemplates   *providers.LaunchTemplates
+	registry          *providers.Registry
+	registryOnce      sync.Once
+
+	// hookKeys derives the per-session secrets hooks sign requests with.
+	hookKeys     *hookauth.Keyring
+	hookKeysOnce sync.Once
+
+	// jobs holds running headless jobs by session id.
+	jobs   map[string]*headlessJob
+	jobsMu sync.Mutex
+	// jobQueue holds spawn_job requests waiting for a run slot.
+	jobQueue     *jobqueue.Queue
+	jobQueueOnce sync.Once
 
 	commandExecutor *commands.Executor
 
 	// fileBridge is nil when the host has no sync folders configured.
 	fileBridge *filebridge.Bridge
 
+	// watches evaluates output watch rules against changed snapshots.
+	watches *watch.Engine
+	// budgets measures session, group and host spend against token and
+	// cost limits.
+	budgets *budget.Engine
+
+	// approvalPolicy is nil unless approvals.policy_file is set.
+	approvalPolicy *policy.File
+
+	// recorder is nil unless pane recording is enabled. recordingPanes maps
+	// each recording session to the pane currently piped into it.
+	recorder             *recording.Recorder
+	recordingMu          sync.Mutex
+	recordingPanes       map[string]string
+	lastRecordingPruneAt time.Time
+
+	// screens is nil unless the terminal screen model is enabled. screenPanes
+	// holds the panes currently piped into it.
+	screens     *tmux.ScreenTracker
+	screenMu    sync.Mutex
+	screenPanes map[string]bool
+
+	// shellPanes maps shell sessions to the OSC 133 command history of their
+	// pane while command marks are enabled.
+	shellMu    sync.Mutex
+	shellPanes map[string]*shellPane
+
 	tmuxTopologyMu          sync.Mutex
 	tmuxTopologyTimer       *time.Timer
 	pendingTmuxTopology     *protocol.TmuxTopologyPayload
@@ -132,6 +181,9 @@ type SessionState struct {
 	LastUsageAt     time.Time
 	Unmanaged       bool
 	LastCWD         string // Track CWD changes
+	// ScreenStatus is the status the provider's screen patterns last
+	// matched, or "".
+	ScreenStatus string
 }
 
 func cloneJSONMap(value map[string]any) map[string]any {
@@ -159,6 +211,15 @@ func main() {
 		case "sessions":
 			runSessionsCommand(os.Args[2:])
 			return
+		case "cast":
+			runCastCommand(os.Args[2:])
+			return
+		case "hooks":
+			runHooksCommand(os.Args[2:])
+			return
+		case "costs":
+			runCostsCommand(os.Args[2:])
+			return
 		case "version":
 			runVersionCommand()
 			return
@@ -185,6 +246,10 @@ Commands:
   (none)       Run as daemon (default)
   status       Show agent status
   sessions     List tmux sessions
+  cast         Export a session recording as an asciinema .cast file
+  hooks        Install, check or remove provider hook settings
+               (agentd hooks install|status|uninstall)
+  costs        Show estimated weekly spend by repository
   version      Show version information
   help         Show this help
 
@@ -228,6 +293,7 @@ func runStatusCommand(args []string) {
 		"tmux_connected":  err == nil,
 		"pane_count":      len(panes),
 		"hooks_listen":    cfg.Providers.Claude.HooksHTTPListen,
+		"hooks_socket":    cfg.Providers.Claude.HooksSocket,
 		"spawn_enabled":   cfg.Security.AllowSpawn,
 		"kill_enabled":    cfg.Security.AllowKill,
 		"console_enabled": cfg.Security.AllowConsoleStream,
@@ -253,6 +319,9 @@ func runStatusCommand(args []string) {
 		}
 		fmt.Printf("Pane Count:     %d\n", len(panes))
 		fmt.Printf("Hooks Listen:   %s\n", cfg.Providers.Claude.HooksHTTPListen)
+		if cfg.Providers.Claude.HooksSocket != "" {
+			fmt.Printf("Hooks Socket:   %s\n", cfg.Providers.Claude.HooksSocket)
+		}
 		fmt.Printf("\nCapabilities:\n")
 		fmt.Printf("  Spawn:          %v\n", cfg.Security.AllowSpawn)
 		fmt.Printf("  Kill:           %v\n", cfg.Security.AllowKill)
@@ -277,6 +346,16 @@ func runSessionsCommand(args []string) {
 		return
 	}
 
+	registry, err := providers.NewRegistry(cfg)
+	if err != nil {
+		if *jsonOutput {
+			outputJSON(map[string]any{"error": err.Error()})
+		} else {
+			log.Fatalf("Invalid provider definitions: %v", err)
+		}
+		return
+	}
+
 	tmuxClient := tmux.NewClient(&cfg.Tmux)
 	panes, err := tmuxClient.ListPanes()
 	if err != nil {
@@ -301,7 +380,7 @@ func runSessionsCommand(args []string) {
 			continue
 		}
 
-		provider := detectProviderForPane(pane, procSnap)
+		provider := detectProviderForPane(registry, pane, procSnap)
 
 		// Get git info
 		var repoRoot, gitBranch, gitRemote string
@@ -406,6 +485,10 @@ func runDaemon() {
 		gitCache:          tmux.NewGitCache(10 * time.Second),
 		gitStatusCache:    tmux.NewGitStatusCache(10 * time.Second),
 		usageTracker:      usage.NewUsageTracker(),
+		watches:           newWatchEngine(cfg),
+		budgets:           newBudgetEngine(cfg),
+		approvalPolicy:    newApprovalPolicy(cfg),
+		recorder:          newRecorder(cfg),
 	}
 
 	// A misconfigured sync folder must not stop the agent from starting; the
@@ -428,6 +511,26 @@ func runDaemon() {
 }
 
 func (a *Agent) Run() error {
+	registry, err := providers.NewRegistry(a.cfg)
+	if err != nil {
+		return fmt.Errorf("invalid provider definitions: %w", err)
+	}
+	a.registry = registry
+	if a.usageTracker != nil {
+		a.usageTracker.SetParser(registry.ParseUsage)
+		a.usageTracker.SetPricing(newPriceTable(a.cfg.Providers.Pricing))
+		a.usageTracker.SetAttribution(a.sessionCostAttribution)
+		if err := a.usageTracker.OpenCostLedger(a.cfg.Storage.StateDir); err != nil {
+			log.Printf("Weekly cost ledger unavailable: %v", err)
+		}
+		if a.budgets != nil {
+			if err := a.budgets.Open(a.cfg.Storage.StateDir); err != nil {
+				log.Printf("Budget spend will not persist: %v", err)
+			}
+			a.usageTracker.SetSpendListener(a.handleSpend)
+		}
+	}
+
 	// Initialize tmux client
 	a.tmuxClient = tmux.NewClient(&a.cfg.Tmux)
 
@@ -464,6 +567,10 @@ func (a *Agent) Run() error {
 	}
 	defer outboundQueue.Close()
 	a.wsClient.SetQueue(outboundQueue, a.cfg.Storage.StateDir)
+	a.jobQueue, err = jobqueue.Open(a.cfg.Storage.StateDir, jobQueueLimits(a.cfg))
+	if err != nil {
+		return fmt.Errorf("failed to load job queue: %w", err)
+	}
 	lastAcked, err := queue.LoadAckedSeq(a.cfg.Storage.StateDir)
 	if err != nil {
 		return fmt.Errorf("failed to load acknowledged sequence: %w", err)
@@ -485,6 +592,11 @@ func (a *Agent) Run() error {
 	a.claudeProvider = providers.NewClaudeProvider(&a.cfg.Providers.Claude)
 	a.claudeProvider.SetHookHandler(a.handleClaudeHook)
 	a.claudeProvider.SetCodexHookHandler(a.handleCodexHook)
+	a.claudeProvider.SetGeminiHookHandler(a.handleGeminiHook)
+	a.claudeProvider.SetApprovalWaitFunc(a.approvalWait)
+	a.claudeProvider.SetApprovalReminderHandler(a.handleApprovalReminder)
+	a.claudeProvider.SetApprovalExpiredHandler(a.handleApprovalExpired)
+	a.claudeProvider.SetAuthenticator(a.hookAuthenticator())
 	a.claudeProvider.SetOrchestratorHandler(orchestrator.NewHandler(&agentOrchestratorBackend{agent: a}))
 
 	// Initialize console streamer
@@ -500,6 +612,14 @@ func (a *Agent) Run() error {
 	a.terminalManager.SetOutputHandler(a.handleTerminalOutput)
 	a.terminalManager.SetStatusHandler(a.handleTerminalStatus)
 	a.terminalManager.SetAuditHandler(a.handleTerminalAudit)
+	a.terminalManager.SetLinkHandler(a.handleTerminalLink)
+	if a.cfg.Security.AllowClipboardWrite {
+		a.terminalManager.SetClipboardHandler(a.handleTerminalClipboard)
+	}
+	if a.cfg.Terminal.ScreenModel {
+		a.screens = tmux.NewScreenTracker(a.tmuxClient, a.cfg.Storage.StateDir)
+		a.terminalManager.SetScreenSource(a.screens)
+	}
 	a.terminalManager.Start()
 
 	// Initialize pipe mux for console + terminal output
@@ -529,12 +649,20 @@ func (a *Agent) Run() error {
 		return fmt.Errorf("failed to start Claude hooks server: %w", err)
 	}
 
+	// Resume jobs queued before the last shutdown
+	a.restoreQueuedJobs()
+
 	// Start tmux poller
 	go a.pollTmux()
 
 	// Start snapshot capture
 	go a.captureSnapshots()
 
+	// Start transcript usage tailing for Claude Code and Codex sessions
+	if a.cfg.Providers.TranscriptUsage.Enabled {
+		go a.tailTranscriptUsage()
+	}
+
 	// Start provider usage polling (if configured)
 	go a.pollProviderUsage()
 	// Start Gemini stats polling via tmux (if configured)
@@ -556,7 +684,18 @@ func (a *Agent) Run() error {
 		a.tmuxHooks.Close()
 	}
 	a.stopTmuxTopology()
+	a.stopRecordings()
+	a.stopScreens()
+	a.stopShellCommands()
 	a.claudeProvider.Stop()
+	if a.usageTracker != nil {
+		if err := a.usageTracker.FlushCostLedger(); err != nil {
+			log.Printf("Failed to save weekly cost ledger: %v", err)
+		}
+	}
+	if a.budgets != nil {
+		a.budgets.Flush()
+	}
 	a.wsClient.Close()
 
 	return nil
@@ -907,6 +1046,8 @@ func (a *Agent) sendHello() error {
 		maxFileBytes = a.fileBridge.MaxFileBytes()
 	}
 
+	recordingEnabled := a.recorder != nil
+
 	payload := protocol.AgentHelloPayload{
 		Host: protocol.AgentHostInfo{
 			ID:           a.cfg.Host.ID,
@@ -923,6 +1064,7 @@ func (a *Agent) sendHello() error {
 				AcpStatus:     acpStatus,
 				PreviewPorts:  &previewPorts,
 				FileBridge:    &fileBridgeEnabled,
+				Recording:     &recordingEnabled,
 
 				FileBridgeDropDir:      dropDir,
 				FileBridgeOutDir:       outDir,
@@ -960,16 +1102,14 @@ func (a *Agent) send(msgType string, payload any) error {
 }
 
 func (a *Agent) providerAvailabilityMap() map[string]bool {
-	return map[string]bool{
-		"claude_code": providerCommandAvailable(a.cfg, "claude_code"),
-		"codex":       providerCommandAvailable(a.cfg, "codex"),
-		"gemini_cli":  providerCommandAvailable(a.cfg, "gemini_cli"),
-		"opencode":    providerCommandAvailable(a.cfg, "opencode"),
-		"cursor":      providerCommandAvailable(a.cfg, "cursor"),
-		"aider":       providerCommandAvailable(a.cfg, "aider"),
-		"continue":    providerCommandAvailable(a.cfg, "continue"),
-		"shell":       true,
+	available := make(map[string]bool)
+	for _, provider := range a.providerRegistry().Names() {
+		if provider == "unknown" {
+			continue
+		}
+		available[provider] = providerCommandAvailable(a.cfg, provider)
 	}
+	return available
 }
 
 func providerCommandAvailable(cfg *config.Config, provider string) bool {
@@ -1089,6 +1229,8 @@ func (a *Agent) handleMessage(msgType string, payload json.RawMessage) {
 		a.handleTerminalInput(payload)
 	case "terminal.resize":
 		a.handleTerminalResize(payload)
+	case "terminal.ack":
+		a.handleTerminalAck(payload)
 	case "terminal.navigate":
 		a.handleTerminalNavigate(payload)
 	case "terminal.control":
@@ -1135,6 +1277,8 @@ func (a *Agent) executeCommand(cmd commands.Dispatch) (map[string]any, error) {
 			break
 		}
 		err = a.executeSendInput(session, cmd.Command.Payload)
+	case "broadcast_input":
+		resultPayload, err = a.executeBroadcastInput(cmd.CmdID, cmd.Command.Payload)
 	case "send_keys":
 		if !exists {
 			err = fmt.Errorf("session not found")
@@ -1213,6 +1357,8 @@ func (a *Agent) executeCommand(cmd commands.Dispatch) (map[string]any, error) {
 		err = a.executeSpawnSession(cmd.SessionID, cmd.Command.Payload)
 	case "spawn_job":
 		err = a.executeSpawnJob(cmd.SessionID, cmd.Command.Payload)
+	case "cancel_job":
+		resultPayload, err = a.executeCancelJob(cmd.SessionID, cmd.Command.Payload)
 	case "fork":
 		if !exists {
 			err = fmt.Errorf("session not found")
@@ -1239,6 +1385,38 @@ func (a *Agent) executeCommand(cmd commands.Dispatch) (map[string]any, error) {
 			break
 		}
 		resultPayload, err = a.executeCaptureTranscriptCommand(session, cmd.Command.Payload)
+	case "search_panes":
+		resultPayload, err = a.executeSearchPanes(cmd.Command.Payload)
+	case "register_watch":
+		resultPayload, err = a.executeRegisterWatch(cmd.Command.Payload)
+	case "remove_watch":
+		err = a.executeRemoveWatch(cmd.Command.Payload)
+	case "list_watches":
+		resultPayload, err = a.executeListWatches()
+	case "set_budget":
+		resultPayload, err = a.executeSetBudget(cmd.Command.Payload)
+	case "remove_budget":
+		err = a.executeRemoveBudget(cmd.Command.Payload)
+	case "list_budgets":
+		resultPayload, err = a.executeListBudgets()
+	case "get_usage_costs":
+		resultPayload, err = a.executeGetUsageCosts()
+	case "read_recording":
+		resultPayload, err = a.executeReadRecording(cmd.SessionID, cmd.Command.Payload)
+	case "export_cast":
+		resultPayload, err = a.executeExportCast(cmd.SessionID, cmd.Command.Payload)
+	case "list_commands":
+		if !exists {
+			err = fmt.Errorf("session not found")
+			break
+		}
+		resultPayload, err = a.executeListCommands(session, cmd.Command.Payload)
+	case "read_screen":
+		if !exists {
+			err = fmt.Errorf("session not found")
+			break
+		}
+		resultPayload, err = a.executeReadScreen(session, cmd.Command.Payload)
 	case "copy_to_session":
 		if !exists {
 			err = fmt.Errorf("session not found")
@@ -1345,6 +1523,9 @@ func (a *Agent) executeSendInput(session *SessionState, payload json.RawMessage)
 		return err
 	}
 
+	if err := a.budgetHold(session.ID, "", ""); err != nil {
+		return err
+	}
 	return a.sendInputSmart(session.PaneID, p.Text, p.Enter)
 }
 
@@ -2410,6 +2591,7 @@ func (a *Agent) executeRenameSession(session *SessionState, payload json.RawMess
 	a.sessionsMu.Lock()
 	session.Title = p.Title
 	session.LastActivity = time.Now().UTC()
+	metadata := cloneJSONMap(session.Metadata)
 	a.sessionsMu.Unlock()
 
 	update := protocol.SessionUpsert{
@@ -2420,6 +2602,10 @@ func (a *Agent) executeRenameSession(session *SessionState, payload json.RawMess
 		Title:          protocol.String(session.Title),
 		LastActivityAt: session.LastActivity.UTC().Format(time.RFC3339),
 	}
+	if metadata != nil {
+		// Sent so a configured provider's provider_name does not replace it.
+		update.Metadata = protocol.NewSessionMetadata(metadata)
+	}
 	a.send(protocol.TypeSessionsUpsert, protocol.SessionsUpsertPayload{Sessions: []protocol.SessionUpsert{update}})
 	return nil
 }
@@ -2451,6 +2637,9 @@ func (a *Agent) executeSpawnSessionWorktree(sessionID string, p protocol.SpawnSe
 	if p.Provider == "" {
 		p.Provider = "claude_code"
 	}
+	if err := a.budgetHold("", p.GroupID, p.Provider); err != nil {
+		return err
+	}
 	if p.Tmux.TargetSession == "" {
 		p.Tmux.TargetSession = a.cfg.Spawn.TmuxSessionName
 	}
@@ -2581,6 +2770,12 @@ func (a *Agent) executeSpawnSessionInteractive(sessionID string, p protocol.Spaw
 	if p.WorkingDirectory == nil || *p.WorkingDirectory == "" {
 		return fmt.Errorf("working_directory is required")
 	}
+	if p.Provider == "" {
+		p.Provider = "shell"
+	}
+	if err := a.budgetHold("", p.GroupID, p.Provider); err != nil {
+		return err
+	}
 	_, resolvedWorkingDir, err := normalizeListDirectoryPath(*p.WorkingDirectory)
 	if err != nil {
 		return err
@@ -2588,9 +2783,6 @@ func (a *Agent) executeSpawnSessionInteractive(sessionID string, p protocol.Spaw
 	if err := writeMemoryFiles(resolvedWorkingDir, p.MemoryFiles); err != nil {
 		return err
 	}
-	if p.Provider == "" {
-		p.Provider = "shell"
-	}
 	if sessionID == "" {
 		sessionID = uuid.New().String()
 	}
@@ -2725,9 +2917,15 @@ func (a *Agent) executeSpawnJob(sessionID string, payload json.RawMessage) error
 	if p.Provider == "" {
 		p.Provider = "codex"
 	}
+	if normalized := a.providerRegistry().Normalize(p.Provider); normalized != "" {
+		p.Provider = normalized
+	}
 	if p.CWD == "" || p.Prompt == "" {
 		return fmt.Errorf("cwd and prompt are required")
 	}
+	if p.TimeoutMS < 0 {
+		return fmt.Errorf("timeout_ms must be nonnegative")
+	}
 	if a.launchTemplates == nil {
 		a.launchTemplates = providers.NewLaunchTemplates(a.cfg)
 	}
@@ -2739,37 +2937,23 @@ func (a *Agent) executeSpawnJob(sessionID string, payload json.RawMessage) error
 		return err
 	}
 
+	if err := a.budgetHold("", p.GroupID, p.Provider); err != nil {
+		return err
+	}
 	if sessionID == "" {
 		sessionID = uuid.New().String()
 	}
 
-	// Register job session
-	now := time.Now().UTC()
-	a.sessionsMu.Lock()
-	a.sessions[sessionID] = &SessionState{
-		ID:           sessionID,
-		Kind:         "job",
-		Provider:     p.Provider,
-		Status:       "STARTING",
-		CWD:          p.CWD,
-		LastActivity: now,
-		LastOutput:   now,
-	}
-	a.sessionsMu.Unlock()
-
-	a.send(protocol.TypeSessionsUpsert, protocol.SessionsUpsertPayload{
-		Sessions: []protocol.SessionUpsert{{
-			ID:             sessionID,
-			Kind:           "job",
-			Provider:       p.Provider,
-			Status:         "STARTING",
-			CWD:            protocol.String(p.CWD),
-			LastActivityAt: time.Now().UTC().Format(time.RFC3339),
-		}},
+	return a.enqueueJob(jobqueue.Job{
+		SessionID: sessionID,
+		Provider:  p.Provider,
+		GroupID:   p.GroupID,
+		CWD:       p.CWD,
+		Prompt:    p.Prompt,
+		Env:       p.Env,
+		TimeoutMS: p.TimeoutMS,
+		Priority:  p.Priority,
 	})
-
-	go a.runHeadlessJob(sessionID, p.Provider, p.CWD, p.Prompt, p.Env)
-	return nil
 }
 
 func (a *Agent) executeFork(parentSession *SessionState, payload json.RawMessage) error {
@@ -2958,11 +3142,16 @@ func (a *Agent) interactiveLaunchCommand(provider string, flags []string, env ma
 	if a.launchTemplates == nil {
 		a.launchTemplates = providers.NewLaunchTemplates(a.cfg)
 	}
-	requestEnv := make(map[string]string, len(env)+1)
+	requestEnv := make(map[string]string, len(env)+3)
 	for key, value := range env {
 		requestEnv[key] = value
 	}
 	requestEnv["AC_SESSION_ID"] = sessionID
+	requestEnv[hookauth.SecretEnv] = a.hookKeyring().Secret(sessionID)
+	if socket := a.cfg.Providers.Claude.HooksSocket; socket != "" {
+		// Point the hook proxy and ac at the socket.
+		requestEnv["AC_AGENTD_SOCKET"] = socket
+	}
 	spec, err := a.launchTemplates.Interactive(provider, flags, requestEnv)
 	if err != nil {
 		return "", err
@@ -2980,85 +3169,73 @@ func isShellCommand(command string) bool {
 	}
 }
 
-func normalizeProviderOverride(value string) string {
-	override := strings.ToLower(strings.TrimSpace(value))
-	switch override {
-	case "claude", "claude_code":
-		return "claude_code"
-	case "codex":
-		return "codex"
-	case "gemini", "gemini_cli":
-		return "gemini_cli"
-	case "opencode":
-		return "opencode"
-	case "cursor":
-		return "cursor"
-	case "aider":
-		return "aider"
-	case "continue":
-		return "continue"
-	case "shell":
-		return "shell"
-	case "unknown":
-		return "unknown"
-	default:
-		return ""
-	}
+// providerRegistry returns the registry built from the agent's config. Run
+// builds it up front so invalid definitions stop startup.
+func (a *Agent) providerRegistry() *providers.Registry {
+	a.registryOnce.Do(func() {
+		if a.registry == nil {
+			registry, err := providers.NewRegistry(a.cfg)
+			if err != nil {
+				registry, _ = providers.NewRegistry(nil)
+			}
+			a.registry = registry
+		}
+	})
+	return a.registry
 }
 
-func detectProviderForPane(pane tmux.Pane, procSnap *proc.Snapshot) string {
-	if override := normalizeProviderOverride(pane.ProviderOverride); override != "" {
+func detectProviderForPane(registry *providers.Registry, pane tmux.Pane, procSnap *proc.Snapshot) string {
+	if override := registry.Normalize(pane.ProviderOverride); override != "" {
 		return override
 	}
 
-	cmd := strings.ToLower(strings.TrimSpace(pane.CurrentCommand))
-	switch {
-	case strings.Contains(cmd, "claude"):
-		return "claude_code"
-	case strings.Contains(cmd, "codex"):
-		return "codex"
-	case strings.Contains(cmd, "gemini"):
-		return "gemini_cli"
-	case strings.Contains(cmd, "opencode"):
-		return "opencode"
-	case strings.Contains(cmd, "cursor"):
-		return "cursor"
-	case strings.Contains(cmd, "aider"):
-		return "aider"
-	case strings.Contains(cmd, "continue"):
-		return "continue"
-	}
-
-	title := strings.ToLower(strings.TrimSpace(pane.PaneTitle))
-	windowName := strings.ToLower(strings.TrimSpace(pane.WindowName))
-	if strings.Contains(title, "gemini") || strings.Contains(windowName, "gemini") {
-		return "gemini_cli"
-	}
-
-	if procSnap != nil && procSnap.HasDescendantCmd(pane.PanePID, []string{"gemini"}) {
-		return "gemini_cli"
+	provider := registry.Detect(providers.PaneSignals{
+		Command:    pane.CurrentCommand,
+		Title:      pane.PaneTitle,
+		WindowName: pane.WindowName,
+		HasDescendant: func(names []string) bool {
+			return procSnap != nil && procSnap.HasDescendantCmd(pane.PanePID, names)
+		},
+	})
+	if provider != "" {
+		return provider
 	}
 
+	cmd := strings.ToLower(strings.TrimSpace(pane.CurrentCommand))
 	if cmd != "" && !isShellCommand(cmd) {
 		return "unknown"
 	}
 	return "shell"
 }
 
-func isInteractiveProvider(provider string) bool {
-	switch provider {
-	case "claude_code", "codex", "gemini_cli", "opencode", "cursor", "aider", "continue", "unknown":
-		return true
-	default:
-		return false
-	}
-}
-
 func (a *Agent) deriveStatus(session *SessionState, pane tmux.Pane) string {
+	// Providers with status patterns say on screen what they are doing.
+	// That includes approval prompts, so an approval is held only while
+	// the prompt is still showing.
+	screenDriven := a.providerRegistry().HasStatusPatterns(session.Provider)
+
 	// Preserve terminal states
 	switch session.Status {
-	case "ERROR", "DONE", "WAITING_FOR_APPROVAL":
+	case "ERROR", "DONE":
 		return session.Status
+	case "WAITING_FOR_APPROVAL":
+		if !screenDriven || session.ScreenStatus == "WAITING_FOR_APPROVAL" {
+			return session.Status
+		}
+	}
+	if screenDriven && session.ScreenStatus != "" {
+		return session.ScreenStatus
+	}
+
+	// Command marks say exactly whether a shell is at its prompt; without
+	// them, fall back to recent output.
+	if session.Provider == "shell" {
+		switch a.shellCommandState(session.ID) {
+		case shellmarks.StateRunning:
+			return "RUNNING"
+		case shellmarks.StateIdle:
+			return "IDLE"
+		}
 	}
 
 	now := time.Now().UTC()
@@ -3076,7 +3253,7 @@ func (a *Agent) deriveStatus(session *SessionState, pane tmux.Pane) string {
 
 	cmd := strings.ToLower(strings.TrimSpace(pane.CurrentCommand))
 	isShell := isShellCommand(cmd)
-	interactive := !isShell || isInteractiveProvider(session.Provider)
+	interactive := !isShell || a.providerRegistry().Interactive(session.Provider)
 
 	if interactive {
 		if active {
@@ -3096,73 +3273,6 @@ func (a *Agent) deriveStatus(session *SessionState, pane tmux.Pane) string {
 	return "IDLE"
 }
 
-func (a *Agent) runHeadlessJob(sessionID, provider, cwd, prompt string, env map[string]string) {
-	if a.launchTemplates == nil {
-		a.launchTemplates = providers.NewLaunchTemplates(a.cfg)
-	}
-	spec, err := a.launchTemplates.Headless(provider, prompt, env)
-	if err != nil {
-		a.updateJobStatus(sessionID, "ERROR")
-		return
-	}
-	cmd, err := spec.ExecCommand(cwd)
-	if err != nil {
-		a.updateJobStatus(sessionID, "ERROR")
-		return
-	}
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		a.updateJobStatus(sessionID, "ERROR")
-		return
-	}
-	cmd.Stderr = os.Stderr
-
-	if err := cmd.Start(); err != nil {
-		a.updateJobStatus(sessionID, "ERROR")
-		return
-	}
-
-	scanner := bufio.NewScanner(stdout)
-	buf := make([]byte, 0, 1024*1024)
-	scanner.Buffer(buf, 1024*1024)
-
-	for scanner.Scan() {
-		line := scanner.Bytes()
-		var evt map[string]any
-		if err := json.Unmarshal(line, &evt); err != nil {
-			continue
-		}
-
-		a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
-			SessionID: sessionID,
-			EventType: providerEventType(provider),
-			Payload:   evt,
-		})
-
-		eventName, _ := evt["event_type"].(string)
-		if eventName == "" {
-			eventName, _ = evt["type"].(string)
-		}
-		if eventName != "" {
-			switch eventName {
-			case "turn.started", "thread.started":
-				a.updateJobStatus(sessionID, "RUNNING")
-			case "turn.completed":
-				// wait for process exit to mark DONE
-			case "error":
-				a.updateJobStatus(sessionID, "ERROR")
-			}
-		}
-	}
-
-	if err := cmd.Wait(); err != nil {
-		a.updateJobStatus(sessionID, "ERROR")
-		return
-	}
-	a.updateJobStatus(sessionID, "DONE")
-}
-
 func providerEventType(provider string) string {
 	switch provider {
 	case "claude_code":
@@ -3175,28 +3285,47 @@ func providerEventType(provider string) string {
 }
 
 func (a *Agent) updateJobStatus(sessionID, status string) {
-	provider := "codex"
+	update := protocol.SessionUpsert{
+		ID:             sessionID,
+		Kind:           "job",
+		Provider:       "codex",
+		Status:         status,
+		LastActivityAt: time.Now().UTC().Format(time.RFC3339),
+	}
 	a.sessionsMu.Lock()
 	if session, ok := a.sessions[sessionID]; ok {
 		session.Status = status
 		session.LastActivity = time.Now().UTC()
-		if session.Provider != "" {
-			provider = session.Provider
-		}
+		update = jobSessionUpsert(session)
 	}
 	a.sessionsMu.Unlock()
 
 	a.send(protocol.TypeSessionsUpsert, protocol.SessionsUpsertPayload{
-		Sessions: []protocol.SessionUpsert{{
-			ID:             sessionID,
-			Kind:           "job",
-			Provider:       provider,
-			Status:         status,
-			LastActivityAt: time.Now().UTC().Format(time.RFC3339),
-		}},
+		Sessions: []protocol.SessionUpsert{update},
 	})
 }
 
+// jobSessionUpsert describes a job session. The caller holds sessionsMu.
+func jobSessionUpsert(session *SessionState) protocol.SessionUpsert {
+	update := protocol.SessionUpsert{
+		ID:             session.ID,
+		Kind:           "job",
+		Provider:       session.Provider,
+		Status:         session.Status,
+		LastActivityAt: session.LastActivity.UTC().Format(time.RFC3339),
+	}
+	if update.Provider == "" {
+		update.Provider = "codex"
+	}
+	if session.CWD != "" {
+		update.CWD = protocol.String(session.CWD)
+	}
+	if session.Metadata != nil {
+		update.Metadata = protocol.NewSessionMetadata(session.Metadata)
+	}
+	return update
+}
+
 func (a *Agent) handleApprovalDecision(payload json.RawMessage) {
 	var decision protocol.ApprovalDecisionPayload
 	if err := json.Unmarshal(payload, &decision); err != nil {
@@ -3214,16 +3343,22 @@ func (a *Agent) handleApprovalDecision(payload json.RawMessage) {
 		provider = session.Provider
 	}
 
+	hookDecision := &providers.ApprovalDecision{
+		Decision:     decision.Decision,
+		Mode:         decision.Mode,
+		UpdatedInput: decision.UpdatedInput,
+	}
+
 	// Apply decision based on mode
 	switch decision.Mode {
 	case "hook":
-		if provider == "claude_code" {
-			a.claudeProvider.DeliverDecision(decision.ApprovalID, &providers.ApprovalDecision{
-				Decision:     decision.Decision,
-				Mode:         decision.Mode,
-				UpdatedInput: decision.UpdatedInput,
-			})
-		} else if exists {
+		delivered := false
+		if provider == "claude_code" || provider == "codex" {
+			delivered = a.claudeProvider.DeliverDecision(decision.ApprovalID, hookDecision)
+		}
+		// Claude always has a waiting hook; other providers fall back to
+		// keystrokes when nothing is blocked on the decision.
+		if !delivered && provider != "claude_code" && exists {
 			keys := a.getApprovalKeys(provider, decision.Decision == "allow")
 			a.tmuxClient.SendKeys(session.PaneID, keys)
 		}
@@ -3234,15 +3369,13 @@ func (a *Agent) handleApprovalDecision(payload json.RawMessage) {
 		}
 	case "both":
 		// Try hook first
-		if provider == "claude_code" {
-			a.claudeProvider.DeliverDecision(decision.ApprovalID, &providers.ApprovalDecision{
-				Decision:     decision.Decision,
-				Mode:         decision.Mode,
-				UpdatedInput: decision.UpdatedInput,
-			})
+		delivered := false
+		if provider == "claude_code" || provider == "codex" {
+			delivered = a.claudeProvider.DeliverDecision(decision.ApprovalID, hookDecision)
 		}
-		// Also send keystrokes as fallback
-		if exists {
+		// Also send keystrokes as fallback. A Codex hook that took the
+		// decision has already answered, and keys would land in the prompt.
+		if exists && !(provider == "codex" && delivered) {
 			keys := a.getApprovalKeys(provider, decision.Decision == "allow")
 			a.tmuxClient.SendKeys(session.PaneID, keys)
 		}
@@ -3255,16 +3388,9 @@ func (a *Agent) handleApprovalDecision(payload json.RawMessage) {
 }
 
 func (a *Agent) getApprovalKeys(provider string, allow bool) []string {
-	switch provider {
-	case "codex":
-		if allow && len(a.cfg.Providers.Codex.ApprovalAllowKeys) > 0 {
-			return a.cfg.Providers.Codex.ApprovalAllowKeys
-		}
-		if !allow && len(a.cfg.Providers.Codex.ApprovalDenyKeys) > 0 {
-			return a.cfg.Providers.Codex.ApprovalDenyKeys
-		}
+	if keys := a.providerRegistry().ApprovalKeys(provider, allow); len(keys) > 0 {
+		return keys
 	}
-
 	return a.claudeProvider.GetApprovalKeys(allow)
 }
 
@@ -3275,7 +3401,7 @@ func (a *Agent) handleClaudeHook(payload providers.ClaudeHookPayload) (*provider
 		return nil, err
 	}
 
-	hookName := extractHookName(hookData)
+	hookName := providers.HookName(hookData)
 
 	sessionID := a.resolveHookSessionID(payload)
 	if sessionID == "" {
@@ -3283,19 +3409,30 @@ func (a *Agent) handleClaudeHook(payload providers.ClaudeHookPayload) (*provider
 		return nil, nil
 	}
 	a.retainTranscriptPath(sessionID, extractHookString(hookData, "transcript_path", "transcriptPath"))
+	a.recordSessionModel(sessionID, extractHookModel(hookData))
 	a.markSessionReady(sessionID)
 
 	// Update session status based on hook
 	newStatus := providers.MapHookToStatus(hookName, hookData)
-	approvalRequested := isApprovalHook(hookName, hookData)
-	if approvalRequested && a.sessionHasPendingApproval(sessionID) {
+	approvalRequested := providers.IsApprovalHook(hookName, hookData)
+	approvalSuppressed := approvalRequested && a.sessionHasPendingApproval(sessionID)
+	if approvalSuppressed {
 		approvalRequested = false
 	}
+	var policyDecision *providers.ApprovalDecision
+	var policyRule string
+	if approvalRequested && payload.ApprovalID != "" {
+		policyDecision, policyRule = a.applyApprovalPolicy(sessionID, "claude_code", payload.ApprovalID, hookData)
+		if policyDecision != nil {
+			approvalRequested = false
+			newStatus = "RUNNING"
+		}
+	}
 	if approvalRequested && newStatus == "" {
 		newStatus = "WAITING_FOR_APPROVAL"
 	}
 
-	toolName := extractToolName(hookData)
+	toolName := providers.ToolName(hookData)
 	a.handleToolHookEvent(sessionID, "claude_code", hookName, hookData, toolName)
 	fallbackCwd := ""
 	a.sessionsMu.RLock()
@@ -3332,7 +3469,11 @@ func (a *Agent) handleClaudeHook(payload providers.ClaudeHookPayload) (*provider
 	}
 
 	if newStatus != "" || approvalRequested {
-		a.updateSessionFromHook(sessionID, newStatus, buildStatusDetail(newStatus, hookName, hookData, approvalRequested), buildApprovalMetadata(approvalID, hookName, hookData, approvalRequested))
+		approvalMeta := buildApprovalMetadata(approvalID, hookName, hookData, approvalRequested)
+		if approvalMeta != nil && !payload.ExpiresAt.IsZero() {
+			approvalMeta["expires_at"] = payload.ExpiresAt.Format(time.RFC3339)
+		}
+		a.updateSessionFromHook(sessionID, newStatus, buildStatusDetail(newStatus, hookName, hookData, approvalRequested), approvalMeta)
 	}
 
 	if approvalRequested {
@@ -3347,6 +3488,15 @@ func (a *Agent) handleClaudeHook(payload providers.ClaudeHookPayload) (*provider
 		if inputSchema != nil {
 			payloadData["input_schema"] = inputSchema
 		}
+		if policyRule != "" {
+			payloadData["policy_rule"] = policyRule
+		}
+		if !payload.ExpiresAt.IsZero() {
+			payloadData["expires_at"] = payload.ExpiresAt.Format(time.RFC3339)
+		}
+		if payload.Fallback != "" {
+			payloadData["fallback"] = payload.Fallback
+		}
 		a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
 			SessionID: sessionID,
 			EventType: "approval.requested",
@@ -3354,7 +3504,12 @@ func (a *Agent) handleClaudeHook(payload providers.ClaudeHookPayload) (*provider
 		})
 	}
 
-	return nil, nil
+	if approvalSuppressed && payload.ApprovalID != "" {
+		// The session's pending approval stays the actionable one; release
+		// the held hook so the CLI prompts for this one itself.
+		return nil, providers.ErrApprovalNotRequested
+	}
+	return policyDecision, nil
 }
 
 func (a *Agent) handleCodexHook(payload providers.ClaudeHookPayload) (*providers.ApprovalDecision, error) {
@@ -3363,25 +3518,37 @@ func (a *Agent) handleCodexHook(payload providers.ClaudeHookPayload) (*providers
 		return nil, err
 	}
 
-	hookName := extractHookName(hookData)
+	hookName := providers.HookName(hookData)
 
 	sessionID := a.resolveHookSessionID(payload)
 	if sessionID == "" {
 		a.bufferHook("codex", payload)
 		return nil, nil
 	}
+	a.retainTranscriptPath(sessionID, extractHookString(hookData, "transcript_path", "transcriptPath"))
+	a.recordSessionModel(sessionID, extractHookModel(hookData))
 	a.markSessionReady(sessionID)
 
-	approvalRequested := isApprovalHook(hookName, hookData)
-	if approvalRequested && a.sessionHasPendingApproval(sessionID) {
+	approvalRequested := providers.IsApprovalHook(hookName, hookData)
+	approvalSuppressed := approvalRequested && a.sessionHasPendingApproval(sessionID)
+	if approvalSuppressed {
 		approvalRequested = false
 	}
 	newStatus := mapCodexHookToStatus(hookName, hookData)
+	var policyDecision *providers.ApprovalDecision
+	var policyRule string
+	if approvalRequested && payload.ApprovalID != "" {
+		policyDecision, policyRule = a.applyApprovalPolicy(sessionID, "codex", payload.ApprovalID, hookData)
+		if policyDecision != nil {
+			approvalRequested = false
+			newStatus = "RUNNING"
+		}
+	}
 	if approvalRequested && newStatus == "" {
 		newStatus = "WAITING_FOR_APPROVAL"
 	}
 
-	toolName := extractToolName(hookData)
+	toolName := providers.ToolName(hookData)
 	a.handleToolHookEvent(sessionID, "codex", hookName, hookData, toolName)
 	fallbackCwd := ""
 	a.sessionsMu.RLock()
@@ -3418,7 +3585,11 @@ func (a *Agent) handleCodexHook(payload providers.ClaudeHookPayload) (*providers
 	}
 
 	if newStatus != "" || approvalRequested {
-		a.updateSessionFromHook(sessionID, newStatus, buildStatusDetail(newStatus, hookName, hookData, approvalRequested), buildApprovalMetadata(approvalID, hookName, hookData, approvalRequested))
+		approvalMeta := buildApprovalMetadata(approvalID, hookName, hookData, approvalRequested)
+		if approvalMeta != nil && !payload.ExpiresAt.IsZero() {
+			approvalMeta["expires_at"] = payload.ExpiresAt.Format(time.RFC3339)
+		}
+		a.updateSessionFromHook(sessionID, newStatus, buildStatusDetail(newStatus, hookName, hookData, approvalRequested), approvalMeta)
 	}
 
 	if approvalRequested {
@@ -3433,6 +3604,15 @@ func (a *Agent) handleCodexHook(payload providers.ClaudeHookPayload) (*providers
 		if inputSchema != nil {
 			codexPayload["input_schema"] = inputSchema
 		}
+		if policyRule != "" {
+			codexPayload["policy_rule"] = policyRule
+		}
+		if !payload.ExpiresAt.IsZero() {
+			codexPayload["expires_at"] = payload.ExpiresAt.Format(time.RFC3339)
+		}
+		if payload.Fallback != "" {
+			codexPayload["fallback"] = payload.Fallback
+		}
 		a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
 			SessionID: sessionID,
 			EventType: "approval.requested",
@@ -3440,7 +3620,12 @@ func (a *Agent) handleCodexHook(payload providers.ClaudeHookPayload) (*providers
 		})
 	}
 
-	return nil, nil
+	if approvalSuppressed && payload.ApprovalID != "" {
+		// The session's pending approval stays the actionable one; release
+		// the held hook so the CLI prompts for this one itself.
+		return nil, providers.ErrApprovalNotRequested
+	}
+	return policyDecision, nil
 }
 
 func (a *Agent) resolveHookSessionID(payload providers.ClaudeHookPayload) string {
@@ -3529,6 +3714,10 @@ func (a *Agent) retryBufferedHooks() {
 				if _, err := a.handleCodexHook(hook.Payload); err != nil {
 					log.Printf("Failed to replay buffered Codex hook: %v", err)
 				}
+			case "gemini_cli":
+				if _, err := a.handleGeminiHook(hook.Payload); err != nil {
+					log.Printf("Failed to replay buffered Gemini hook: %v", err)
+				}
 			}
 			continue
 		}
@@ -3546,36 +3735,6 @@ func (a *Agent) retryBufferedHooks() {
 	}
 }
 
-func extractHookName(hookData map[string]any) string {
-	for _, key := range []string{"hook_event_name", "hookEventName", "hook_name", "event_type", "type", "name"} {
-		if val, ok := hookData[key].(string); ok {
-			return val
-		}
-	}
-	return ""
-}
-
-func extractToolName(hookData map[string]any) string {
-	if val, ok := hookData["tool_name"].(string); ok {
-		return val
-	}
-	if val, ok := hookData["tool"].(string); ok {
-		return val
-	}
-	if tool, ok := hookData["tool"].(map[string]any); ok {
-		if name, ok := tool["name"].(string); ok {
-			return name
-		}
-	}
-	if val, ok := hookData["command"].(string); ok {
-		return val
-	}
-	if val, ok := hookData["action"].(string); ok {
-		return val
-	}
-	return ""
-}
-
 func (a *Agent) handleToolHookEvent(sessionID, provider, hookName string, hookData map[string]any, toolName string) {
 	if sessionID == "" {
 		return
@@ -3888,7 +4047,7 @@ func (a *Agent) handleWorkshopHookEvent(sessionID, provider, hookName string, ho
 
 	switch eventType {
 	case "pre_tool_use":
-		toolName := extractToolName(hookData)
+		toolName := providers.ToolName(hookData)
 		if toolName != "" {
 			base["tool"] = toolName
 		}
@@ -3903,7 +4062,7 @@ func (a *Agent) handleWorkshopHookEvent(sessionID, provider, hookName string, ho
 			base["assistantText"] = assistantText
 		}
 	case "post_tool_use":
-		toolName := extractToolName(hookData)
+		toolName := providers.ToolName(hookData)
 		if toolName != "" {
 			base["tool"] = toolName
 		}
@@ -3972,7 +4131,7 @@ func (a *Agent) handleWorkshopHookEvent(sessionID, provider, hookName string, ho
 	}
 
 	toolUseID := extractToolUseID(hookData)
-	if eventType == "pre_tool_use" && isLegacyTaskTool(extractToolName(hookData)) && toolUseID != "" {
+	if eventType == "pre_tool_use" && isLegacyTaskTool(providers.ToolName(hookData)) && toolUseID != "" {
 		subagentPayload := cloneJSONMap(base)
 		if description := extractSubagentDescription(hookData); description != "" {
 			subagentPayload["description"] = description
@@ -3980,7 +4139,7 @@ func (a *Agent) handleWorkshopHookEvent(sessionID, provider, hookName string, ho
 		subagentPayload["started_at"] = now.Format(time.RFC3339Nano)
 		a.emitWorkshopEvent(sessionID, "subagent_start", subagentPayload)
 	}
-	if eventType == "post_tool_use" && isLegacyTaskTool(extractToolName(hookData)) && toolUseID != "" {
+	if eventType == "post_tool_use" && isLegacyTaskTool(providers.ToolName(hookData)) && toolUseID != "" {
 		subagentPayload := cloneJSONMap(base)
 		if description := extractSubagentDescription(hookData); description != "" {
 			subagentPayload["description"] = description
@@ -4130,39 +4289,6 @@ func looksLikeUsageMap(m map[string]any) bool {
 	return false
 }
 
-func isApprovalHook(hookName string, hookData map[string]any) bool {
-	lower := strings.ToLower(hookName)
-	if strings.Contains(lower, "permission") || strings.Contains(lower, "approval") || strings.Contains(lower, "plan") {
-		return true
-	}
-	if tool := extractToolName(hookData); tool != "" {
-		if strings.Contains(strings.ToLower(tool), "plan") {
-			return true
-		}
-	}
-	if notif, ok := hookData["notification"].(map[string]any); ok {
-		if t, ok := notif["type"].(string); ok {
-			nt := strings.ToLower(t)
-			if strings.Contains(nt, "permission") || strings.Contains(nt, "approval") || strings.Contains(nt, "plan") {
-				return true
-			}
-		}
-	}
-	if val, ok := hookData["permission_request"].(bool); ok && val {
-		return true
-	}
-	if val, ok := hookData["requires_permission"].(bool); ok && val {
-		return true
-	}
-	if val, ok := hookData["requires_approval"].(bool); ok && val {
-		return true
-	}
-	if val, ok := hookData["awaiting_approval"].(bool); ok && val {
-		return true
-	}
-	return false
-}
-
 func approvalReason(hookName string, hookData map[string]any) string {
 	if reason, ok := hookData["reason"].(string); ok && reason != "" {
 		return reason
@@ -4176,7 +4302,7 @@ func approvalReason(hookName string, hookData map[string]any) string {
 	if strings.Contains(lower, "plan") {
 		return "Plan Approval"
 	}
-	if tool := extractToolName(hookData); strings.Contains(strings.ToLower(tool), "plan") {
+	if tool := providers.ToolName(hookData); strings.Contains(strings.ToLower(tool), "plan") {
 		return "Plan Approval"
 	}
 	if strings.Contains(lower, "permission") || strings.Contains(lower, "approval") {
@@ -4192,7 +4318,7 @@ func buildApprovalDetails(hookName string, hookData map[string]any) map[string]a
 	details := map[string]any{
 		"hook_name": hookName,
 	}
-	if tool := extractToolName(hookData); tool != "" {
+	if tool := providers.ToolName(hookData); tool != "" {
 		details["tool"] = tool
 	}
 	if input, ok := hookData["input"].(map[string]any); ok {
@@ -4333,7 +4459,7 @@ func detectApprovalType(hookName string, hookData map[string]any) (string, map[s
 func buildStatusDetail(status, hookName string, hookData map[string]any, approvalRequested bool) string {
 	if approvalRequested {
 		reason := approvalReason(hookName, hookData)
-		tool := extractToolName(hookData)
+		tool := providers.ToolName(hookData)
 		if tool != "" {
 			return fmt.Sprintf("%s: %s", reason, tool)
 		}
@@ -4363,7 +4489,7 @@ func buildApprovalMetadata(approvalID string, hookName string, hookData map[stri
 	if approvalID != "" {
 		meta["id"] = approvalID
 	}
-	if tool := extractToolName(hookData); tool != "" {
+	if tool := providers.ToolName(hookData); tool != "" {
 		meta["tool"] = tool
 	}
 	if summary, ok := hookData["summary"].(string); ok && summary != "" {
@@ -4397,6 +4523,9 @@ func (a *Agent) updateSessionFromHook(sessionID, status, statusDetail string, ap
 		if status != "" && status != "WAITING_FOR_APPROVAL" && status != "WAITING_FOR_INPUT" {
 			session.Metadata["approval"] = nil
 			session.Metadata["status_detail"] = nil
+			if session.Metadata["budget"] != nil {
+				session.Metadata["status_detail"] = budgetExceededDetail
+			}
 		}
 		if statusDetail != "" {
 			session.Metadata["status_detail"] = statusDetail
@@ -4493,7 +4622,7 @@ func (a *Agent) clearApprovalMetadata(sessionID string) {
 
 func mapCodexHookToStatus(hookName string, hookData map[string]any) string {
 	lower := strings.ToLower(hookName)
-	if isApprovalHook(hookName, hookData) {
+	if providers.IsApprovalHook(hookName, hookData) {
 		return "WAITING_FOR_APPROVAL"
 	}
 	if strings.Contains(lower, "input") {
@@ -4625,7 +4754,7 @@ func (a *Agent) syncPanes(panes []tmux.Pane, procSnap *proc.Snapshot) {
 			pane:      pane,
 			sessionID: sessionID,
 			unmanaged: unmanaged,
-			provider:  detectProviderForPane(pane, procSnap),
+			provider:  detectProviderForPane(a.providerRegistry(), pane, procSnap),
 			gitInfo:   gitInfo,
 			gitStatus: gitStatus,
 		})
@@ -4767,6 +4896,7 @@ func (a *Agent) syncPanes(panes []tmux.Pane, procSnap *proc.Snapshot) {
 				Provider:       session.Provider,
 				TmuxPaneID:     protocol.NullString(),
 				TmuxTarget:     protocol.NullString(),
+				Metadata:       protocol.NewSessionMetadata(cloneJSONMap(session.Metadata)),
 				ArchivedAt:     protocol.String(session.LastActivity.UTC().Format(time.RFC3339)),
 				LastActivityAt: session.LastActivity.UTC().Format(time.RFC3339),
 			})
@@ -4824,6 +4954,12 @@ func (a *Agent) syncPanes(panes []tmux.Pane, procSnap *proc.Snapshot) {
 
 	for _, id := range staleIDs {
 		a.usageTracker.RemoveSession(id)
+		if a.watches != nil {
+			a.watches.Forget(id)
+		}
+		if a.budgets != nil {
+			a.budgets.Forget(id)
+		}
 	}
 	if len(updatedSessions) > 0 {
 		if err := a.send(protocol.TypeSessionsUpsert, protocol.SessionsUpsertPayload{Sessions: updatedSessions}); err == nil && len(pendingStates) > 0 {
@@ -4837,6 +4973,9 @@ func (a *Agent) syncPanes(panes []tmux.Pane, procSnap *proc.Snapshot) {
 	if pruneNow {
 		a.send(protocol.TypeSessionsPrune, protocol.SessionsPrunePayload{SessionIDs: activeSessionIDs})
 	}
+	a.reconcileRecordings()
+	a.reconcileScreens()
+	a.reconcileShellCommands()
 }
 
 func (a *Agent) captureSnapshots() {
@@ -4865,10 +5004,12 @@ func (a *Agent) captureSnapshots() {
 			}
 			a.snapshotHash[session.ID] = hash
 			now := time.Now().UTC()
+			screenStatus, _ := a.providerRegistry().ScreenStatus(session.Provider, text)
 			a.sessionsMu.Lock()
 			if s, ok := a.sessions[session.ID]; ok {
 				s.LastOutput = now
 				s.LastActivity = now
+				s.ScreenStatus = screenStatus
 			}
 			a.sessionsMu.Unlock()
 
@@ -4879,42 +5020,14 @@ func (a *Agent) captureSnapshots() {
 				CaptureText: text,
 			})
 
+			a.evaluateWatches(session, text)
+
 			// Emit provider usage snapshots for Claude/Codex when /usage output appears.
 			a.maybeEmitProviderUsageSnapshot(session, text)
 
 			// Parse and emit session usage if changed
 			if sessionUsage := a.usageTracker.ParseAndCheckChanged(session.ID, session.Provider, text); sessionUsage != nil {
-				payload := protocol.SessionUsagePayload{
-					SessionID:                      session.ID,
-					Provider:                       sessionUsage.Provider,
-					InputTokens:                    sessionUsage.InputTokens,
-					OutputTokens:                   sessionUsage.OutputTokens,
-					TotalTokens:                    sessionUsage.TotalTokens,
-					CacheReadTokens:                sessionUsage.CacheReadTokens,
-					CacheWriteTokens:               sessionUsage.CacheWriteTokens,
-					EstimatedCostCents:             sessionUsage.CostCents,
-					SessionUtilizationPercent:      sessionUsage.SessionUtilizationPercent,
-					SessionLeftPercent:             sessionUsage.SessionLeftPercent,
-					SessionResetText:               sessionUsage.SessionResetText,
-					WeeklyUtilizationPercent:       sessionUsage.WeeklyUtilizationPercent,
-					WeeklyLeftPercent:              sessionUsage.WeeklyLeftPercent,
-					WeeklyResetText:                sessionUsage.WeeklyResetText,
-					WeeklySonnetUtilizationPercent: sessionUsage.WeeklySonnetUtilizationPercent,
-					WeeklySonnetResetText:          sessionUsage.WeeklySonnetResetText,
-					WeeklyOpusUtilizationPercent:   sessionUsage.WeeklyOpusUtilizationPercent,
-					WeeklyOpusResetText:            sessionUsage.WeeklyOpusResetText,
-					ContextUsedTokens:              sessionUsage.ContextUsedTokens,
-					ContextTotalTokens:             sessionUsage.ContextTotalTokens,
-					ContextLeftPercent:             sessionUsage.ContextLeftPercent,
-					FiveHourLeftPercent:            sessionUsage.FiveHourLeftPercent,
-					FiveHourResetText:              sessionUsage.FiveHourResetText,
-					DailyUtilizationPercent:        sessionUsage.DailyUtilizationPercent,
-					DailyLeftPercent:               sessionUsage.DailyLeftPercent,
-					DailyResetHours:                sessionUsage.DailyResetHours,
-					ReportedAt:                     sessionUsage.ReportedAt.Format(time.RFC3339),
-					RawUsageLine:                   sessionUsage.RawLine,
-				}
-				a.send(protocol.TypeSessionUsage, payload)
+				a.send(protocol.TypeSessionUsage, sessionUsagePayload(session.ID, sessionUsage))
 
 				// Gemini: also emit provider usage with model details (account scope).
 				if sessionUsage.Provider == "gemini_cli" {
@@ -4962,6 +5075,41 @@ func (a *Agent) captureSnapshots() {
 	}
 }
 
+// sessionUsagePayload converts tracked usage to its session.usage message.
+func sessionUsagePayload(sessionID string, u *usage.SessionUsage) protocol.SessionUsagePayload {
+	return protocol.SessionUsagePayload{
+		SessionID:                      sessionID,
+		Provider:                       u.Provider,
+		Source:                         u.Source,
+		InputTokens:                    u.InputTokens,
+		OutputTokens:                   u.OutputTokens,
+		TotalTokens:                    u.TotalTokens,
+		CacheReadTokens:                u.CacheReadTokens,
+		CacheWriteTokens:               u.CacheWriteTokens,
+		EstimatedCostCents:             u.CostCents,
+		SessionUtilizationPercent:      u.SessionUtilizationPercent,
+		SessionLeftPercent:             u.SessionLeftPercent,
+		SessionResetText:               u.SessionResetText,
+		WeeklyUtilizationPercent:       u.WeeklyUtilizationPercent,
+		WeeklyLeftPercent:              u.WeeklyLeftPercent,
+		WeeklyResetText:                u.WeeklyResetText,
+		WeeklySonnetUtilizationPercent: u.WeeklySonnetUtilizationPercent,
+		WeeklySonnetResetText:          u.WeeklySonnetResetText,
+		WeeklyOpusUtilizationPercent:   u.WeeklyOpusUtilizationPercent,
+		WeeklyOpusResetText:            u.WeeklyOpusResetText,
+		ContextUsedTokens:              u.ContextUsedTokens,
+		ContextTotalTokens:             u.ContextTotalTokens,
+		ContextLeftPercent:             u.ContextLeftPercent,
+		FiveHourLeftPercent:            u.FiveHourLeftPercent,
+		FiveHourResetText:              u.FiveHourResetText,
+		DailyUtilizationPercent:        u.DailyUtilizationPercent,
+		DailyLeftPercent:               u.DailyLeftPercent,
+		DailyResetHours:                u.DailyResetHours,
+		ReportedAt:                     u.ReportedAt.Format(time.RFC3339),
+		RawUsageLine:                   u.RawLine,
+	}
+}
+
 func (a *Agent) maybeEmitProviderUsageSnapshot(session *SessionState, text string) {
 	if session.Provider != "claude_code" && session.Provider != "codex" {
 		return
@@ -5049,6 +5197,7 @@ func (a *Agent) handleTerminalAttach(payload json.RawMessage) {
 		Rows:        req.Rows,
 		ResumeToken: req.ResumeToken,
 		Letterbox:   req.Letterbox,
+		FlowWindow:  req.FlowWindow,
 	})
 	if err != nil {
 		log.Printf("Failed to attach terminal: %v", err)
@@ -5094,6 +5243,7 @@ func (a *Agent) handleTerminalAttach(payload json.RawMessage) {
 		ReadOnly:    &readOnly,
 		ResumeToken: result.ResumeToken,
 		Resumed:     &resumed,
+		FlowWindow:  result.FlowWindow,
 	})
 	log.Printf("Terminal attached: channel=%s pane=%s mode=%s", req.ChannelID, req.PaneID, map[bool]string{true: "PTY", false: "FIFO"}[isPTYMode])
 }
@@ -5126,6 +5276,18 @@ func (a *Agent) handleTerminalResize(payload json.RawMessage) {
 	}
 }
 
+func (a *Agent) handleTerminalAck(payload json.RawMessage) {
+	var req protocol.TerminalAckPayload
+	if err := json.Unmarshal(payload, &req); err != nil {
+		log.Printf("Failed to parse terminal.ack: %v", err)
+		return
+	}
+
+	if err := a.terminalManager.Ack(req.ChannelID, req.Bytes); err != nil {
+		log.Printf("Failed to apply terminal ack: %v", err)
+	}
+}
+
 func (a *Agent) handleTerminalNavigate(payload json.RawMessage) {
 	var req protocol.TerminalNavigatePayload
 	if err := json.Unmarshal(payload, &req); err != nil {
@@ -5253,6 +5415,27 @@ func (a *Agent) handleTerminalOutput(channelID, encodedData string) {
 	})
 }
 
+func (a *Agent) handleTerminalClipboard(channelID string, clipboard tmux.TerminalClipboard) {
+	paneID, _ := a.terminalManager.PaneForChannel(channelID)
+	a.send(protocol.TypeTerminalClipboard, protocol.TerminalClipboardPayload{
+		ChannelID: channelID,
+		PaneID:    paneID,
+		Selection: clipboard.Selection,
+		Data:      clipboard.Data,
+	})
+}
+
+func (a *Agent) handleTerminalLink(channelID string, link tmux.TerminalLink) {
+	paneID, _ := a.terminalManager.PaneForChannel(channelID)
+	a.send(protocol.TypeTerminalLink, protocol.TerminalLinkPayload{
+		ChannelID: channelID,
+		PaneID:    paneID,
+		URI:       link.URI,
+		LinkID:    link.ID,
+		Text:      link.Text,
+	})
+}
+
 func (a *Agent) handleTerminalStatus(channelID, status, message string) {
 	msgType := "terminal." + status
 	paneID, _ := a.terminalManager.PaneForChannel(channelID)
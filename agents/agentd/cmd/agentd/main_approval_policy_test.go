@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/policy"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/providers"
+)
+
+const testApprovalPolicy = `
+rules:
+  - name: never-rm-rf
+    action: deny
+    tools: [Bash]
+    command: 'rm\s+-rf'
+  - name: git-status
+    action: allow
+    tools: [Bash, shell]
+    command: '^git (status|diff)\b'
+  - name: read-in-repo
+    action: allow
+    tools: [Read]
+    in_repo: true
+  - name: review-group-asks
+    action: ask
+    groups: [review]
+`
+
+func TestApprovalPolicyAnswersMatchingPermissionRequests(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	policyPath := filepath.Join(t.TempDir(), "approvals.yaml")
+	if err := os.WriteFile(policyPath, []byte(testApprovalPolicy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cwd := filepath.Join(repo, "cmd")
+	var mu sync.Mutex
+	var events []protocol.EventsAppendPayload
+	agent := &Agent{
+		cfg:            &config.Config{},
+		approvalPolicy: policy.NewFile(policyPath),
+		sessions: map[string]*SessionState{
+			"s1": {ID: "s1", PaneID: "%1", Provider: "claude_code", CWD: cwd, Status: "RUNNING"},
+			"s2": {ID: "s2", PaneID: "%2", Provider: "codex", CWD: cwd, GroupID: "review", Status: "RUNNING"},
+		},
+		sendMessage: func(msgType string, payload any) error {
+			if event, ok := payload.(protocol.EventsAppendPayload); ok {
+				mu.Lock()
+				events = append(events, event)
+				mu.Unlock()
+			}
+			return nil
+		},
+	}
+	hook := func(handler func(providers.ClaudeHookPayload) (*providers.ApprovalDecision, error), sessionID string, hookData map[string]any) *providers.ApprovalDecision {
+		t.Helper()
+		mu.Lock()
+		events = nil
+		mu.Unlock()
+		data, _ := json.Marshal(hookData)
+		payload := providers.ClaudeHookPayload{Hook: data, ApprovalID: "approval-" + sessionID}
+		payload.Meta.ACSessionID = sessionID
+		decision, err := handler(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decision
+	}
+	eventTypes := func() map[string]map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		byType := make(map[string]map[string]any)
+		for _, event := range events {
+			byType[event.EventType] = event.Payload
+		}
+		return byType
+	}
+	permission := func(tool string, input map[string]any) map[string]any {
+		return map[string]any{"hook_name": "PermissionRequest", "tool_name": tool, "tool_input": input}
+	}
+
+	decision := hook(agent.handleClaudeHook, "s1", permission("Read", map[string]any{"file_path": "../go.mod"}))
+	if decision == nil || decision.Decision != "allow" {
+		t.Fatalf("read in repo decision=%+v", decision)
+	}
+	got := eventTypes()
+	if got[approvalPolicyEventType]["rule"] != "read-in-repo" || got["approval.requested"] != nil {
+		t.Fatalf("events=%v", got)
+	}
+	if status := agent.sessions["s1"].Status; status != "RUNNING" {
+		t.Fatalf("status=%q", status)
+	}
+
+	decision = hook(agent.handleClaudeHook, "s1", permission("Bash", map[string]any{"command": "cd /tmp && rm -rf build"}))
+	if decision == nil || decision.Decision != "deny" || eventTypes()[approvalPolicyEventType]["rule"] != "never-rm-rf" {
+		t.Fatalf("rm decision=%+v events=%v", decision, eventTypes())
+	}
+
+	// Reading outside the repository falls through to a human.
+	decision = hook(agent.handleClaudeHook, "s1", permission("Read", map[string]any{"file_path": "/etc/passwd"}))
+	got = eventTypes()
+	if decision != nil || got["approval.requested"] == nil || got[approvalPolicyEventType] != nil {
+		t.Fatalf("outside repo decision=%+v events=%v", decision, got)
+	}
+
+	decision = hook(agent.handleCodexHook, "s2", map[string]any{"type": "exec_approval_request", "tool_name": "shell", "command": []any{"git", "status", "--short"}})
+	if decision == nil || decision.Decision != "allow" {
+		t.Fatalf("codex git status decision=%+v", decision)
+	}
+	decision = hook(agent.handleCodexHook, "s2", map[string]any{"type": "exec_approval_request", "tool_name": "shell", "command": []any{"make", "deploy"}})
+	got = eventTypes()
+	if decision != nil || got["approval.requested"]["policy_rule"] != "review-group-asks" {
+		t.Fatalf("ask decision=%+v events=%v", decision, got)
+	}
+}
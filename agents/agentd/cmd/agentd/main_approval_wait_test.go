@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/providers"
+)
+
+func TestApprovalWaitUsesGroupSettingsAndReportsExpiry(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Providers.Claude.ApprovalWaitConfig = config.ApprovalWaitConfig{TimeoutMs: 300000, ReminderMs: 60000}
+	cfg.Providers.Claude.ApprovalGroups = map[string]config.ApprovalWaitConfig{
+		"overnight": {TimeoutMs: 1800000, Fallback: config.ApprovalFallbackDeny},
+	}
+	var events []protocol.EventsAppendPayload
+	agent := &Agent{
+		cfg: cfg,
+		sessions: map[string]*SessionState{
+			"s1": {ID: "s1", PaneID: "%1", Provider: "claude_code", GroupID: "overnight", Status: "RUNNING"},
+			"s2": {ID: "s2", PaneID: "%2", Provider: "claude_code", Status: "RUNNING"},
+		},
+		sendMessage: func(msgType string, payload any) error {
+			if event, ok := payload.(protocol.EventsAppendPayload); ok {
+				events = append(events, event)
+			}
+			return nil
+		},
+	}
+	payloadFor := func(sessionID string) providers.ClaudeHookPayload {
+		var payload providers.ClaudeHookPayload
+		payload.Meta.ACSessionID = sessionID
+		payload.ApprovalID = "approval-" + sessionID
+		return payload
+	}
+
+	if wait := agent.approvalWait(payloadFor("s1")); wait.TimeoutMs != 1800000 || wait.Fallback != "deny" || wait.ReminderMs != 60000 {
+		t.Fatalf("group wait=%+v", wait)
+	}
+	if wait := agent.approvalWait(payloadFor("s2")); wait.TimeoutMs != 300000 || wait.Fallback != "prompt" {
+		t.Fatalf("default wait=%+v", wait)
+	}
+
+	// The countdown reaches the session metadata and the request.
+	payload := payloadFor("s1")
+	payload.ExpiresAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	payload.Fallback = config.ApprovalFallbackDeny
+	payload.Hook, _ = json.Marshal(map[string]any{"hook_name": "PermissionRequest", "tool_name": "Bash"})
+	if _, err := agent.handleClaudeHook(payload); err != nil {
+		t.Fatal(err)
+	}
+	approval, _ := agent.sessions["s1"].Metadata["approval"].(map[string]any)
+	if agent.sessions["s1"].Status != "WAITING_FOR_APPROVAL" || approval["expires_at"] != "2026-01-02T03:04:05Z" {
+		t.Fatalf("status=%q approval=%v", agent.sessions["s1"].Status, approval)
+	}
+	if last := events[len(events)-1]; last.EventType != "approval.requested" || last.Payload["expires_at"] != "2026-01-02T03:04:05Z" || last.Payload["fallback"] != "deny" {
+		t.Fatalf("request event=%+v", last)
+	}
+
+	events = nil
+	agent.handleApprovalReminder(payload, 2)
+	agent.handleApprovalExpired(payload, config.ApprovalFallbackDeny)
+	if len(events) != 2 || events[0].EventType != approvalReminderEventType || events[0].Payload["reminder"] != 2 ||
+		events[1].EventType != approvalExpiredEventType || events[1].Payload["fallback"] != "deny" {
+		t.Fatalf("events=%+v", events)
+	}
+	if status := agent.sessions["s1"].Status; status != "RUNNING" || agent.sessions["s1"].Metadata["approval"] != nil {
+		t.Fatalf("status after fallback=%q metadata=%v", status, agent.sessions["s1"].Metadata)
+	}
+}
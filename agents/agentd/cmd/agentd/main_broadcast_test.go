@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/tmux"
+)
+
+func TestBroadcastInputReportsEachTargetAndAudits(t *testing.T) {
+	tempDir := t.TempDir()
+	callsPath := filepath.Join(tempDir, "calls.txt")
+	tmuxBin := filepath.Join(tempDir, "tmux-fixture")
+	// Pane %3 is gone; tmux fails for it.
+	script := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$*\" >> %q\ncase \"$*\" in *'-t %%3 '*) exit 1;; esac\n", callsPath)
+	if err := os.WriteFile(tmuxBin, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var events []protocol.EventsAppendPayload
+	agent := &Agent{
+		cfg:        &config.Config{Security: config.SecurityConfig{AllowSendInput: true}},
+		tmuxClient: tmux.NewClient(&config.TmuxConfig{Bin: tmuxBin}),
+		sessions: map[string]*SessionState{
+			"a": {ID: "a", PaneID: "%1", Provider: "claude_code", GroupID: "review", Status: "IDLE"},
+			"b": {ID: "b", PaneID: "%2", Provider: "codex", GroupID: "review", Status: "RUNNING"},
+			"c": {ID: "c", PaneID: "%3", Provider: "claude_code", GroupID: "review", Status: "IDLE"},
+			"d": {ID: "d", PaneID: "%4", Provider: "claude_code", GroupID: "other", Status: "IDLE"},
+			"e": {ID: "e", PaneID: "%5", Provider: "claude_code", GroupID: "review", Status: "DONE"},
+		},
+		sendMessage: func(msgType string, payload any) error {
+			if event, ok := payload.(protocol.EventsAppendPayload); ok && msgType == protocol.TypeEventsAppend {
+				mu.Lock()
+				events = append(events, event)
+				mu.Unlock()
+			}
+			return nil
+		},
+	}
+	broadcast := func(payload string) (map[string]any, error) {
+		return agent.executeCommand(commands.Dispatch{
+			CmdID:   "cmd-1",
+			Command: protocol.Command{Type: "broadcast_input", Payload: json.RawMessage(payload)},
+		})
+	}
+
+	result, err := broadcast(`{"group_id":"review","provider":"claude_code","text":"run the tests","enter":true}`)
+	if err != nil {
+		t.Fatalf("broadcast: %v", err)
+	}
+	targets := result["targets"].([]map[string]any)
+	if len(targets) != 2 || targets[0]["session_id"] != "a" || targets[0]["ok"] != true ||
+		targets[1]["session_id"] != "c" || targets[1]["ok"] != false || targets[1]["error"] == nil {
+		t.Fatalf("targets=%+v", targets)
+	}
+	if result["delivered"] != 1 || result["failed"] != 1 {
+		t.Fatalf("result=%+v", result)
+	}
+	calls := readRecordingCalls(t, callsPath)
+	sort.Strings(calls)
+	if calls[0] != "send-keys -t %1 -l -- run the tests" || calls[1] != "send-keys -t %1 Enter" {
+		t.Fatalf("calls=%q", calls)
+	}
+	mu.Lock()
+	if len(events) != 2 || events[0].EventType != broadcastInputEventType ||
+		events[0].Payload["broadcast_id"] != result["broadcast_id"] || events[1].Payload["delivered"] != false {
+		t.Fatalf("events=%+v", events)
+	}
+	mu.Unlock()
+
+	result, err = broadcast(`{"session_ids":["b","missing"],"text":"hi"}`)
+	if err != nil {
+		t.Fatalf("explicit broadcast: %v", err)
+	}
+	targets = result["targets"].([]map[string]any)
+	if len(targets) != 2 || targets[0]["session_id"] != "b" || targets[0]["ok"] != true ||
+		targets[1]["session_id"] != "missing" || targets[1]["error"] != "session not found" {
+		t.Fatalf("explicit targets=%+v", targets)
+	}
+
+	result, err = broadcast(`{"session_ids":["a","b","d"],"group_id":"review","provider":"claude_code","text":"hi"}`)
+	if err != nil {
+		t.Fatalf("filtered broadcast: %v", err)
+	}
+	targets = result["targets"].([]map[string]any)
+	if len(targets) != 1 || targets[0]["session_id"] != "a" || targets[0]["ok"] != true {
+		t.Fatalf("filtered targets=%+v", targets)
+	}
+	if _, err := broadcast(`{"session_ids":["b"],"provider":"claude_code","text":"hi"}`); commandResultCode(err) != "NO_TARGETS" {
+		t.Fatalf("filtered-out broadcast err=%v", err)
+	}
+
+	if _, err := broadcast(`{"provider":"gemini","text":"hi"}`); commandResultCode(err) != "NO_TARGETS" {
+		t.Fatalf("unmatched broadcast err=%v", err)
+	}
+	if _, err := broadcast(`{"text":"hi"}`); commandResultCode(err) != "INVALID_TARGET" {
+		t.Fatalf("untargeted broadcast err=%v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agent-command/agentd/internal/budget"
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/orchestrator"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/tmux"
+	"github.com/agent-command/agentd/internal/usage"
+)
+
+func TestGroupBudgetWarnsThenHoldsAndInterrupts(t *testing.T) {
+	tempDir := t.TempDir()
+	callsPath := filepath.Join(tempDir, "calls.txt")
+	tmuxBin := filepath.Join(tempDir, "tmux-fixture")
+	script := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$*\" >> %q\n", callsPath)
+	if err := os.WriteFile(tmuxBin, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Security: config.SecurityConfig{AllowSendInput: true, AllowSpawn: true}}
+	var events []protocol.EventsAppendPayload
+	agent := &Agent{
+		cfg:          cfg,
+		budgets:      newBudgetEngine(cfg),
+		tmuxClient:   tmux.NewClient(&config.TmuxConfig{Bin: tmuxBin}),
+		usageTracker: usage.NewUsageTracker(),
+		sessions: map[string]*SessionState{
+			"a": {ID: "a", PaneID: "%1", Provider: "claude_code", GroupID: "fleet", Status: "RUNNING"},
+			"b": {ID: "b", PaneID: "%2", Provider: "codex", GroupID: "fleet", Status: "IDLE"},
+			"c": {ID: "c", PaneID: "%3", Provider: "shell", GroupID: "fleet", Status: "IDLE"},
+			"d": {ID: "d", PaneID: "%4", Provider: "codex", GroupID: "other", Status: "RUNNING"},
+		},
+		sendMessage: func(msgType string, payload any) error {
+			if msgType == protocol.TypeEventsAppend {
+				events = append(events, payload.(protocol.EventsAppendPayload))
+			}
+			return nil
+		},
+	}
+	agent.usageTracker.SetAttribution(agent.sessionCostAttribution)
+	agent.usageTracker.SetSpendListener(agent.handleSpend)
+
+	if _, err := executeAgentCommand(t, agent, "", "set_budget", map[string]any{
+		"budget_id": "fleet", "scope": "group", "group_id": "fleet", "unit": "cents", "limit": 100, "soft_percent": 50,
+	}); err != nil {
+		t.Fatalf("set_budget: %v", err)
+	}
+
+	// The first cost each session reports is its baseline.
+	agent.usageTracker.RecordCost("a", "claude_code", 0)
+	agent.usageTracker.RecordCost("b", "codex", 0)
+	agent.usageTracker.RecordCost("a", "claude_code", 60)
+	if len(events) != 1 || events[0].EventType != "budget.warning" || events[0].SessionID != "a" || events[0].Payload["threshold"] != 50.0 {
+		t.Fatalf("warning events=%+v", events)
+	}
+
+	agent.usageTracker.RecordCost("b", "codex", 50)
+	if len(events) != 2 || events[1].EventType != "budget.exceeded" || events[1].Payload["spent"] != 110.0 {
+		t.Fatalf("exceeded events=%+v", events)
+	}
+	if held := events[1].Payload["session_ids"].([]string); len(held) != 2 || held[0] != "a" || held[1] != "b" {
+		t.Fatalf("held=%v", held)
+	}
+	// Only the session mid-turn is interrupted.
+	calls, err := os.ReadFile(callsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(calls)); got != "send-keys -t %1 C-c" {
+		t.Fatalf("tmux calls=%q", got)
+	}
+	for _, id := range []string{"a", "b"} {
+		if detail := agent.sessions[id].Metadata["status_detail"]; detail != budgetExceededDetail {
+			t.Fatalf("%s status_detail=%v", id, detail)
+		}
+	}
+
+	if _, err := executeAgentCommand(t, agent, "b", "send_input", map[string]any{"text": "go on", "enter": true}); commandResultCode(err) != "BUDGET_EXCEEDED" {
+		t.Fatalf("send_input to held session: %v", err)
+	}
+	if _, err := executeAgentCommand(t, agent, "c", "send_input", map[string]any{"text": "ls"}); err != nil {
+		t.Fatalf("send_input to shell: %v", err)
+	}
+	backend := &agentOrchestratorBackend{agent: agent}
+	var httpErr *orchestrator.HTTPError
+	if err := backend.Send(context.Background(), "", orchestrator.SendRequest{SessionID: "a", Input: "continue"}); !errors.As(err, &httpErr) || httpErr.Status != http.StatusTooManyRequests {
+		t.Fatalf("orchestrator send to held session: %v", err)
+	}
+	// New agent sessions cannot start in the group.
+	if _, err := executeAgentCommand(t, agent, "", "spawn_session", map[string]any{
+		"provider": "codex", "working_directory": tempDir, "group_id": "fleet",
+	}); commandResultCode(err) != "BUDGET_EXCEEDED" {
+		t.Fatalf("spawn_session in held group: %v", err)
+	}
+
+	// The hold outlasts the turn ending.
+	agent.updateSessionFromHook("a", "IDLE", "", nil)
+	if detail := agent.sessions["a"].Metadata["status_detail"]; detail != budgetExceededDetail {
+		t.Fatalf("status_detail after turn end=%v", detail)
+	}
+
+	// Raising the limit lifts the hold.
+	if _, err := executeAgentCommand(t, agent, "", "set_budget", map[string]any{
+		"budget_id": "fleet", "scope": "group", "group_id": "fleet", "unit": "cents", "limit": 1000,
+	}); err != nil {
+		t.Fatalf("set_budget: %v", err)
+	}
+	if detail := agent.sessions["a"].Metadata["status_detail"]; detail != nil || agent.sessions["a"].Metadata["budget"] != nil {
+		t.Fatalf("metadata after raise=%+v", agent.sessions["a"].Metadata)
+	}
+	if _, err := executeAgentCommand(t, agent, "b", "send_input", map[string]any{"text": "go on"}); err != nil {
+		t.Fatalf("send_input after raise: %v", err)
+	}
+
+	listed, err := executeAgentCommand(t, agent, "", "list_budgets", map[string]any{})
+	if err != nil {
+		t.Fatalf("list_budgets: %v", err)
+	}
+	if budgets := listed["budgets"].([]budget.Budget); len(budgets) != 1 || budgets[0].Limit != 1000 {
+		t.Fatalf("listed=%+v", listed)
+	}
+	if spend := listed["spend"].(budget.Report); spend.Groups["fleet"].Cents != 110 {
+		t.Fatalf("spend=%+v", spend)
+	}
+	if _, err := executeAgentCommand(t, agent, "", "remove_budget", map[string]any{"budget_id": "missing"}); commandResultCode(err) != "BUDGET_NOT_FOUND" {
+		t.Fatalf("remove missing budget: %v", err)
+	}
+}
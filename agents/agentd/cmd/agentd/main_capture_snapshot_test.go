@@ -390,6 +390,19 @@ func executeSnapshotCaptureForSession(
 	agent *Agent,
 	sessionID string,
 	request map[string]any,
+) (map[string]any, error) {
+	t.Helper()
+	return executeAgentCommand(t, agent, sessionID, "capture_pane", request)
+}
+
+// executeAgentCommand dispatches a dashboard command to agent as the
+// control plane would, with request as its JSON payload.
+func executeAgentCommand(
+	t *testing.T,
+	agent *Agent,
+	sessionID string,
+	commandType string,
+	request map[string]any,
 ) (map[string]any, error) {
 	t.Helper()
 	payload, err := json.Marshal(request)
@@ -399,7 +412,7 @@ func executeSnapshotCaptureForSession(
 	return agent.executeCommand(commands.Dispatch{
 		SessionID: sessionID,
 		Command: protocol.Command{
-			Type:    "capture_pane",
+			Type:    commandType,
 			Payload: payload,
 		},
 	})
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/providers"
+	"github.com/agent-command/agentd/internal/tmux"
+)
+
+func TestCodexHookDecisionFallsBackToKeystrokes(t *testing.T) {
+	tempDir := t.TempDir()
+	callsPath := filepath.Join(tempDir, "calls.txt")
+	tmuxBin := filepath.Join(tempDir, "tmux-fixture")
+	script := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$*\" >> %q\n", callsPath)
+	if err := os.WriteFile(tmuxBin, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	cfg.Providers.Codex.ApprovalAllowKeys = []string{"y"}
+	agent := &Agent{
+		cfg:             cfg,
+		tmuxClient:      tmux.NewClient(&config.TmuxConfig{Bin: tmuxBin}),
+		claudeProvider:  providers.NewClaudeProvider(&cfg.Providers.Claude),
+		sessions:        map[string]*SessionState{"session-1": {ID: "session-1", PaneID: "%4", Provider: "codex", Status: "WAITING_FOR_APPROVAL"}},
+		recentDecisions: make(map[string]time.Time),
+		sendMessage:     func(string, any) error { return nil },
+	}
+
+	// No Codex hook is blocked on this approval, so the decision is typed.
+	payload, _ := json.Marshal(map[string]any{"approval_id": "approval-1", "session_id": "session-1", "decision": "allow", "mode": "hook"})
+	agent.handleApprovalDecision(payload)
+
+	calls := readRecordingCalls(t, callsPath)
+	if len(calls) != 1 || calls[0] != "send-keys -t %4 y" {
+		t.Fatalf("tmux calls=%q", calls)
+	}
+}
+
+func TestCodexHookReleasedWhileApprovalPending(t *testing.T) {
+	var events []string
+	agent := &Agent{
+		cfg: &config.Config{},
+		sessions: map[string]*SessionState{"session-1": {
+			ID:       "session-1",
+			PaneID:   "%4",
+			Provider: "codex",
+			Status:   "WAITING_FOR_APPROVAL",
+			Metadata: map[string]any{"approval": map[string]any{"id": "approval-1"}},
+		}},
+		recentDecisions: make(map[string]time.Time),
+		sendMessage: func(msgType string, payload any) error {
+			if event, ok := payload.(protocol.EventsAppendPayload); ok {
+				events = append(events, event.EventType)
+			}
+			return nil
+		},
+	}
+	hook, _ := json.Marshal(map[string]any{"hook_event_name": "PermissionRequest", "tool_name": "shell"})
+	payload := providers.ClaudeHookPayload{Hook: hook, ApprovalID: "approval-2"}
+	payload.Meta.ACSessionID = "session-1"
+
+	decision, err := agent.handleCodexHook(payload)
+	if decision != nil || !errors.Is(err, providers.ErrApprovalNotRequested) {
+		t.Fatalf("decision=%v err=%v", decision, err)
+	}
+	for _, eventType := range events {
+		if eventType == "approval.requested" {
+			t.Fatal("second approval was raised while one is pending")
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/providers"
+)
+
+func TestGeminiHooksDriveStatusToolEventsAndApprovals(t *testing.T) {
+	var mu sync.Mutex
+	var events []protocol.EventsAppendPayload
+	var started []protocol.ToolEventStartedPayload
+	var completed []protocol.ToolEventCompletedPayload
+	agent := &Agent{
+		cfg:               &config.Config{},
+		sessions:          map[string]*SessionState{"session-1": {ID: "session-1", Kind: "tmux_pane", PaneID: "%3", Provider: "gemini_cli", Status: "STARTING"}},
+		pendingToolEvents: make(map[string][]toolEventPending),
+		recentDecisions:   make(map[string]time.Time),
+		sendMessage: func(msgType string, payload any) error {
+			mu.Lock()
+			defer mu.Unlock()
+			switch value := payload.(type) {
+			case protocol.EventsAppendPayload:
+				events = append(events, value)
+			case protocol.ToolEventStartedPayload:
+				started = append(started, value)
+			case protocol.ToolEventCompletedPayload:
+				completed = append(completed, value)
+			}
+			return nil
+		},
+	}
+	status := func() string {
+		agent.sessionsMu.RLock()
+		defer agent.sessionsMu.RUnlock()
+		return agent.sessions["session-1"].Status
+	}
+	hook := func(body string) {
+		t.Helper()
+		payload := providers.ClaudeHookPayload{Hook: json.RawMessage(body)}
+		payload.Meta.TmuxPane = "%3"
+		if _, err := agent.handleGeminiHook(payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hook(`{"hook_event_name":"BeforeAgent","prompt":"list files","cwd":"/repo"}`)
+	if status() != "RUNNING" {
+		t.Fatalf("status after BeforeAgent=%q", status())
+	}
+	hook(`{"hook_event_name":"Notification","notification_type":"ToolPermission","message":"Allow shell command?","details":{"type":"exec","command":"ls -la"}}`)
+	if status() != "WAITING_FOR_APPROVAL" {
+		t.Fatalf("status after permission notification=%q", status())
+	}
+	hook(`{"hook_event_name":"BeforeTool","tool_name":"run_shell_command","tool_input":{"command":"ls -la"}}`)
+	hook(`{"hook_event_name":"AfterTool","tool_name":"run_shell_command","tool_input":{"command":"ls -la"},"tool_response":{"llmContent":"a b","error":"exit 2"}}`)
+	hook(`{"hook_event_name":"AfterAgent","prompt_response":"done"}`)
+	if status() != "WAITING_FOR_INPUT" {
+		t.Fatalf("status after AfterAgent=%q", status())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(started) != 1 || started[0].Provider != "gemini_cli" || started[0].ToolName != "run_shell_command" || started[0].ToolInput["command"] != "ls -la" {
+		t.Fatalf("started=%+v", started)
+	}
+	if len(completed) != 1 || completed[0].EventID != started[0].EventID || completed[0].Success {
+		t.Fatalf("completed=%+v", completed)
+	}
+	counts := make(map[string]int)
+	var approval map[string]any
+	for _, event := range events {
+		counts[event.EventType]++
+		if event.EventType == "approval.requested" {
+			approval = event.Payload
+		}
+	}
+	if counts["gemini.hook"] != 5 || counts["approval.requested"] != 1 ||
+		counts["workshop.user_prompt_submit"] != 1 || counts["workshop.pre_tool_use"] != 1 ||
+		counts["workshop.post_tool_use"] != 1 || counts["workshop.stop"] != 1 {
+		t.Fatalf("event counts=%v", counts)
+	}
+	if approval["provider"] != "gemini_cli" || approval["reason"] != "permission_prompt" {
+		t.Fatalf("approval=%v", approval)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/agent-command/agentd/internal/config"
+)
+
+func TestHookTargetsPointProxiesAtConfiguredListener(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Providers.Claude.HooksHTTPListen = "0.0.0.0:7788"
+	cfg.Providers.Claude.ApprovalGroups = map[string]config.ApprovalWaitConfig{
+		"overnight": {TimeoutMs: 3600000},
+	}
+	opts := hookTargetOptions{
+		scope:      "all",
+		providers:  []string{"claude", "codex"},
+		projectDir: "/work/repo",
+		home:       "/home/me",
+		claudeHook: "/usr/local/bin/ac-claude-hook",
+		codexHook:  "/usr/local/bin/ac-codex-hook",
+		env: func(key string) string {
+			if key == "CODEX_HOME" {
+				return "/srv/codex"
+			}
+			return ""
+		},
+	}
+
+	targets, err := hookTargets(cfg, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{}
+	for _, target := range targets {
+		paths = append(paths, target.Path)
+	}
+	want := []string{
+		filepath.FromSlash("/home/me/.claude/settings.json"),
+		filepath.FromSlash("/work/repo/.claude/settings.json"),
+		filepath.FromSlash("/srv/codex/hooks.json"),
+		filepath.FromSlash("/work/repo/.codex/hooks.json"),
+	}
+	for i := range want {
+		if i >= len(paths) || paths[i] != want[i] {
+			t.Fatalf("paths=%v", paths)
+		}
+	}
+
+	for _, hook := range targets[2].Hooks {
+		switch hook.Event {
+		case "PreToolUse":
+			if hook.Command != "AC_AGENTD_URL=http://127.0.0.1:7788/v1/hooks/codex /usr/local/bin/ac-codex-hook" {
+				t.Fatalf("command=%q", hook.Command)
+			}
+		case "PermissionRequest":
+			if hook.Timeout != 3630 || hook.Command != "AC_AGENTD_URL=http://127.0.0.1:7788/v1/hooks/codex AC_HOOK_TIMEOUT=3600 /usr/local/bin/ac-codex-hook" {
+				t.Fatalf("permission hook=%+v", hook)
+			}
+		}
+	}
+
+	cfg.Providers.Claude.HooksSocket = "/run/agent d/hooks.sock"
+	targets, err = hookTargets(cfg, hookTargetOptions{scope: "user", providers: []string{"claude"}, home: "/home/me", claudeHook: "/usr/local/bin/ac-claude-hook", env: func(string) string { return "" }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := targets[0].Hooks[0].Command; got != "AC_AGENTD_SOCKET='/run/agent d/hooks.sock' /usr/local/bin/ac-claude-hook" {
+		t.Fatalf("socket command=%q", got)
+	}
+
+	if _, err := hookTargets(cfg, hookTargetOptions{scope: "user", providers: []string{"gemini"}, env: func(string) string { return "" }}); err == nil {
+		t.Fatal("accepted an unsupported provider")
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agent-command/agentd/internal/budget"
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/usage"
+)
+
+type jobRecorder struct {
+	mu       sync.Mutex
+	events   []protocol.EventsAppendPayload
+	started  []protocol.ToolEventStartedPayload
+	complete []protocol.ToolEventCompletedPayload
+	usage    []protocol.SessionUsagePayload
+	finished chan protocol.SessionUpsert
+}
+
+func newJobTestAgent(t *testing.T) (*Agent, *jobRecorder) {
+	t.Helper()
+	recorder := &jobRecorder{finished: make(chan protocol.SessionUpsert, 64)}
+	cfg := &config.Config{
+		Security: config.SecurityConfig{AllowSpawn: true, AllowKill: true},
+		Providers: config.ProvidersConfig{Definitions: []config.ProviderDefinition{{
+			Name:   "scripted",
+			Launch: &config.ProviderLaunchTemplate{HeadlessArgv: []string{"sh", "-c", "{{prompt}}"}},
+		}}},
+	}
+	agent := &Agent{
+		cfg:               cfg,
+		sessions:          make(map[string]*SessionState),
+		pendingToolEvents: make(map[string][]toolEventPending),
+		sendMessage: func(msgType string, payload any) error {
+			switch value := payload.(type) {
+			case protocol.EventsAppendPayload:
+				recorder.mu.Lock()
+				recorder.events = append(recorder.events, value)
+				recorder.mu.Unlock()
+			case protocol.ToolEventStartedPayload:
+				recorder.mu.Lock()
+				recorder.started = append(recorder.started, value)
+				recorder.mu.Unlock()
+			case protocol.ToolEventCompletedPayload:
+				recorder.mu.Lock()
+				recorder.complete = append(recorder.complete, value)
+				recorder.mu.Unlock()
+			case protocol.SessionUsagePayload:
+				recorder.mu.Lock()
+				recorder.usage = append(recorder.usage, value)
+				recorder.mu.Unlock()
+			case protocol.SessionsUpsertPayload:
+				for _, upsert := range value.Sessions {
+					if upsert.Metadata != nil {
+						recorder.finished <- upsert
+					}
+				}
+			}
+			return nil
+		},
+	}
+	return agent, recorder
+}
+
+func spawnTestJob(t *testing.T, agent *Agent, sessionID, script string, timeoutMS int64) {
+	t.Helper()
+	payload, _ := json.Marshal(protocol.SpawnJobPayload{Provider: "scripted", CWD: t.TempDir(), Prompt: script, TimeoutMS: timeoutMS})
+	if _, err := agent.executeCommand(commands.Dispatch{
+		SessionID: sessionID,
+		Command:   protocol.Command{Type: "spawn_job", Payload: payload},
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForJobEnd returns the next upsert for sessionID whose job metadata
+// carries a final reason.
+func waitForJobEnd(t *testing.T, recorder *jobRecorder, sessionID string) (protocol.SessionUpsert, map[string]any) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case upsert := <-recorder.finished:
+			job := upsertJobMetadata(t, upsert)
+			if upsert.ID == sessionID && job["reason"] != nil {
+				return upsert, job
+			}
+		case <-timeout:
+			t.Fatal("job did not finish")
+		}
+	}
+}
+
+func upsertJobMetadata(t *testing.T, upsert protocol.SessionUpsert) map[string]any {
+	t.Helper()
+	data, _ := json.Marshal(upsert.Metadata)
+	var metadata map[string]any
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	job, _ := metadata["job"].(map[string]any)
+	return job
+}
+
+func TestHeadlessJobReportsExitCodeAndStderr(t *testing.T) {
+	agent, recorder := newJobTestAgent(t)
+	spawnTestJob(t, agent, "job-1", `echo '{"type":"thread.started"}'; echo 'rate limited' >&2; exit 3`, 0)
+
+	upsert, job := waitForJobEnd(t, recorder, "job-1")
+	if upsert.Status != "ERROR" || job["exit_code"] != float64(3) || job["reason"] != "exited" || job["signal"] != nil {
+		t.Fatalf("status=%q job=%v", upsert.Status, job)
+	}
+	if _, ok := job["duration_ms"].(float64); !ok {
+		t.Fatalf("job metadata has no duration: %v", job)
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	var stderr, events int
+	for _, event := range recorder.events {
+		switch event.EventType {
+		case "job.stderr":
+			if event.Payload["line"] != "rate limited" {
+				t.Fatalf("stderr event=%v", event.Payload)
+			}
+			stderr++
+		case "scripted.event":
+			events++
+		}
+	}
+	if stderr != 1 || events != 1 {
+		t.Fatalf("stderr events=%d provider events=%d", stderr, events)
+	}
+}
+
+func TestCancelJobKillsProcessGroup(t *testing.T) {
+	agent, recorder := newJobTestAgent(t)
+	// The background sleep holds stdout open; only a group kill ends it.
+	spawnTestJob(t, agent, "job-1", `sleep 30 & echo '{"type":"thread.started"}'; wait`, 0)
+
+	cancel := func() (map[string]any, error) {
+		return agent.executeCommand(commands.Dispatch{
+			SessionID: "job-1",
+			Command:   protocol.Command{Type: "cancel_job", Payload: json.RawMessage(`{"grace_ms":2000}`)},
+		})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		result, err := cancel()
+		if err == nil {
+			if result["cancelled"] != true {
+				t.Fatalf("cancel result=%v", result)
+			}
+			break
+		}
+		if commandResultCode(err) != "JOB_NOT_RUNNING" || time.Now().After(deadline) {
+			t.Fatalf("cancel: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	upsert, job := waitForJobEnd(t, recorder, "job-1")
+	if upsert.Status != "ERROR" || job["reason"] != "cancelled" || job["signal"] != "SIGTERM" || job["exit_code"] != float64(-1) {
+		t.Fatalf("status=%q job=%v", upsert.Status, job)
+	}
+	if _, err := cancel(); commandResultCode(err) != "JOB_NOT_RUNNING" {
+		t.Fatalf("cancel after exit err=%v", err)
+	}
+}
+
+func TestHeadlessJobStopSkipsExitedProcess(t *testing.T) {
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	job := &headlessJob{cmd: cmd, startedAt: time.Now(), done: make(chan struct{})}
+	job.finish()
+	if job.stop("cancelled", time.Millisecond) {
+		t.Fatal("stop signalled a job whose process had exited")
+	}
+	if job.reason() != "" {
+		t.Fatalf("reason=%q", job.reason())
+	}
+}
+
+func TestHeadlessJobTimeout(t *testing.T) {
+	agent, recorder := newJobTestAgent(t)
+	spawnTestJob(t, agent, "job-1", `sleep 30`, 100)
+
+	upsert, job := waitForJobEnd(t, recorder, "job-1")
+	if upsert.Status != "ERROR" || job["reason"] != "timeout" || job["signal"] != "SIGTERM" {
+		t.Fatalf("status=%q job=%v", upsert.Status, job)
+	}
+}
+
+func TestSpawnJobQueuesBeyondProviderLimit(t *testing.T) {
+	agent, recorder := newJobTestAgent(t)
+	agent.cfg.Spawn.MaxConcurrentJobs = 1
+	spawn := func(sessionID string, priority int) {
+		t.Helper()
+		payload, _ := json.Marshal(protocol.SpawnJobPayload{Provider: "scripted", CWD: t.TempDir(), Prompt: "sleep 30", Priority: priority})
+		if _, err := agent.executeCommand(commands.Dispatch{
+			SessionID: sessionID,
+			Command:   protocol.Command{Type: "spawn_job", Payload: payload},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel := func(sessionID string) map[string]any {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			result, err := agent.executeCommand(commands.Dispatch{
+				SessionID: sessionID,
+				Command:   protocol.Command{Type: "cancel_job"},
+			})
+			if err == nil {
+				return result
+			}
+			if commandResultCode(err) != "JOB_NOT_RUNNING" || time.Now().After(deadline) {
+				t.Fatalf("cancel %s: %v", sessionID, err)
+			}
+		}
+	}
+	status := func(sessionID string) string {
+		agent.sessionsMu.RLock()
+		defer agent.sessionsMu.RUnlock()
+		return agent.sessions[sessionID].Status
+	}
+
+	spawn("job-1", 0)
+	spawn("job-2", 0)
+	spawn("job-3", 5)
+	if status("job-1") == "QUEUED" || status("job-2") != "QUEUED" || status("job-3") != "QUEUED" {
+		t.Fatalf("statuses %s %s %s", status("job-1"), status("job-2"), status("job-3"))
+	}
+	agent.sessionsMu.RLock()
+	positions := map[string]any{
+		"job-2": agent.sessions["job-2"].Metadata["job"].(map[string]any)["queue_position"],
+		"job-3": agent.sessions["job-3"].Metadata["job"].(map[string]any)["queue_position"],
+	}
+	agent.sessionsMu.RUnlock()
+	if positions["job-3"] != 1 || positions["job-2"] != 2 {
+		t.Fatalf("queue positions=%v", positions)
+	}
+
+	if result := cancel("job-2"); result["queued"] != true {
+		t.Fatalf("queued cancel=%v", result)
+	}
+	if upsert, job := waitForJobEnd(t, recorder, "job-2"); upsert.Status != "ERROR" || job["reason"] != "cancelled" {
+		t.Fatalf("queued job status=%q metadata=%v", upsert.Status, job)
+	}
+
+	cancel("job-1")
+	waitForJobEnd(t, recorder, "job-1")
+	// The freed slot goes to job-3, which can then be stopped in turn.
+	if result := cancel("job-3"); result["cancelled"] != true || result["queued"] != nil {
+		t.Fatalf("job-3 cancel=%v", result)
+	}
+	// The final metadata keeps what the queue recorded about the job.
+	_, job := waitForJobEnd(t, recorder, "job-3")
+	if job["signal"] != "SIGTERM" || job["priority"] != float64(5) || job["enqueued_at"] == nil || job["queued_ms"] == nil || job["queue_position"] != nil {
+		t.Fatalf("job-3 metadata=%v", job)
+	}
+}
+
+func TestSpawnJobRefusedInGroupOverBudget(t *testing.T) {
+	agent, _ := newJobTestAgent(t)
+	agent.budgets = budget.NewEngine()
+	if _, err := agent.budgets.Add(budget.Budget{ID: "fleet", Scope: budget.ScopeGroup, GroupID: "fleet", Unit: budget.UnitTokens, Limit: 100}); err != nil {
+		t.Fatal(err)
+	}
+	agent.budgets.Record(usage.Spend{SessionID: "other", GroupID: "fleet", Tokens: 150, SessionTokens: 150})
+
+	spawn := func(groupID string) error {
+		payload, _ := json.Marshal(protocol.SpawnJobPayload{Provider: "scripted", GroupID: groupID, CWD: t.TempDir(), Prompt: "true"})
+		_, err := agent.executeCommand(commands.Dispatch{Command: protocol.Command{Type: "spawn_job", Payload: payload}})
+		return err
+	}
+	if err := spawn("fleet"); commandResultCode(err) != "BUDGET_EXCEEDED" {
+		t.Fatalf("spawn_job in held group: %v", err)
+	}
+	if err := spawn("other"); err != nil {
+		t.Fatalf("spawn_job in another group: %v", err)
+	}
+}
+
+func TestQueuedJobFailsWhenBudgetExceededBeforeDispatch(t *testing.T) {
+	agent, recorder := newJobTestAgent(t)
+	agent.cfg.Spawn.MaxConcurrentJobs = 1
+	agent.budgets = budget.NewEngine()
+	if _, err := agent.budgets.Add(budget.Budget{ID: "daily", Scope: budget.ScopeHost, Unit: budget.UnitCents, Limit: 100}); err != nil {
+		t.Fatal(err)
+	}
+	spawnTestJob(t, agent, "job-1", "sleep 30", 0)
+	spawnTestJob(t, agent, "job-2", "echo ran", 0)
+
+	// The host goes over budget while job-2 waits for a slot.
+	agent.budgets.Record(usage.Spend{SessionID: "other", Cents: 150, SessionCents: 150})
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		_, err := agent.executeCommand(commands.Dispatch{SessionID: "job-1", Command: protocol.Command{Type: "cancel_job"}})
+		if err == nil {
+			break
+		}
+		if commandResultCode(err) != "JOB_NOT_RUNNING" || time.Now().After(deadline) {
+			t.Fatalf("cancel job-1: %v", err)
+		}
+	}
+
+	upsert, job := waitForJobEnd(t, recorder, "job-2")
+	if upsert.Status != "ERROR" || job["reason"] != "budget_exceeded" || job["error"] == nil || job["exit_code"] != nil {
+		t.Fatalf("job-2 status=%q metadata=%v", upsert.Status, job)
+	}
+}
+
+// spawnStreamJob runs a job that prints lines as its stdout stream.
+func spawnStreamJob(t *testing.T, agent *Agent, sessionID string, lines ...string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stream.jsonl")
+	data := ""
+	for _, line := range lines {
+		data += line + "\n"
+	}
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	spawnTestJob(t, agent, sessionID, "cat "+path, 0)
+}
+
+func TestHeadlessJobClaudeStreamToolEventsAndUsage(t *testing.T) {
+	agent, recorder := newJobTestAgent(t)
+	spawnStreamJob(t, agent, "job-1",
+		`{"type":"system","subtype":"init","model":"claude-sonnet-4"}`,
+		`{"type":"assistant","message":{"id":"msg_1","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"ls"}}],"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":100}}}`,
+		`{"type":"assistant","message":{"id":"msg_1","content":[{"type":"tool_use","id":"toolu_2","name":"Read","input":{"file_path":"go.mod"}}],"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":100}}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_2","content":"module x","is_error":true},{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"go.mod"}]}]}}`,
+		`{"type":"result","subtype":"success","total_cost_usd":0.0123,"usage":{"input_tokens":20,"output_tokens":30,"cache_read_input_tokens":200,"cache_creation_input_tokens":50}}`,
+	)
+	waitForJobEnd(t, recorder, "job-1")
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.started) != 2 || recorder.started[0].ToolName != "Bash" || recorder.started[0].ToolInput["command"] != "ls" ||
+		recorder.started[1].ToolName != "Read" {
+		t.Fatalf("started=%+v", recorder.started)
+	}
+	if len(recorder.complete) != 2 {
+		t.Fatalf("completed=%+v", recorder.complete)
+	}
+	for _, completed := range recorder.complete {
+		switch completed.EventID {
+		case recorder.started[0].EventID:
+			if !completed.Success || completed.ToolOutput["output"] != "go.mod" {
+				t.Fatalf("Bash completion=%+v", completed)
+			}
+		case recorder.started[1].EventID:
+			if completed.Success {
+				t.Fatalf("Read completion=%+v", completed)
+			}
+		default:
+			t.Fatalf("completion for unknown start: %+v", completed)
+		}
+	}
+
+	// The repeated msg_1 usage counts once before the final result.
+	if len(recorder.usage) != 2 || *recorder.usage[0].InputTokens != 10 || *recorder.usage[0].TotalTokens != 115 {
+		t.Fatalf("usage=%+v", recorder.usage)
+	}
+	last := recorder.usage[1]
+	if *last.InputTokens != 20 || *last.OutputTokens != 30 || *last.CacheReadTokens != 200 ||
+		*last.CacheWriteTokens != 50 || *last.TotalTokens != 300 || *last.EstimatedCostCents != 1 {
+		t.Fatalf("final usage=%+v", last)
+	}
+}
+
+func TestHeadlessJobCodexStreamToolEventsAndUsage(t *testing.T) {
+	agent, recorder := newJobTestAgent(t)
+	spawnStreamJob(t, agent, "job-1",
+		`{"type":"thread.started","thread_id":"t1"}`,
+		`{"type":"item.started","item":{"id":"item_0","type":"command_execution","command":"go test ./...","status":"in_progress"}}`,
+		`{"type":"item.completed","item":{"id":"item_0","type":"command_execution","command":"go test ./...","aggregated_output":"FAIL","exit_code":1,"status":"failed"}}`,
+		`{"type":"item.completed","item":{"id":"item_1","type":"file_change","changes":[{"path":"a.go","kind":"update"}],"status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"item_2","type":"agent_message","text":"done"}}`,
+		`{"type":"turn.completed","usage":{"input_tokens":1200,"cached_input_tokens":1000,"output_tokens":80}}`,
+	)
+	waitForJobEnd(t, recorder, "job-1")
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	// The file change arrives completed only and gets a synthetic start.
+	if len(recorder.started) != 2 || recorder.started[0].ToolName != "shell" || recorder.started[1].ToolName != "apply_patch" {
+		t.Fatalf("started=%+v", recorder.started)
+	}
+	if len(recorder.complete) != 2 || recorder.complete[0].Success || recorder.complete[0].ToolOutput["exit_code"] != 1 ||
+		!recorder.complete[1].Success {
+		t.Fatalf("completed=%+v", recorder.complete)
+	}
+	if len(recorder.usage) != 1 {
+		t.Fatalf("usage=%+v", recorder.usage)
+	}
+	usage := recorder.usage[0]
+	if *usage.InputTokens != 1200 || *usage.OutputTokens != 80 || *usage.CacheReadTokens != 1000 || *usage.TotalTokens != 1280 {
+		t.Fatalf("usage=%+v", usage)
+	}
+}
+
+func TestHeadlessJobUsageIsPricedAtReportedModel(t *testing.T) {
+	agent, recorder := newJobTestAgent(t)
+	agent.usageTracker = usage.NewUsageTracker()
+	agent.usageTracker.SetPricing(newPriceTable(config.PricingConfig{Models: map[string]config.ModelPrice{
+		"claude-sonnet-4*": {Input: 3, Output: 15, CacheRead: 0.3},
+	}}))
+	spawnStreamJob(t, agent, "job-1",
+		`{"type":"system","subtype":"init","model":"claude-sonnet-4-20250514"}`,
+		`{"type":"assistant","message":{"id":"msg_1","content":[],"usage":{"input_tokens":1000000,"output_tokens":100000,"cache_read_input_tokens":1000000}}}`,
+	)
+	waitForJobEnd(t, recorder, "job-1")
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.usage) != 1 || recorder.usage[0].EstimatedCostCents == nil || *recorder.usage[0].EstimatedCostCents != 480 {
+		t.Fatalf("usage=%+v", recorder.usage)
+	}
+	sessions := agent.usageTracker.Costs().Sessions
+	if len(sessions) != 1 || sessions[0].Model != "claude-sonnet-4-20250514" || sessions[0].CostCents != 480 {
+		t.Fatalf("sessions=%+v", sessions)
+	}
+}
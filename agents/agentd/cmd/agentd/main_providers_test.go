@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/providers"
+	"github.com/agent-command/agentd/internal/tmux"
+)
+
+func TestConfiguredProviderDrivesDetectionAndScreenStatus(t *testing.T) {
+	interactive := true
+	agent := &Agent{cfg: &config.Config{Providers: config.ProvidersConfig{
+		Definitions: []config.ProviderDefinition{{
+			Name:        "goose",
+			Detect:      config.ProviderDetectionRule{Commands: []string{"goose"}},
+			Interactive: &interactive,
+			StatusPatterns: []config.ProviderStatusPattern{
+				{Status: "WAITING_FOR_APPROVAL", Pattern: `Allow\?`},
+			},
+		}},
+	}}}
+	pane := tmux.Pane{PaneID: "%1", CurrentCommand: "goose"}
+	if got := detectProviderForPane(agent.providerRegistry(), pane, nil); got != "goose" {
+		t.Fatalf("provider=%q", got)
+	}
+	if got := detectProviderForPane(agent.providerRegistry(), tmux.Pane{CurrentCommand: "bash", ProviderOverride: "Goose"}, nil); got != "goose" {
+		t.Fatalf("override provider=%q", got)
+	}
+	available := agent.providerAvailabilityMap()
+	if _, listed := available["goose"]; !listed || available["goose"] || !available["shell"] {
+		t.Fatalf("availability=%v, want goose listed but not launchable", available)
+	}
+
+	quiet := time.Now().Add(-time.Minute)
+	session := &SessionState{ID: "s", Provider: "goose", Status: "RUNNING", LastOutput: quiet, LastActivity: quiet, ScreenStatus: "WAITING_FOR_APPROVAL"}
+	if got := agent.deriveStatus(session, pane); got != "WAITING_FOR_APPROVAL" {
+		t.Fatalf("status with prompt on screen=%q", got)
+	}
+	// The approval is released once the prompt leaves the screen.
+	session.Status = "WAITING_FOR_APPROVAL"
+	session.ScreenStatus = ""
+	if got := agent.deriveStatus(session, pane); got != "WAITING_FOR_INPUT" {
+		t.Fatalf("status after prompt cleared=%q", got)
+	}
+}
+
+func TestConfiguredProviderUpsertsAsUnknownWithItsName(t *testing.T) {
+	data, err := json.Marshal(sessionUpsert(&SessionState{
+		ID:       "s",
+		Kind:     "tmux_pane",
+		Provider: "goose",
+		Status:   "RUNNING",
+		Metadata: map[string]any{"status_detail": "thinking"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wire struct {
+		Provider string         `json:"provider"`
+		Metadata map[string]any `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		t.Fatal(err)
+	}
+	if wire.Provider != "unknown" || wire.Metadata["provider_name"] != "goose" || wire.Metadata["status_detail"] != "thinking" {
+		t.Fatalf("wire=%s", data)
+	}
+
+	wire.Metadata = nil
+	data, err = json.Marshal(protocol.SessionUpsert{ID: "s", Kind: "job", Provider: "goose", Status: "DONE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		t.Fatal(err)
+	}
+	if wire.Provider != "unknown" || len(wire.Metadata) != 1 || wire.Metadata["provider_name"] != "goose" {
+		t.Fatalf("wire without metadata=%s", data)
+	}
+
+	wire.Metadata = nil
+	data, err = json.Marshal(sessionUpsert(&SessionState{ID: "s", Kind: "tmux_pane", Provider: "codex", Status: "IDLE"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &wire); err != nil {
+		t.Fatal(err)
+	}
+	if wire.Provider != "codex" || wire.Metadata != nil {
+		t.Fatalf("builtin wire=%s", data)
+	}
+}
+
+func TestGeminiApprovalUsesClaudeApprovalKeys(t *testing.T) {
+	cfg := &config.Config{Providers: config.ProvidersConfig{Claude: config.ClaudeConfig{
+		ApprovalAllowKeys: []string{"1", "Enter"},
+		ApprovalDenyKeys:  []string{"3", "Enter"},
+	}}}
+	agent := &Agent{cfg: cfg, claudeProvider: providers.NewClaudeProvider(&cfg.Providers.Claude)}
+	if got := agent.getApprovalKeys("gemini_cli", true); len(got) != 2 || got[0] != "1" {
+		t.Fatalf("allow keys=%v", got)
+	}
+	if got := agent.getApprovalKeys("gemini_cli", false); len(got) != 2 || got[0] != "3" {
+		t.Fatalf("deny keys=%v", got)
+	}
+}
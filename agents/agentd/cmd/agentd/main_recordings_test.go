@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/filebridge"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/recording"
+	"github.com/agent-command/agentd/internal/tmux"
+)
+
+func TestReconcileRecordingsPipesLivePanesAndStopsFinished(t *testing.T) {
+	tempDir := t.TempDir()
+	callsPath := filepath.Join(tempDir, "calls.txt")
+	tmuxBin := filepath.Join(tempDir, "tmux-fixture")
+	script := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$*\" >> %q\n", callsPath)
+	if err := os.WriteFile(tmuxBin, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	recorder, err := recording.New(recording.Config{Dir: filepath.Join(tempDir, "recordings")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+	client := tmux.NewClient(&config.TmuxConfig{Bin: tmuxBin})
+	session := &SessionState{ID: "session-1", PaneID: "%4", Kind: "tmux_pane", Status: "RUNNING"}
+	agent := &Agent{
+		cfg:        &config.Config{Recording: config.RecordingConfig{RetentionHours: 72}},
+		tmuxClient: client,
+		pipeMux:    tmux.NewPipeMux(client, filepath.Join(tempDir, "console")),
+		recorder:   recorder,
+		sessions:   map[string]*SessionState{"session-1": session},
+	}
+
+	agent.reconcileRecordings()
+	if !recorder.Recording("session-1") {
+		t.Fatal("live pane is not recording")
+	}
+	calls := readRecordingCalls(t, callsPath)
+	if len(calls) != 1 || !strings.HasPrefix(calls[0], "pipe-pane -t %4 ") || !strings.Contains(calls[0], "output.pipe") {
+		t.Fatalf("calls=%q", calls)
+	}
+
+	agent.reconcileRecordings()
+	if calls := readRecordingCalls(t, callsPath); len(calls) != 1 {
+		t.Fatalf("reconcile re-piped an unchanged pane: %q", calls)
+	}
+
+	session.Status = "DONE"
+	agent.reconcileRecordings()
+	if recorder.Recording("session-1") {
+		t.Fatal("finished session still recording")
+	}
+	if calls := readRecordingCalls(t, callsPath); len(calls) != 2 || calls[1] != "pipe-pane -t %4" {
+		t.Fatalf("calls=%q", calls)
+	}
+}
+
+func TestReadRecordingCommand(t *testing.T) {
+	recorder, err := recording.New(recording.Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+	base := time.Date(2026, 7, 23, 3, 0, 0, 0, time.UTC)
+	for index, part := range []string{"build ok\r\n", "\x1b[31mtest failed\x1b[0m\r\n"} {
+		if err := recorder.Append("session-1", base.Add(time.Duration(index)*time.Hour), []byte(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	agent := &Agent{cfg: &config.Config{}, recorder: recorder}
+
+	result, err := executeAgentCommand(t, agent, "session-1", "read_recording", map[string]any{
+		"since": base.Add(30 * time.Minute).Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := result["chunks"].([]protocol.RecordingChunk)
+	if len(chunks) != 1 || chunks[0].Offset != 10 || result["retained_end_offset"] != int64(32) {
+		t.Fatalf("result=%+v", result)
+	}
+	if data, _ := base64.StdEncoding.DecodeString(chunks[0].Data); string(data) != "\x1b[31mtest failed\x1b[0m\r\n" {
+		t.Fatalf("data=%q", data)
+	}
+
+	if _, err := executeAgentCommand(t, agent, "session-2", "read_recording", map[string]any{}); commandResultCode(err) != "RECORDING_NOT_FOUND" {
+		t.Fatalf("missing err=%v", err)
+	}
+	if _, err := executeAgentCommand(t, agent, "session-1", "read_recording", map[string]any{"until": "yesterday"}); commandResultCode(err) != "INVALID_RANGE" {
+		t.Fatalf("bad time err=%v", err)
+	}
+	if _, err := executeAgentCommand(t, &Agent{cfg: &config.Config{}}, "session-1", "read_recording", map[string]any{}); commandResultCode(err) != "RECORDING_DISABLED" {
+		t.Fatalf("disabled err=%v", err)
+	}
+}
+
+func readRecordingCalls(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestExportCastCommandInlineAndPublished(t *testing.T) {
+	tempDir := t.TempDir()
+	recorder, err := recording.New(recording.Config{Dir: filepath.Join(tempDir, "recordings")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+	base := time.Date(2026, 7, 23, 3, 0, 0, 0, time.UTC)
+	if err := recorder.Resize("session-1", base, 132, 43); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Append("session-1", base, []byte("hello\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	dropDir := filepath.Join(tempDir, "drop")
+	outDir := filepath.Join(tempDir, "out")
+	for _, dir := range []string{dropDir, outDir} {
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bridge, err := filebridge.New(filebridge.Config{Enabled: true, DropDir: dropDir, OutDir: outDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := &Agent{
+		cfg:        &config.Config{},
+		recorder:   recorder,
+		fileBridge: bridge,
+		sessions:   map[string]*SessionState{"session-1": {ID: "session-1", Title: "nightly refactor"}},
+	}
+
+	inline, err := executeAgentCommand(t, agent, "session-1", "export_cast", map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cast := inline["cast"].(string)
+	if !strings.Contains(cast, `"width":132,"height":43`) || !strings.Contains(cast, `"title":"nightly refactor"`) ||
+		!strings.Contains(cast, `[0,"o","hello\r\n"]`) {
+		t.Fatalf("cast=%s", cast)
+	}
+
+	published, err := executeAgentCommand(t, agent, "session-1", "export_cast", map[string]any{"publish": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := published["path"].(string)
+	if filepath.Dir(path) != outDir || published["name"] != "session-1-20260723T030000Z.cast" {
+		t.Fatalf("published=%+v", published)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != cast {
+		t.Fatalf("published file=%q err=%v", data, err)
+	}
+
+	if _, err := executeAgentCommand(t, agent, "session-1", "export_cast", map[string]any{"since": "2026-07-24T00:00:00Z"}); commandResultCode(err) != "EMPTY_RANGE" {
+		t.Fatalf("empty err=%v", err)
+	}
+}
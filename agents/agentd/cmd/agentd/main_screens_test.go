@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/tmux"
+)
+
+func TestReadScreenServesTrackedPaneWithoutCapture(t *testing.T) {
+	tempDir := t.TempDir()
+	callsPath := filepath.Join(tempDir, "calls.txt")
+	tmuxBin := filepath.Join(tempDir, "tmux-fixture")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$*\" >> " + callsPath + "\n" +
+		"case \"$1\" in\n" +
+		"display-message) printf '20\\t3\\t5\\t0\\t0\\n' ;;\n" +
+		"capture-pane) printf 'hello\\n' ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(tmuxBin, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	client := tmux.NewClient(&config.TmuxConfig{Bin: tmuxBin})
+	screens := tmux.NewScreenTracker(client, tempDir)
+	defer screens.Close()
+	session := &SessionState{
+		ID:       "session-1",
+		PaneID:   "%4",
+		Kind:     "tmux_pane",
+		Status:   "RUNNING",
+		Metadata: map[string]any{"tmux": map[string]any{"pane_width": 30, "pane_height": 3}},
+	}
+	agent := &Agent{
+		cfg:        &config.Config{},
+		tmuxClient: client,
+		pipeMux:    tmux.NewPipeMux(client, filepath.Join(tempDir, "console")),
+		screens:    screens,
+		sessions:   map[string]*SessionState{"session-1": session},
+	}
+
+	agent.reconcileScreens()
+	calls := readRecordingCalls(t, callsPath)
+	if len(calls) != 3 || !strings.HasPrefix(calls[2], "pipe-pane -t %4 ") || !strings.Contains(calls[2], "pane_4.screen") {
+		t.Fatalf("calls=%q", calls)
+	}
+
+	result, err := executeAgentCommand(t, agent, "session-1", "read_screen", map[string]any{"include_ansi": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["cols"] != 30 || result["rows"] != 3 || result["alt_screen"] != false {
+		t.Fatalf("result=%+v", result)
+	}
+	if got := result["lines"]; !reflect.DeepEqual(got, []string{"hello", "", ""}) {
+		t.Fatalf("lines=%q", got)
+	}
+	if cursor := result["cursor"].(map[string]any); cursor["row"] != 0 || cursor["col"] != 5 {
+		t.Fatalf("cursor=%+v", cursor)
+	}
+	if ansi, _ := base64.StdEncoding.DecodeString(result["ansi"].(string)); !strings.Contains(string(ansi), "hello") {
+		t.Fatalf("ansi=%q", ansi)
+	}
+	if calls := readRecordingCalls(t, callsPath); len(calls) != 3 {
+		t.Fatalf("read_screen called tmux: %q", calls[3:])
+	}
+
+	session.Status = "DONE"
+	agent.reconcileScreens()
+	if _, err := executeAgentCommand(t, agent, "session-1", "read_screen", map[string]any{}); commandResultCode(err) != "SCREEN_UNAVAILABLE" {
+		t.Fatalf("untracked err=%v", err)
+	}
+	if _, err := executeAgentCommand(t, &Agent{cfg: &config.Config{}, sessions: agent.sessions}, "session-1", "read_screen", map[string]any{}); commandResultCode(err) != "SCREEN_UNAVAILABLE" {
+		t.Fatalf("disabled err=%v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/tmux"
+)
+
+func TestSearchPanesFindsMatchesAcrossSessionsWithContext(t *testing.T) {
+	agent := newSearchPanesAgent(t, map[string][]string{
+		"%1": {"build ok", "panic: nil map", "goroutine 1", "done"},
+		"%2": {"FAIL pkg/a", "ok pkg/b"},
+		"%3": {"nothing here"},
+	})
+
+	result, err := executeAgentCommand(t, agent, "", "search_panes", map[string]any{
+		"pattern":       `panic:|FAIL`,
+		"context_lines": 1,
+	})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	matches := result["matches"].([]protocol.SearchPaneMatch)
+	if len(matches) != 2 ||
+		result["total_matches"] != 2 ||
+		result["sessions_searched"] != 3 ||
+		result["has_more"] != false {
+		t.Fatalf("search result=%+v", result)
+	}
+	first := matches[0]
+	if first.SessionID != "session-a" ||
+		first.PaneID != "%1" ||
+		first.LineNumber != 2 ||
+		first.Line != "panic: nil map" ||
+		strings.Join(first.Before, "|") != "build ok" ||
+		strings.Join(first.After, "|") != "goroutine 1" {
+		t.Fatalf("first match=%+v", first)
+	}
+	second := matches[1]
+	if second.SessionID != "session-b" || second.LineNumber != 1 || len(second.Before) != 0 {
+		t.Fatalf("second match=%+v", second)
+	}
+}
+
+func TestSearchPanesFiltersByProviderAndGroup(t *testing.T) {
+	agent := newSearchPanesAgent(t, map[string][]string{
+		"%1": {"needle"},
+		"%2": {"needle"},
+		"%3": {"needle"},
+	})
+
+	byProvider, err := executeAgentCommand(t, agent, "", "search_panes", map[string]any{
+		"pattern":  "needle",
+		"literal":  true,
+		"provider": "codex",
+	})
+	if err != nil {
+		t.Fatalf("provider search: %v", err)
+	}
+	if matches := byProvider["matches"].([]protocol.SearchPaneMatch); len(matches) != 1 || matches[0].SessionID != "session-b" {
+		t.Fatalf("provider matches=%+v", matches)
+	}
+
+	byGroup, err := executeAgentCommand(t, agent, "", "search_panes", map[string]any{
+		"pattern":     "NEEDLE",
+		"group_id":    "group-1",
+		"ignore_case": true,
+	})
+	if err != nil {
+		t.Fatalf("group search: %v", err)
+	}
+	if matches := byGroup["matches"].([]protocol.SearchPaneMatch); len(matches) != 2 ||
+		matches[0].SessionID != "session-a" ||
+		matches[1].SessionID != "session-c" {
+		t.Fatalf("group matches=%+v", matches)
+	}
+}
+
+func TestSearchPanesPagesFromStoredResult(t *testing.T) {
+	agent := newSearchPanesAgent(t, map[string][]string{
+		"%1": {"hit-0", "hit-1", "hit-2"},
+	})
+
+	first, err := executeAgentCommand(t, agent, "", "search_panes", map[string]any{
+		"pattern":     "hit",
+		"session_ids": []string{"session-a"},
+		"page_size":   2,
+	})
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	if first["has_more"] != true || first["next_after"] != 2 || first["total_matches"] != 3 {
+		t.Fatalf("first page=%+v", first)
+	}
+
+	second, err := executeAgentCommand(t, agent, "", "search_panes", map[string]any{
+		"search_id":   first["search_id"],
+		"after_match": first["next_after"],
+		"page_size":   2,
+	})
+	if err != nil {
+		t.Fatalf("second page: %v", err)
+	}
+	matches := second["matches"].([]protocol.SearchPaneMatch)
+	if len(matches) != 1 || matches[0].Line != "hit-2" || second["has_more"] != false {
+		t.Fatalf("second page=%+v", second)
+	}
+	if _, exists := second["next_after"]; exists {
+		t.Fatalf("final page exposes next_after: %+v", second)
+	}
+}
+
+func TestSearchPanesExpiredSearchReturnsResultCode(t *testing.T) {
+	agent := newSearchPanesAgent(t, map[string][]string{"%1": {"hit"}})
+	now := time.Date(2026, 7, 23, 12, 0, 0, 0, time.UTC)
+	agent.paneSearches.now = func() time.Time { return now }
+
+	created, err := executeAgentCommand(t, agent, "", "search_panes", map[string]any{"pattern": "hit"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	now = now.Add(paneSearchIdleTTL)
+	_, err = executeAgentCommand(t, agent, "", "search_panes", map[string]any{
+		"search_id":   created["search_id"],
+		"after_match": 0,
+	})
+	var coded interface{ CommandResultCode() string }
+	if err == nil || !errors.As(err, &coded) || coded.CommandResultCode() != "SEARCH_EXPIRED" {
+		t.Fatalf("expired search err=%v", err)
+	}
+}
+
+func TestSearchPanesRejectsInvalidPattern(t *testing.T) {
+	agent := newSearchPanesAgent(t, map[string][]string{"%1": {"hit"}})
+	if _, err := executeAgentCommand(t, agent, "", "search_panes", map[string]any{"pattern": "("}); err == nil {
+		t.Fatal("expected invalid pattern error")
+	}
+	if _, err := executeAgentCommand(t, agent, "", "search_panes", map[string]any{"pattern": ""}); err == nil {
+		t.Fatal("expected empty pattern error")
+	}
+}
+
+func TestSearchPanesReportsCaptureFailuresPerSession(t *testing.T) {
+	agent := newSearchPanesAgent(t, map[string][]string{"%1": {"hit"}})
+	agent.sessions["session-missing"] = &SessionState{ID: "session-missing", PaneID: "%9"}
+
+	result, err := executeAgentCommand(t, agent, "", "search_panes", map[string]any{"pattern": "hit"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	searchErrors, _ := result["errors"].(map[string]string)
+	if _, failed := searchErrors["session-missing"]; !failed || result["total_matches"] != 1 {
+		t.Fatalf("search result=%+v", result)
+	}
+}
+
+// newSearchPanesAgent serves each pane's history from its own fixture file and
+// fails capture for any pane without one.
+func newSearchPanesAgent(t *testing.T, panes map[string][]string) *Agent {
+	t.Helper()
+	tempDir := t.TempDir()
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\ncase \"$*\" in\n")
+	for paneID, lines := range panes {
+		outputPath := filepath.Join(tempDir, strings.TrimPrefix(paneID, "%")+".txt")
+		writeSnapshotOutput(t, outputPath, lines)
+		fmt.Fprintf(&script, "*\"-t %s \"*) cat %q ;;\n", paneID, outputPath)
+	}
+	script.WriteString("*) exit 1 ;;\nesac\n")
+	tmuxBin := filepath.Join(tempDir, "tmux-fixture")
+	if err := os.WriteFile(tmuxBin, []byte(script.String()), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return &Agent{
+		tmuxClient: tmux.NewClient(&config.TmuxConfig{Bin: tmuxBin}),
+		sessions: map[string]*SessionState{
+			"session-a": {ID: "session-a", PaneID: "%1", Provider: "claude_code", GroupID: "group-1"},
+			"session-b": {ID: "session-b", PaneID: "%2", Provider: "codex"},
+			"session-c": {ID: "session-c", PaneID: "%3", Provider: "shell", GroupID: "group-1"},
+			"session-j": {ID: "session-j", Kind: "job", Provider: "codex"},
+		},
+	}
+}
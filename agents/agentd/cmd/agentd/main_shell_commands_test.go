@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/tmux"
+)
+
+func TestShellCommandMarksEmitEventsAndDriveStatus(t *testing.T) {
+	tempDir := t.TempDir()
+	callsPath := filepath.Join(tempDir, "calls.txt")
+	tmuxBin := filepath.Join(tempDir, "tmux-fixture")
+	script := fmt.Sprintf("#!/bin/sh\nprintf '%%s\\n' \"$*\" >> %q\n", callsPath)
+	if err := os.WriteFile(tmuxBin, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	client := tmux.NewClient(&config.TmuxConfig{Bin: tmuxBin})
+	session := &SessionState{ID: "session-1", PaneID: "%4", Kind: "tmux_pane", Provider: "shell", Status: "IDLE"}
+	var mu sync.Mutex
+	var events []protocol.EventsAppendPayload
+	agent := &Agent{
+		cfg: &config.Config{
+			Tmux:    config.TmuxConfig{CommandMarks: true},
+			Storage: config.StorageConfig{StateDir: tempDir},
+		},
+		tmuxClient: client,
+		pipeMux:    tmux.NewPipeMux(client, filepath.Join(tempDir, "console")),
+		sessions:   map[string]*SessionState{"session-1": session},
+		sendMessage: func(msgType string, payload any) error {
+			if event, ok := payload.(protocol.EventsAppendPayload); ok && msgType == protocol.TypeEventsAppend {
+				mu.Lock()
+				events = append(events, event)
+				mu.Unlock()
+			}
+			return nil
+		},
+	}
+	defer agent.stopShellCommands()
+
+	agent.reconcileShellCommands()
+	calls := readRecordingCalls(t, callsPath)
+	if len(calls) != 1 || !strings.HasPrefix(calls[0], "pipe-pane -t %4 ") || !strings.Contains(calls[0], "pane_4.marks") {
+		t.Fatalf("calls=%q", calls)
+	}
+	pane := tmux.Pane{PaneID: "%4", CurrentCommand: "bash"}
+	if got := agent.deriveStatus(session, pane); got != "IDLE" {
+		t.Fatalf("status before marks=%q", got)
+	}
+
+	fifo, err := os.OpenFile(filepath.Join(tempDir, "marks", "pane_4.marks"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fifo.Close()
+	write := func(data string) {
+		t.Helper()
+		if _, err := fifo.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForEvents := func(want int) []protocol.EventsAppendPayload {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			mu.Lock()
+			got := append([]protocol.EventsAppendPayload(nil), events...)
+			mu.Unlock()
+			if len(got) >= want {
+				return got
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("events=%+v, want %d", got, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	write("\x1bPtmux;\x1b\x1b]133;A\x07\x1b\\$ \x1bPtmux;\x1b\x1b]133;C;cmdline_url=go%20test%20.%2F...\x07\x1b\\")
+	started := waitForEvents(1)[0]
+	if started.EventType != "shell.command" || started.SessionID != "session-1" ||
+		started.Payload["state"] != "running" || started.Payload["command"] != "go test ./..." {
+		t.Fatalf("started event=%+v", started)
+	}
+	if got := agent.deriveStatus(session, pane); got != "RUNNING" {
+		t.Fatalf("status while running=%q", got)
+	}
+
+	write("FAIL\r\n\x1bPtmux;\x1b\x1b]133;D;1\x07\x1b\\")
+	finished := waitForEvents(2)[1]
+	if finished.Payload["state"] != "finished" || finished.Payload["exit_code"] != 1 || finished.Payload["pane_id"] != "%4" {
+		t.Fatalf("finished event=%+v", finished)
+	}
+	if got := agent.deriveStatus(session, pane); got != "IDLE" {
+		t.Fatalf("status after command=%q", got)
+	}
+
+	payload, _ := json.Marshal(map[string]any{"limit": 5})
+	result, err := agent.executeCommand(commands.Dispatch{
+		SessionID: "session-1",
+		Command:   protocol.Command{Type: "list_commands", Payload: payload},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := result["commands"].([]map[string]any)
+	if result["state"] != "idle" || len(listed) != 1 || listed[0]["command"] != "go test ./..." || listed[0]["exit_code"] != 1 {
+		t.Fatalf("list_commands=%+v", result)
+	}
+
+	session.Status = "DONE"
+	agent.reconcileShellCommands()
+	if _, err := agent.executeCommand(commands.Dispatch{
+		SessionID: "session-1",
+		Command:   protocol.Command{Type: "list_commands"},
+	}); commandResultCode(err) != "COMMAND_MARKS_UNAVAILABLE" {
+		t.Fatalf("untracked err=%v", err)
+	}
+}
@@ -385,3 +385,232 @@ func TestCaptureTranscriptDropsToolUseResultAndBudgetsAllStrings(t *testing.T) {
 		t.Fatalf("sanitized entry too large: %d bytes", len(encoded))
 	}
 }
+
+func writeCodexRollout(t *testing.T, root, day, name, cwd string, startedAt time.Time, entries ...string) string {
+	t.Helper()
+	dir := filepath.Join(root, filepath.FromSlash(day))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	meta := `{"timestamp":"` + startedAt.Format(time.RFC3339Nano) + `","type":"session_meta","payload":{"id":"` + name + `","timestamp":"` + startedAt.Format(time.RFC3339Nano) + `","cwd":"` + cwd + `"}}`
+	path := filepath.Join(dir, "rollout-"+name+".jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(append([]string{meta}, entries...), "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCaptureTranscriptDerivesCodexRolloutByCwd(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	older := writeCodexRollout(t, root, "2026/10/17", "older", "/work/api", now.Add(-time.Hour))
+	newer := writeCodexRollout(t, root, "2026/10/18", "newer", "/work/api", now.Add(-time.Minute),
+		`{"type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"fix the build"}]}}`,
+		`{"type":"response_item","payload":{"type":"function_call_output","call_id":"c1","output":"ok"},"tool_use_result":{"stdout":"huge"}}`)
+	writeCodexRollout(t, root, "2026/10/18", "elsewhere", "/work/web", now)
+	for path, modTime := range map[string]time.Time{older: now.Add(-time.Hour), newer: now} {
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	agent := &Agent{
+		sessions:          map[string]*SessionState{"session-1": {ID: "session-1", Provider: "codex", CWD: "/work/api"}},
+		transcriptPaths:   make(map[string]string),
+		codexSessionsRoot: root,
+	}
+	payload, _ := json.Marshal(protocol.CaptureTranscriptPayload{PageSize: 2})
+	result, err := agent.executeCommand(commands.Dispatch{
+		SessionID: "session-1",
+		Command:   protocol.Command{Type: "capture_transcript", Payload: payload},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["source"] != "derived" || result["total_entries"] != 3 || result["first_entry"] != 1 {
+		t.Fatalf("result=%v", result)
+	}
+	entries := result["entries"].([]map[string]any)
+	if _, kept := entries[1]["tool_use_result"]; kept {
+		t.Fatalf("tool_use_result was not stripped: %v", entries[1])
+	}
+	if entries[0]["type"] != "response_item" {
+		t.Fatalf("entries=%v", entries)
+	}
+
+	// A session with nothing recorded in its cwd has no transcript.
+	agent.sessions["session-1"].CWD = "/work/none"
+	_, err = agent.executeCommand(commands.Dispatch{
+		SessionID: "session-1",
+		Command:   protocol.Command{Type: "capture_transcript", Payload: payload},
+	})
+	if commandResultCode(err) != "no_transcript" {
+		t.Fatalf("missing rollout err=%v", err)
+	}
+}
+
+func TestFindCodexRolloutPrefersRolloutStartedWithProcess(t *testing.T) {
+	root := t.TempDir()
+	started := time.Date(2026, 10, 18, 9, 0, 0, 0, time.Local)
+	stale := writeCodexRollout(t, root, "2026/10/10", "stale", "/work/api", started.Add(-8*24*time.Hour))
+	before := writeCodexRollout(t, root, "2026/10/18", "before", "/work/api", started.Add(-time.Hour))
+	first := writeCodexRollout(t, root, "2026/10/18", "first", "/work/api", started.Add(time.Second))
+	later := writeCodexRollout(t, root, "2026/10/18", "later", "/work/api", started.Add(time.Hour))
+	for path, modTime := range map[string]time.Time{stale: started.Add(-8 * 24 * time.Hour), before: started.Add(3 * time.Hour), first: started, later: started.Add(2 * time.Hour)} {
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := findCodexRollout(root, "/work/api", started); filepath.Base(got) != "rollout-first.jsonl" {
+		t.Fatalf("rollout for start time=%q", got)
+	}
+	if got := findCodexRollout(root, "/work/api", time.Time{}); filepath.Base(got) != "rollout-before.jsonl" {
+		t.Fatalf("newest rollout=%q", got)
+	}
+	// A process started after every rollout has none yet.
+	if got := findCodexRollout(root, "/work/api", started.Add(2*time.Hour)); got != "" {
+		t.Fatalf("rollout for later start=%q", got)
+	}
+}
+
+func TestCaptureTranscriptUsesRolloutOpenInPane(t *testing.T) {
+	root := t.TempDir()
+	path := writeCodexRollout(t, root, "2026/10/18", "open", "/work/other", time.Now(),
+		`{"type":"event_msg","payload":{"type":"agent_message","message":"done"}}`)
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	agent := &Agent{
+		sessions: map[string]*SessionState{"session-1": {
+			ID:       "session-1",
+			Provider: "codex",
+			CWD:      "/work/api",
+			Metadata: map[string]any{"tmux": map[string]any{"pane_pid": os.Getpid()}},
+		}},
+		transcriptPaths:   make(map[string]string),
+		codexSessionsRoot: root,
+	}
+	payload, _ := json.Marshal(protocol.CaptureTranscriptPayload{})
+	result, err := agent.executeCommand(commands.Dispatch{
+		SessionID: "session-1",
+		Command:   protocol.Command{Type: "capture_transcript", Payload: payload},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["source"] != "process" || result["total_entries"] != 2 {
+		t.Fatalf("result=%v", result)
+	}
+}
+
+func TestCaptureTranscriptNormalizesClaudeEntries(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.jsonl")
+	lines := []string{
+		`{"type":"summary","summary":"Build fixes"}`,
+		`{"type":"user","timestamp":"2026-10-18T09:00:00Z","message":{"role":"user","content":"Run the tests"}}`,
+		`{"type":"assistant","timestamp":"2026-10-18T09:00:02Z","message":{"role":"assistant","model":"claude-sonnet-4","content":[{"type":"thinking","thinking":"Use go test"},{"type":"text","text":"Running them."},{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"go test ./..."}}],"usage":{"input_tokens":12,"output_tokens":40,"cache_read_input_tokens":900}}}`,
+		`{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","is_error":true,"content":[{"type":"text","text":"FAIL ./cmd"}]}]},"toolUseResult":{"stdout":"FAIL ./cmd"}}`,
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	agent := &Agent{
+		sessions:           map[string]*SessionState{"session-1": {ID: "session-1", Provider: "claude_code"}},
+		transcriptPaths:    map[string]string{"session-1": path},
+		claudeProjectsRoot: dir,
+	}
+	payload, _ := json.Marshal(protocol.CaptureTranscriptPayload{PageSize: 3, Format: "normalized", IncludeRaw: true})
+	result, err := agent.executeCommand(commands.Dispatch{
+		SessionID: "session-1",
+		Command:   protocol.Command{Type: "capture_transcript", Payload: payload},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result["format"] != "normalized" || result["first_entry"] != 1 || result["total_entries"] != 4 {
+		t.Fatalf("result=%v", result)
+	}
+	entries := result["entries"].([]protocol.TranscriptEntry)
+	if len(entries) != 3 {
+		t.Fatalf("entries=%+v", entries)
+	}
+	user, assistant, tool := entries[0], entries[1], entries[2]
+	if user.Index != 1 || user.Kind != "message" || user.Role != "user" || len(user.Text) != 1 || user.Text[0].Text != "Run the tests" {
+		t.Fatalf("user=%+v", user)
+	}
+	if assistant.Model != "claude-sonnet-4" || len(assistant.Text) != 2 || assistant.Text[0].Type != "thinking" ||
+		assistant.Usage == nil || assistant.Usage.InputTokens != 12 || assistant.Usage.CacheReadTokens != 900 {
+		t.Fatalf("assistant=%+v", assistant)
+	}
+	call := assistant.ToolCalls[0]
+	if call.Name != "Bash" || call.Input["command"] != "go test ./..." || call.Output == nil || *call.Output != "FAIL ./cmd" || !call.IsError {
+		t.Fatalf("tool call=%+v", call)
+	}
+	if tool.Kind != "tool_result" || tool.Role != "tool" || tool.ToolCalls[0].Name != "Bash" || tool.Raw == nil {
+		t.Fatalf("tool result=%+v", tool)
+	}
+	if _, kept := tool.Raw["toolUseResult"]; kept {
+		t.Fatal("raw entry was not sanitized")
+	}
+
+	payload, _ = json.Marshal(protocol.CaptureTranscriptPayload{Format: "html"})
+	if _, err := agent.executeCommand(commands.Dispatch{
+		SessionID: "session-1",
+		Command:   protocol.Command{Type: "capture_transcript", Payload: payload},
+	}); err == nil {
+		t.Fatal("unknown format was accepted")
+	}
+}
+
+func TestNormalizeCodexTranscriptPage(t *testing.T) {
+	var records []map[string]any
+	for _, line := range []string{
+		`{"timestamp":"2026-10-18T09:00:00Z","type":"session_meta","payload":{"id":"s","cwd":"/work"}}`,
+		`{"timestamp":"2026-10-18T09:00:01Z","type":"turn_context","payload":{"cwd":"/work","model":"gpt-5-codex"}}`,
+		`{"timestamp":"2026-10-18T09:00:01Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"list files"}]}}`,
+		`{"timestamp":"2026-10-18T09:00:02Z","type":"response_item","payload":{"type":"reasoning","summary":[{"type":"summary_text","text":"Use ls"}]}}`,
+		`{"timestamp":"2026-10-18T09:00:02Z","type":"response_item","payload":{"type":"function_call","name":"shell","arguments":"{\"command\":[\"ls\"]}","call_id":"call_1"}}`,
+		`{"timestamp":"2026-10-18T09:00:03Z","type":"response_item","payload":{"type":"function_call_output","call_id":"call_1","output":"{\"output\":\"a.go\\n\",\"metadata\":{\"exit_code\":0}}"}}`,
+		`{"timestamp":"2026-10-18T09:00:04Z","type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"One file."}]}}`,
+		`{"timestamp":"2026-10-18T09:00:04Z","type":"event_msg","payload":{"type":"token_count","info":{"last_token_usage":{"input_tokens":500,"cached_input_tokens":300,"output_tokens":20,"reasoning_output_tokens":8}}}}`,
+		`{"type":"x-unparseable"}`,
+	} {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	entries := normalizeTranscriptPage("codex", records, 10, false)
+	if len(entries) != len(records) || entries[0].Index != 10 || entries[0].Kind != "event" || entries[0].Event != "session_meta" {
+		t.Fatalf("entries=%+v", entries)
+	}
+	if user := entries[2]; user.Role != "user" || user.Model != "" || user.Text[0].Text != "list files" {
+		t.Fatalf("user=%+v", user)
+	}
+	if reasoning := entries[3]; reasoning.Role != "assistant" || reasoning.Text[0].Type != "thinking" || reasoning.Model != "gpt-5-codex" {
+		t.Fatalf("reasoning=%+v", reasoning)
+	}
+	call := entries[4].ToolCalls[0]
+	if call.Name != "shell" || call.Input["command"].([]any)[0] != "ls" || call.Output == nil || *call.Output != "a.go\n" || call.IsError {
+		t.Fatalf("call=%+v", call)
+	}
+	if result := entries[5]; result.Kind != "tool_result" || result.ToolCalls[0].Name != "shell" || entries[5].Raw != nil {
+		t.Fatalf("result=%+v", result)
+	}
+	if reply := entries[6]; reply.Role != "assistant" || reply.Text[0].Text != "One file." {
+		t.Fatalf("reply=%+v", reply)
+	}
+	if usage := entries[7].Usage; entries[7].Event != "token_count" || usage == nil || usage.InputTokens != 500 || usage.CacheReadTokens != 300 || usage.ReasoningTokens != 8 {
+		t.Fatalf("usage entry=%+v", entries[7])
+	}
+	if entries[8].Kind != "unparseable" {
+		t.Fatalf("stub=%+v", entries[8])
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/usage"
+)
+
+func TestTranscriptUsageTailsClaudeTranscript(t *testing.T) {
+	projectsRoot := t.TempDir()
+	transcriptPath := filepath.Join(projectsRoot, "-repo", "session.jsonl")
+	if err := os.MkdirAll(filepath.Dir(transcriptPath), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	first := `{"type":"assistant","message":{"id":"msg_1","model":"claude-opus-4-1","usage":{"input_tokens":100000,"output_tokens":1000}}}` + "\n"
+	second := `{"type":"assistant","message":{"id":"msg_2","model":"claude-opus-4-1","usage":{"input_tokens":100000,"output_tokens":1000,"cache_read_input_tokens":50000}}}` + "\n"
+	if err := os.WriteFile(transcriptPath, []byte(first+second[:40]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []protocol.SessionUsagePayload
+	agent := &Agent{
+		cfg: &config.Config{},
+		sessions: map[string]*SessionState{
+			"session-1": {ID: "session-1", Kind: "tmux_pane", Provider: "claude_code", Status: "RUNNING", GroupID: "fleet", CWD: "/repo"},
+		},
+		transcriptPaths:    map[string]string{"session-1": transcriptPath},
+		claudeProjectsRoot: projectsRoot,
+		usageTracker:       usage.NewUsageTracker(),
+		sendMessage: func(msgType string, payload any) error {
+			if msgType == protocol.TypeSessionUsage {
+				sent = append(sent, payload.(protocol.SessionUsagePayload))
+			}
+			return nil
+		},
+	}
+	agent.usageTracker.SetPricing(newPriceTable(config.PricingConfig{
+		Models: map[string]config.ModelPrice{"claude-opus-4*": {Input: 15, Output: 75}},
+	}))
+	agent.usageTracker.SetAttribution(agent.sessionCostAttribution)
+
+	tails := make(map[string]*transcriptUsageTail)
+	now := time.Now()
+	agent.pollTranscriptUsage(tails, false, now)
+	if len(sent) != 1 || sent[0].Source != usage.SourceTranscript || *sent[0].InputTokens != 100000 ||
+		*sent[0].ContextUsedTokens != 101000 || *sent[0].EstimatedCostCents != 158 {
+		t.Fatalf("first poll sent=%+v", sent)
+	}
+
+	// The record cut off mid-write is read once it is complete.
+	file, err := os.OpenFile(transcriptPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(second[40:]); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	agent.pollTranscriptUsage(tails, false, now.Add(time.Second))
+	if len(sent) != 2 || *sent[1].InputTokens != 200000 || *sent[1].CacheReadTokens != 50000 || *sent[1].ContextUsedTokens != 151000 {
+		t.Fatalf("second poll sent=%+v", sent[1:])
+	}
+	agent.pollTranscriptUsage(tails, false, now.Add(2*time.Second))
+	if len(sent) != 2 {
+		t.Fatalf("unchanged transcript sent again: %+v", sent[2:])
+	}
+	if groups := agent.usageTracker.Costs().Groups; len(groups) != 1 || groups[0].GroupID != "fleet" {
+		t.Fatalf("groups=%+v", groups)
+	}
+
+	// A session running before agentd started counts only toward itself.
+	agent.sessions["session-2"] = &SessionState{ID: "session-2", Kind: "tmux_pane", Provider: "claude_code", Status: "RUNNING", GroupID: "fleet"}
+	agent.transcriptPaths["session-2"] = transcriptPath
+	before := agent.usageTracker.Costs().Groups[0].CostCents
+	agent.pollTranscriptUsage(map[string]*transcriptUsageTail{}, true, now)
+	if len(sent) != 3 || sent[2].SessionID != "session-2" || *sent[2].EstimatedCostCents != 315 {
+		t.Fatalf("startup poll sent=%+v", sent[2:])
+	}
+	if after := agent.usageTracker.Costs().Groups[0].CostCents; after != before {
+		t.Fatalf("backlog added to group totals: %v -> %v", before, after)
+	}
+}
+
+func TestTranscriptUsageKeepsFirstTranscriptWithoutHook(t *testing.T) {
+	projectsRoot := t.TempDir()
+	dir := filepath.Join(projectsRoot, "-repo")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	record := `{"type":"assistant","message":{"id":"msg_1","model":"claude-opus-4-1","usage":{"input_tokens":1000,"output_tokens":10}}}` + "\n"
+	own := filepath.Join(dir, "own.jsonl")
+	if err := os.WriteFile(own, []byte(record), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []protocol.SessionUsagePayload
+	agent := &Agent{
+		cfg: &config.Config{},
+		sessions: map[string]*SessionState{
+			"session-1": {ID: "session-1", Kind: "tmux_pane", Provider: "claude_code", Status: "RUNNING", CWD: "/repo"},
+		},
+		transcriptPaths:    map[string]string{},
+		claudeProjectsRoot: projectsRoot,
+		usageTracker:       usage.NewUsageTracker(),
+		sendMessage: func(msgType string, payload any) error {
+			if msgType == protocol.TypeSessionUsage {
+				sent = append(sent, payload.(protocol.SessionUsagePayload))
+			}
+			return nil
+		},
+	}
+	tails := make(map[string]*transcriptUsageTail)
+	now := time.Now()
+	agent.pollTranscriptUsage(tails, false, now)
+	if tails["session-1"].path != own || len(sent) != 1 {
+		t.Fatalf("path=%q sent=%+v", tails["session-1"].path, sent)
+	}
+
+	// Another session in the same cwd writes a newer transcript; the
+	// session stays on its own.
+	other := filepath.Join(dir, "other.jsonl")
+	if err := os.WriteFile(other, []byte(record+record), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := now.Add(time.Minute)
+	if err := os.Chtimes(other, later, later); err != nil {
+		t.Fatal(err)
+	}
+	agent.pollTranscriptUsage(tails, false, now.Add(2*transcriptUsageResolveInterval))
+	if tails["session-1"].path != own || len(sent) != 1 {
+		t.Fatalf("switched to path=%q sent=%+v", tails["session-1"].path, sent[1:])
+	}
+
+	// A hook reporting a transcript is followed at once.
+	agent.transcriptPaths["session-1"] = other
+	agent.pollTranscriptUsage(tails, false, now.Add(2*transcriptUsageResolveInterval+time.Second))
+	if tails["session-1"].path != other {
+		t.Fatalf("hook path not followed: %q", tails["session-1"].path)
+	}
+}
+
+func TestTranscriptUsageReadsCodexRollout(t *testing.T) {
+	root := t.TempDir()
+	rollout := writeCodexRollout(t, root, "2026/10/18", "usage", "/work/api", time.Now(),
+		`{"type":"turn_context","payload":{"model":"gpt-5-codex"}}`,
+		`{"type":"event_msg","payload":{"type":"token_count","info":{"total_token_usage":{"input_tokens":4000,"cached_input_tokens":3000,"output_tokens":500,"total_tokens":4500},"last_token_usage":{"total_tokens":4500},"model_context_window":272000},"rate_limits":{"primary":{"used_percent":20,"window_minutes":300}}}}`)
+
+	var sent []protocol.SessionUsagePayload
+	agent := &Agent{
+		cfg: &config.Config{},
+		sessions: map[string]*SessionState{
+			"session-1": {ID: "session-1", Kind: "tmux_pane", Provider: "codex", Status: "RUNNING", CWD: "/work/api"},
+		},
+		transcriptPaths:   map[string]string{"session-1": rollout},
+		codexSessionsRoot: root,
+		usageTracker:      usage.NewUsageTracker(),
+		sendMessage: func(msgType string, payload any) error {
+			if msgType == protocol.TypeSessionUsage {
+				sent = append(sent, payload.(protocol.SessionUsagePayload))
+			}
+			return nil
+		},
+	}
+	agent.usageTracker.SetPricing(newPriceTable(config.PricingConfig{
+		Models: map[string]config.ModelPrice{"gpt-5*": {Input: 1.25, Output: 10}},
+	}))
+	agent.pollTranscriptUsage(make(map[string]*transcriptUsageTail), false, time.Now())
+	if len(sent) != 1 || *sent[0].InputTokens != 4000 || *sent[0].CacheReadTokens != 3000 ||
+		*sent[0].ContextTotalTokens != 272000 || *sent[0].FiveHourLeftPercent != 80 {
+		t.Fatalf("sent=%+v", sent)
+	}
+	if sessions := agent.usageTracker.Costs().Sessions; len(sessions) != 1 || sessions[0].Model != "gpt-5-codex" {
+		t.Fatalf("sessions=%+v", sessions)
+	}
+}
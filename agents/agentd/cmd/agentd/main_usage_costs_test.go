@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/providers"
+	"github.com/agent-command/agentd/internal/usage"
+)
+
+func TestHookModelPricesSessionAndCostsAreReported(t *testing.T) {
+	agent := &Agent{
+		cfg: &config.Config{},
+		sessions: map[string]*SessionState{
+			"session-1": {ID: "session-1", Kind: "tmux_pane", PaneID: "%1", GroupID: "fleet", CWD: "/src/app/pkg", RepoRoot: "/src/app"},
+		},
+		transcriptPaths: make(map[string]string),
+		usageTracker:    usage.NewUsageTracker(),
+		sendMessage:     func(string, any) error { return nil },
+	}
+	agent.usageTracker.SetPricing(newPriceTable(config.PricingConfig{
+		Models:        map[string]config.ModelPrice{"claude-opus-4*": {Input: 15, Output: 75}, "claude-sonnet-4*": {Input: 3, Output: 15}},
+		DefaultModels: map[string]string{"claude_code": "claude-sonnet-4"},
+	}))
+	agent.usageTracker.SetAttribution(agent.sessionCostAttribution)
+
+	hook := providers.ClaudeHookPayload{Hook: json.RawMessage(`{"hook_event_name":"SessionStart","model":{"id":"claude-opus-4-1","display_name":"Opus"}}`)}
+	hook.Meta.ACSessionID = "session-1"
+	if _, err := agent.handleClaudeHook(hook); err != nil {
+		t.Fatal(err)
+	}
+	// The first counts are the session's baseline.
+	agent.usageTracker.RecordTokens("session-1", "claude_code", usage.Tokens{})
+	if cost := agent.usageTracker.RecordTokens("session-1", "claude_code", usage.Tokens{Input: 100_000}); cost == nil || *cost != 150 {
+		t.Fatalf("cost=%v", cost)
+	}
+
+	result, err := agent.executeCommand(commands.Dispatch{Command: protocol.Command{Type: "get_usage_costs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(result)
+	var report usage.CostReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Sessions) != 1 || report.Sessions[0].Model != "claude-opus-4-1" || report.Sessions[0].Repo != "/src/app" {
+		t.Fatalf("sessions=%+v", report.Sessions)
+	}
+	if len(report.Groups) != 1 || report.Groups[0].GroupID != "fleet" || report.Groups[0].CostCents != 150 {
+		t.Fatalf("groups=%+v", report.Groups)
+	}
+	if len(report.Weekly) != 1 || report.Weekly[0].Repo != "/src/app" || report.Weekly[0].CostCents != 150 {
+		t.Fatalf("weekly=%+v", report.Weekly)
+	}
+}
+
+func TestRecentCostWeeks(t *testing.T) {
+	entries := []usage.WeeklyCost{
+		{Week: "2026-W05", Repo: "/src/a", CostCents: 10},
+		{Week: "2026-W06", Repo: "/src/a", CostCents: 20},
+		{Week: "2026-W06", Repo: "/src/b", CostCents: 90},
+		{Week: "2026-W07", Repo: "/src/a", CostCents: 5},
+	}
+	got := recentCostWeeks(entries, 2)
+	if len(got) != 3 || got[0].Repo != "/src/b" || got[1].Repo != "/src/a" || got[2].Week != "2026-W07" {
+		t.Fatalf("recent weeks=%+v", got)
+	}
+}
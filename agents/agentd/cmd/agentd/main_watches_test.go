@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/watch"
+)
+
+func TestEvaluateWatchesEmitsMatchAndSetsStatus(t *testing.T) {
+	cfg := &config.Config{Watches: []config.WatchConfig{{
+		ID:           "test-failures",
+		Pattern:      `FAIL|panic:`,
+		ContextLines: 1,
+		SetStatus:    "ERROR",
+		Notify:       true,
+	}}}
+	var events []protocol.EventsAppendPayload
+	var upserts []protocol.SessionUpsert
+	session := &SessionState{ID: "session-1", PaneID: "%3", Kind: "tmux_pane", Status: "RUNNING"}
+	agent := &Agent{
+		cfg:      cfg,
+		watches:  newWatchEngine(cfg),
+		sessions: map[string]*SessionState{"session-1": session},
+		sendMessage: func(msgType string, payload any) error {
+			switch msgType {
+			case protocol.TypeEventsAppend:
+				events = append(events, payload.(protocol.EventsAppendPayload))
+			case protocol.TypeSessionsUpsert:
+				upserts = append(upserts, payload.(protocol.SessionsUpsertPayload).Sessions...)
+			}
+			return nil
+		},
+	}
+
+	agent.evaluateWatches(session, "$ go test ./...\n")
+	agent.evaluateWatches(session, "$ go test ./...\n\x1b[31m--- FAIL: TestParse\x1b[0m\nFAIL\tpkg\n")
+
+	if len(events) != 2 {
+		t.Fatalf("events=%+v", events)
+	}
+	first := events[0]
+	if first.EventType != "watch.match" ||
+		first.SessionID != "session-1" ||
+		first.Payload["watch_id"] != "test-failures" ||
+		first.Payload["line"] != "--- FAIL: TestParse" ||
+		first.Payload["notify"] != true {
+		t.Fatalf("first event=%+v", first)
+	}
+	if before := first.Payload["before"].([]string); len(before) != 1 || before[0] != "$ go test ./..." {
+		t.Fatalf("before=%q", before)
+	}
+	if session.Status != "ERROR" || len(upserts) == 0 {
+		t.Fatalf("status=%s upserts=%+v", session.Status, upserts)
+	}
+}
+
+func TestRegisterListAndRemoveWatchCommands(t *testing.T) {
+	cfg := &config.Config{Watches: []config.WatchConfig{{ID: "cfg", Pattern: "panic:"}}}
+	agent := &Agent{cfg: cfg, watches: newWatchEngine(cfg)}
+
+	registered, err := executeAgentCommand(t, agent, "", "register_watch", map[string]any{
+		"watch_id":    "broken-build",
+		"pattern":     "error:",
+		"provider":    "codex",
+		"cooldown_ms": 5000,
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if rule := registered["watch"].(watch.Rule); rule.ID != "broken-build" || rule.Source != watch.SourceCommand {
+		t.Fatalf("registered=%+v", registered)
+	}
+	if _, err := executeAgentCommand(t, agent, "", "register_watch", map[string]any{
+		"pattern":   "x",
+		"send_keys": []string{"C-c"},
+	}); err == nil {
+		t.Fatal("send_keys allowed without allow_send_input")
+	}
+
+	listed, err := executeAgentCommand(t, agent, "", "list_watches", map[string]any{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if rules := listed["watches"].([]watch.Rule); len(rules) != 2 || rules[0].ID != "broken-build" || rules[1].ID != "cfg" {
+		t.Fatalf("listed=%+v", listed)
+	}
+
+	if _, err := executeAgentCommand(t, agent, "", "remove_watch", map[string]any{"watch_id": "cfg"}); err == nil {
+		t.Fatal("removed config watch")
+	}
+	if _, err := executeAgentCommand(t, agent, "", "remove_watch", map[string]any{"watch_id": "broken-build"}); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := executeAgentCommand(t, agent, "", "remove_watch", map[string]any{"watch_id": "broken-build"}); err == nil {
+		t.Fatal("removed missing watch")
+	}
+}
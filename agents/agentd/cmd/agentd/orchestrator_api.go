@@ -42,7 +42,7 @@ func (b *agentOrchestratorBackend) Spawn(_ context.Context, callerSessionID stri
 	if !b.agent.cfg.Security.AllowSpawn {
 		return orchestrator.SpawnResponse{}, orchestrator.Forbidden("spawn not allowed by policy")
 	}
-	request.Provider = normalizeProviderOverride(request.Provider)
+	request.Provider = b.agent.providerRegistry().Normalize(request.Provider)
 	if request.Provider == "" || request.Provider == "unknown" {
 		return orchestrator.SpawnResponse{}, orchestrator.BadRequest("provider is not supported")
 	}
@@ -62,6 +62,15 @@ func (b *agentOrchestratorBackend) Spawn(_ context.Context, callerSessionID stri
 	}
 	parentSnapshot := *parent
 	b.agent.sessionsMu.RUnlock()
+	// Children share their parent's group, so a group budget caps the
+	// whole tree, and a parent over budget cannot spawn its way around it.
+	err = b.agent.budgetHold(callerSessionID, "", "")
+	if err == nil {
+		err = b.agent.budgetHold("", parentSnapshot.GroupID, request.Provider)
+	}
+	if err != nil {
+		return orchestrator.SpawnResponse{}, orchestrator.TooManyRequests(err.Error())
+	}
 
 	name := strings.TrimSpace(request.Name)
 	if name == "" {
@@ -158,6 +167,7 @@ func (b *agentOrchestratorBackend) Spawn(_ context.Context, callerSessionID stri
 		Title:           name,
 		CWD:             request.CWD,
 		TmuxTarget:      created.TmuxTarget,
+		GroupID:         parentSnapshot.GroupID,
 		ParentSessionID: callerSessionID,
 		Ready:           request.Provider == "shell",
 		Metadata:        map[string]any{"parent_session_id": callerSessionID},
@@ -237,6 +247,9 @@ func (b *agentOrchestratorBackend) Send(_ context.Context, _ string, request orc
 	}
 	paneID := session.PaneID
 	b.agent.sessionsMu.RUnlock()
+	if err := b.agent.budgetHold(request.SessionID, "", ""); err != nil {
+		return orchestrator.TooManyRequests(err.Error())
+	}
 	if err := b.agent.localTmuxRunner().SendInput(paneID, request.Input, request.ShouldEnter()); err != nil {
 		return fmt.Errorf("send input: %w", err)
 	}
@@ -466,7 +479,9 @@ func (a *Agent) sendPromptAfterReady(sessionID, paneID, prompt string, runner Tm
 				a.topologyMu.Unlock()
 				continue
 			}
-			if err := runner.SendInput(paneID, prompt, true); err != nil {
+			if err := a.budgetHold(sessionID, "", ""); err != nil {
+				log.Printf("Prompt for child session %s held: %v", sessionID, err)
+			} else if err := runner.SendInput(paneID, prompt, true); err != nil {
 				log.Printf("Failed to send prompt to ready child session %s: %v", sessionID, err)
 			}
 			a.topologyMu.Unlock()
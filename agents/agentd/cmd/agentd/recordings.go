@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/recording"
+)
+
+const recordingPruneInterval = time.Hour
+
+// newRecorder returns nil when recording is disabled or its directory is
+// unusable; the agent runs without recordings rather than refusing to start.
+func newRecorder(cfg *config.Config) *recording.Recorder {
+	if !cfg.Recording.Enabled {
+		return nil
+	}
+	recorder, err := recording.New(recording.Config{
+		Dir:             cfg.Recording.Dir,
+		SegmentBytes:    cfg.Recording.SegmentBytes,
+		MaxSessionBytes: cfg.Recording.MaxSessionBytes,
+	})
+	if err != nil {
+		log.Printf("Pane recording disabled: %v", err)
+		return nil
+	}
+	return recorder
+}
+
+// reconcileRecordings pipes every live tmux pane into the recorder and stops
+// recording sessions that have finished or disappeared. It runs after each
+// pane sync so new panes are picked up within one poll interval.
+func (a *Agent) reconcileRecordings() {
+	if a.recorder == nil || a.pipeMux == nil {
+		return
+	}
+	a.sessionsMu.RLock()
+	live := make(map[string]string, len(a.sessions))
+	sizes := make(map[string][2]int, len(a.sessions))
+	for _, session := range a.sessions {
+		if session.Kind == "tmux_pane" && session.PaneID != "" && session.Status != "DONE" {
+			live[session.ID] = session.PaneID
+			cols, rows := sessionPaneSize(session)
+			sizes[session.ID] = [2]int{cols, rows}
+		}
+	}
+	a.sessionsMu.RUnlock()
+
+	a.recordingMu.Lock()
+	defer a.recordingMu.Unlock()
+	if a.recordingPanes == nil {
+		a.recordingPanes = make(map[string]string)
+	}
+	for sessionID, paneID := range a.recordingPanes {
+		if live[sessionID] == paneID {
+			continue
+		}
+		_ = a.pipeMux.SetRecording(paneID, "", false)
+		delete(a.recordingPanes, sessionID)
+		if _, ok := live[sessionID]; !ok {
+			a.recorder.Stop(sessionID)
+		}
+	}
+	for sessionID, paneID := range live {
+		if _, ok := a.recordingPanes[sessionID]; ok {
+			continue
+		}
+		fifoPath, err := a.recorder.Start(sessionID)
+		if err != nil {
+			log.Printf("Failed to start recording for %s: %v", sessionID, err)
+			continue
+		}
+		if err := a.pipeMux.SetRecording(paneID, fifoPath, true); err != nil {
+			log.Printf("Failed to pipe %s into recording: %v", paneID, err)
+			a.recorder.Stop(sessionID)
+			continue
+		}
+		a.recordingPanes[sessionID] = paneID
+	}
+	// Pane size comes from the poll rather than viewer resize requests: a pane
+	// in a split window is smaller than the viewer, and output is rendered at
+	// the pane's size. Unchanged sizes are ignored by the recorder.
+	now := time.Now()
+	for sessionID := range a.recordingPanes {
+		if size := sizes[sessionID]; size[0] > 0 && size[1] > 0 {
+			if err := a.recorder.Resize(sessionID, now, size[0], size[1]); err != nil {
+				log.Printf("Failed to record pane size for %s: %v", sessionID, err)
+			}
+		}
+	}
+
+	if time.Since(a.lastRecordingPruneAt) > recordingPruneInterval {
+		a.lastRecordingPruneAt = time.Now()
+		cutoff := time.Now().Add(-time.Duration(a.cfg.Recording.RetentionHours) * time.Hour)
+		if err := a.recorder.PruneOlderThan(cutoff); err != nil {
+			log.Printf("Failed to prune recordings: %v", err)
+		}
+	}
+}
+
+func (a *Agent) stopRecordings() {
+	if a.recorder == nil {
+		return
+	}
+	a.recordingMu.Lock()
+	for sessionID, paneID := range a.recordingPanes {
+		if a.pipeMux != nil {
+			_ = a.pipeMux.SetRecording(paneID, "", false)
+		}
+		delete(a.recordingPanes, sessionID)
+	}
+	a.recordingMu.Unlock()
+	a.recorder.Close()
+}
+
+// executeReadRecording does not require a live session: recordings are most
+// useful after the pane and its scrollback are gone.
+func (a *Agent) executeReadRecording(sessionID string, payload json.RawMessage) (map[string]any, error) {
+	if a.recorder == nil {
+		return nil, commands.NewResultError("RECORDING_DISABLED", "pane recording is not enabled on this host")
+	}
+	var p protocol.ReadRecordingPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+	}
+	query := recording.Query{
+		StartOffset: p.StartOffset,
+		EndOffset:   p.EndOffset,
+		MaxBytes:    p.MaxBytes,
+	}
+	var err error
+	if query.Since, err = parseRecordingTime("since", p.Since); err != nil {
+		return nil, err
+	}
+	if query.Until, err = parseRecordingTime("until", p.Until); err != nil {
+		return nil, err
+	}
+
+	result, err := a.recorder.Read(sessionID, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, recording.ErrNotFound):
+			return nil, commands.NewResultError("RECORDING_NOT_FOUND", err.Error())
+		case errors.Is(err, recording.ErrInvalidRange):
+			return nil, commands.NewResultError("INVALID_RANGE", err.Error())
+		}
+		return nil, err
+	}
+
+	chunks := make([]protocol.RecordingChunk, 0, len(result.Chunks))
+	for _, chunk := range result.Chunks {
+		chunks = append(chunks, protocol.RecordingChunk{
+			At:     chunk.At.UTC().Format(time.RFC3339Nano),
+			Offset: chunk.Offset,
+			Data:   base64.StdEncoding.EncodeToString(chunk.Data),
+		})
+	}
+	response := map[string]any{
+		"chunks":                chunks,
+		"retained_start_offset": result.RetainedStart,
+		"retained_end_offset":   result.RetainedEnd,
+		"has_more":              result.HasMore,
+	}
+	if result.HasMore {
+		response["next_offset"] = result.NextOffset
+	}
+	return response, nil
+}
+
+func parseRecordingTime(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, commands.NewResultError("INVALID_RANGE", fmt.Sprintf("%s must be an RFC3339 timestamp", field))
+	}
+	return parsed, nil
+}
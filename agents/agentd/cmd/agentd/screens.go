@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/protocol"
+)
+
+// reconcileScreens pipes every live tmux pane into the screen tracker and
+// drops panes that have gone away, keeping each model at its pane's size.
+func (a *Agent) reconcileScreens() {
+	if a.screens == nil || a.pipeMux == nil {
+		return
+	}
+	a.sessionsMu.RLock()
+	live := make(map[string][2]int, len(a.sessions))
+	for _, session := range a.sessions {
+		if session.Kind == "tmux_pane" && session.PaneID != "" && session.Status != "DONE" {
+			cols, rows := sessionPaneSize(session)
+			live[session.PaneID] = [2]int{cols, rows}
+		}
+	}
+	a.sessionsMu.RUnlock()
+
+	a.screenMu.Lock()
+	defer a.screenMu.Unlock()
+	if a.screenPanes == nil {
+		a.screenPanes = make(map[string]bool)
+	}
+	for paneID := range a.screenPanes {
+		if _, ok := live[paneID]; ok {
+			continue
+		}
+		_ = a.pipeMux.SetScreen(paneID, "", false)
+		a.screens.Untrack(paneID)
+		delete(a.screenPanes, paneID)
+	}
+	for paneID, size := range live {
+		if !a.screenPanes[paneID] {
+			fifoPath, err := a.screens.Track(paneID)
+			if err != nil {
+				log.Printf("Failed to track screen for %s: %v", paneID, err)
+				continue
+			}
+			if err := a.pipeMux.SetScreen(paneID, fifoPath, true); err != nil {
+				log.Printf("Failed to pipe %s into screen tracker: %v", paneID, err)
+				a.screens.Untrack(paneID)
+				continue
+			}
+			a.screenPanes[paneID] = true
+		}
+		a.screens.Resize(paneID, size[0], size[1])
+	}
+}
+
+func (a *Agent) stopScreens() {
+	if a.screens == nil {
+		return
+	}
+	a.screenMu.Lock()
+	for paneID := range a.screenPanes {
+		if a.pipeMux != nil {
+			_ = a.pipeMux.SetScreen(paneID, "", false)
+		}
+		delete(a.screenPanes, paneID)
+	}
+	a.screenMu.Unlock()
+	a.screens.Close()
+}
+
+// sessionPaneSize returns the pane size recorded by the last poll, or zeros
+// when the session has no tmux metadata yet.
+func sessionPaneSize(session *SessionState) (cols, rows int) {
+	if tmuxMeta, ok := session.Metadata["tmux"].(map[string]any); ok {
+		cols, _ = tmuxMeta["pane_width"].(int)
+		rows, _ = tmuxMeta["pane_height"].(int)
+	}
+	return cols, rows
+}
+
+func (a *Agent) executeReadScreen(session *SessionState, payload json.RawMessage) (map[string]any, error) {
+	var p protocol.ReadScreenPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+	}
+	if a.screens == nil {
+		return nil, commands.NewResultError("SCREEN_UNAVAILABLE", "the terminal screen model is not enabled on this host")
+	}
+	screen := a.screens.Screen(session.PaneID)
+	if screen == nil {
+		return nil, commands.NewResultError("SCREEN_UNAVAILABLE", "the pane's screen is not tracked yet")
+	}
+	cols, rows := screen.Size()
+	cursorRow, cursorCol, cursorVisible := screen.Cursor()
+	result := map[string]any{
+		"pane_id":    session.PaneID,
+		"cols":       cols,
+		"rows":       rows,
+		"lines":      screen.Lines(),
+		"alt_screen": screen.AltScreen(),
+		"cursor": map[string]any{
+			"row":     cursorRow,
+			"col":     cursorCol,
+			"visible": cursorVisible,
+		},
+	}
+	if title := screen.Title(); title != "" {
+		result["title"] = title
+	}
+	if p.IncludeANSI {
+		result["ansi"] = base64.StdEncoding.EncodeToString(screen.Snapshot())
+	}
+	return result, nil
+}
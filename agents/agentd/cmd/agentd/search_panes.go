@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/tmux"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPaneSearchPageSize     = 100
+	maxPaneSearchPageSize         = 1000
+	maxPaneSearchContextLines     = 20
+	maxPaneSearchMatches          = 10_000
+	maxPaneSearchPatternBytes     = 4096
+	paneSearchConcurrency         = 4
+	paneSearchIdleTTL             = 60 * time.Minute
+	maxPaneSearches               = 4
+	maxPaneSearchLineDisplayBytes = 4096
+)
+
+type paneSearchTarget struct {
+	sessionID string
+	paneID    string
+}
+
+type paneSearchResult struct {
+	id               string
+	matches          []protocol.SearchPaneMatch
+	sessionsSearched int
+	truncated        bool
+	errors           map[string]string
+	lastAccess       time.Time
+}
+
+// paneSearchCache keeps completed searches so later pages are served from the
+// same point-in-time result instead of re-reading every pane's history.
+type paneSearchCache struct {
+	mu      sync.Mutex
+	entries map[string]*paneSearchResult
+	now     func() time.Time
+}
+
+func (a *Agent) executeSearchPanes(payload json.RawMessage) (map[string]any, error) {
+	var p protocol.SearchPanesPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	if p.PageSize == 0 {
+		p.PageSize = defaultPaneSearchPageSize
+	}
+	if p.PageSize < 1 || p.PageSize > maxPaneSearchPageSize {
+		return nil, fmt.Errorf("page_size must be between 1 and %d", maxPaneSearchPageSize)
+	}
+
+	if p.SearchID != "" {
+		if p.AfterMatch == nil {
+			return nil, fmt.Errorf("after_match is required with search_id")
+		}
+		return a.paneSearches.page(p.SearchID, *p.AfterMatch, p.PageSize)
+	}
+	if p.AfterMatch != nil {
+		return nil, fmt.Errorf("search_id is required with after_match")
+	}
+
+	matcher, err := compilePaneSearchPattern(p.Pattern, p.Literal, p.IgnoreCase)
+	if err != nil {
+		return nil, err
+	}
+	if p.ContextLines < 0 || p.ContextLines > maxPaneSearchContextLines {
+		return nil, fmt.Errorf("context_lines must be between 0 and %d", maxPaneSearchContextLines)
+	}
+
+	targets := a.paneSearchTargets(p)
+	matches, truncated, searchErrors := a.searchPaneTargets(targets, matcher, p.ContextLines)
+	search := a.paneSearches.store(matches, len(targets), truncated, searchErrors)
+	return a.paneSearches.page(search.id, 0, p.PageSize)
+}
+
+func compilePaneSearchPattern(pattern string, literal, ignoreCase bool) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	if len(pattern) > maxPaneSearchPatternBytes {
+		return nil, fmt.Errorf("pattern exceeds %d bytes", maxPaneSearchPatternBytes)
+	}
+	if literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	matcher, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return matcher, nil
+}
+
+// paneSearchTargets resolves the session filter against live panes. Filters are
+// combined; an empty filter selects every pane agentd is tracking.
+func (a *Agent) paneSearchTargets(p protocol.SearchPanesPayload) []paneSearchTarget {
+	var wanted map[string]bool
+	if len(p.SessionIDs) > 0 {
+		wanted = make(map[string]bool, len(p.SessionIDs))
+		for _, sessionID := range p.SessionIDs {
+			wanted[sessionID] = true
+		}
+	}
+	provider := strings.TrimSpace(p.Provider)
+	groupID := strings.TrimSpace(p.GroupID)
+
+	a.sessionsMu.RLock()
+	targets := make([]paneSearchTarget, 0, len(a.sessions))
+	for id, session := range a.sessions {
+		if session.PaneID == "" || session.Status == "DONE" {
+			continue
+		}
+		if wanted != nil && !wanted[id] {
+			continue
+		}
+		if groupID != "" && session.GroupID != groupID {
+			continue
+		}
+		if provider != "" && session.Provider != provider {
+			continue
+		}
+		targets = append(targets, paneSearchTarget{sessionID: id, paneID: session.PaneID})
+	}
+	a.sessionsMu.RUnlock()
+
+	sort.Slice(targets, func(i, j int) bool { return targets[i].sessionID < targets[j].sessionID })
+	return targets
+}
+
+// searchPaneTargets captures each pane's full history on a small worker pool
+// and returns matches ordered by session, then line. A pane that cannot be
+// captured is reported in the error map rather than failing the whole search.
+func (a *Agent) searchPaneTargets(
+	targets []paneSearchTarget,
+	matcher *regexp.Regexp,
+	contextLines int,
+) ([]protocol.SearchPaneMatch, bool, map[string]string) {
+	perTarget := make([][]protocol.SearchPaneMatch, len(targets))
+	targetErrors := make([]error, len(targets))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < min(paneSearchConcurrency, len(targets)); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range work {
+				target := targets[index]
+				content, err := a.tmuxClient.CapturePaneRange(target.paneID, tmux.CapturePaneOptions{
+					Mode:      tmux.CaptureModeFull,
+					StripANSI: true,
+				})
+				if err != nil {
+					targetErrors[index] = err
+					continue
+				}
+				perTarget[index] = matchPaneLines(target, splitCaptureSnapshotLines(content), matcher, contextLines)
+			}
+		}()
+	}
+	for index := range targets {
+		work <- index
+	}
+	close(work)
+	wg.Wait()
+
+	var matches []protocol.SearchPaneMatch
+	truncated := false
+	var searchErrors map[string]string
+	for index, targetMatches := range perTarget {
+		if targetErrors[index] != nil {
+			if searchErrors == nil {
+				searchErrors = make(map[string]string)
+			}
+			searchErrors[targets[index].sessionID] = targetErrors[index].Error()
+			continue
+		}
+		if remaining := maxPaneSearchMatches - len(matches); len(targetMatches) > remaining {
+			targetMatches = targetMatches[:remaining]
+			truncated = true
+		}
+		matches = append(matches, targetMatches...)
+	}
+	return matches, truncated, searchErrors
+}
+
+func matchPaneLines(
+	target paneSearchTarget,
+	lines []string,
+	matcher *regexp.Regexp,
+	contextLines int,
+) []protocol.SearchPaneMatch {
+	var matches []protocol.SearchPaneMatch
+	for index, line := range lines {
+		if !matcher.MatchString(line) {
+			continue
+		}
+		match := protocol.SearchPaneMatch{
+			SessionID:  target.sessionID,
+			PaneID:     target.paneID,
+			LineNumber: index + 1,
+			Line:       truncatePaneSearchLine(line),
+		}
+		if contextLines > 0 {
+			match.Before = truncatePaneSearchLines(lines[max(0, index-contextLines):index])
+			match.After = truncatePaneSearchLines(lines[index+1 : min(len(lines), index+1+contextLines)])
+		}
+		matches = append(matches, match)
+		if len(matches) >= maxPaneSearchMatches {
+			break
+		}
+	}
+	return matches
+}
+
+func truncatePaneSearchLines(lines []string) []string {
+	if len(lines) == 0 {
+		return nil
+	}
+	truncated := make([]string, len(lines))
+	for index, line := range lines {
+		truncated[index] = truncatePaneSearchLine(line)
+	}
+	return truncated
+}
+
+func truncatePaneSearchLine(line string) string {
+	if len(line) <= maxPaneSearchLineDisplayBytes {
+		return strings.Clone(line)
+	}
+	return strings.ToValidUTF8(line[:maxPaneSearchLineDisplayBytes], "")
+}
+
+func (c *paneSearchCache) store(
+	matches []protocol.SearchPaneMatch,
+	sessionsSearched int,
+	truncated bool,
+	searchErrors map[string]string,
+) *paneSearchResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*paneSearchResult)
+	}
+	now := c.currentTimeLocked()
+	c.pruneExpiredLocked(now)
+	for len(c.entries) >= maxPaneSearches {
+		oldestID := ""
+		var oldestAccess time.Time
+		for searchID, search := range c.entries {
+			if oldestID == "" || search.lastAccess.Before(oldestAccess) {
+				oldestID = searchID
+				oldestAccess = search.lastAccess
+			}
+		}
+		delete(c.entries, oldestID)
+	}
+	search := &paneSearchResult{
+		id:               uuid.NewString(),
+		matches:          matches,
+		sessionsSearched: sessionsSearched,
+		truncated:        truncated,
+		errors:           searchErrors,
+		lastAccess:       now,
+	}
+	c.entries[search.id] = search
+	return search
+}
+
+func (c *paneSearchCache) page(searchID string, afterMatch, pageSize int) (map[string]any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.currentTimeLocked()
+	c.pruneExpiredLocked(now)
+	search := c.entries[searchID]
+	if search == nil {
+		return nil, commands.NewResultError(
+			"SEARCH_EXPIRED",
+			"pane search expired; run the search again",
+		)
+	}
+	if afterMatch < 0 || afterMatch > len(search.matches) {
+		return nil, fmt.Errorf("after_match must be between 0 and %d", len(search.matches))
+	}
+	search.lastAccess = now
+
+	end := min(len(search.matches), afterMatch+pageSize)
+	matches := search.matches[afterMatch:end]
+	if matches == nil {
+		matches = []protocol.SearchPaneMatch{}
+	}
+	result := map[string]any{
+		"search_id":         search.id,
+		"matches":           matches,
+		"range_start":       afterMatch,
+		"range_end":         end,
+		"total_matches":     len(search.matches),
+		"sessions_searched": search.sessionsSearched,
+		"truncated":         search.truncated,
+		"has_more":          end < len(search.matches),
+	}
+	if end < len(search.matches) {
+		result["next_after"] = end
+	}
+	if len(search.errors) > 0 {
+		result["errors"] = search.errors
+	}
+	return result, nil
+}
+
+func (c *paneSearchCache) currentTimeLocked() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *paneSearchCache) pruneExpiredLocked(now time.Time) {
+	for searchID, search := range c.entries {
+		if now.Sub(search.lastAccess) >= paneSearchIdleTTL {
+			delete(c.entries, searchID)
+		}
+	}
+}
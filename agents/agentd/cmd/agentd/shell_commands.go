@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/shellmarks"
+	"github.com/agent-command/agentd/internal/tmux"
+)
+
+const shellCommandEventType = "shell.command"
+
+// shellPane follows the OSC 133 marks of one shell session's pane.
+type shellPane struct {
+	paneID  string
+	history *shellmarks.History
+	tap     *tmux.PaneTap
+}
+
+// reconcileShellCommands taps the output of every live shell pane for command
+// marks. Only agentd-owned bash panes emit them; other shells simply never
+// report a command, and deriveStatus keeps using its output heuristic.
+func (a *Agent) reconcileShellCommands() {
+	if a.cfg == nil || !a.cfg.Tmux.CommandMarks || a.pipeMux == nil {
+		return
+	}
+	a.sessionsMu.RLock()
+	live := make(map[string]string, len(a.sessions))
+	for _, session := range a.sessions {
+		if session.Kind == "tmux_pane" && session.PaneID != "" && session.Status != "DONE" && session.Provider == "shell" {
+			live[session.ID] = session.PaneID
+		}
+	}
+	a.sessionsMu.RUnlock()
+
+	a.shellMu.Lock()
+	defer a.shellMu.Unlock()
+	if a.shellPanes == nil {
+		a.shellPanes = make(map[string]*shellPane)
+	}
+	for sessionID, pane := range a.shellPanes {
+		if live[sessionID] == pane.paneID {
+			continue
+		}
+		_ = a.pipeMux.SetCommandMarks(pane.paneID, "", false)
+		pane.tap.Close()
+		delete(a.shellPanes, sessionID)
+	}
+	dir := filepath.Join(a.cfg.Storage.StateDir, "marks")
+	for sessionID, paneID := range live {
+		if _, ok := a.shellPanes[sessionID]; ok {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			log.Printf("Failed to create command mark dir: %v", err)
+			return
+		}
+		pane := &shellPane{paneID: paneID, history: shellmarks.NewHistory(shellmarks.DefaultHistoryLimit)}
+		sessionID := sessionID
+		tap, err := tmux.NewPaneTap(filepath.Join(dir, "pane_"+strings.TrimPrefix(paneID, "%")+".marks"), func(data []byte) {
+			a.handleShellOutput(sessionID, pane, data)
+		})
+		if err != nil {
+			log.Printf("Failed to tap %s for command marks: %v", paneID, err)
+			continue
+		}
+		if err := a.pipeMux.SetCommandMarks(paneID, tap.Path(), true); err != nil {
+			log.Printf("Failed to pipe %s into command marks: %v", paneID, err)
+			tap.Close()
+			continue
+		}
+		pane.tap = tap
+		a.shellPanes[sessionID] = pane
+	}
+}
+
+func (a *Agent) stopShellCommands() {
+	a.shellMu.Lock()
+	defer a.shellMu.Unlock()
+	for sessionID, pane := range a.shellPanes {
+		if a.pipeMux != nil {
+			_ = a.pipeMux.SetCommandMarks(pane.paneID, "", false)
+		}
+		pane.tap.Close()
+		delete(a.shellPanes, sessionID)
+	}
+}
+
+func (a *Agent) handleShellOutput(sessionID string, pane *shellPane, data []byte) {
+	now := time.Now().UTC()
+	for _, command := range pane.history.Write(now, data) {
+		payload := shellCommandPayload(command, now)
+		payload["pane_id"] = pane.paneID
+		a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
+			SessionID: sessionID,
+			EventType: shellCommandEventType,
+			Payload:   payload,
+		})
+	}
+}
+
+// shellCommandState reports what a tracked shell is doing, or StateUnknown
+// when its pane has produced no marks.
+func (a *Agent) shellCommandState(sessionID string) shellmarks.State {
+	a.shellMu.Lock()
+	pane := a.shellPanes[sessionID]
+	a.shellMu.Unlock()
+	if pane == nil {
+		return shellmarks.StateUnknown
+	}
+	return pane.history.State()
+}
+
+func (a *Agent) executeListCommands(session *SessionState, payload json.RawMessage) (map[string]any, error) {
+	var p protocol.ListCommandsPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+	}
+	a.shellMu.Lock()
+	pane := a.shellPanes[session.ID]
+	a.shellMu.Unlock()
+	if pane == nil {
+		return nil, commands.NewResultError("COMMAND_MARKS_UNAVAILABLE", "command tracking is not active for this session")
+	}
+	now := time.Now().UTC()
+	history := pane.history.Commands(p.Limit)
+	entries := make([]map[string]any, 0, len(history))
+	for _, command := range history {
+		entries = append(entries, shellCommandPayload(command, now))
+	}
+	state := string(pane.history.State())
+	if state == "" {
+		state = "unknown"
+	}
+	return map[string]any{
+		"commands": entries,
+		"state":    state,
+	}, nil
+}
+
+func shellCommandPayload(command shellmarks.Command, now time.Time) map[string]any {
+	payload := map[string]any{
+		"seq":         command.Seq,
+		"command":     command.Command,
+		"state":       "running",
+		"started_at":  command.StartedAt.UTC().Format(time.RFC3339Nano),
+		"duration_ms": command.Duration(now).Milliseconds(),
+	}
+	if !command.Running() {
+		payload["state"] = "finished"
+		payload["finished_at"] = command.FinishedAt.UTC().Format(time.RFC3339Nano)
+		if command.ExitCode != nil {
+			payload["exit_code"] = *command.ExitCode
+		}
+	}
+	return payload
+}
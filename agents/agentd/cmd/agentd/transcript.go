@@ -39,17 +39,37 @@ func (a *Agent) executeCaptureTranscriptCommand(session *SessionState, payload j
 	if request.BeforeEntry != nil && *request.BeforeEntry < 0 {
 		return nil, fmt.Errorf("before_entry must be nonnegative")
 	}
+	switch request.Format {
+	case "", protocol.TranscriptFormatRaw, protocol.TranscriptFormatNormalized:
+	default:
+		return nil, fmt.Errorf("format must be %q or %q", protocol.TranscriptFormatRaw, protocol.TranscriptFormatNormalized)
+	}
 
-	transcriptPath, source, err := a.resolveTranscriptPath(session)
+	unavailable := "Claude transcript is unavailable"
+	resolve := a.resolveTranscriptPath
+	if session.Provider == "codex" {
+		unavailable = "Codex transcript is unavailable"
+		resolve = a.resolveCodexRolloutPath
+	}
+	transcriptPath, source, err := resolve(session)
 	if err != nil {
-		return nil, commands.NewResultError("no_transcript", "Claude transcript is unavailable")
+		return nil, commands.NewResultError("no_transcript", unavailable)
 	}
 	entries, firstEntry, totalEntries, err := readTranscriptPage(transcriptPath, request)
 	if err != nil {
 		if errors.Is(err, errInvalidTranscriptRequest) {
 			return nil, err
 		}
-		return nil, commands.NewResultError("no_transcript", "Claude transcript is unavailable")
+		return nil, commands.NewResultError("no_transcript", unavailable)
+	}
+	if request.Format == protocol.TranscriptFormatNormalized {
+		return map[string]any{
+			"entries":       normalizeTranscriptPage(session.Provider, entries, firstEntry, request.IncludeRaw),
+			"first_entry":   firstEntry,
+			"total_entries": totalEntries,
+			"source":        source,
+			"format":        protocol.TranscriptFormatNormalized,
+		}, nil
 	}
 	return map[string]any{
 		"entries":       entries,
@@ -126,8 +146,8 @@ func claudeProjectSlug(cwd string) string {
 	}, cwd)
 }
 
-func resolveTranscriptFile(projectsRoot, candidate string) (string, error) {
-	root, err := filepath.EvalSymlinks(projectsRoot)
+func resolveTranscriptFile(transcriptRoot, candidate string) (string, error) {
+	root, err := filepath.EvalSymlinks(transcriptRoot)
 	if err != nil {
 		return "", err
 	}
@@ -137,7 +157,7 @@ func resolveTranscriptFile(projectsRoot, candidate string) (string, error) {
 	}
 	relative, err := filepath.Rel(root, resolved)
 	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
-		return "", fmt.Errorf("transcript path is outside Claude projects")
+		return "", fmt.Errorf("transcript path is outside %s", transcriptRoot)
 	}
 	info, err := os.Stat(resolved)
 	if err != nil {
@@ -179,7 +199,7 @@ func readTranscriptPage(path string, request protocol.CaptureTranscriptPayload)
 		if entryIndex >= firstEntry && entryIndex < endEntry {
 			var entry map[string]any
 			if err := json.Unmarshal([]byte(line), &entry); err != nil {
-				// Claude appends to these files live; a torn or corrupt line
+				// Providers append to these files live; a torn or corrupt line
 				// must not fail the page. The stub renders as nothing.
 				entries = append(entries, map[string]any{"type": "x-unparseable"})
 			} else {
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/agent-command/agentd/internal/protocol"
+)
+
+// transcriptAdapter turns one sanitized provider record into a
+// TranscriptEntry. Adapters see a page's records in order and may carry
+// state between them, such as the model named by a Codex turn_context.
+type transcriptAdapter interface {
+	normalize(entry map[string]any) protocol.TranscriptEntry
+}
+
+func newTranscriptAdapter(provider string) transcriptAdapter {
+	if provider == "codex" {
+		return &codexTranscriptAdapter{}
+	}
+	return claudeTranscriptAdapter{}
+}
+
+// normalizeTranscriptPage converts a page of sanitized records. Each record
+// yields exactly one entry so indices match the raw format, and tool
+// results are copied onto their calls when both are on the page.
+func normalizeTranscriptPage(provider string, entries []map[string]any, firstEntry int, includeRaw bool) []protocol.TranscriptEntry {
+	adapter := newTranscriptAdapter(provider)
+	normalized := make([]protocol.TranscriptEntry, 0, len(entries))
+	type callRef struct{ entry, call int }
+	calls := make(map[string]callRef)
+	for i, raw := range entries {
+		var entry protocol.TranscriptEntry
+		if recordType, _ := raw["type"].(string); recordType == "x-unparseable" {
+			entry = protocol.TranscriptEntry{Kind: "unparseable"}
+		} else {
+			entry = adapter.normalize(raw)
+		}
+		entry.Index = firstEntry + i
+		if includeRaw {
+			entry.Raw = raw
+		}
+
+		for j := range entry.ToolCalls {
+			call := &entry.ToolCalls[j]
+			if call.ID == "" {
+				continue
+			}
+			if entry.Kind != "tool_result" {
+				calls[call.ID] = callRef{entry: len(normalized), call: j}
+				continue
+			}
+			ref, ok := calls[call.ID]
+			if !ok {
+				continue
+			}
+			origin := &normalized[ref.entry].ToolCalls[ref.call]
+			if call.Name == "" {
+				call.Name = origin.Name
+			}
+			origin.Output = call.Output
+			origin.IsError = call.IsError
+		}
+		normalized = append(normalized, entry)
+	}
+	return normalized
+}
+
+// claudeTranscriptAdapter reads Claude Code project JSONL records:
+// {"type":"user|assistant|system|summary","message":{"role","model",
+// "content","usage"},"timestamp"}.
+type claudeTranscriptAdapter struct{}
+
+func (claudeTranscriptAdapter) normalize(record map[string]any) protocol.TranscriptEntry {
+	recordType, _ := record["type"].(string)
+	entry := protocol.TranscriptEntry{Timestamp: transcriptString(record, "timestamp")}
+	message, ok := record["message"].(map[string]any)
+	if !ok {
+		entry.Kind = "event"
+		entry.Event = recordType
+		if summary := transcriptString(record, "summary"); summary != "" {
+			entry.Text = []protocol.TranscriptText{{Type: "text", Text: summary}}
+		} else if content := transcriptString(record, "content"); content != "" {
+			entry.Text = []protocol.TranscriptText{{Type: "text", Text: content}}
+		}
+		return entry
+	}
+
+	entry.Kind = "message"
+	entry.Role = transcriptString(message, "role")
+	if entry.Role == "" {
+		entry.Role = recordType
+	}
+	entry.Model = transcriptString(message, "model")
+	entry.Usage = claudeTranscriptUsage(message["usage"])
+
+	results := 0
+	switch content := message["content"].(type) {
+	case string:
+		entry.Text = appendTranscriptText(entry.Text, "text", content)
+	case []any:
+		for _, item := range content {
+			block, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			switch transcriptString(block, "type") {
+			case "text":
+				entry.Text = appendTranscriptText(entry.Text, "text", transcriptString(block, "text"))
+			case "thinking":
+				entry.Text = appendTranscriptText(entry.Text, "thinking", transcriptString(block, "thinking"))
+			case "tool_use":
+				input, _ := block["input"].(map[string]any)
+				entry.ToolCalls = append(entry.ToolCalls, protocol.TranscriptToolCall{
+					ID:    transcriptString(block, "id"),
+					Name:  transcriptString(block, "name"),
+					Input: input,
+				})
+			case "tool_result":
+				results++
+				isError, _ := block["is_error"].(bool)
+				output := transcriptContentText(block["content"])
+				entry.ToolCalls = append(entry.ToolCalls, protocol.TranscriptToolCall{
+					ID:      transcriptString(block, "tool_use_id"),
+					Output:  &output,
+					IsError: isError,
+				})
+			}
+		}
+	}
+	// Claude returns tool results in user turns; a turn holding only
+	// results is the tool speaking, not the user.
+	if results > 0 && results == len(entry.ToolCalls) && len(entry.Text) == 0 {
+		entry.Kind = "tool_result"
+		entry.Role = "tool"
+	}
+	return entry
+}
+
+func claudeTranscriptUsage(value any) *protocol.TranscriptUsage {
+	usage, ok := value.(map[string]any)
+	if !ok {
+		return nil
+	}
+	normalized := &protocol.TranscriptUsage{
+		InputTokens:         transcriptInt(usage, "input_tokens"),
+		OutputTokens:        transcriptInt(usage, "output_tokens"),
+		CacheReadTokens:     transcriptInt(usage, "cache_read_input_tokens"),
+		CacheCreationTokens: transcriptInt(usage, "cache_creation_input_tokens"),
+	}
+	if *normalized == (protocol.TranscriptUsage{}) {
+		return nil
+	}
+	return normalized
+}
+
+// codexTranscriptAdapter reads Codex rollout records:
+// {"timestamp","type":"session_meta|turn_context|response_item|event_msg",
+// "payload":{...}}. Codex names the model per turn rather than per message.
+type codexTranscriptAdapter struct {
+	model string
+}
+
+func (c *codexTranscriptAdapter) normalize(record map[string]any) protocol.TranscriptEntry {
+	recordType, _ := record["type"].(string)
+	payload, _ := record["payload"].(map[string]any)
+	entry := protocol.TranscriptEntry{Timestamp: transcriptString(record, "timestamp"), Kind: "event", Event: recordType}
+
+	switch recordType {
+	case "turn_context":
+		if model := transcriptString(payload, "model"); model != "" {
+			c.model = model
+		}
+		entry.Model = c.model
+	case "event_msg":
+		eventType := transcriptString(payload, "type")
+		entry.Event = eventType
+		switch eventType {
+		case "token_count":
+			info, _ := payload["info"].(map[string]any)
+			entry.Usage = codexTranscriptUsage(info["last_token_usage"])
+		case "agent_message", "user_message":
+			entry.Text = appendTranscriptText(nil, "text", transcriptString(payload, "message"))
+		}
+	case "response_item":
+		c.normalizeResponseItem(payload, &entry)
+	}
+	return entry
+}
+
+func (c *codexTranscriptAdapter) normalizeResponseItem(item map[string]any, entry *protocol.TranscriptEntry) {
+	itemType := transcriptString(item, "type")
+	entry.Kind = "message"
+	entry.Event = ""
+	entry.Role = "assistant"
+	entry.Model = c.model
+	switch itemType {
+	case "message":
+		entry.Role = transcriptString(item, "role")
+		if entry.Role == "developer" {
+			entry.Role = "system"
+		}
+		if entry.Role != "assistant" {
+			entry.Model = ""
+		}
+		if content, ok := item["content"].([]any); ok {
+			for _, part := range content {
+				if block, ok := part.(map[string]any); ok {
+					entry.Text = appendTranscriptText(entry.Text, "text", transcriptString(block, "text"))
+				}
+			}
+		}
+	case "reasoning":
+		if summary, ok := item["summary"].([]any); ok {
+			for _, part := range summary {
+				if block, ok := part.(map[string]any); ok {
+					entry.Text = appendTranscriptText(entry.Text, "thinking", transcriptString(block, "text"))
+				}
+			}
+		}
+	case "function_call", "custom_tool_call":
+		call := protocol.TranscriptToolCall{ID: transcriptString(item, "call_id"), Name: transcriptString(item, "name")}
+		arguments := transcriptString(item, "arguments")
+		if arguments == "" {
+			arguments = transcriptString(item, "input")
+		}
+		var input map[string]any
+		if json.Unmarshal([]byte(arguments), &input) == nil {
+			call.Input = input
+		} else if arguments != "" {
+			call.Input = map[string]any{"input": arguments}
+		}
+		entry.ToolCalls = []protocol.TranscriptToolCall{call}
+	case "local_shell_call":
+		call := protocol.TranscriptToolCall{ID: transcriptString(item, "call_id"), Name: "shell"}
+		if action, ok := item["action"].(map[string]any); ok {
+			call.Input = action
+		}
+		entry.ToolCalls = []protocol.TranscriptToolCall{call}
+	case "function_call_output", "custom_tool_call_output":
+		entry.Kind = "tool_result"
+		entry.Role = "tool"
+		entry.Model = ""
+		output, isError := codexToolOutput(item["output"])
+		entry.ToolCalls = []protocol.TranscriptToolCall{{ID: transcriptString(item, "call_id"), Output: &output, IsError: isError}}
+	default:
+		entry.Kind = "event"
+		entry.Event = itemType
+		entry.Role = ""
+		entry.Model = ""
+	}
+}
+
+// codexToolOutput unwraps function_call_output, which is either plain text
+// or a JSON string {"output": "...", "metadata": {"exit_code": N}}.
+func codexToolOutput(value any) (string, bool) {
+	var text string
+	switch typed := value.(type) {
+	case string:
+		text = typed
+	case map[string]any:
+		text = transcriptString(typed, "content")
+	}
+	var wrapped struct {
+		Output   *string `json:"output"`
+		Metadata struct {
+			ExitCode *int `json:"exit_code"`
+		} `json:"metadata"`
+	}
+	if json.Unmarshal([]byte(text), &wrapped) == nil && wrapped.Output != nil {
+		return *wrapped.Output, wrapped.Metadata.ExitCode != nil && *wrapped.Metadata.ExitCode != 0
+	}
+	return text, false
+}
+
+func codexTranscriptUsage(value any) *protocol.TranscriptUsage {
+	usage, ok := value.(map[string]any)
+	if !ok {
+		return nil
+	}
+	normalized := &protocol.TranscriptUsage{
+		InputTokens:     transcriptInt(usage, "input_tokens"),
+		OutputTokens:    transcriptInt(usage, "output_tokens"),
+		CacheReadTokens: transcriptInt(usage, "cached_input_tokens"),
+		ReasoningTokens: transcriptInt(usage, "reasoning_output_tokens"),
+	}
+	if *normalized == (protocol.TranscriptUsage{}) {
+		return nil
+	}
+	return normalized
+}
+
+func appendTranscriptText(blocks []protocol.TranscriptText, blockType, text string) []protocol.TranscriptText {
+	if strings.TrimSpace(text) == "" {
+		return blocks
+	}
+	return append(blocks, protocol.TranscriptText{Type: blockType, Text: text})
+}
+
+// transcriptContentText flattens a tool_result content value, a string or a
+// list of text blocks, to text.
+func transcriptContentText(value any) string {
+	switch typed := value.(type) {
+	case string:
+		return typed
+	case []any:
+		var parts []string
+		for _, item := range typed {
+			if block, ok := item.(map[string]any); ok && transcriptString(block, "type") == "text" {
+				parts = append(parts, transcriptString(block, "text"))
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+func transcriptString(value map[string]any, key string) string {
+	text, _ := value[key].(string)
+	return text
+}
+
+func transcriptInt(value map[string]any, key string) int64 {
+	number, _ := value[key].(float64)
+	return int64(number)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/usage"
+)
+
+const (
+	// transcriptUsageResolveInterval is how often a transcript that was not
+	// found is looked up again.
+	transcriptUsageResolveInterval = 30 * time.Second
+	// transcriptUsageReadLimit caps how much one poll reads, so a long
+	// transcript is caught up over several polls.
+	transcriptUsageReadLimit = 16 * 1024 * 1024
+)
+
+// transcriptUsageTail follows one session's transcript from where the last
+// poll stopped reading.
+type transcriptUsageTail struct {
+	provider   string
+	retained   string
+	path       string
+	resolvedAt time.Time
+	offset     int64
+	partial    []byte
+	usage      *usage.TranscriptUsage
+	// backlog is set for sessions that were running when agentd started,
+	// until their transcript has been read to the end: that usage was
+	// counted toward group and weekly totals by the agentd run before.
+	backlog bool
+}
+
+// tailTranscriptUsage reads usage from the transcripts of Claude Code and
+// Codex sessions, which report exact token counts where the screen only
+// shows what the CLI chooses to print. Screen parsing still covers sessions
+// without a readable transcript.
+func (a *Agent) tailTranscriptUsage() {
+	ticker := time.NewTicker(time.Duration(a.cfg.Providers.TranscriptUsage.IntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	tails := make(map[string]*transcriptUsageTail)
+	startup := true
+	for now := range ticker.C {
+		a.pollTranscriptUsage(tails, startup, now)
+		startup = false
+	}
+}
+
+func (a *Agent) pollTranscriptUsage(tails map[string]*transcriptUsageTail, startup bool, now time.Time) {
+	a.sessionsMu.RLock()
+	sessions := make([]*SessionState, 0, len(a.sessions))
+	for _, s := range a.sessions {
+		if s.Kind == "tmux_pane" && s.Status != "DONE" && (s.Provider == "claude_code" || s.Provider == "codex") {
+			sessions = append(sessions, s)
+		}
+	}
+	a.sessionsMu.RUnlock()
+
+	active := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		active[session.ID] = true
+		tail := tails[session.ID]
+		if tail == nil {
+			tail = &transcriptUsageTail{provider: session.Provider, backlog: startup}
+			tails[session.ID] = tail
+		}
+		a.readTranscriptUsage(session, tail, now)
+	}
+	for id := range tails {
+		if !active[id] {
+			delete(tails, id)
+		}
+	}
+}
+
+func (a *Agent) readTranscriptUsage(session *SessionState, tail *transcriptUsageTail, now time.Time) {
+	// A hook reporting a new transcript, as after /clear, is picked up at
+	// once. Otherwise the session keeps the first transcript found: without
+	// a hook the lookup picks the newest transcript for the cwd, which can
+	// be another session's, and switching would count a whole transcript
+	// again. Lookups that found nothing are retried less often since they
+	// scan the disk.
+	retained := a.transcriptPathForSession(session.ID)
+	if tail.resolvedAt.IsZero() || retained != tail.retained ||
+		(tail.path == "" && now.Sub(tail.resolvedAt) >= transcriptUsageResolveInterval) {
+		tail.retained = retained
+		tail.resolvedAt = now
+		resolve := a.resolveTranscriptPath
+		if session.Provider == "codex" {
+			resolve = a.resolveCodexRolloutPath
+		}
+		if path, _, err := resolve(session); err == nil {
+			if path != tail.path {
+				if tail.path != "" {
+					tail.backlog = false
+				}
+				tail.path = path
+				tail.restart()
+			}
+		}
+	}
+	if tail.path == "" {
+		tail.backlog = false
+		return
+	}
+
+	changed, atEnd, err := tail.read()
+	if errors.Is(err, os.ErrNotExist) {
+		tail.path = ""
+		return
+	}
+	backlog := tail.backlog
+	if atEnd {
+		tail.backlog = false
+	}
+	if err != nil || !changed {
+		return
+	}
+	a.recordSessionModel(session.ID, tail.usage.Model())
+	current := tail.usage.Usage()
+	if current == nil {
+		return
+	}
+	if merged := a.usageTracker.RecordTranscriptUsage(session.ID, session.Provider, current, tail.usage.Tokens(), backlog); merged != nil {
+		a.send(protocol.TypeSessionUsage, sessionUsagePayload(session.ID, merged))
+	}
+}
+
+func (t *transcriptUsageTail) restart() {
+	t.offset = 0
+	t.partial = nil
+	t.usage = usage.NewTranscriptUsage(t.provider)
+}
+
+// read adds the records appended since the last read and reports whether
+// any changed usage and whether it reached the end of the transcript. A
+// transcript that shrank was rewritten and is read again from the start.
+func (t *transcriptUsageTail) read() (changed, atEnd bool, err error) {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return false, false, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return false, false, err
+	}
+	if info.Size() < t.offset {
+		t.restart()
+	}
+	if info.Size() == t.offset {
+		return false, true, nil
+	}
+	if _, err := file.Seek(t.offset, io.SeekStart); err != nil {
+		return false, false, err
+	}
+
+	reader := bufio.NewReader(io.LimitReader(file, transcriptUsageReadLimit))
+	for {
+		line, err := reader.ReadBytes('\n')
+		t.offset += int64(len(line))
+		if err != nil {
+			// Keep a record still being written for the next read, unless
+			// it is too long to be one worth reading.
+			t.partial = append(t.partial, line...)
+			if len(t.partial) > maxTranscriptScannerToken {
+				t.partial = nil
+			}
+			break
+		}
+		if len(t.partial) > 0 {
+			line = append(t.partial, line...)
+			t.partial = nil
+		}
+		if t.usage.Add(bytes.TrimSpace(line)) {
+			changed = true
+		}
+	}
+	return changed, t.offset >= info.Size(), nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/usage"
+)
+
+func newPriceTable(pricing config.PricingConfig) *usage.PriceTable {
+	models := make(map[string]usage.Price, len(pricing.Models))
+	for model, price := range pricing.Models {
+		models[model] = usage.Price{
+			Input:      price.Input,
+			Output:     price.Output,
+			CacheRead:  price.CacheRead,
+			CacheWrite: price.CacheWrite,
+		}
+	}
+	return usage.NewPriceTable(models, pricing.DefaultModels)
+}
+
+// sessionCostAttribution charges a session's spend to its group and to the
+// repository it works in, or its directory outside a repository.
+func (a *Agent) sessionCostAttribution(sessionID string) (string, string) {
+	a.sessionsMu.RLock()
+	defer a.sessionsMu.RUnlock()
+	session, ok := a.sessions[sessionID]
+	if !ok {
+		return "", ""
+	}
+	repo := session.RepoRoot
+	if repo == "" {
+		repo = session.CWD
+	}
+	return session.GroupID, repo
+}
+
+// recordSessionModel notes the model a session reported, so its tokens are
+// priced at that model's rate.
+func (a *Agent) recordSessionModel(sessionID, model string) {
+	if a.usageTracker != nil {
+		a.usageTracker.SetModel(sessionID, model)
+	}
+}
+
+// extractHookModel returns the model named in a hook payload, given either
+// as an ID or as an object carrying one.
+func extractHookModel(hookData map[string]any) string {
+	if model := extractHookString(hookData, "model", "model_id"); model != "" {
+		return model
+	}
+	if model, ok := hookData["model"].(map[string]any); ok {
+		return extractHookString(model, "id", "model_id")
+	}
+	return ""
+}
+
+func (a *Agent) executeGetUsageCosts() (map[string]any, error) {
+	report := usage.CostReport{Sessions: []usage.SessionCost{}, Groups: []usage.GroupCost{}, Weekly: []usage.WeeklyCost{}}
+	if a.usageTracker != nil {
+		report = a.usageTracker.Costs()
+	}
+	return map[string]any{
+		"sessions": report.Sessions,
+		"groups":   report.Groups,
+		"weekly":   report.Weekly,
+	}, nil
+}
+
+func runCostsCommand(args []string) {
+	fs := flag.NewFlagSet("costs", flag.ExitOnError)
+	configPath := fs.String("config", "/etc/agentd/config.yaml", "Path to config file")
+	weeks := fs.Int("weeks", 4, "Number of most recent weeks to show (0 for all)")
+	jsonOutput := fs.Bool("json", false, "Output in JSON format")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	entries, err := usage.ReadCostLedger(cfg.Storage.StateDir)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	entries = recentCostWeeks(entries, *weeks)
+
+	if *jsonOutput {
+		outputJSON(map[string]any{"weekly": entries})
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Println("No spend recorded. Costs are estimated from providers.pricing in the config.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WEEK\tREPO\tCOST (USD)")
+	for _, entry := range entries {
+		repo := entry.Repo
+		if repo == "" {
+			repo = "(unknown)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.2f\n", entry.Week, repo, entry.CostCents/100)
+	}
+	w.Flush()
+}
+
+// recentCostWeeks keeps the entries of the last n weeks that have spend,
+// most expensive repository first within a week.
+func recentCostWeeks(entries []usage.WeeklyCost, n int) []usage.WeeklyCost {
+	var weeks []string
+	for _, entry := range entries {
+		if len(weeks) == 0 || weeks[len(weeks)-1] != entry.Week {
+			weeks = append(weeks, entry.Week)
+		}
+	}
+	if n > 0 && len(weeks) > n {
+		oldest := weeks[len(weeks)-n]
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.Week >= oldest {
+				kept = append(kept, entry)
+			}
+		}
+		entries = kept
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Week != entries[j].Week {
+			return entries[i].Week < entries[j].Week
+		}
+		return entries[i].CostCents > entries[j].CostCents
+	})
+	return entries
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/agent-command/agentd/internal/commands"
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/protocol"
+	"github.com/agent-command/agentd/internal/providerusage"
+	"github.com/agent-command/agentd/internal/watch"
+)
+
+const watchMatchEventType = "watch.match"
+
+// newWatchEngine installs the config-declared watches. A bad rule is logged and
+// skipped so one typo does not take the daemon down.
+func newWatchEngine(cfg *config.Config) *watch.Engine {
+	engine := watch.NewEngine()
+	for _, declared := range cfg.Watches {
+		rule := watch.Rule{
+			ID:           declared.ID,
+			Pattern:      declared.Pattern,
+			Literal:      declared.Literal,
+			IgnoreCase:   declared.IgnoreCase,
+			GroupID:      declared.GroupID,
+			Provider:     declared.Provider,
+			ContextLines: declared.ContextLines,
+			CooldownMs:   declared.CooldownMs,
+			Actions: watch.Actions{
+				SetStatus: declared.SetStatus,
+				Notify:    declared.Notify,
+				SendKeys:  declared.SendKeys,
+			},
+			Source: watch.SourceConfig,
+		}
+		if err := validateWatchActions(cfg, rule.Actions); err != nil {
+			log.Printf("Skipping watch %q: %v", declared.ID, err)
+			continue
+		}
+		if _, err := engine.Add(rule); err != nil {
+			log.Printf("Skipping watch %q: %v", declared.ID, err)
+		}
+	}
+	return engine
+}
+
+func validateWatchActions(cfg *config.Config, actions watch.Actions) error {
+	switch actions.SetStatus {
+	case "", "RUNNING", "IDLE", "WAITING_FOR_INPUT", "ERROR":
+	default:
+		return fmt.Errorf("set_status must be RUNNING, IDLE, WAITING_FOR_INPUT, or ERROR")
+	}
+	if len(actions.SendKeys) > 0 && !cfg.Security.AllowSendInput {
+		return fmt.Errorf("send_keys not allowed by policy")
+	}
+	return nil
+}
+
+func (a *Agent) executeRegisterWatch(payload json.RawMessage) (map[string]any, error) {
+	if a.watches == nil {
+		return nil, fmt.Errorf("watches are not available")
+	}
+	var p protocol.RegisterWatchPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	rule := watch.Rule{
+		ID:           p.WatchID,
+		Pattern:      p.Pattern,
+		Literal:      p.Literal,
+		IgnoreCase:   p.IgnoreCase,
+		SessionIDs:   p.SessionIDs,
+		GroupID:      p.GroupID,
+		Provider:     p.Provider,
+		ContextLines: p.ContextLines,
+		CooldownMs:   p.CooldownMs,
+		Actions: watch.Actions{
+			SetStatus: p.SetStatus,
+			Notify:    p.Notify,
+			SendKeys:  p.SendKeys,
+		},
+		Source: watch.SourceCommand,
+	}
+	if err := validateWatchActions(a.cfg, rule.Actions); err != nil {
+		return nil, err
+	}
+	installed, err := a.watches.Add(rule)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"watch": installed}, nil
+}
+
+func (a *Agent) executeRemoveWatch(payload json.RawMessage) error {
+	if a.watches == nil {
+		return fmt.Errorf("watches are not available")
+	}
+	var p protocol.RemoveWatchPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return err
+	}
+	if err := a.watches.Remove(p.WatchID); err != nil {
+		if errors.Is(err, watch.ErrNotFound) {
+			return commands.NewResultError("WATCH_NOT_FOUND", err.Error())
+		}
+		return err
+	}
+	return nil
+}
+
+func (a *Agent) executeListWatches() (map[string]any, error) {
+	rules := []watch.Rule{}
+	if a.watches != nil {
+		rules = a.watches.Rules()
+	}
+	return map[string]any{"watches": rules}, nil
+}
+
+// evaluateWatches runs watch rules over a changed snapshot and emits one
+// watch.match event per match before applying the rule's actions.
+func (a *Agent) evaluateWatches(session *SessionState, text string) {
+	if a.watches == nil {
+		return
+	}
+	a.sessionsMu.RLock()
+	target := watch.Target{
+		SessionID: session.ID,
+		PaneID:    session.PaneID,
+		GroupID:   session.GroupID,
+		Provider:  session.Provider,
+	}
+	a.sessionsMu.RUnlock()
+
+	lines := splitCaptureSnapshotLines(providerusage.StripANSI(text))
+	for _, match := range a.watches.Evaluate(target, lines) {
+		a.send(protocol.TypeEventsAppend, protocol.EventsAppendPayload{
+			SessionID: match.SessionID,
+			EventType: watchMatchEventType,
+			Payload: map[string]any{
+				"watch_id":   match.Rule.ID,
+				"pattern":    match.Rule.Pattern,
+				"source":     match.Rule.Source,
+				"pane_id":    match.PaneID,
+				"line":       match.Line,
+				"before":     match.Before,
+				"after":      match.After,
+				"actions":    match.Rule.Actions,
+				"notify":     match.Rule.Actions.Notify,
+				"matched_at": match.MatchedAt.UTC().Format(time.RFC3339),
+			},
+		})
+		a.applyWatchActions(match)
+	}
+}
+
+func (a *Agent) applyWatchActions(match watch.Match) {
+	actions := match.Rule.Actions
+	if actions.SetStatus != "" {
+		detail := truncateSummary(fmt.Sprintf("Watch %s matched: %s", match.Rule.ID, strings.TrimSpace(match.Line)))
+		a.updateSessionFromHook(match.SessionID, actions.SetStatus, detail, nil)
+	}
+	if len(actions.SendKeys) > 0 && match.PaneID != "" {
+		if !a.cfg.Security.AllowSendInput {
+			log.Printf("Watch %s send_keys skipped: send_input not allowed by policy", match.Rule.ID)
+			return
+		}
+		if err := a.tmuxClient.SendKeys(match.PaneID, actions.SendKeys); err != nil {
+			log.Printf("Watch %s send_keys failed for %s: %v", match.Rule.ID, match.SessionID, err)
+		}
+	}
+}
@@ -0,0 +1,485 @@
+// Package budget enforces token and cost limits on what sessions spend.
+//
+// A budget caps one session, or a group of sessions or the whole host
+// together for a UTC day, in tokens or cents. Spend is fed in from the usage
+// tracker; the engine reports each budget whose soft threshold or hard limit
+// a report crosses, once per level, and answers which budgets a session is
+// over.
+package budget
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/agent-command/agentd/internal/usage"
+	"github.com/google/uuid"
+)
+
+const (
+	ScopeSession = "session"
+	ScopeGroup   = "group"
+	ScopeHost    = "host"
+
+	UnitTokens = "tokens"
+	UnitCents  = "cents"
+
+	// ActionInterrupt interrupts the sessions over budget and holds their
+	// prompts; ActionPause only holds prompts, letting the current turn
+	// finish.
+	ActionInterrupt = "interrupt"
+	ActionPause     = "pause"
+
+	SourceConfig  = "config"
+	SourceCommand = "command"
+
+	LevelSoft = "soft"
+	LevelHard = "hard"
+
+	// DefaultSoftPercent is the share of the limit that raises a warning
+	// when a budget does not set one.
+	DefaultSoftPercent = 80
+)
+
+const stateFile = "budgets.json"
+
+// saveDelay batches the state writes of spend reported in quick succession.
+const saveDelay = 5 * time.Second
+
+var (
+	ErrNotFound     = errors.New("budget not found")
+	ErrConfigBudget = errors.New("budget is defined in config and cannot be removed")
+)
+
+// Budget limits spend. A session budget applies to each session it selects
+// on its own: the one named by SessionID, those of GroupID, or every
+// session. A group budget applies to the sessions of GroupID together, or
+// to each group when GroupID is empty. A host budget applies to every
+// session on the host together. Group and host spend start again each UTC
+// day.
+type Budget struct {
+	ID          string  `json:"id"`
+	Scope       string  `json:"scope"`
+	SessionID   string  `json:"session_id,omitempty"`
+	GroupID     string  `json:"group_id,omitempty"`
+	Unit        string  `json:"unit"`
+	Limit       int64   `json:"limit"`
+	SoftPercent float64 `json:"soft_percent"`
+	Action      string  `json:"action"`
+	Source      string  `json:"source"`
+}
+
+// Usage is spend in both units.
+type Usage struct {
+	Tokens int64   `json:"tokens"`
+	Cents  float64 `json:"cents"`
+}
+
+// Crossing is a budget at or over one of its thresholds.
+type Crossing struct {
+	Budget Budget
+	Level  string
+	// Subject is what the budget was applied to: a session ID, a group ID
+	// or, for host budgets, the day.
+	Subject   string
+	Spent     float64
+	Threshold float64
+}
+
+// Report is the day's spend budgets are measured against, other than
+// sessions' own totals.
+type Report struct {
+	Day    string           `json:"day"`
+	Host   Usage            `json:"host"`
+	Groups map[string]Usage `json:"groups"`
+}
+
+type state struct {
+	Day     string           `json:"day"`
+	Host    Usage            `json:"host"`
+	Groups  map[string]Usage `json:"groups"`
+	Budgets []Budget         `json:"budgets"`
+}
+
+type Engine struct {
+	mu       sync.Mutex
+	budgets  map[string]Budget
+	sessions map[string]Usage
+	groups   map[string]Usage
+	day      string
+	host     Usage
+	// levels holds the level each budget last reached per subject, so a
+	// threshold is reported once per crossing.
+	levels map[string]string
+	path   string
+	now    func() time.Time
+	// saveTimer is set while a state write is pending; see Flush.
+	// saveMu orders the writes.
+	saveTimer *time.Timer
+	saveMu    sync.Mutex
+}
+
+func NewEngine() *Engine {
+	return &Engine{
+		budgets:  make(map[string]Budget),
+		sessions: make(map[string]Usage),
+		groups:   make(map[string]Usage),
+		levels:   make(map[string]string),
+		now:      time.Now,
+	}
+}
+
+// Open restores group and host spend and command-set budgets from
+// stateDir, and keeps them there from then on.
+func (e *Engine) Open(stateDir string) error {
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	path := filepath.Join(stateDir, stateFile)
+	var saved state
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to read budgets: %w", err)
+	default:
+		if err := json.Unmarshal(data, &saved); err != nil {
+			return fmt.Errorf("failed to parse budgets: %w", err)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.path = path
+	e.day, e.host = saved.Day, saved.Host
+	for group, spent := range saved.Groups {
+		e.groups[group] = spent
+	}
+	for _, budget := range saved.Budgets {
+		if existing, ok := e.budgets[budget.ID]; ok && existing.Source == SourceConfig {
+			continue
+		}
+		if budget, err := normalize(budget); err == nil {
+			e.budgets[budget.ID] = budget
+		}
+	}
+	e.rollDayLocked()
+	return nil
+}
+
+// Add validates and installs a budget, replacing any budget with the same
+// ID. A missing ID is generated.
+func (e *Engine) Add(budget Budget) (Budget, error) {
+	budget, err := normalize(budget)
+	if err != nil {
+		return Budget{}, err
+	}
+	e.mu.Lock()
+	if existing, ok := e.budgets[budget.ID]; ok && existing.Source == SourceConfig && budget.Source != SourceConfig {
+		e.mu.Unlock()
+		return Budget{}, fmt.Errorf("budget %q is defined in config", budget.ID)
+	}
+	e.budgets[budget.ID] = budget
+	e.clearLevelsLocked(budget.ID)
+	if budget.Source != SourceConfig {
+		e.saveLocked()
+	}
+	e.mu.Unlock()
+	e.Flush()
+	return budget, nil
+}
+
+// Remove deletes a command-set budget. Config budgets live as long as the
+// config file that declares them.
+func (e *Engine) Remove(id string) error {
+	e.mu.Lock()
+	existing, ok := e.budgets[id]
+	if !ok {
+		e.mu.Unlock()
+		return ErrNotFound
+	}
+	if existing.Source == SourceConfig {
+		e.mu.Unlock()
+		return ErrConfigBudget
+	}
+	delete(e.budgets, id)
+	e.clearLevelsLocked(id)
+	e.saveLocked()
+	e.mu.Unlock()
+	e.Flush()
+	return nil
+}
+
+// Budgets returns every installed budget ordered by ID.
+func (e *Engine) Budgets() []Budget {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.sortedLocked()
+}
+
+// Spend returns today's host spend and each group's.
+func (e *Engine) Spend() Report {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rollDayLocked()
+	groups := make(map[string]Usage, len(e.groups))
+	for group, spent := range e.groups {
+		groups[group] = spent
+	}
+	return Report{Day: e.day, Host: e.host, Groups: groups}
+}
+
+// Record adds a session's spend and returns the thresholds it crossed.
+// Backlog spend counts toward the session's own budgets only.
+func (e *Engine) Record(spend usage.Spend) []Crossing {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rollDayLocked()
+	e.sessions[spend.SessionID] = Usage{Tokens: int64(spend.SessionTokens), Cents: spend.SessionCents}
+	if !spend.Backlog {
+		delta := Usage{Tokens: int64(spend.Tokens), Cents: spend.Cents}
+		if spend.GroupID != "" {
+			e.groups[spend.GroupID] = e.groups[spend.GroupID].add(delta)
+		}
+		e.host = e.host.add(delta)
+		e.saveLocked()
+	}
+
+	var crossings []Crossing
+	for _, budget := range e.sortedLocked() {
+		subject, spent, ok := e.measureLocked(budget, spend.SessionID, spend.GroupID)
+		if !ok {
+			continue
+		}
+		key := budget.ID + "\x00" + subject
+		level, threshold := budget.level(spent)
+		previous := e.levels[key]
+		if level == previous {
+			continue
+		}
+		if level == "" {
+			delete(e.levels, key)
+		} else {
+			e.levels[key] = level
+		}
+		if rank(level) > rank(previous) {
+			crossings = append(crossings, Crossing{Budget: budget, Level: level, Subject: subject, Spent: spent, Threshold: threshold})
+		}
+	}
+	return crossings
+}
+
+// Exceeded returns the budgets a session in groupID is over the hard limit
+// of. sessionID may be empty to check a session not yet started.
+func (e *Engine) Exceeded(sessionID, groupID string) []Crossing {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rollDayLocked()
+	var exceeded []Crossing
+	for _, budget := range e.sortedLocked() {
+		subject, spent, ok := e.measureLocked(budget, sessionID, groupID)
+		if !ok {
+			continue
+		}
+		if level, threshold := budget.level(spent); level == LevelHard {
+			exceeded = append(exceeded, Crossing{Budget: budget, Level: level, Subject: subject, Spent: spent, Threshold: threshold})
+		}
+	}
+	return exceeded
+}
+
+// Forget drops a session's totals. Its spend stays in its group's
+// and the host's.
+func (e *Engine) Forget(sessionID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.sessions, sessionID)
+	for id := range e.budgets {
+		delete(e.levels, id+"\x00"+sessionID)
+	}
+}
+
+// Applies reports whether budget covers a session in groupID.
+func (b Budget) Applies(sessionID, groupID string) bool {
+	switch b.Scope {
+	case ScopeSession:
+		if b.SessionID != "" {
+			return sessionID == b.SessionID
+		}
+		return sessionID != "" && (b.GroupID == "" || groupID == b.GroupID)
+	case ScopeGroup:
+		return groupID != "" && (b.GroupID == "" || groupID == b.GroupID)
+	case ScopeHost:
+		return true
+	}
+	return false
+}
+
+func (b Budget) level(spent float64) (string, float64) {
+	limit := float64(b.Limit)
+	if spent >= limit {
+		return LevelHard, limit
+	}
+	if soft := limit * b.SoftPercent / 100; b.SoftPercent < 100 && spent >= soft {
+		return LevelSoft, soft
+	}
+	return "", 0
+}
+
+func (b Budget) amount(spent Usage) float64 {
+	if b.Unit == UnitTokens {
+		return float64(spent.Tokens)
+	}
+	return spent.Cents
+}
+
+func (e *Engine) measureLocked(budget Budget, sessionID, groupID string) (string, float64, bool) {
+	if !budget.Applies(sessionID, groupID) {
+		return "", 0, false
+	}
+	switch budget.Scope {
+	case ScopeSession:
+		return sessionID, budget.amount(e.sessions[sessionID]), true
+	case ScopeGroup:
+		return groupID, budget.amount(e.groups[groupID]), true
+	default:
+		return e.day, budget.amount(e.host), true
+	}
+}
+
+func (e *Engine) sortedLocked() []Budget {
+	budgets := make([]Budget, 0, len(e.budgets))
+	for _, budget := range e.budgets {
+		budgets = append(budgets, budget)
+	}
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].ID < budgets[j].ID })
+	return budgets
+}
+
+// rollDayLocked starts a new day of group and host spend when the UTC date
+// has changed. Group budgets keep their group as the subject, so their
+// levels are cleared to report the new day's crossings.
+func (e *Engine) rollDayLocked() {
+	day := e.now().UTC().Format("2006-01-02")
+	if day == e.day {
+		return
+	}
+	e.day = day
+	e.host = Usage{}
+	e.groups = make(map[string]Usage)
+	for id, budget := range e.budgets {
+		if budget.Scope == ScopeGroup {
+			e.clearLevelsLocked(id)
+		}
+	}
+}
+
+func (e *Engine) clearLevelsLocked(id string) {
+	for key := range e.levels {
+		if strings.HasPrefix(key, id+"\x00") {
+			delete(e.levels, key)
+		}
+	}
+}
+
+// saveLocked schedules a write of spend and command-set budgets, batching
+// the reports that follow within saveDelay.
+func (e *Engine) saveLocked() {
+	if e.path == "" || e.saveTimer != nil {
+		return
+	}
+	e.saveTimer = time.AfterFunc(saveDelay, e.Flush)
+}
+
+// Flush writes a pending save now. agentd calls it on shutdown. Losing the
+// file only loses spend history, so a failed write is not reported.
+func (e *Engine) Flush() {
+	e.saveMu.Lock()
+	defer e.saveMu.Unlock()
+	e.mu.Lock()
+	if e.saveTimer == nil {
+		e.mu.Unlock()
+		return
+	}
+	e.saveTimer.Stop()
+	e.saveTimer = nil
+	path := e.path
+	saved := state{Day: e.day, Host: e.host, Groups: e.groups, Budgets: []Budget{}}
+	for _, budget := range e.sortedLocked() {
+		if budget.Source != SourceConfig {
+			saved.Budgets = append(saved.Budgets, budget)
+		}
+	}
+	data, err := json.Marshal(saved)
+	e.mu.Unlock()
+	if err != nil {
+		return
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return
+	}
+	_ = os.Rename(tmpPath, path)
+}
+
+func (u Usage) add(other Usage) Usage {
+	return Usage{Tokens: u.Tokens + other.Tokens, Cents: u.Cents + other.Cents}
+}
+
+func rank(level string) int {
+	switch level {
+	case LevelSoft:
+		return 1
+	case LevelHard:
+		return 2
+	}
+	return 0
+}
+
+func normalize(budget Budget) (Budget, error) {
+	if budget.ID == "" {
+		budget.ID = uuid.NewString()
+	}
+	switch budget.Scope {
+	case ScopeSession:
+	case ScopeGroup:
+		if budget.SessionID != "" {
+			return Budget{}, fmt.Errorf("session_id only applies to session budgets")
+		}
+	case ScopeHost:
+		if budget.SessionID != "" || budget.GroupID != "" {
+			return Budget{}, fmt.Errorf("host budgets apply to every session")
+		}
+	default:
+		return Budget{}, fmt.Errorf("scope must be session, group or host")
+	}
+	if budget.Unit != UnitTokens && budget.Unit != UnitCents {
+		return Budget{}, fmt.Errorf("unit must be tokens or cents")
+	}
+	if budget.Limit <= 0 {
+		return Budget{}, fmt.Errorf("limit must be positive")
+	}
+	if budget.SoftPercent < 0 || budget.SoftPercent > 100 {
+		return Budget{}, fmt.Errorf("soft_percent must be between 0 and 100")
+	}
+	if budget.SoftPercent == 0 {
+		budget.SoftPercent = DefaultSoftPercent
+	}
+	switch budget.Action {
+	case "":
+		budget.Action = ActionInterrupt
+	case ActionInterrupt, ActionPause:
+	default:
+		return Budget{}, fmt.Errorf("action must be interrupt or pause")
+	}
+	if budget.Source == "" {
+		budget.Source = SourceCommand
+	}
+	return budget, nil
+}
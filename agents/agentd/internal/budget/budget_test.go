@@ -0,0 +1,155 @@
+package budget
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/agent-command/agentd/internal/usage"
+)
+
+func TestAddValidatesBudgets(t *testing.T) {
+	engine := NewEngine()
+	for _, invalid := range []Budget{
+		{Scope: "tree", Unit: UnitCents, Limit: 1},
+		{Scope: ScopeHost, GroupID: "fleet", Unit: UnitCents, Limit: 1},
+		{Scope: ScopeGroup, SessionID: "s1", Unit: UnitCents, Limit: 1},
+		{Scope: ScopeSession, Unit: "dollars", Limit: 1},
+		{Scope: ScopeSession, Unit: UnitTokens, Limit: 0},
+		{Scope: ScopeSession, Unit: UnitTokens, Limit: 1, SoftPercent: 120},
+		{Scope: ScopeSession, Unit: UnitTokens, Limit: 1, Action: "kill"},
+	} {
+		if _, err := engine.Add(invalid); err == nil {
+			t.Fatalf("Add(%+v) accepted", invalid)
+		}
+	}
+
+	added, err := engine.Add(Budget{Scope: ScopeSession, Unit: UnitTokens, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.ID == "" || added.SoftPercent != DefaultSoftPercent || added.Action != ActionInterrupt || added.Source != SourceCommand {
+		t.Fatalf("defaults=%+v", added)
+	}
+
+	if _, err := engine.Add(Budget{ID: "daily", Scope: ScopeHost, Unit: UnitCents, Limit: 100, Source: SourceConfig}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Add(Budget{ID: "daily", Scope: ScopeHost, Unit: UnitCents, Limit: 500}); err == nil {
+		t.Fatal("a command replaced a config budget")
+	}
+	if err := engine.Remove("daily"); !errors.Is(err, ErrConfigBudget) {
+		t.Fatalf("Remove config budget: %v", err)
+	}
+	if err := engine.Remove("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Remove missing budget: %v", err)
+	}
+}
+
+func TestRecordReportsEachThresholdOnce(t *testing.T) {
+	engine := NewEngine()
+	for _, budget := range []Budget{
+		{ID: "per-session", Scope: ScopeSession, Unit: UnitTokens, Limit: 1000},
+		{ID: "fleet", Scope: ScopeGroup, GroupID: "fleet", Unit: UnitCents, Limit: 100, SoftPercent: 50, Action: ActionPause},
+	} {
+		if _, err := engine.Add(budget); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	crossings := engine.Record(usage.Spend{SessionID: "a", GroupID: "fleet", Tokens: 850, Cents: 30, SessionTokens: 850, SessionCents: 30})
+	if len(crossings) != 1 || crossings[0].Budget.ID != "per-session" || crossings[0].Level != LevelSoft || crossings[0].Threshold != 800 {
+		t.Fatalf("first crossings=%+v", crossings)
+	}
+	if crossings := engine.Record(usage.Spend{SessionID: "a", GroupID: "fleet", Tokens: 50, SessionTokens: 900, SessionCents: 30}); len(crossings) != 0 {
+		t.Fatalf("soft threshold reported again: %+v", crossings)
+	}
+
+	// Another session's spend counts toward the group, not toward a's budget.
+	crossings = engine.Record(usage.Spend{SessionID: "b", GroupID: "fleet", Tokens: 10, Cents: 75, SessionTokens: 10, SessionCents: 75})
+	if len(crossings) != 1 || crossings[0].Budget.ID != "fleet" || crossings[0].Level != LevelHard || crossings[0].Subject != "fleet" || crossings[0].Spent != 105 {
+		t.Fatalf("group crossings=%+v", crossings)
+	}
+	if exceeded := engine.Exceeded("", "fleet"); len(exceeded) != 1 || exceeded[0].Budget.Action != ActionPause {
+		t.Fatalf("exceeded=%+v", exceeded)
+	}
+	if exceeded := engine.Exceeded("c", "other"); len(exceeded) != 0 {
+		t.Fatalf("other group exceeded=%+v", exceeded)
+	}
+
+	// Raising the limit lifts the hold and re-arms the thresholds.
+	if _, err := engine.Add(Budget{ID: "fleet", Scope: ScopeGroup, GroupID: "fleet", Unit: UnitCents, Limit: 1000, Action: ActionPause}); err != nil {
+		t.Fatal(err)
+	}
+	if exceeded := engine.Exceeded("b", "fleet"); len(exceeded) != 0 {
+		t.Fatalf("exceeded after raise=%+v", exceeded)
+	}
+
+	// Backlog spend counts toward the session only.
+	engine.Record(usage.Spend{SessionID: "old", GroupID: "fleet", Tokens: 5000, Cents: 5000, SessionTokens: 5000, SessionCents: 5000, Backlog: true})
+	if spend := engine.Spend(); spend.Groups["fleet"].Cents != 105 || spend.Host.Tokens != 910 {
+		t.Fatalf("spend=%+v", spend)
+	}
+	if exceeded := engine.Exceeded("old", "fleet"); len(exceeded) != 1 || exceeded[0].Budget.ID != "per-session" {
+		t.Fatalf("backlog session exceeded=%+v", exceeded)
+	}
+}
+
+func TestHostBudgetIsDailyAndPersisted(t *testing.T) {
+	now := time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	stateDir := t.TempDir()
+
+	engine := NewEngine()
+	engine.now = clock
+	if err := engine.Open(stateDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Add(Budget{ID: "daily", Scope: ScopeHost, Unit: UnitCents, Limit: 100}); err != nil {
+		t.Fatal(err)
+	}
+	crossings := engine.Record(usage.Spend{SessionID: "a", Cents: 120, SessionCents: 120})
+	if len(crossings) != 1 || crossings[0].Level != LevelHard || crossings[0].Subject != "2026-10-18" {
+		t.Fatalf("crossings=%+v", crossings)
+	}
+	engine.Flush()
+
+	reopened := NewEngine()
+	reopened.now = clock
+	if err := reopened.Open(stateDir); err != nil {
+		t.Fatal(err)
+	}
+	if budgets := reopened.Budgets(); len(budgets) != 1 || budgets[0].ID != "daily" {
+		t.Fatalf("restored budgets=%+v", budgets)
+	}
+	if exceeded := reopened.Exceeded("b", ""); len(exceeded) != 1 {
+		t.Fatalf("restored spend not over budget: %+v", reopened.Spend())
+	}
+
+	now = now.Add(2 * time.Hour)
+	if exceeded := reopened.Exceeded("b", ""); len(exceeded) != 0 {
+		t.Fatalf("budget still exceeded the next day: %+v", exceeded)
+	}
+}
+
+func TestGroupSpendStartsAgainEachDay(t *testing.T) {
+	now := time.Date(2026, 10, 18, 23, 0, 0, 0, time.UTC)
+	engine := NewEngine()
+	engine.now = func() time.Time { return now }
+	if _, err := engine.Add(Budget{ID: "team", Scope: ScopeGroup, GroupID: "g", Unit: UnitTokens, Limit: 100}); err != nil {
+		t.Fatal(err)
+	}
+	crossings := engine.Record(usage.Spend{SessionID: "a", GroupID: "g", Tokens: 150, SessionTokens: 150})
+	if len(crossings) != 1 || crossings[0].Level != LevelHard {
+		t.Fatalf("crossings=%+v", crossings)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if exceeded := engine.Exceeded("b", "g"); len(exceeded) != 0 {
+		t.Fatalf("group budget still exceeded the next day: %+v", exceeded)
+	}
+	crossings = engine.Record(usage.Spend{SessionID: "b", GroupID: "g", Tokens: 120, SessionTokens: 120})
+	if len(crossings) != 1 || crossings[0].Level != LevelHard {
+		t.Fatalf("next day crossings=%+v", crossings)
+	}
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,14 +15,19 @@ type Config struct {
 	Terminal     TerminalConfig     `yaml:"terminal"`
 	Spawn        SpawnConfig        `yaml:"spawn"`
 	Security     SecurityConfig     `yaml:"security"`
+	Approvals    ApprovalsConfig    `yaml:"approvals"`
 	Providers    ProvidersConfig    `yaml:"providers"`
 	Storage      StorageConfig      `yaml:"storage"`
 	Preview      PreviewConfig      `yaml:"preview"`
 	FileBridge   FileBridgeConfig   `yaml:"file_bridge"`
+	Watches      []WatchConfig      `yaml:"watches"`
+	Recording    RecordingConfig    `yaml:"recording"`
+	Budgets      []BudgetConfig     `yaml:"budgets"`
 }
 
 type TerminalConfig struct {
 	PerViewerPTY bool `yaml:"per_viewer_pty"`
+	ScreenModel  bool `yaml:"screen_model"`
 }
 
 type HostConfig struct {
@@ -43,6 +50,9 @@ type TmuxConfig struct {
 	SnapshotIntervalMs int    `yaml:"snapshot_interval_ms"`
 	SnapshotMaxBytes   int    `yaml:"snapshot_max_bytes"`
 	OptionSessionID    string `yaml:"option_session_id"`
+	// CommandMarks follows OSC 133 marks from agentd-owned bash panes to track
+	// command boundaries and exit statuses.
+	CommandMarks bool `yaml:"command_marks"`
 }
 
 type SpawnConfig struct {
@@ -50,6 +60,11 @@ type SpawnConfig struct {
 	DefaultShell         string `yaml:"default_shell"`
 	WorktreesRoot        string `yaml:"worktrees_root"`
 	MaxChildrenPerParent int    `yaml:"max_children_per_parent"`
+	// MaxConcurrentJobs caps running spawn_job processes per provider;
+	// further jobs wait in the queue. MaxConcurrentJobsByProvider overrides
+	// it for named providers.
+	MaxConcurrentJobs           int            `yaml:"max_concurrent_jobs"`
+	MaxConcurrentJobsByProvider map[string]int `yaml:"max_concurrent_jobs_by_provider"`
 }
 
 type SecurityConfig struct {
@@ -57,6 +72,21 @@ type SecurityConfig struct {
 	AllowKill          bool `yaml:"allow_kill"`
 	AllowSpawn         bool `yaml:"allow_spawn"`
 	AllowConsoleStream bool `yaml:"allow_console_stream"`
+	// AllowClipboardWrite forwards OSC 52 clipboard writes from panes to
+	// terminal viewers. Off by default: any program in a pane could
+	// otherwise set the viewer's clipboard.
+	AllowClipboardWrite bool `yaml:"allow_clipboard_write"`
+	// RequireHookAuth rejects hook and orchestrator requests that are not
+	// signed with a session secret or traced to a managed pane by peer
+	// credentials. Bad signatures are rejected either way.
+	RequireHookAuth bool `yaml:"require_hook_auth"`
+}
+
+// ApprovalsConfig controls how permission requests are answered on the host.
+type ApprovalsConfig struct {
+	// PolicyFile is a YAML rules file evaluated before a request is sent
+	// to the control plane. Matching allow or deny rules answer at once.
+	PolicyFile string `yaml:"policy_file"`
 }
 
 type ProvidersConfig struct {
@@ -65,6 +95,77 @@ type ProvidersConfig struct {
 	Gemini          GeminiConfig                      `yaml:"gemini"`
 	OpenCode        OpenCodeConfig                    `yaml:"opencode"`
 	LaunchTemplates map[string]ProviderLaunchTemplate `yaml:"launch_templates"`
+	// Definitions add CLI agents or adjust built-in ones. See
+	// ProviderDefinition.
+	Definitions     []ProviderDefinition  `yaml:"definitions"`
+	Pricing         PricingConfig         `yaml:"pricing"`
+	TranscriptUsage TranscriptUsageConfig `yaml:"transcript_usage"`
+}
+
+// TranscriptUsageConfig controls reading Claude and Codex session usage
+// from their transcripts. Sessions without a readable transcript fall back
+// to usage scraped from the screen.
+type TranscriptUsageConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	IntervalMs int  `yaml:"interval_ms"`
+}
+
+// PricingConfig prices token usage so session costs can be estimated when
+// the provider does not report them.
+type PricingConfig struct {
+	// Models maps a model ID, or an ID prefix ending in "*", to its price.
+	Models map[string]ModelPrice `yaml:"models"`
+	// DefaultModels names the model to price a provider's sessions at
+	// until a session reports its own.
+	DefaultModels map[string]string `yaml:"default_models"`
+}
+
+// ModelPrice is a model's price in USD per million tokens.
+type ModelPrice struct {
+	Input      float64 `yaml:"input"`
+	Output     float64 `yaml:"output"`
+	CacheRead  float64 `yaml:"cache_read"`
+	CacheWrite float64 `yaml:"cache_write"`
+}
+
+// ProviderDefinition describes a CLI agent: how to recognise its panes, how
+// to launch and drive it, and how to read its screen. The built-in providers
+// are defined the same way; an entry named after one overrides only the
+// fields it sets.
+type ProviderDefinition struct {
+	Name string `yaml:"name"`
+	// Aliases are other names accepted for the provider, e.g. in spawn
+	// requests and pane provider overrides.
+	Aliases []string              `yaml:"aliases"`
+	Detect  ProviderDetectionRule `yaml:"detect"`
+	// Interactive providers wait for input when quiet rather than idling.
+	Interactive       *bool                   `yaml:"interactive"`
+	ApprovalAllowKeys []string                `yaml:"approval_allow_keys"`
+	ApprovalDenyKeys  []string                `yaml:"approval_deny_keys"`
+	Launch            *ProviderLaunchTemplate `yaml:"launch"`
+	// StatusPatterns are checked in order against the pane's screen; the
+	// first match sets the session status.
+	StatusPatterns []ProviderStatusPattern `yaml:"status_patterns"`
+	// UsagePatterns are regexes for usage lines, with named groups
+	// input_tokens, output_tokens, total_tokens, cache_read_tokens,
+	// cache_write_tokens and cost_usd. Built-in parsers are used when unset.
+	UsagePatterns []string `yaml:"usage_patterns"`
+}
+
+// ProviderDetectionRule recognises a provider's panes. Command and title
+// entries are case-insensitive substrings; descendants are process names
+// anywhere below the pane's shell.
+type ProviderDetectionRule struct {
+	Commands    []string `yaml:"commands"`
+	Titles      []string `yaml:"titles"`
+	Descendants []string `yaml:"descendants"`
+}
+
+// ProviderStatusPattern maps a screen regex to a session status: RUNNING,
+// IDLE, WAITING_FOR_INPUT or WAITING_FOR_APPROVAL.
+type ProviderStatusPattern struct {
+	Status  string `yaml:"status"`
+	Pattern string `yaml:"pattern"`
 }
 
 type ProviderLaunchTemplate struct {
@@ -75,7 +176,16 @@ type ProviderLaunchTemplate struct {
 }
 
 type ClaudeConfig struct {
-	HooksHTTPListen    string   `yaml:"hooks_http_listen"`
+	// HooksHTTPListen is the TCP address for the hook and orchestrator
+	// endpoints; HooksHTTPListenOff serves them only on HooksSocket.
+	HooksHTTPListen string `yaml:"hooks_http_listen"`
+	// HooksSocket also serves the endpoints on a Unix socket, which callers
+	// can reach without a TCP port and which identifies the calling process.
+	HooksSocket string `yaml:"hooks_socket"`
+	// HooksSocketMode is the socket's octal permission mode.
+	HooksSocketMode string `yaml:"hooks_socket_mode"`
+	// HooksSocketOwner is "user", "user:group" or ":group", by name or ID.
+	HooksSocketOwner   string   `yaml:"hooks_socket_owner"`
 	PermissionStrategy string   `yaml:"permission_strategy"`
 	ApprovalAllowKeys  []string `yaml:"approval_allow_keys"`
 	ApprovalDenyKeys   []string `yaml:"approval_deny_keys"`
@@ -84,6 +194,74 @@ type ClaudeConfig struct {
 	UsageParseJSON     bool     `yaml:"usage_parse_json"`
 	UsageSessionName   string   `yaml:"usage_session_name"`
 	UsageIdleMs        int      `yaml:"usage_idle_ms"`
+	// The approval wait settings apply to every blocking permission
+	// request; ApprovalGroups overrides them for sessions in a group.
+	ApprovalWaitConfig `yaml:",inline"`
+	ApprovalGroups     map[string]ApprovalWaitConfig `yaml:"approval_groups"`
+}
+
+// HooksHTTPListenOff disables the TCP hooks listener.
+const HooksHTTPListenOff = "off"
+
+// HooksSocketPerm returns the permission bits for HooksSocket.
+func (c ClaudeConfig) HooksSocketPerm() os.FileMode {
+	mode, err := strconv.ParseUint(c.HooksSocketMode, 8, 32)
+	if err != nil {
+		return 0o600
+	}
+	return os.FileMode(mode) & os.ModePerm
+}
+
+// Approval fallbacks, applied when a blocking permission request times out.
+const (
+	// ApprovalFallbackPrompt returns no decision, so the CLI shows its own
+	// dialog in the pane.
+	ApprovalFallbackPrompt = "prompt"
+	ApprovalFallbackDeny   = "deny"
+	ApprovalFallbackAllow  = "allow"
+	// ApprovalFallbackWait ignores the timeout and waits for a decision.
+	ApprovalFallbackWait = "wait"
+)
+
+// ApprovalWaitConfig bounds how long a blocking permission request waits
+// for a decision. Zero fields inherit the host-wide setting.
+type ApprovalWaitConfig struct {
+	TimeoutMs int    `yaml:"approval_timeout_ms"`
+	Fallback  string `yaml:"approval_fallback"`
+	// ReminderMs re-sends the approval notification at this interval
+	// while the request waits. Zero sends no reminders.
+	ReminderMs int `yaml:"approval_reminder_ms"`
+}
+
+// ApprovalWait returns the wait settings for a session in group.
+func (c ClaudeConfig) ApprovalWait(group string) ApprovalWaitConfig {
+	wait := c.ApprovalWaitConfig
+	if override, ok := c.ApprovalGroups[group]; ok && group != "" {
+		if override.TimeoutMs > 0 {
+			wait.TimeoutMs = override.TimeoutMs
+		}
+		if override.Fallback != "" {
+			wait.Fallback = override.Fallback
+		}
+		if override.ReminderMs > 0 {
+			wait.ReminderMs = override.ReminderMs
+		}
+	}
+	if wait.TimeoutMs <= 0 {
+		wait.TimeoutMs = 600000
+	}
+	if wait.Fallback == "" {
+		wait.Fallback = ApprovalFallbackPrompt
+	}
+	return wait
+}
+
+func validApprovalFallback(fallback string) bool {
+	switch fallback {
+	case "", ApprovalFallbackPrompt, ApprovalFallbackDeny, ApprovalFallbackAllow, ApprovalFallbackWait:
+		return true
+	}
+	return false
 }
 
 type CodexConfig struct {
@@ -137,13 +315,58 @@ type FileBridgeConfig struct {
 	MaxFileBytes int64  `yaml:"max_file_bytes"`
 }
 
+// WatchConfig declares an output watch evaluated against new pane output. Empty
+// group_id and provider match every session; a match always emits a
+// watch.match event and may additionally run the listed actions.
+type WatchConfig struct {
+	ID           string   `yaml:"id"`
+	Pattern      string   `yaml:"pattern"`
+	Literal      bool     `yaml:"literal"`
+	IgnoreCase   bool     `yaml:"ignore_case"`
+	GroupID      string   `yaml:"group_id"`
+	Provider     string   `yaml:"provider"`
+	ContextLines int      `yaml:"context_lines"`
+	CooldownMs   int      `yaml:"cooldown_ms"`
+	SetStatus    string   `yaml:"set_status"`
+	Notify       bool     `yaml:"notify"`
+	SendKeys     []string `yaml:"send_keys"`
+}
+
+// BudgetConfig declares a token or cost budget. scope is session (each
+// session, or those of group_id), group (group_id, or each group) or host
+// (every session per UTC day); unit is tokens or cents.
+type BudgetConfig struct {
+	ID          string  `yaml:"id"`
+	Scope       string  `yaml:"scope"`
+	GroupID     string  `yaml:"group_id"`
+	Unit        string  `yaml:"unit"`
+	Limit       int64   `yaml:"limit"`
+	SoftPercent float64 `yaml:"soft_percent"`
+	Action      string  `yaml:"action"`
+}
+
+// RecordingConfig persists raw output of managed panes beyond tmux scrollback.
+// Segments are gzip-compressed once full and the oldest are dropped when a
+// session exceeds max_session_bytes.
+type RecordingConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	Dir             string `yaml:"dir"`
+	SegmentBytes    int64  `yaml:"segment_bytes"`
+	MaxSessionBytes int64  `yaml:"max_session_bytes"`
+	RetentionHours  int    `yaml:"retention_hours"`
+}
+
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg := Config{Terminal: TerminalConfig{PerViewerPTY: true}}
+	cfg := Config{
+		Tmux:      TmuxConfig{CommandMarks: true},
+		Terminal:  TerminalConfig{PerViewerPTY: true},
+		Providers: ProvidersConfig{TranscriptUsage: TranscriptUsageConfig{Enabled: true}},
+	}
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
@@ -176,15 +399,33 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.Spawn.MaxChildrenPerParent == 0 {
 		cfg.Spawn.MaxChildrenPerParent = 8
 	}
+	if cfg.Spawn.MaxConcurrentJobs == 0 {
+		cfg.Spawn.MaxConcurrentJobs = 2
+	}
 	if cfg.Storage.StateDir == "" {
 		cfg.Storage.StateDir = "/var/lib/agentd"
 	}
+	if cfg.Recording.Dir == "" {
+		cfg.Recording.Dir = cfg.Storage.StateDir + "/recordings"
+	}
+	if cfg.Recording.RetentionHours == 0 {
+		cfg.Recording.RetentionHours = 72
+	}
 	if cfg.Storage.OutboundQueueMax == 0 {
 		cfg.Storage.OutboundQueueMax = 50000
 	}
 	if cfg.Providers.Claude.HooksHTTPListen == "" {
 		cfg.Providers.Claude.HooksHTTPListen = "127.0.0.1:7777"
 	}
+	if cfg.Providers.Claude.HooksSocketMode == "" {
+		cfg.Providers.Claude.HooksSocketMode = "0600"
+	}
+	if mode, err := strconv.ParseUint(cfg.Providers.Claude.HooksSocketMode, 8, 32); err != nil || mode > 0o777 {
+		return nil, fmt.Errorf("providers.claude.hooks_socket_mode: %q is not an octal permission mode", cfg.Providers.Claude.HooksSocketMode)
+	}
+	if cfg.Providers.Claude.HooksHTTPListen == HooksHTTPListenOff && cfg.Providers.Claude.HooksSocket == "" {
+		return nil, fmt.Errorf("providers.claude.hooks_http_listen: cannot be off without hooks_socket")
+	}
 	if cfg.Providers.Claude.PermissionStrategy == "" {
 		cfg.Providers.Claude.PermissionStrategy = "both"
 	}
@@ -200,6 +441,22 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.Providers.Claude.UsageIdleMs == 0 && cfg.Providers.Claude.UsageSessionName != "" {
 		cfg.Providers.Claude.UsageIdleMs = 15000
 	}
+	if !validApprovalFallback(cfg.Providers.Claude.Fallback) {
+		return nil, fmt.Errorf("providers.claude.approval_fallback: unknown fallback %q", cfg.Providers.Claude.Fallback)
+	}
+	for group, wait := range cfg.Providers.Claude.ApprovalGroups {
+		if !validApprovalFallback(wait.Fallback) {
+			return nil, fmt.Errorf("providers.claude.approval_groups.%s.approval_fallback: unknown fallback %q", group, wait.Fallback)
+		}
+	}
+	if cfg.Providers.TranscriptUsage.IntervalMs == 0 {
+		cfg.Providers.TranscriptUsage.IntervalMs = 5000
+	}
+	for model, price := range cfg.Providers.Pricing.Models {
+		if price.Input < 0 || price.Output < 0 || price.CacheRead < 0 || price.CacheWrite < 0 {
+			return nil, fmt.Errorf("providers.pricing.models.%s: prices cannot be negative", model)
+		}
+	}
 	if len(cfg.Providers.Codex.ApprovalAllowKeys) == 0 {
 		cfg.Providers.Codex.ApprovalAllowKeys = []string{"y", "Enter"}
 	}
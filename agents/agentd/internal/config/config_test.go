@@ -55,3 +55,104 @@ func TestTmuxTopologyEventsDefaultsOffAndCanBeEnabled(t *testing.T) {
 		})
 	}
 }
+
+func TestClaudeApprovalWaitGroupsOverrideHostSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `providers:
+  claude:
+    approval_timeout_ms: 120000
+    approval_reminder_ms: 30000
+    approval_groups:
+      overnight:
+        approval_timeout_ms: 3600000
+        approval_fallback: deny
+`
+	if err := os.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if wait := cfg.Providers.Claude.ApprovalWait("overnight"); wait != (ApprovalWaitConfig{TimeoutMs: 3600000, Fallback: "deny", ReminderMs: 30000}) {
+		t.Fatalf("overnight wait=%+v", wait)
+	}
+	if wait := cfg.Providers.Claude.ApprovalWait("other"); wait != (ApprovalWaitConfig{TimeoutMs: 120000, Fallback: "prompt", ReminderMs: 30000}) {
+		t.Fatalf("default wait=%+v", wait)
+	}
+
+	if err := os.WriteFile(path, []byte("providers:\n  claude:\n    approval_fallback: maybe\n"), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatal("LoadConfig accepted an unknown approval fallback")
+	}
+}
+
+func TestClaudeHooksSocketSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `providers:
+  claude:
+    hooks_http_listen: off
+    hooks_socket: /run/agentd/hooks.sock
+    hooks_socket_mode: "0660"
+`
+	if err := os.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Providers.Claude.HooksHTTPListen != HooksHTTPListenOff || cfg.Providers.Claude.HooksSocketPerm() != 0o660 {
+		t.Fatalf("claude=%+v", cfg.Providers.Claude)
+	}
+
+	for _, invalid := range []string{
+		"providers:\n  claude:\n    hooks_socket: /tmp/a.sock\n    hooks_socket_mode: \"rw\"\n",
+		"providers:\n  claude:\n    hooks_http_listen: off\n",
+	} {
+		if err := os.WriteFile(path, []byte(invalid), 0600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := LoadConfig(path); err == nil {
+			t.Fatalf("LoadConfig accepted %q", invalid)
+		}
+	}
+}
+
+func TestProviderPricing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `providers:
+  pricing:
+    default_models:
+      claude_code: claude-sonnet-4
+    models:
+      "claude-sonnet-4*": {input: 3, output: 15, cache_read: 0.3, cache_write: 3.75}
+`
+	if err := os.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	price := cfg.Providers.Pricing.Models["claude-sonnet-4*"]
+	if price.Input != 3 || price.Output != 15 || price.CacheRead != 0.3 || price.CacheWrite != 3.75 {
+		t.Fatalf("price=%+v", price)
+	}
+	if cfg.Providers.Pricing.DefaultModels["claude_code"] != "claude-sonnet-4" {
+		t.Fatalf("default_models=%v", cfg.Providers.Pricing.DefaultModels)
+	}
+	if usage := cfg.Providers.TranscriptUsage; !usage.Enabled || usage.IntervalMs != 5000 {
+		t.Fatalf("transcript_usage defaults=%+v", usage)
+	}
+
+	invalid := "providers:\n  pricing:\n    models:\n      gpt-5: {input: -1}\n"
+	if err := os.WriteFile(path, []byte(invalid), 0600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatalf("LoadConfig accepted a negative price")
+	}
+}
@@ -0,0 +1,262 @@
+// Package hookauth authenticates local requests to agentd's hook and
+// orchestrator endpoints.
+//
+// Each managed pane is launched with a per-session secret in
+// AC_SESSION_SECRET. Clients sign requests with it: the signature is an
+// HMAC-SHA256 over the timestamp, method, request URI and body, sent with the
+// session ID in headers. Secrets are derived from a host key kept in the state
+// directory, so they survive an agentd restart without being stored per
+// session.
+//
+// Requests arriving over a Unix socket may instead be attributed by peer
+// credentials: the caller's PID is traced up its process tree to the pane of
+// a managed session.
+package hookauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	SessionHeader   = "X-AC-Session-Id"
+	TimestampHeader = "X-AC-Timestamp"
+	SignatureHeader = "X-AC-Signature"
+	// SecretEnv carries a session's secret into its pane.
+	SecretEnv = "AC_SESSION_SECRET"
+
+	// MaxSkew bounds how far a signed timestamp may be from now, which
+	// limits how long a captured request can be replayed.
+	MaxSkew      = 5 * time.Minute
+	maxBodyBytes = 16 << 20
+	keyBytes     = 32
+)
+
+var (
+	ErrUnsigned       = errors.New("request is not signed")
+	ErrBadSignature   = errors.New("request signature does not match")
+	ErrStaleTimestamp = errors.New("request timestamp is outside the allowed window")
+)
+
+// Keyring derives per-session secrets from a host key.
+type Keyring struct {
+	key []byte
+}
+
+func NewKeyring(key []byte) *Keyring {
+	return &Keyring{key: key}
+}
+
+// LoadKeyring reads the host key at path, creating it with owner-only
+// permissions when it does not exist.
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(string(bytes.TrimSpace(data)))
+		if err != nil || len(key) < keyBytes {
+			return nil, fmt.Errorf("%s: malformed hook auth key", path)
+		}
+		return NewKeyring(key), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, keyBytes)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			// Another agentd won the race; use its key.
+			return LoadKeyring(path)
+		}
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		return nil, err
+	}
+	return NewKeyring(key), nil
+}
+
+// Secret returns the secret for sessionID.
+func (k *Keyring) Secret(sessionID string) string {
+	mac := hmac.New(sha256.New, k.key)
+	mac.Write([]byte("session:" + sessionID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns the signature for a request.
+func Sign(secret string, timestamp int64, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d\n%s\n%s\n", timestamp, method, requestURI)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signed request and returns the session it was signed for.
+func (k *Keyring) Verify(r *http.Request, body []byte, now time.Time) (string, error) {
+	signature := r.Header.Get(SignatureHeader)
+	sessionID := r.Header.Get(SessionHeader)
+	if signature == "" || sessionID == "" {
+		return "", ErrUnsigned
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return "", ErrStaleTimestamp
+	}
+	if skew := now.Sub(time.Unix(timestamp, 0)); skew > MaxSkew || skew < -MaxSkew {
+		return "", ErrStaleTimestamp
+	}
+	want := Sign(k.Secret(sessionID), timestamp, r.Method, r.URL.RequestURI(), body)
+	if !hmac.Equal([]byte(want), []byte(signature)) {
+		return "", ErrBadSignature
+	}
+	return sessionID, nil
+}
+
+// PeerCred identifies the process on the other end of a Unix socket.
+type PeerCred struct {
+	PID int
+	UID int
+	GID int
+}
+
+type contextKey int
+
+const (
+	peerKey contextKey = iota
+	sessionKey
+)
+
+// ConnContext records peer credentials for Unix socket connections. Use it
+// as an http.Server ConnContext.
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return ctx
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return ctx
+	}
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil || credErr != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, peerKey, PeerCred{PID: int(cred.Pid), UID: int(cred.Uid), GID: int(cred.Gid)})
+}
+
+func PeerFromContext(ctx context.Context) (PeerCred, bool) {
+	peer, ok := ctx.Value(peerKey).(PeerCred)
+	return peer, ok
+}
+
+// SessionFromContext returns the session a request was authenticated as.
+func SessionFromContext(ctx context.Context) (string, bool) {
+	sessionID, ok := ctx.Value(sessionKey).(string)
+	return sessionID, ok && sessionID != ""
+}
+
+// Authenticator checks requests before they reach the hook and orchestrator
+// handlers.
+type Authenticator struct {
+	Keyring *Keyring
+	// Require rejects requests that are neither signed nor attributed to a
+	// session by peer credentials. Without it such requests pass through
+	// unauthenticated, but a bad signature is always rejected.
+	Require bool
+	// PeerSession maps a peer PID to the managed session it runs in, or "".
+	PeerSession func(pid int) string
+	// Exempt paths skip authentication, such as a metrics endpoint.
+	Exempt []string
+
+	now func() time.Time
+}
+
+// Wrap returns next behind the authenticator. An authenticated request
+// carries its session in the context and in the session header, replacing
+// whatever the caller claimed.
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range a.Exempt {
+			if r.URL.Path == path {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			http.Error(w, "Failed to read body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sessionID, err := a.authenticate(r, body)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, errForeignPeer) {
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if sessionID != "" {
+			r = r.WithContext(context.WithValue(r.Context(), sessionKey, sessionID))
+			r.Header.Set(SessionHeader, sessionID)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+var errForeignPeer = errors.New("peer runs as a different user")
+
+func (a *Authenticator) authenticate(r *http.Request, body []byte) (string, error) {
+	if r.Header.Get(SignatureHeader) != "" {
+		if a.Keyring == nil {
+			return "", ErrBadSignature
+		}
+		now := time.Now()
+		if a.now != nil {
+			now = a.now()
+		}
+		return a.Keyring.Verify(r, body, now)
+	}
+	if peer, ok := PeerFromContext(r.Context()); ok {
+		if peer.UID != os.Getuid() && peer.UID != 0 {
+			return "", errForeignPeer
+		}
+		if a.PeerSession != nil {
+			if sessionID := a.PeerSession(peer.PID); sessionID != "" {
+				return sessionID, nil
+			}
+		}
+	}
+	if a.Require {
+		return "", ErrUnsigned
+	}
+	return "", nil
+}
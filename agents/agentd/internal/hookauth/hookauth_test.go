@@ -0,0 +1,145 @@
+package hookauth
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedRequest(secret, sessionID, body string, at time.Time) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/hooks/claude", strings.NewReader(body))
+	req.Header.Set(SessionHeader, sessionID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(at.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(secret, at.Unix(), http.MethodPost, "/v1/hooks/claude", []byte(body)))
+	return req
+}
+
+func TestLoadKeyringPersistsHostKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "hook-auth.key")
+	first, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("key file mode=%v err=%v", info.Mode(), err)
+	}
+	second, err := LoadKeyring(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Secret("s1") != second.Secret("s1") || first.Secret("s1") == first.Secret("s2") {
+		t.Fatal("secrets are not stable per session")
+	}
+}
+
+func TestWrapVerifiesSignaturesAndOverridesClaimedSession(t *testing.T) {
+	keyring := NewKeyring([]byte(strings.Repeat("k", 32)))
+	now := time.Unix(1_800_000_000, 0)
+	var gotSession, gotHeader, gotBody string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSession, _ = SessionFromContext(r.Context())
+		gotHeader = r.Header.Get(SessionHeader)
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+	})
+	auth := &Authenticator{Keyring: keyring, now: func() time.Time { return now }}
+	handler := auth.Wrap(next)
+	body := `{"hook":{},"meta":{"ac_session_id":"s2"}}`
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, signedRequest(keyring.Secret("s1"), "s1", body, now))
+	if rec.Code != http.StatusOK || gotSession != "s1" || gotHeader != "s1" || gotBody != body {
+		t.Fatalf("status=%d session=%q header=%q body=%q", rec.Code, gotSession, gotHeader, gotBody)
+	}
+
+	for name, req := range map[string]*http.Request{
+		"other session's secret": signedRequest(keyring.Secret("s1"), "s2", body, now),
+		"stale":                  signedRequest(keyring.Secret("s1"), "s1", body, now.Add(-10*time.Minute)),
+		"tampered body": func() *http.Request {
+			req := signedRequest(keyring.Secret("s1"), "s1", body, now)
+			req.Body = io.NopCloser(strings.NewReader(strings.Replace(body, "s2", "s3", 1)))
+			return req
+		}(),
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status=%d", name, rec.Code)
+		}
+	}
+
+	// Unsigned requests pass unless authentication is required.
+	gotSession = ""
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/hooks/claude", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || gotSession != "" {
+		t.Fatalf("unsigned status=%d session=%q", rec.Code, gotSession)
+	}
+	auth.Require = true
+	auth.Exempt = []string{"/metrics"}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/hooks/claude", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("required unsigned status=%d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("exempt status=%d", rec.Code)
+	}
+}
+
+func TestWrapAttributesUnixPeersToSessions(t *testing.T) {
+	dir, err := os.MkdirTemp("", "hookauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "agentd.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var peerPID int
+	auth := &Authenticator{
+		Require: true,
+		PeerSession: func(pid int) string {
+			peerPID = pid
+			return "s1"
+		},
+	}
+	server := &http.Server{
+		Handler: auth.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Header.Get(SessionHeader)))
+		})),
+		ConnContext: ConnContext,
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	req, _ := http.NewRequest(http.MethodGet, "http://agentd/v1/agent/sessions", nil)
+	req.Header.Set(SessionHeader, "spoofed")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "s1" || peerPID != os.Getpid() {
+		t.Fatalf("status=%d body=%q peer pid=%d", resp.StatusCode, body, peerPID)
+	}
+}
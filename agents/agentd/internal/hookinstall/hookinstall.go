@@ -0,0 +1,472 @@
+// Package hookinstall merges agentd's hook proxy entries into provider
+// settings files and reports where a file has drifted from them.
+//
+// Claude Code settings.json and Codex hooks.json share a layout: a "hooks"
+// object mapping an event name to matcher groups, each holding command
+// hooks. Entries whose command runs one of the hook proxies are agentd's;
+// everything else in the file is left as it was.
+package hookinstall
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProxyNames are the hook proxy executables. A hook command mentioning one
+// of them is treated as managed by agentd.
+var ProxyNames = []string{"ac-claude-hook", "ac-codex-hook"}
+
+// Hook is one hook entry agentd wants in a settings file.
+type Hook struct {
+	Event   string
+	Matcher string
+	Command string
+	// Timeout is in seconds; zero leaves the provider default.
+	Timeout int
+}
+
+// Target is a settings file and the hooks it should carry.
+type Target struct {
+	Provider string
+	Scope    string
+	Path     string
+	Hooks    []Hook
+}
+
+// Drift kinds.
+const (
+	DriftMissing   = "missing"
+	DriftStale     = "stale"
+	DriftDuplicate = "duplicate"
+	DriftExtra     = "extra"
+)
+
+// Drift is a difference between the managed entries in a file and the
+// wanted hooks.
+type Drift struct {
+	Kind    string `json:"kind"`
+	Event   string `json:"event"`
+	Matcher string `json:"matcher,omitempty"`
+	Command string `json:"command,omitempty"`
+}
+
+// Status describes a target's file.
+type Status struct {
+	Exists bool `json:"exists"`
+	// Managed counts the agentd entries present in the file.
+	Managed int     `json:"managed"`
+	Drift   []Drift `json:"drift,omitempty"`
+}
+
+// Installed reports whether the file carries exactly the wanted hooks.
+func (s Status) Installed() bool {
+	return s.Exists && s.Managed > 0 && len(s.Drift) == 0
+}
+
+// Result describes a change made to a target's file.
+type Result struct {
+	Changed bool   `json:"changed"`
+	Backup  string `json:"backup,omitempty"`
+}
+
+// document is a settings file's top-level members in file order. Only
+// "hooks" is ever decoded; every other value is written back as it was read,
+// so installing keeps the user's key order and number formatting.
+type document struct {
+	keys   []string
+	values map[string]json.RawMessage
+}
+
+func (d *document) set(key string, value json.RawMessage) {
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.values[key] = value
+}
+
+func (d *document) delete(key string) {
+	if _, ok := d.values[key]; !ok {
+		return
+	}
+	delete(d.values, key)
+	for i, existing := range d.keys {
+		if existing == key {
+			d.keys = append(d.keys[:i], d.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+type managedEntry struct {
+	event   string
+	matcher string
+	command string
+	timeout int
+}
+
+// Inspect compares the file against the target's hooks.
+func Inspect(t Target) (Status, error) {
+	doc, exists, err := load(t.Path)
+	if err != nil {
+		return Status{}, err
+	}
+	hooks, err := doc.hooks(t.Path)
+	if err != nil {
+		return Status{}, err
+	}
+	entries := managedEntries(hooks)
+	status := Status{Exists: exists, Managed: len(entries)}
+
+	wanted := make(map[string]bool, len(t.Hooks))
+	for _, hook := range t.Hooks {
+		key := hook.Event + "\x00" + hook.Matcher
+		wanted[key] = true
+		var found []managedEntry
+		for _, entry := range entries {
+			if entry.event == hook.Event && entry.matcher == hook.Matcher {
+				found = append(found, entry)
+			}
+		}
+		switch {
+		case len(found) == 0:
+			status.Drift = append(status.Drift, Drift{Kind: DriftMissing, Event: hook.Event, Matcher: hook.Matcher, Command: hook.Command})
+		case len(found) > 1:
+			status.Drift = append(status.Drift, Drift{Kind: DriftDuplicate, Event: hook.Event, Matcher: hook.Matcher, Command: found[0].command})
+		case found[0].command != hook.Command || found[0].timeout != hook.Timeout:
+			status.Drift = append(status.Drift, Drift{Kind: DriftStale, Event: hook.Event, Matcher: hook.Matcher, Command: found[0].command})
+		}
+	}
+	for _, entry := range entries {
+		if !wanted[entry.event+"\x00"+entry.matcher] {
+			status.Drift = append(status.Drift, Drift{Kind: DriftExtra, Event: entry.event, Matcher: entry.matcher, Command: entry.command})
+		}
+	}
+	sort.SliceStable(status.Drift, func(i, j int) bool {
+		if status.Drift[i].Event != status.Drift[j].Event {
+			return status.Drift[i].Event < status.Drift[j].Event
+		}
+		return status.Drift[i].Matcher < status.Drift[j].Matcher
+	})
+	return status, nil
+}
+
+// Install replaces the managed entries in the file with the target's
+// hooks. A file that already matches is left untouched.
+func Install(t Target, now time.Time) (Result, error) {
+	status, err := Inspect(t)
+	if err != nil {
+		return Result{}, err
+	}
+	if status.Installed() {
+		return Result{}, nil
+	}
+	doc, _, err := load(t.Path)
+	if err != nil {
+		return Result{}, err
+	}
+	hooks, err := doc.hooks(t.Path)
+	if err != nil {
+		return Result{}, err
+	}
+	if hooks == nil {
+		hooks = make(map[string]any)
+	}
+	removeManaged(hooks)
+	for _, hook := range t.Hooks {
+		entry := map[string]any{"type": "command", "command": hook.Command}
+		if hook.Timeout > 0 {
+			entry["timeout"] = hook.Timeout
+		}
+		group := map[string]any{"hooks": []any{entry}}
+		if hook.Matcher != "" {
+			group["matcher"] = hook.Matcher
+		}
+		groups, _ := hooks[hook.Event].([]any)
+		hooks[hook.Event] = append(groups, group)
+	}
+	if err := doc.setHooks(hooks); err != nil {
+		return Result{}, err
+	}
+	return write(t.Path, doc, status.Exists, now)
+}
+
+// Uninstall removes the managed entries from the file.
+func Uninstall(t Target, now time.Time) (Result, error) {
+	doc, exists, err := load(t.Path)
+	if err != nil || !exists {
+		return Result{}, err
+	}
+	hooks, err := doc.hooks(t.Path)
+	if err != nil {
+		return Result{}, err
+	}
+	if len(managedEntries(hooks)) == 0 {
+		return Result{}, nil
+	}
+	removeManaged(hooks)
+	if len(hooks) == 0 {
+		doc.delete("hooks")
+	} else if err := doc.setHooks(hooks); err != nil {
+		return Result{}, err
+	}
+	return write(t.Path, doc, true, now)
+}
+
+func load(path string) (*document, bool, error) {
+	doc := &document{values: make(map[string]json.RawMessage)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return doc, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return doc, true, nil
+	}
+	if err := doc.parse(data); err != nil {
+		return nil, true, fmt.Errorf("%s: %w", path, err)
+	}
+	return doc, true, nil
+}
+
+// parse reads the top-level object's members in order. A repeated key keeps
+// its first position and its last value, as json.Unmarshal would.
+func (d *document) parse(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil {
+		return err
+	} else if token != json.Delim('{') {
+		return errors.New("settings are not a JSON object")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		d.set(key, value)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("unexpected data after the settings object")
+	}
+	return nil
+}
+
+// hooks decodes the "hooks" member. Numbers stay json.Number so integers
+// in user hooks are not rewritten as floats.
+func (d *document) hooks(path string) (map[string]any, error) {
+	raw, ok := d.values["hooks"]
+	if !ok {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	hooks, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: \"hooks\" is not an object", path)
+	}
+	return hooks, nil
+}
+
+func (d *document) setHooks(hooks map[string]any) error {
+	raw, err := marshal(hooks)
+	if err != nil {
+		return err
+	}
+	d.set("hooks", raw)
+	return nil
+}
+
+// marshal encodes value without escaping the <, > and & common in shell
+// commands.
+func marshal(value any) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// hookTimeout reads a hook's timeout in seconds.
+func hookTimeout(value any) int {
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0
+	}
+	seconds, _ := number.Float64()
+	return int(seconds)
+}
+
+// encode writes the members in order, indented two spaces.
+func (d *document) encode(buf *bytes.Buffer) error {
+	if len(d.keys) == 0 {
+		buf.WriteString("{}\n")
+		return nil
+	}
+	buf.WriteString("{\n")
+	for i, key := range d.keys {
+		name, err := marshal(key)
+		if err != nil {
+			return err
+		}
+		buf.WriteString("  ")
+		buf.Write(name)
+		buf.WriteString(": ")
+		if err := json.Indent(buf, d.values[key], "  ", "  "); err != nil {
+			return err
+		}
+		if i < len(d.keys)-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	return nil
+}
+
+func isManaged(command string) bool {
+	for _, name := range ProxyNames {
+		if strings.Contains(command, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func managedEntries(hooks map[string]any) []managedEntry {
+	var entries []managedEntry
+	for event, value := range hooks {
+		groups, _ := value.([]any)
+		for _, value := range groups {
+			group, _ := value.(map[string]any)
+			matcher, _ := group["matcher"].(string)
+			list, _ := group["hooks"].([]any)
+			for _, value := range list {
+				hook, _ := value.(map[string]any)
+				command, _ := hook["command"].(string)
+				if !isManaged(command) {
+					continue
+				}
+				entries = append(entries, managedEntry{event: event, matcher: matcher, command: command, timeout: hookTimeout(hook["timeout"])})
+			}
+		}
+	}
+	return entries
+}
+
+// removeManaged drops managed hooks, then any groups and events they leave
+// empty.
+func removeManaged(hooks map[string]any) {
+	for event, value := range hooks {
+		groups, ok := value.([]any)
+		if !ok {
+			continue
+		}
+		keptGroups := groups[:0]
+		for _, value := range groups {
+			group, ok := value.(map[string]any)
+			if !ok {
+				keptGroups = append(keptGroups, value)
+				continue
+			}
+			list, ok := group["hooks"].([]any)
+			if !ok {
+				keptGroups = append(keptGroups, value)
+				continue
+			}
+			kept := list[:0]
+			for _, value := range list {
+				hook, _ := value.(map[string]any)
+				if command, _ := hook["command"].(string); !isManaged(command) {
+					kept = append(kept, value)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			group["hooks"] = kept
+			keptGroups = append(keptGroups, group)
+		}
+		if len(keptGroups) == 0 {
+			delete(hooks, event)
+		} else {
+			hooks[event] = keptGroups
+		}
+	}
+}
+
+// write saves doc, first copying an existing file to a timestamped backup
+// beside it. The new file replaces the old one atomically and keeps its
+// permissions.
+func write(path string, doc *document, exists bool, now time.Time) (Result, error) {
+	var buf bytes.Buffer
+	if err := doc.encode(&buf); err != nil {
+		return Result{}, err
+	}
+
+	mode := os.FileMode(0o644)
+	result := Result{Changed: true}
+	if exists {
+		info, err := os.Stat(path)
+		if err != nil {
+			return Result{}, err
+		}
+		mode = info.Mode().Perm()
+		old, err := os.ReadFile(path)
+		if err != nil {
+			return Result{}, err
+		}
+		result.Backup = path + ".agentd-backup-" + now.UTC().Format("20060102T150405Z")
+		if err := os.WriteFile(result.Backup, old, mode); err != nil {
+			return Result{}, err
+		}
+	} else if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Result{}, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return Result{}, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return Result{}, err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return Result{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return Result{}, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Result{}, err
+	}
+	return result, nil
+}
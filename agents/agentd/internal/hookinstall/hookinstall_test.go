@@ -0,0 +1,204 @@
+package hookinstall
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testTarget(path string) Target {
+	return Target{
+		Provider: "claude",
+		Scope:    "user",
+		Path:     path,
+		Hooks: []Hook{
+			{Event: "PreToolUse", Matcher: "*", Command: "AC_AGENTD_URL=http://127.0.0.1:7777/v1/hooks/claude /usr/local/bin/ac-claude-hook"},
+			{Event: "PermissionRequest", Matcher: "*", Command: "AC_AGENTD_URL=http://127.0.0.1:7777/v1/hooks/claude /usr/local/bin/ac-claude-hook", Timeout: 630},
+			{Event: "Stop", Command: "AC_AGENTD_URL=http://127.0.0.1:7777/v1/hooks/claude /usr/local/bin/ac-claude-hook"},
+		},
+	}
+}
+
+func readSettings(t *testing.T, path string) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestInstallMergesIdempotentlyAndKeepsUserHooks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	existing := `{
+  "model": "opus",
+  "hooks": {
+    "PreToolUse": [
+      {"matcher": "Bash", "hooks": [
+        {"type": "command", "command": "/home/me/bin/audit"},
+        {"type": "command", "command": "/opt/old/ac-claude-hook"}
+      ]}
+    ],
+    "SubagentStop": [
+      {"hooks": [{"type": "command", "command": "/opt/old/ac-claude-hook"}]}
+    ]
+  }
+}`
+	if err := os.WriteFile(path, []byte(existing), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	target := testTarget(path)
+
+	status, err := Inspect(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]string{}
+	for _, drift := range status.Drift {
+		kinds[drift.Event+"/"+drift.Matcher] = drift.Kind
+	}
+	if kinds["PreToolUse/Bash"] != DriftExtra || kinds["SubagentStop/"] != DriftExtra ||
+		kinds["PreToolUse/*"] != DriftMissing || kinds["Stop/"] != DriftMissing || status.Installed() {
+		t.Fatalf("drift=%+v", status.Drift)
+	}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	result, err := Install(target, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Changed || result.Backup != path+".agentd-backup-20261018T120000Z" {
+		t.Fatalf("result=%+v", result)
+	}
+	if backup, _ := os.ReadFile(result.Backup); string(backup) != existing {
+		t.Fatalf("backup=%s", backup)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Fatalf("mode=%v", info.Mode().Perm())
+	}
+
+	doc := readSettings(t, path)
+	hooks := doc["hooks"].(map[string]any)
+	if doc["model"] != "opus" || hooks["SubagentStop"] != nil {
+		t.Fatalf("settings=%v", doc)
+	}
+	preToolUse := hooks["PreToolUse"].([]any)
+	userGroup := preToolUse[0].(map[string]any)
+	if len(preToolUse) != 2 || userGroup["matcher"] != "Bash" || len(userGroup["hooks"].([]any)) != 1 {
+		t.Fatalf("PreToolUse=%v", preToolUse)
+	}
+	if status, err := Inspect(target); err != nil || !status.Installed() || status.Managed != 3 {
+		t.Fatalf("status=%+v err=%v", status, err)
+	}
+
+	again, err := Install(target, now.Add(time.Minute))
+	if err != nil || again.Changed || again.Backup != "" {
+		t.Fatalf("second install=%+v err=%v", again, err)
+	}
+}
+
+func TestInspectReportsStaleAndDuplicateEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	target := testTarget(path)
+	if _, err := Install(target, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	doc := readSettings(t, path)
+	hooks := doc["hooks"].(map[string]any)
+	hooks["PermissionRequest"].([]any)[0].(map[string]any)["hooks"].([]any)[0].(map[string]any)["timeout"] = 60
+	hooks["Stop"] = append(hooks["Stop"].([]any), hooks["Stop"].([]any)[0])
+	data, _ := json.Marshal(doc)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := Inspect(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Drift) != 2 || status.Drift[0].Kind != DriftStale || status.Drift[0].Event != "PermissionRequest" ||
+		status.Drift[1].Kind != DriftDuplicate || status.Drift[1].Event != "Stop" {
+		t.Fatalf("drift=%+v", status.Drift)
+	}
+	if _, err := Install(target, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := Inspect(target); !status.Installed() {
+		t.Fatalf("reinstall left drift %+v", status.Drift)
+	}
+}
+
+func TestUninstallRemovesOnlyManagedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "settings.json")
+	target := testTarget(path)
+	if result, err := Uninstall(target, time.Now()); err != nil || result.Changed {
+		t.Fatalf("uninstall without a file=%+v err=%v", result, err)
+	}
+	result, err := Install(target, time.Now())
+	if err != nil || !result.Changed || result.Backup != "" {
+		t.Fatalf("install into a new file=%+v err=%v", result, err)
+	}
+
+	doc := readSettings(t, path)
+	doc["permissions"] = map[string]any{"allow": []any{"Bash(ls)"}}
+	data, _ := json.Marshal(doc)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, err = Uninstall(target, time.Now())
+	if err != nil || !result.Changed || result.Backup == "" {
+		t.Fatalf("uninstall=%+v err=%v", result, err)
+	}
+	doc = readSettings(t, path)
+	if _, ok := doc["hooks"]; ok || doc["permissions"] == nil {
+		t.Fatalf("settings after uninstall=%v", doc)
+	}
+}
+
+func TestInstallKeepsKeyOrderAndNumbers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	existing := `{
+  "model": "opus",
+  "cleanupPeriodDays": 30,
+  "env": {"MAX_THINKING_TOKENS": "31999", "seed": 12345678901234567890},
+  "hooks": {
+    "Stop": [{"hooks": [{"type": "command", "command": "/home/me/bin/notify", "timeout": 60}]}]
+  },
+  "alwaysThinkingEnabled": true
+}`
+	if err := os.WriteFile(path, []byte(existing), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Install(testTarget(path), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(data)
+
+	last := -1
+	for _, key := range []string{`"model"`, `"cleanupPeriodDays"`, `"env"`, `"hooks"`, `"alwaysThinkingEnabled"`} {
+		index := strings.Index(text, key)
+		if index <= last {
+			t.Fatalf("key %s out of order:\n%s", key, text)
+		}
+		last = index
+	}
+	for _, want := range []string{`"cleanupPeriodDays": 30,`, `12345678901234567890`, `"timeout": 60`, `"timeout": 630`} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("settings lost %s:\n%s", want, text)
+		}
+	}
+	if status, err := Inspect(testTarget(path)); err != nil || !status.Installed() {
+		t.Fatalf("status=%+v err=%v", status, err)
+	}
+}
@@ -0,0 +1,209 @@
+// Package jobqueue holds headless jobs waiting for a run slot. Pending jobs
+// are kept in the state directory so they survive a daemon restart; jobs
+// that were running when the daemon stopped are not resumed.
+package jobqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const fileName = "job-queue.json"
+
+// Job is a queued spawn_job request.
+type Job struct {
+	SessionID  string            `json:"session_id"`
+	Provider   string            `json:"provider"`
+	GroupID    string            `json:"group_id,omitempty"`
+	CWD        string            `json:"cwd"`
+	Prompt     string            `json:"prompt"`
+	Env        map[string]string `json:"env,omitempty"`
+	TimeoutMS  int64             `json:"timeout_ms,omitempty"`
+	Priority   int               `json:"priority,omitempty"`
+	EnqueuedAt time.Time         `json:"enqueued_at"`
+	Seq        int64             `json:"seq"`
+}
+
+// Limits caps concurrently running jobs. ByProvider overrides Default for a
+// provider; a limit below one is treated as one.
+type Limits struct {
+	Default    int
+	ByProvider map[string]int
+}
+
+func (l Limits) forProvider(provider string) int {
+	limit := l.Default
+	if override, ok := l.ByProvider[provider]; ok {
+		limit = override
+	}
+	return max(limit, 1)
+}
+
+// Queue orders pending jobs by priority, highest first, then by arrival.
+type Queue struct {
+	path    string
+	limits  Limits
+	mu      sync.Mutex
+	pending []Job
+	running map[string]string
+	seq     int64
+}
+
+// Open loads the queue kept in stateDir. An empty stateDir keeps the queue
+// in memory only.
+func Open(stateDir string, limits Limits) (*Queue, error) {
+	q := &Queue{limits: limits, running: make(map[string]string)}
+	if stateDir == "" {
+		return q, nil
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	q.path = filepath.Join(stateDir, fileName)
+	data, err := os.ReadFile(q.path)
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job queue: %w", err)
+	}
+	if err := json.Unmarshal(data, &q.pending); err != nil {
+		return nil, fmt.Errorf("failed to parse job queue: %w", err)
+	}
+	for _, job := range q.pending {
+		q.seq = max(q.seq, job.Seq)
+	}
+	q.sortLocked()
+	return q, nil
+}
+
+// Enqueue adds job and returns its 1-based queue position.
+func (q *Queue) Enqueue(job Job) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, pending := range q.pending {
+		if pending.SessionID == job.SessionID {
+			return 0, fmt.Errorf("job %s is already queued", job.SessionID)
+		}
+	}
+	if _, ok := q.running[job.SessionID]; ok {
+		return 0, fmt.Errorf("job %s is already running", job.SessionID)
+	}
+	q.seq++
+	job.Seq = q.seq
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = time.Now().UTC()
+	}
+	previous := q.pending
+	q.pending = append(append([]Job(nil), q.pending...), job)
+	q.sortLocked()
+	if err := q.saveLocked(); err != nil {
+		q.pending = previous
+		return 0, err
+	}
+	for i, pending := range q.pending {
+		if pending.SessionID == job.SessionID {
+			return i + 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// Next removes and returns the jobs that can start now, marking them
+// running. A provider at its limit does not hold back other providers.
+func (q *Queue) Next() ([]Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	active := make(map[string]int)
+	for _, provider := range q.running {
+		active[provider]++
+	}
+	var ready []Job
+	remaining := make([]Job, 0, len(q.pending))
+	for _, job := range q.pending {
+		if active[job.Provider] < q.limits.forProvider(job.Provider) {
+			active[job.Provider]++
+			ready = append(ready, job)
+			continue
+		}
+		remaining = append(remaining, job)
+	}
+	if len(ready) == 0 {
+		return nil, nil
+	}
+	previous := q.pending
+	q.pending = remaining
+	if err := q.saveLocked(); err != nil {
+		q.pending = previous
+		return nil, err
+	}
+	for _, job := range ready {
+		q.running[job.SessionID] = job.Provider
+	}
+	return ready, nil
+}
+
+// Finish frees the run slot held by sessionID.
+func (q *Queue) Finish(sessionID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.running, sessionID)
+}
+
+// Remove drops a pending job. It reports false when sessionID is not queued.
+func (q *Queue) Remove(sessionID string) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, job := range q.pending {
+		if job.SessionID != sessionID {
+			continue
+		}
+		previous := q.pending
+		q.pending = append(append([]Job(nil), q.pending[:i]...), q.pending[i+1:]...)
+		if err := q.saveLocked(); err != nil {
+			q.pending = previous
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// Pending returns the queued jobs in run order.
+func (q *Queue) Pending() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]Job(nil), q.pending...)
+}
+
+func (q *Queue) sortLocked() {
+	sort.SliceStable(q.pending, func(i, j int) bool {
+		if q.pending[i].Priority != q.pending[j].Priority {
+			return q.pending[i].Priority > q.pending[j].Priority
+		}
+		return q.pending[i].Seq < q.pending[j].Seq
+	})
+}
+
+func (q *Queue) saveLocked() error {
+	if q.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(q.pending)
+	if err != nil {
+		return err
+	}
+	tmpPath := q.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write job queue: %w", err)
+	}
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		return fmt.Errorf("failed to replace job queue: %w", err)
+	}
+	return nil
+}
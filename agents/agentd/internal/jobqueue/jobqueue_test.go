@@ -0,0 +1,100 @@
+package jobqueue
+
+import (
+	"testing"
+)
+
+func sessionIDs(jobs []Job) []string {
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.SessionID)
+	}
+	return ids
+}
+
+func TestQueueOrdersByPriorityAndLimitsPerProvider(t *testing.T) {
+	q, err := Open("", Limits{Default: 1, ByProvider: map[string]int{"claude_code": 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range []Job{
+		{SessionID: "a", Provider: "codex"},
+		{SessionID: "b", Provider: "codex"},
+		{SessionID: "c", Provider: "codex", Priority: 5},
+		{SessionID: "d", Provider: "claude_code"},
+		{SessionID: "e", Provider: "claude_code"},
+		{SessionID: "f", Provider: "claude_code"},
+	} {
+		if _, err := q.Enqueue(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if position, err := q.Enqueue(Job{SessionID: "g", Provider: "codex", Priority: 1}); err != nil || position != 2 {
+		t.Fatalf("position=%d err=%v", position, err)
+	}
+	if _, err := q.Enqueue(Job{SessionID: "a", Provider: "codex"}); err == nil {
+		t.Fatal("duplicate job was queued")
+	}
+
+	ready, err := q.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionIDs(ready); len(got) != 3 || got[0] != "c" || got[1] != "d" || got[2] != "e" {
+		t.Fatalf("first dispatch=%v", got)
+	}
+	if ready, _ := q.Next(); len(ready) != 0 {
+		t.Fatalf("dispatched over the limit: %v", sessionIDs(ready))
+	}
+
+	q.Finish("c")
+	ready, _ = q.Next()
+	if got := sessionIDs(ready); len(got) != 1 || got[0] != "g" {
+		t.Fatalf("after finish=%v", got)
+	}
+	if removed, err := q.Remove("b"); err != nil || !removed {
+		t.Fatalf("remove=%v err=%v", removed, err)
+	}
+	if got := sessionIDs(q.Pending()); len(got) != 2 || got[0] != "a" || got[1] != "f" {
+		t.Fatalf("pending=%v", got)
+	}
+}
+
+func TestQueueKeepsPendingJobsAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, Limits{Default: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, job := range []Job{
+		{SessionID: "running", Provider: "codex", Prompt: "one", Priority: 9},
+		{SessionID: "low", Provider: "codex", Prompt: "two", Env: map[string]string{"CI": "1"}},
+		{SessionID: "high", Provider: "codex", Prompt: "three", Priority: 3, TimeoutMS: 60000},
+	} {
+		if _, err := q.Enqueue(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir, Limits{Default: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending := reopened.Pending()
+	if got := sessionIDs(pending); len(got) != 2 || got[0] != "high" || got[1] != "low" {
+		t.Fatalf("pending after reopen=%v", got)
+	}
+	if pending[0].TimeoutMS != 60000 || pending[1].Env["CI"] != "1" || pending[1].EnqueuedAt.IsZero() {
+		t.Fatalf("pending jobs lost fields: %+v", pending)
+	}
+	// Sequence numbers continue, so a new job still sorts after older ones.
+	if _, err := reopened.Enqueue(Job{SessionID: "new", Provider: "codex", Priority: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionIDs(reopened.Pending()); got[0] != "high" || got[1] != "new" {
+		t.Fatalf("pending with new job=%v", got)
+	}
+}
@@ -128,7 +128,7 @@ func NewHandler(backend Backend) http.Handler {
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if !isLoopback(r.RemoteAddr) {
+	if !isLocalCaller(r) {
 		writeError(w, http.StatusForbidden, "orchestrator API is loopback-only")
 		return
 	}
@@ -216,6 +216,14 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	writeBackendError(w, err)
 }
 
+// isLocalCaller reports whether r came over loopback or a Unix socket.
+func isLocalCaller(r *http.Request) bool {
+	if _, ok := r.Context().Value(http.LocalAddrContextKey).(*net.UnixAddr); ok {
+		return true
+	}
+	return isLoopback(r.RemoteAddr)
+}
+
 func isLoopback(remoteAddr string) bool {
 	host, _, err := net.SplitHostPort(remoteAddr)
 	if err != nil {
@@ -2,6 +2,7 @@ package orchestrator
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -80,3 +81,18 @@ func TestAPIAcceptsTrackedLoopbackSession(t *testing.T) {
 		t.Fatalf("status=%d, want=%d; body=%s", res.Code, http.StatusOK, res.Body.String())
 	}
 }
+
+func TestAPIAcceptsUnixSocketCaller(t *testing.T) {
+	handler := NewHandler(&stubBackend{tracked: map[string]bool{"session-1": true}})
+	req := httptest.NewRequest(http.MethodGet, "/v1/agent/sessions", nil)
+	req.RemoteAddr = "@"
+	req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, &net.UnixAddr{Name: "/run/agentd.sock", Net: "unix"}))
+	req.Header.Set(SessionHeader, "session-1")
+	res := httptest.NewRecorder()
+
+	handler.ServeHTTP(res, req)
+
+	if res.Code != http.StatusOK {
+		t.Fatalf("status=%d, want=%d; body=%s", res.Code, http.StatusOK, res.Body.String())
+	}
+}
@@ -0,0 +1,238 @@
+// Package policy evaluates host-side approval rules before a permission
+// request is escalated to the control plane.
+//
+// Rules are read from a YAML file and checked in order; the first rule whose
+// conditions all hold decides. An allow or deny outcome answers the hook at
+// once, while ask (and no match at all) leaves the request to a human. The
+// file is re-read when its modification time changes, so edits take effect
+// without restarting agentd.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+	ActionAsk   = "ask"
+)
+
+// shellMetacharacters run or feed another command: ; & | chain and pipe,
+// $ and backticks expand and substitute, < and > redirect, and a newline
+// starts a new command.
+const shellMetacharacters = ";&|$`<>\n\r"
+
+// Rule matches a permission request. Empty conditions match everything; a
+// rule with path conditions only matches requests that name at least one
+// path. An allow rule with a command condition never matches a command
+// that chains, pipes, substitutes or redirects, since its pattern only
+// vouches for the part it matched.
+type Rule struct {
+	Name      string   `yaml:"name"`
+	Action    string   `yaml:"action"`
+	Tools     []string `yaml:"tools"`
+	Command   string   `yaml:"command"`
+	Providers []string `yaml:"providers"`
+	Groups    []string `yaml:"groups"`
+	// PathPrefix requires every path to lie under it. A relative prefix is
+	// taken from the repository root.
+	PathPrefix string `yaml:"path_prefix"`
+	// InRepo requires every path to be inside (true) or at least one path
+	// to be outside (false) the session's repository.
+	InRepo *bool `yaml:"in_repo"`
+
+	command *regexp.Regexp
+}
+
+// Request describes a pending permission request.
+type Request struct {
+	Provider string
+	GroupID  string
+	ToolName string
+	Command  string
+	// Paths are the files the tool would touch. Relative paths are taken
+	// from CWD.
+	Paths    []string
+	CWD      string
+	RepoRoot string
+}
+
+// Decision names the rule that matched and its outcome.
+type Decision struct {
+	Rule   string
+	Action string
+}
+
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Parse reads and validates a rules document.
+func Parse(data []byte) (*Policy, error) {
+	var policy Policy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return nil, err
+	}
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		switch rule.Action {
+		case ActionAllow, ActionDeny, ActionAsk:
+		default:
+			return nil, fmt.Errorf("rule %q: action must be allow, deny or ask", rule.Name)
+		}
+		if rule.Command != "" {
+			re, err := regexp.Compile(rule.Command)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: command: %w", rule.Name, err)
+			}
+			rule.command = re
+		}
+	}
+	return &policy, nil
+}
+
+// Evaluate returns the first rule matching req.
+func (p *Policy) Evaluate(req Request) (Decision, bool) {
+	if p == nil {
+		return Decision{}, false
+	}
+	for _, rule := range p.Rules {
+		if rule.matches(req) {
+			return Decision{Rule: rule.Name, Action: rule.Action}, true
+		}
+	}
+	return Decision{}, false
+}
+
+func (r Rule) matches(req Request) bool {
+	if len(r.Tools) > 0 && !containsFold(r.Tools, req.ToolName) {
+		return false
+	}
+	if len(r.Providers) > 0 && !containsFold(r.Providers, req.Provider) {
+		return false
+	}
+	if len(r.Groups) > 0 && !containsFold(r.Groups, req.GroupID) {
+		return false
+	}
+	if r.command != nil && (req.Command == "" || !r.command.MatchString(req.Command)) {
+		return false
+	}
+	if r.command != nil && r.Action == ActionAllow && strings.ContainsAny(req.Command, shellMetacharacters) {
+		return false
+	}
+	if r.PathPrefix == "" && r.InRepo == nil {
+		return true
+	}
+	if len(req.Paths) == 0 {
+		return false
+	}
+
+	paths := make([]string, 0, len(req.Paths))
+	for _, path := range req.Paths {
+		paths = append(paths, resolve(req.CWD, path))
+	}
+	if r.PathPrefix != "" {
+		prefix := r.PathPrefix
+		if !filepath.IsAbs(prefix) {
+			if req.RepoRoot == "" {
+				return false
+			}
+			prefix = filepath.Join(req.RepoRoot, prefix)
+		}
+		for _, path := range paths {
+			if !within(prefix, path) {
+				return false
+			}
+		}
+	}
+	if r.InRepo != nil {
+		outside := req.RepoRoot == ""
+		for _, path := range paths {
+			if !outside && !within(req.RepoRoot, path) {
+				outside = true
+			}
+		}
+		if *r.InRepo == outside {
+			return false
+		}
+	}
+	return true
+}
+
+func resolve(cwd, path string) string {
+	if !filepath.IsAbs(path) && cwd != "" {
+		path = filepath.Join(cwd, path)
+	}
+	return filepath.Clean(path)
+}
+
+func within(root, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(root), path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// File is a rules file that reloads itself when it changes on disk.
+type File struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	policy  *Policy
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Policy returns the current rules. When the file fails to load, the last
+// good rules stay in effect and the error is returned alongside them.
+func (f *File) Policy() (*Policy, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			f.policy = nil
+			f.modTime = time.Time{}
+			return nil, nil
+		}
+		return f.policy, err
+	}
+	if f.policy != nil && info.ModTime().Equal(f.modTime) {
+		return f.policy, nil
+	}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return f.policy, err
+	}
+	policy, err := Parse(data)
+	if err != nil {
+		return f.policy, fmt.Errorf("%s: %w", f.path, err)
+	}
+	f.policy = policy
+	f.modTime = info.ModTime()
+	return policy, nil
+}
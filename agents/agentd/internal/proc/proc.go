@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Entry struct {
@@ -64,10 +65,37 @@ func TakeSnapshot() *Snapshot {
 }
 
 func (s *Snapshot) HasDescendantCmd(pid int, substrings []string) bool {
+	return s.FindDescendantCmd(pid, substrings) > 0
+}
+
+// FindDescendantCmd returns the first process at or below pid, breadth
+// first, whose command line contains one of substrings, or 0.
+func (s *Snapshot) FindDescendantCmd(pid int, substrings []string) int {
+	for _, current := range s.Descendants(pid) {
+		entry, ok := s.entries[current]
+		if !ok {
+			continue
+		}
+		haystack := entry.Cmdline
+		if haystack == "" {
+			haystack = entry.Comm
+		}
+		for _, substr := range substrings {
+			if substr != "" && strings.Contains(haystack, substr) {
+				return current
+			}
+		}
+	}
+	return 0
+}
+
+// Descendants returns pid and every process below it, breadth first.
+func (s *Snapshot) Descendants(pid int) []int {
 	if s == nil || pid <= 0 {
-		return false
+		return nil
 	}
 
+	var result []int
 	queue := []int{pid}
 	visited := make(map[int]struct{})
 	for len(queue) > 0 {
@@ -77,25 +105,86 @@ func (s *Snapshot) HasDescendantCmd(pid int, substrings []string) bool {
 			continue
 		}
 		visited[current] = struct{}{}
-
-		if entry, ok := s.entries[current]; ok {
-			haystack := entry.Cmdline
-			if haystack == "" {
-				haystack = entry.Comm
-			}
-			for _, substr := range substrings {
-				if substr != "" && strings.Contains(haystack, substr) {
-					return true
-				}
-			}
-		}
+		result = append(result, current)
 
 		if kids := s.children[current]; len(kids) > 0 {
 			queue = append(queue, kids...)
 		}
 	}
+	return result
+}
+
+// Ancestors returns pid and each of its parents up to, but not including,
+// init. It reads /proc directly rather than a snapshot so processes that
+// started after the last snapshot are still found.
+func Ancestors(pid int) []int {
+	var result []int
+	seen := make(map[int]struct{})
+	for pid > 1 {
+		if _, ok := seen[pid]; ok {
+			break
+		}
+		seen[pid] = struct{}{}
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			break
+		}
+		result = append(result, pid)
+		_, ppid, ok := parseStat(string(stat))
+		if !ok {
+			break
+		}
+		pid = ppid
+	}
+	return result
+}
+
+// StartTime returns when pid started, from its stat start time and the boot
+// time in /proc/stat.
+func StartTime(pid int) (time.Time, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return time.Time{}, err
+	}
+	text := string(stat)
+	rparen := strings.LastIndex(text, ")")
+	if rparen == -1 || rparen+2 > len(text) {
+		return time.Time{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	// Fields after the command start at state (field 3); starttime is 22.
+	rest := strings.Fields(text[rparen+2:])
+	if len(rest) < 20 {
+		return time.Time{}, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+	ticks, err := strconv.ParseInt(rest[19], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	bootTime, err := readBootTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return bootTime.Add(time.Duration(ticks) * time.Second / clockTicksPerSecond), nil
+}
+
+// clockTicksPerSecond is USER_HZ, which Linux fixes at 100 for userspace.
+const clockTicksPerSecond = 100
 
-	return false
+func readBootTime() (time.Time, error) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("btime not found in /proc/stat")
 }
 
 func parsePID(name string) (int, bool) {
@@ -115,11 +115,24 @@ type SpawnSessionPayload struct {
 	Env              map[string]string        `json:"env,omitempty"`
 }
 
+// SpawnJobPayload queues a headless provider run. Jobs start in Priority
+// order, highest first, as the provider's concurrency limit allows. A
+// nonzero TimeoutMS stops the job's process group when it runs longer.
+// GroupID puts the job's spend toward that group's budgets.
 type SpawnJobPayload struct {
-	Provider string            `json:"provider"`
-	CWD      string            `json:"cwd"`
-	Prompt   string            `json:"prompt"`
-	Env      map[string]string `json:"env,omitempty"`
+	Provider  string            `json:"provider"`
+	GroupID   string            `json:"group_id,omitempty"`
+	CWD       string            `json:"cwd"`
+	Prompt    string            `json:"prompt"`
+	Env       map[string]string `json:"env,omitempty"`
+	TimeoutMS int64             `json:"timeout_ms,omitempty"`
+	Priority  int               `json:"priority,omitempty"`
+}
+
+// CancelJobPayload stops a running headless job. The process group gets
+// SIGTERM, then SIGKILL after GraceMS (default 5000).
+type CancelJobPayload struct {
+	GraceMS int64 `json:"grace_ms,omitempty"`
 }
 
 type ForkPayload struct {
@@ -150,16 +163,195 @@ type CapturePanePayload struct {
 	BeforeLine *int   `json:"before_line,omitempty"`
 }
 
+// SearchPanesPayload greps managed pane scrollback. An empty filter searches
+// every pane on the host; SearchID and AfterMatch page through a prior result.
+type SearchPanesPayload struct {
+	Pattern      string   `json:"pattern"`
+	Literal      bool     `json:"literal,omitempty"`
+	IgnoreCase   bool     `json:"ignore_case,omitempty"`
+	SessionIDs   []string `json:"session_ids,omitempty"`
+	GroupID      string   `json:"group_id,omitempty"`
+	Provider     string   `json:"provider,omitempty"`
+	ContextLines int      `json:"context_lines,omitempty"`
+	PageSize     int      `json:"page_size,omitempty"`
+	SearchID     string   `json:"search_id,omitempty"`
+	AfterMatch   *int     `json:"after_match,omitempty"`
+}
+
+// SearchPaneMatch is one matching scrollback line. LineNumber is 1-based from
+// the oldest retained history line of the pane.
+type SearchPaneMatch struct {
+	SessionID  string   `json:"session_id"`
+	PaneID     string   `json:"pane_id"`
+	LineNumber int      `json:"line_number"`
+	Line       string   `json:"line"`
+	Before     []string `json:"before,omitempty"`
+	After      []string `json:"after,omitempty"`
+}
+
+// RegisterWatchPayload installs an output watch. Scope fields are combined and
+// an empty scope watches every pane. Registering an existing command watch ID
+// replaces it.
+type RegisterWatchPayload struct {
+	WatchID      string   `json:"watch_id,omitempty"`
+	Pattern      string   `json:"pattern"`
+	Literal      bool     `json:"literal,omitempty"`
+	IgnoreCase   bool     `json:"ignore_case,omitempty"`
+	SessionIDs   []string `json:"session_ids,omitempty"`
+	GroupID      string   `json:"group_id,omitempty"`
+	Provider     string   `json:"provider,omitempty"`
+	ContextLines int      `json:"context_lines,omitempty"`
+	CooldownMs   int      `json:"cooldown_ms,omitempty"`
+	SetStatus    string   `json:"set_status,omitempty"`
+	Notify       bool     `json:"notify,omitempty"`
+	SendKeys     []string `json:"send_keys,omitempty"`
+}
+
+type RemoveWatchPayload struct {
+	WatchID string `json:"watch_id"`
+}
+
+// SetBudgetPayload installs a token or cost budget. Setting an existing
+// command budget ID replaces it, which also lifts a hold when the new limit
+// is higher.
+type SetBudgetPayload struct {
+	BudgetID    string  `json:"budget_id,omitempty"`
+	Scope       string  `json:"scope"`
+	SessionID   string  `json:"session_id,omitempty"`
+	GroupID     string  `json:"group_id,omitempty"`
+	Unit        string  `json:"unit"`
+	Limit       int64   `json:"limit"`
+	SoftPercent float64 `json:"soft_percent,omitempty"`
+	Action      string  `json:"action,omitempty"`
+}
+
+type RemoveBudgetPayload struct {
+	BudgetID string `json:"budget_id"`
+}
+
+// ReadRecordingPayload reads a session's recorded pane output. Since and Until
+// are RFC3339 timestamps; offsets index the session's raw output stream. Omitted
+// bounds leave that side of the range open.
+type ReadRecordingPayload struct {
+	Since       string `json:"since,omitempty"`
+	Until       string `json:"until,omitempty"`
+	StartOffset int64  `json:"start_offset,omitempty"`
+	EndOffset   int64  `json:"end_offset,omitempty"`
+	MaxBytes    int    `json:"max_bytes,omitempty"`
+}
+
+// RecordingChunk is one recorded write. Data is base64 because raw terminal
+// output is not guaranteed to be valid UTF-8.
+type RecordingChunk struct {
+	At     string `json:"at"`
+	Offset int64  `json:"offset"`
+	Data   string `json:"data"`
+}
+
+type ReadRecordingResult struct {
+	Chunks              []RecordingChunk `json:"chunks"`
+	RetainedStartOffset int64            `json:"retained_start_offset"`
+	RetainedEndOffset   int64            `json:"retained_end_offset"`
+	HasMore             bool             `json:"has_more,omitempty"`
+	NextOffset          int64            `json:"next_offset,omitempty"`
+}
+
+// ExportCastPayload renders recorded output as an asciinema v2 cast. Publish
+// writes the file to the file bridge out dir instead of returning it inline.
+type ExportCastPayload struct {
+	Since         string  `json:"since,omitempty"`
+	Until         string  `json:"until,omitempty"`
+	Title         string  `json:"title,omitempty"`
+	IdleTimeLimit float64 `json:"idle_time_limit,omitempty"`
+	Publish       bool    `json:"publish,omitempty"`
+}
+
+// ListCommandsPayload asks for a shell session's recent commands, oldest
+// first. Limit 0 returns everything retained.
+type ListCommandsPayload struct {
+	Limit int `json:"limit,omitempty"`
+}
+
+// BroadcastInputPayload types the same text into several sessions. Targets
+// are every live session matching GroupID and Provider (both, when both are
+// set), narrowed to SessionIDs when given.
+type BroadcastInputPayload struct {
+	SessionIDs []string `json:"session_ids,omitempty"`
+	GroupID    string   `json:"group_id,omitempty"`
+	Provider   string   `json:"provider,omitempty"`
+	Text       string   `json:"text"`
+	Enter      bool     `json:"enter"`
+}
+
+// ReadScreenPayload asks for the modelled screen of a session's pane. The
+// text lines are always returned; IncludeANSI adds a base64 repaint with
+// colours and modes.
+type ReadScreenPayload struct {
+	IncludeANSI bool `json:"include_ansi,omitempty"`
+}
+
+// CaptureTranscriptPayload pages through a session's provider transcript.
+// Format "normalized" returns TranscriptEntry values instead of the
+// provider's own records; IncludeRaw attaches each record to its entry.
 type CaptureTranscriptPayload struct {
-	PageSize    int  `json:"page_size,omitempty"`
-	BeforeEntry *int `json:"before_entry,omitempty"`
+	PageSize    int    `json:"page_size,omitempty"`
+	BeforeEntry *int   `json:"before_entry,omitempty"`
+	Format      string `json:"format,omitempty"`
+	IncludeRaw  bool   `json:"include_raw,omitempty"`
 }
 
+const (
+	TranscriptFormatRaw        = "raw"
+	TranscriptFormatNormalized = "normalized"
+)
+
 type CaptureTranscriptResult struct {
 	Entries      []map[string]any `json:"entries"`
 	FirstEntry   int              `json:"first_entry"`
 	TotalEntries int              `json:"total_entries"`
 	Source       string           `json:"source"`
+	Format       string           `json:"format,omitempty"`
+}
+
+// TranscriptEntry is one transcript record in a provider-neutral shape.
+// Kind is "message", "tool_result", "event" or "unparseable". Role is
+// "user", "assistant", "system" or "tool".
+type TranscriptEntry struct {
+	Index     int                  `json:"index"`
+	Kind      string               `json:"kind"`
+	Role      string               `json:"role,omitempty"`
+	Event     string               `json:"event,omitempty"`
+	Timestamp string               `json:"timestamp,omitempty"`
+	Model     string               `json:"model,omitempty"`
+	Text      []TranscriptText     `json:"text,omitempty"`
+	ToolCalls []TranscriptToolCall `json:"tool_calls,omitempty"`
+	Usage     *TranscriptUsage     `json:"usage,omitempty"`
+	Raw       map[string]any       `json:"raw,omitempty"`
+}
+
+// TranscriptText is a block of text; Type is "text" or "thinking".
+type TranscriptText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// TranscriptToolCall is a tool invocation or, on a tool_result entry, its
+// result. Output is filled on the call too when its result is on the same
+// page.
+type TranscriptToolCall struct {
+	ID      string         `json:"id,omitempty"`
+	Name    string         `json:"name,omitempty"`
+	Input   map[string]any `json:"input,omitempty"`
+	Output  *string        `json:"output,omitempty"`
+	IsError bool           `json:"is_error,omitempty"`
+}
+
+type TranscriptUsage struct {
+	InputTokens         int64 `json:"input_tokens,omitempty"`
+	OutputTokens        int64 `json:"output_tokens,omitempty"`
+	CacheReadTokens     int64 `json:"cache_read_tokens,omitempty"`
+	CacheCreationTokens int64 `json:"cache_creation_tokens,omitempty"`
+	ReasoningTokens     int64 `json:"reasoning_tokens,omitempty"`
 }
 
 type CopyToSessionPayload struct {
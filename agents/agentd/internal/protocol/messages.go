@@ -32,11 +32,14 @@ const (
 	TypeTerminalAttach           = "terminal.attach"
 	TypeTerminalInput            = "terminal.input"
 	TypeTerminalResize           = "terminal.resize"
+	TypeTerminalAck              = "terminal.ack"
 	TypeTerminalNavigate         = "terminal.navigate"
 	TypeTerminalNavigationResult = "terminal.navigation_result"
 	TypeTerminalDetach           = "terminal.detach"
 	TypeTerminalControl          = "terminal.control"
 	TypeTerminalOutput           = "terminal.output"
+	TypeTerminalClipboard        = "terminal.clipboard"
+	TypeTerminalLink             = "terminal.link"
 	TypeTerminalAttached         = "terminal.attached"
 	TypeTerminalDetached         = "terminal.detached"
 	TypeTerminalError            = "terminal.error"
@@ -106,6 +109,7 @@ type HostCapabilities struct {
 	FileBridgeDropDir       string          `json:"file_bridge_drop_dir,omitempty"`
 	FileBridgeOutDir        string          `json:"file_bridge_out_dir,omitempty"`
 	FileBridgeMaxFileBytes  int64           `json:"file_bridge_max_file_bytes,omitempty"`
+	Recording               *bool           `json:"recording,omitempty"`
 	Providers               map[string]bool `json:"providers"`
 }
 
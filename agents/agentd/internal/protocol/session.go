@@ -101,6 +101,23 @@ func (metadata SessionMetadata) MarshalJSON() ([]byte, error) {
 	return json.Marshal(wire(metadata))
 }
 
+// with returns a copy of metadata with key set to value.
+func (metadata SessionMetadata) with(key string, value any) (*SessionMetadata, error) {
+	data, err := metadata.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = map[string]any{}
+	}
+	fields[key] = value
+	return NewSessionMetadata(fields), nil
+}
+
 func (metadata *SessionMetadata) UnmarshalJSON(data []byte) error {
 	type wire SessionMetadata
 	var decoded wire
@@ -112,6 +129,25 @@ func (metadata *SessionMetadata) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// wireProviders are the provider names the control plane's session schema
+// accepts. Config-defined providers are sent as "unknown" with their name in
+// metadata.provider_name.
+var wireProviders = map[string]bool{
+	"claude_code": true,
+	"codex":       true,
+	"gemini_cli":  true,
+	"opencode":    true,
+	"cursor":      true,
+	"aider":       true,
+	"continue":    true,
+	"shell":       true,
+	"unknown":     true,
+}
+
+// ProviderNameMetadataKey carries the real name of a provider the control
+// plane does not know.
+const ProviderNameMetadataKey = "provider_name"
+
 type SessionUpsert struct {
 	ID             string           `json:"id"`
 	HostID         string           `json:"host_id,omitempty"`
@@ -136,3 +172,23 @@ type SessionUpsert struct {
 	IdledAt        NullableString   `json:"idled_at,omitempty"`
 	ArchivedAt     NullableString   `json:"archived_at,omitempty"`
 }
+
+func (update SessionUpsert) MarshalJSON() ([]byte, error) {
+	type wire SessionUpsert
+	out := wire(update)
+	if out.Provider != "" && !wireProviders[out.Provider] {
+		// The control plane replaces metadata it is sent, so an upsert
+		// without metadata still carries provider_name alone.
+		base := SessionMetadata{}
+		if out.Metadata != nil {
+			base = *out.Metadata
+		}
+		metadata, err := base.with(ProviderNameMetadataKey, out.Provider)
+		if err != nil {
+			return nil, err
+		}
+		out.Metadata = metadata
+		out.Provider = "unknown"
+	}
+	return json.Marshal(out)
+}
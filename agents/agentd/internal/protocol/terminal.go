@@ -8,6 +8,8 @@ type TerminalAttachPayload struct {
 	Rows        int    `json:"rows,omitempty"`
 	ResumeToken string `json:"resume_token,omitempty"`
 	Letterbox   bool   `json:"letterbox,omitempty"`
+	// FlowWindow opts into credit-based flow control, in decoded output bytes.
+	FlowWindow int `json:"flow_window,omitempty"`
 }
 
 type TerminalInputPayload struct {
@@ -15,6 +17,13 @@ type TerminalInputPayload struct {
 	Data      string `json:"data"`
 }
 
+// TerminalAckPayload returns credit for Bytes of decoded terminal.output data
+// the viewer has consumed.
+type TerminalAckPayload struct {
+	ChannelID string `json:"channel_id"`
+	Bytes     int    `json:"bytes"`
+}
+
 type TerminalResizePayload struct {
 	ChannelID string `json:"channel_id"`
 	Cols      int    `json:"cols"`
@@ -52,6 +61,25 @@ type TerminalOutputPayload struct {
 	Encoding  string `json:"encoding,omitempty"`
 }
 
+// TerminalClipboardPayload carries an OSC 52 clipboard write from the viewed
+// pane. Data is base64, exactly as the application sent it.
+type TerminalClipboardPayload struct {
+	ChannelID string `json:"channel_id"`
+	PaneID    string `json:"pane_id,omitempty"`
+	Selection string `json:"selection"`
+	Data      string `json:"data"`
+}
+
+// TerminalLinkPayload reports an OSC 8 hyperlink printed in the viewed pane
+// and the text it covers.
+type TerminalLinkPayload struct {
+	ChannelID string `json:"channel_id"`
+	PaneID    string `json:"pane_id,omitempty"`
+	URI       string `json:"uri"`
+	LinkID    string `json:"link_id,omitempty"`
+	Text      string `json:"text,omitempty"`
+}
+
 type TerminalStatusPayload struct {
 	ChannelID   string `json:"channel_id"`
 	PaneID      string `json:"pane_id,omitempty"`
@@ -59,6 +87,7 @@ type TerminalStatusPayload struct {
 	ReadOnly    *bool  `json:"readonly,omitempty"`
 	ResumeToken string `json:"resume_token,omitempty"`
 	Resumed     *bool  `json:"resumed,omitempty"`
+	FlowWindow  int    `json:"flow_window,omitempty"`
 }
 
 type TerminalAuditPayload struct {
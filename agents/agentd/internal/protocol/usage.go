@@ -29,6 +29,7 @@ type ProviderUsagePayload struct {
 type SessionUsagePayload struct {
 	SessionID                      string   `json:"session_id"`
 	Provider                       string   `json:"provider"`
+	Source                         string   `json:"source,omitempty"`
 	InputTokens                    *int     `json:"input_tokens,omitempty"`
 	OutputTokens                   *int     `json:"output_tokens,omitempty"`
 	TotalTokens                    *int     `json:"total_tokens,omitempty"`
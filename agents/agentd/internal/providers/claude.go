@@ -3,6 +3,8 @@ package providers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -11,6 +13,7 @@ import (
 	"time"
 
 	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/hookauth"
 	"github.com/agent-command/agentd/internal/metrics"
 	"github.com/google/uuid"
 )
@@ -24,6 +27,11 @@ type ClaudeHookPayload struct {
 		HookPID     int    `json:"hook_pid"`
 	} `json:"meta"`
 	ApprovalID string `json:"-"`
+	// ExpiresAt is when a blocking request stops waiting; zero when it
+	// waits until a decision arrives.
+	ExpiresAt time.Time `json:"-"`
+	// Fallback is the approval wait fallback of a blocking request.
+	Fallback string `json:"-"`
 }
 
 type ApprovalDecision struct {
@@ -34,16 +42,31 @@ type ApprovalDecision struct {
 
 type ClaudeHookHandler func(payload ClaudeHookPayload) (*ApprovalDecision, error)
 
+// ErrApprovalNotRequested is returned by a hook handler that declines to
+// raise an approval for a held hook, such as when the session already has
+// one pending. The hook is released without a decision instead of waiting
+// for one that will never arrive.
+var ErrApprovalNotRequested = errors.New("approval not requested")
+
 type ClaudeProvider struct {
 	cfg                 *config.ClaudeConfig
 	server              *http.Server
 	handler             ClaudeHookHandler
 	codexHandler        ClaudeHookHandler
+	geminiHandler       ClaudeHookHandler
 	orchestratorHandler http.Handler
+	auth                *hookauth.Authenticator
 
 	// Pending approval requests waiting for decisions
 	pendingApprovals map[string]chan *ApprovalDecision
 	mu               sync.Mutex
+
+	// waitFunc picks the wait settings for a blocking request; nil uses
+	// the host-wide settings. The reminder and expiry handlers observe
+	// the wait and may be nil.
+	waitFunc        func(payload ClaudeHookPayload) config.ApprovalWaitConfig
+	reminderHandler func(payload ClaudeHookPayload, reminder int)
+	expiredHandler  func(payload ClaudeHookPayload, fallback string)
 }
 
 func NewClaudeProvider(cfg *config.ClaudeConfig) *ClaudeProvider {
@@ -61,6 +84,27 @@ func (p *ClaudeProvider) SetCodexHookHandler(handler ClaudeHookHandler) {
 	p.codexHandler = handler
 }
 
+func (p *ClaudeProvider) SetGeminiHookHandler(handler ClaudeHookHandler) {
+	p.geminiHandler = handler
+}
+
+func (p *ClaudeProvider) SetApprovalWaitFunc(fn func(payload ClaudeHookPayload) config.ApprovalWaitConfig) {
+	p.waitFunc = fn
+}
+
+func (p *ClaudeProvider) SetApprovalReminderHandler(handler func(payload ClaudeHookPayload, reminder int)) {
+	p.reminderHandler = handler
+}
+
+func (p *ClaudeProvider) SetApprovalExpiredHandler(handler func(payload ClaudeHookPayload, fallback string)) {
+	p.expiredHandler = handler
+}
+
+// SetAuthenticator puts every endpoint except /metrics behind auth.
+func (p *ClaudeProvider) SetAuthenticator(auth *hookauth.Authenticator) {
+	p.auth = auth
+}
+
 func (p *ClaudeProvider) SetOrchestratorHandler(handler http.Handler) {
 	p.orchestratorHandler = handler
 }
@@ -69,14 +113,36 @@ func (p *ClaudeProvider) Start() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/v1/hooks/claude", p.handleHook)
 	mux.HandleFunc("/v1/hooks/codex", p.handleCodexHook)
+	mux.HandleFunc("/v1/hooks/gemini", p.handleGeminiHook)
 	if p.orchestratorHandler != nil {
 		mux.Handle("/v1/agent/", p.orchestratorHandler)
 	}
 	mux.Handle("/metrics", metrics.Handler())
 
+	var handler http.Handler = mux
+	if p.auth != nil {
+		handler = p.auth.Wrap(mux)
+	}
 	p.server = &http.Server{
-		Addr:    p.cfg.HooksHTTPListen,
-		Handler: mux,
+		Addr:        p.cfg.HooksHTTPListen,
+		Handler:     handler,
+		ConnContext: hookauth.ConnContext,
+	}
+
+	if p.cfg.HooksSocket != "" {
+		listener, err := listenHooksSocket(p.cfg.HooksSocket, p.cfg.HooksSocketPerm(), p.cfg.HooksSocketOwner)
+		if err != nil {
+			return fmt.Errorf("hooks socket: %w", err)
+		}
+		go func() {
+			log.Printf("Claude hooks HTTP server listening on unix:%s", p.cfg.HooksSocket)
+			if err := p.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				log.Printf("Claude hooks socket error: %v", err)
+			}
+		}()
+	}
+	if p.cfg.HooksHTTPListen == config.HooksHTTPListenOff {
+		return nil
 	}
 
 	go func() {
@@ -115,6 +181,7 @@ func (p *ClaudeProvider) handleHook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	authenticatedSession(r, &payload)
 
 	// Check if this is a permission request that needs to wait
 	var hookData map[string]any
@@ -126,51 +193,22 @@ func (p *ClaudeProvider) handleHook(w http.ResponseWriter, r *http.Request) {
 			approvalID := uuid.New().String()
 			payload.ApprovalID = approvalID
 
-			// Create channel for decision
-			decisionCh := make(chan *ApprovalDecision, 1)
-			p.mu.Lock()
-			p.pendingApprovals[approvalID] = decisionCh
-			p.mu.Unlock()
-
-			// Call handler (async, will send approval to control plane)
-			if p.handler != nil {
-				go func() {
-					decision, _ := p.handler(payload)
-					if decision != nil {
-						p.DeliverDecision(approvalID, decision)
-					}
-				}()
-			}
-
-			// Wait for decision (with timeout)
-			select {
-			case decision := <-decisionCh:
-				p.mu.Lock()
-				delete(p.pendingApprovals, approvalID)
-				p.mu.Unlock()
-
-				if decision != nil {
-					// Return Claude-compatible decision JSON
-					claudeDecision := map[string]any{
-						"hookSpecificOutput": map[string]any{
-							"hookEventName": "PermissionRequest",
-							"decision": map[string]any{
-								"behavior": decision.Decision,
-							},
+			if decision := p.awaitDecision(r.Context(), approvalID, p.handler, payload); decision != nil {
+				// Return Claude-compatible decision JSON
+				claudeDecision := map[string]any{
+					"hookSpecificOutput": map[string]any{
+						"hookEventName": "PermissionRequest",
+						"decision": map[string]any{
+							"behavior": decision.Decision,
 						},
-					}
-					if decision.UpdatedInput != nil {
-						claudeDecision["hookSpecificOutput"].(map[string]any)["decision"].(map[string]any)["updatedInput"] = decision.UpdatedInput
-					}
-					w.Header().Set("Content-Type", "application/json")
-					json.NewEncoder(w).Encode(claudeDecision)
-					return
+					},
 				}
-
-			case <-time.After(10 * time.Minute):
-				p.mu.Lock()
-				delete(p.pendingApprovals, approvalID)
-				p.mu.Unlock()
+				if decision.UpdatedInput != nil {
+					claudeDecision["hookSpecificOutput"].(map[string]any)["decision"].(map[string]any)["updatedInput"] = decision.UpdatedInput
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(claudeDecision)
+				return
 			}
 
 			// No decision - return empty (Claude will show dialog)
@@ -204,6 +242,23 @@ func (p *ClaudeProvider) handleCodexHook(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	authenticatedSession(r, &payload)
+
+	var hookData map[string]any
+	if err := json.Unmarshal(payload.Hook, &hookData); err == nil && IsApprovalHook(HookName(hookData), hookData) {
+		approvalID := uuid.New().String()
+		payload.ApprovalID = approvalID
+
+		if decision := p.awaitDecision(r.Context(), approvalID, p.codexHandler, payload); decision != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(codexDecisionResponse(approvalID, decision))
+			return
+		}
+
+		// No decision - Codex falls back to its own prompt.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
 
 	if p.codexHandler != nil {
 		go p.codexHandler(payload)
@@ -212,7 +267,201 @@ func (p *ClaudeProvider) handleCodexHook(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// DeliverDecision delivers an approval decision to a waiting hook
+// authenticatedSession replaces the session a hook claims with the one its
+// request was authenticated as, so one pane cannot report for another.
+func authenticatedSession(r *http.Request, payload *ClaudeHookPayload) {
+	if sessionID, ok := hookauth.SessionFromContext(r.Context()); ok {
+		payload.Meta.ACSessionID = sessionID
+	}
+}
+
+// HookName returns the event name a hook payload reports, checking the
+// keys the supported CLIs use for it.
+func HookName(hookData map[string]any) string {
+	for _, key := range []string{"hook_event_name", "hookEventName", "hook_name", "event_type", "type", "name"} {
+		if val, ok := hookData[key].(string); ok {
+			return val
+		}
+	}
+	return ""
+}
+
+// ToolName returns the tool or command a hook payload is about.
+func ToolName(hookData map[string]any) string {
+	if val, ok := hookData["tool_name"].(string); ok {
+		return val
+	}
+	if val, ok := hookData["tool"].(string); ok {
+		return val
+	}
+	if tool, ok := hookData["tool"].(map[string]any); ok {
+		if name, ok := tool["name"].(string); ok {
+			return name
+		}
+	}
+	if val, ok := hookData["command"].(string); ok {
+		return val
+	}
+	if val, ok := hookData["action"].(string); ok {
+		return val
+	}
+	return ""
+}
+
+// IsApprovalHook reports whether a hook is asking for an approval. The hooks
+// server decides from it whether to hold the request open, and the agent
+// whether to raise approval.requested, so both agree on every hook.
+func IsApprovalHook(hookName string, hookData map[string]any) bool {
+	lower := strings.ToLower(hookName)
+	if strings.Contains(lower, "permission") || strings.Contains(lower, "approval") || strings.Contains(lower, "plan") {
+		return true
+	}
+	if tool := ToolName(hookData); tool != "" {
+		if strings.Contains(strings.ToLower(tool), "plan") {
+			return true
+		}
+	}
+	if notif, ok := hookData["notification"].(map[string]any); ok {
+		if t, ok := notif["type"].(string); ok {
+			nt := strings.ToLower(t)
+			if strings.Contains(nt, "permission") || strings.Contains(nt, "approval") || strings.Contains(nt, "plan") {
+				return true
+			}
+		}
+	}
+	for _, key := range []string{"permission_request", "requires_permission", "requires_approval", "awaiting_approval"} {
+		if val, ok := hookData[key].(bool); ok && val {
+			return true
+		}
+	}
+	return false
+}
+
+// codexDecisionResponse builds the hook reply Codex reads an approval from.
+// The decision uses Codex's own review vocabulary alongside the allow/deny
+// behavior the Claude hooks use.
+func codexDecisionResponse(approvalID string, decision *ApprovalDecision) map[string]any {
+	review := "denied"
+	if decision.Decision == "allow" {
+		review = "approved"
+	}
+	response := map[string]any{
+		"approval_id": approvalID,
+		"decision":    review,
+		"behavior":    decision.Decision,
+	}
+	if decision.UpdatedInput != nil {
+		response["updated_input"] = decision.UpdatedInput
+	}
+	return response
+}
+
+// awaitDecision registers approvalID, runs handler so the request reaches the
+// control plane, and waits for DeliverDecision. While it waits, reminders
+// fire at the configured interval. When the timeout passes first, the
+// fallback decides: allow or deny answer the hook, and prompt returns nil
+// so the CLI asks in the pane. It also returns nil if the hook disconnects.
+func (p *ClaudeProvider) awaitDecision(ctx context.Context, approvalID string, handler ClaudeHookHandler, payload ClaudeHookPayload) *ApprovalDecision {
+	wait := p.approvalWait(payload)
+	payload.Fallback = wait.Fallback
+	var expired <-chan time.Time
+	if wait.Fallback != config.ApprovalFallbackWait {
+		timeout := time.Duration(wait.TimeoutMs) * time.Millisecond
+		payload.ExpiresAt = time.Now().Add(timeout).UTC()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var remind <-chan time.Time
+	if wait.ReminderMs > 0 {
+		ticker := time.NewTicker(time.Duration(wait.ReminderMs) * time.Millisecond)
+		defer ticker.Stop()
+		remind = ticker.C
+	}
+
+	decisionCh := make(chan *ApprovalDecision, 1)
+	p.mu.Lock()
+	p.pendingApprovals[approvalID] = decisionCh
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.pendingApprovals, approvalID)
+		p.mu.Unlock()
+	}()
+
+	// Call handler (async, will send approval to control plane)
+	if handler != nil {
+		go func() {
+			decision, err := handler(payload)
+			if decision != nil || errors.Is(err, ErrApprovalNotRequested) {
+				p.DeliverDecision(approvalID, decision)
+			}
+		}()
+	}
+
+	reminders := 0
+	for {
+		select {
+		case decision := <-decisionCh:
+			return decision
+		case <-remind:
+			reminders++
+			if p.reminderHandler != nil {
+				go p.reminderHandler(payload, reminders)
+			}
+		case <-expired:
+			if p.expiredHandler != nil {
+				go p.expiredHandler(payload, wait.Fallback)
+			}
+			switch wait.Fallback {
+			case config.ApprovalFallbackAllow, config.ApprovalFallbackDeny:
+				return &ApprovalDecision{Decision: wait.Fallback, Mode: "hook"}
+			}
+			return nil
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (p *ClaudeProvider) approvalWait(payload ClaudeHookPayload) config.ApprovalWaitConfig {
+	if p.waitFunc != nil {
+		return p.waitFunc(payload)
+	}
+	return p.cfg.ApprovalWait("")
+}
+
+// handleGeminiHook accepts Gemini CLI hook events. Gemini's permission
+// notification cannot hold the tool call, so decisions are delivered as
+// keystrokes and the hook returns at once.
+func (p *ClaudeProvider) handleGeminiHook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	var payload ClaudeHookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	authenticatedSession(r, &payload)
+
+	if p.geminiHandler != nil {
+		go p.geminiHandler(payload)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeliverDecision delivers an approval decision to a waiting hook. It
+// returns false when no hook is waiting on approvalID.
 func (p *ClaudeProvider) DeliverDecision(approvalID string, decision *ApprovalDecision) bool {
 	p.mu.Lock()
 	ch, ok := p.pendingApprovals[approvalID]
@@ -0,0 +1,203 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/agent-command/agentd/internal/config"
+)
+
+func postCodexHook(p *ClaudeProvider, hook string) *httptest.ResponseRecorder {
+	body := `{"hook":` + hook + `,"meta":{"tmux_pane":"%1"}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/hooks/codex", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	p.handleCodexHook(rec, req)
+	return rec
+}
+
+func TestCodexPermissionHookBlocksUntilDecision(t *testing.T) {
+	p := NewClaudeProvider(&config.ClaudeConfig{})
+	requested := make(chan string, 1)
+	p.SetCodexHookHandler(func(payload ClaudeHookPayload) (*ApprovalDecision, error) {
+		requested <- payload.ApprovalID
+		return nil, nil
+	})
+	go func() {
+		approvalID := <-requested
+		if !p.DeliverDecision(approvalID, &ApprovalDecision{Decision: "allow", UpdatedInput: map[string]any{"command": "ls"}}) {
+			t.Error("no hook was waiting on the approval")
+		}
+	}()
+
+	rec := postCodexHook(p, `{"hook_event_name":"PermissionRequest","tool_name":"shell","tool_input":{"command":"ls -la"}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status=%d", rec.Code)
+	}
+	var response map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response["decision"] != "approved" || response["behavior"] != "allow" || response["approval_id"] == "" {
+		t.Fatalf("response=%v", response)
+	}
+	if input, _ := response["updated_input"].(map[string]any); input["command"] != "ls" {
+		t.Fatalf("updated_input=%v", response["updated_input"])
+	}
+	if p.DeliverDecision(response["approval_id"].(string), &ApprovalDecision{Decision: "deny"}) {
+		t.Fatal("approval stayed pending after it was answered")
+	}
+}
+
+func TestCodexHookWithoutDecisionFallsBackToPrompt(t *testing.T) {
+	p := NewClaudeProvider(&config.ClaudeConfig{ApprovalWaitConfig: config.ApprovalWaitConfig{TimeoutMs: 20}})
+	calls := make(chan string, 2)
+	p.SetCodexHookHandler(func(payload ClaudeHookPayload) (*ApprovalDecision, error) {
+		calls <- payload.ApprovalID
+		return nil, nil
+	})
+
+	if rec := postCodexHook(p, `{"hook_event_name":"approval-requested","requires_approval":true}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("timed out approval status=%d", rec.Code)
+	}
+	if approvalID := <-calls; approvalID == "" {
+		t.Fatal("approval hook was not given an approval id")
+	}
+
+	// Other hooks never wait.
+	if rec := postCodexHook(p, `{"hook_event_name":"agent-turn-complete"}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("status=%d", rec.Code)
+	}
+	select {
+	case approvalID := <-calls:
+		if approvalID != "" {
+			t.Fatalf("non-approval hook got approval id %q", approvalID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler was not called")
+	}
+}
+
+func TestCodexHookReleasedWhenApprovalNotRequested(t *testing.T) {
+	p := NewClaudeProvider(&config.ClaudeConfig{ApprovalWaitConfig: config.ApprovalWaitConfig{TimeoutMs: 60000}})
+	p.SetCodexHookHandler(func(payload ClaudeHookPayload) (*ApprovalDecision, error) {
+		return nil, ErrApprovalNotRequested
+	})
+
+	done := make(chan int, 1)
+	go func() {
+		done <- postCodexHook(p, `{"hook_event_name":"PermissionRequest","tool_name":"shell"}`).Code
+	}()
+	select {
+	case code := <-done:
+		if code != http.StatusNoContent {
+			t.Fatalf("status=%d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hook stayed held after the handler declined the approval")
+	}
+}
+
+func TestIsApprovalHookMatchesPlanAndNotificationHooks(t *testing.T) {
+	cases := []struct {
+		name string
+		hook map[string]any
+		want bool
+	}{
+		{"permission", map[string]any{"hook_event_name": "PermissionRequest"}, true},
+		{"plan tool", map[string]any{"hook_event_name": "PreToolUse", "tool_name": "ExitPlanMode"}, true},
+		{"notification", map[string]any{"type": "notification", "notification": map[string]any{"type": "approval_required"}}, true},
+		{"flag", map[string]any{"hook_event_name": "exec", "requires_approval": true}, true},
+		{"turn", map[string]any{"hook_event_name": "agent-turn-complete"}, false},
+	}
+	for _, tc := range cases {
+		if got := IsApprovalHook(HookName(tc.hook), tc.hook); got != tc.want {
+			t.Errorf("%s: IsApprovalHook=%v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPermissionRequestRemindsThenAppliesGroupFallback(t *testing.T) {
+	p := NewClaudeProvider(&config.ClaudeConfig{})
+	p.SetApprovalWaitFunc(func(payload ClaudeHookPayload) config.ApprovalWaitConfig {
+		if payload.Meta.TmuxPane != "%1" {
+			t.Errorf("wait func got pane %q", payload.Meta.TmuxPane)
+		}
+		return config.ApprovalWaitConfig{TimeoutMs: 200, Fallback: config.ApprovalFallbackDeny, ReminderMs: 60}
+	})
+	expiresAt := make(chan time.Time, 1)
+	p.SetHookHandler(func(payload ClaudeHookPayload) (*ApprovalDecision, error) {
+		if payload.ApprovalID != "" {
+			expiresAt <- payload.ExpiresAt
+		}
+		return nil, nil
+	})
+	var mu sync.Mutex
+	var reminders []int
+	p.SetApprovalReminderHandler(func(payload ClaudeHookPayload, reminder int) {
+		mu.Lock()
+		reminders = append(reminders, reminder)
+		mu.Unlock()
+	})
+	expired := make(chan string, 1)
+	p.SetApprovalExpiredHandler(func(payload ClaudeHookPayload, fallback string) {
+		expired <- fallback
+	})
+
+	started := time.Now()
+	body := `{"hook":{"hook_name":"PermissionRequest","tool_name":"Bash"},"meta":{"tmux_pane":"%1"}}`
+	rec := httptest.NewRecorder()
+	p.handleHook(rec, httptest.NewRequest(http.MethodPost, "/v1/hooks/claude", strings.NewReader(body)))
+
+	var response struct {
+		HookSpecificOutput struct {
+			Decision struct {
+				Behavior string `json:"behavior"`
+			} `json:"decision"`
+		} `json:"hookSpecificOutput"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || response.HookSpecificOutput.Decision.Behavior != "deny" {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+	if got := <-expiresAt; got.Before(started.Add(200*time.Millisecond)) || got.After(time.Now().Add(time.Second)) {
+		t.Fatalf("expires_at=%v started=%v", got, started)
+	}
+	if fallback := <-expired; fallback != config.ApprovalFallbackDeny {
+		t.Fatalf("expired fallback=%q", fallback)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reminders) < 2 || reminders[0] != 1 || reminders[1] != 2 {
+		t.Fatalf("reminders=%v", reminders)
+	}
+}
+
+func TestPermissionRequestWaitFallbackIgnoresTimeout(t *testing.T) {
+	p := NewClaudeProvider(&config.ClaudeConfig{ApprovalWaitConfig: config.ApprovalWaitConfig{TimeoutMs: 10, Fallback: config.ApprovalFallbackWait}})
+	requested := make(chan ClaudeHookPayload, 1)
+	p.SetHookHandler(func(payload ClaudeHookPayload) (*ApprovalDecision, error) {
+		if payload.ApprovalID != "" {
+			requested <- payload
+		}
+		return nil, nil
+	})
+	go func() {
+		payload := <-requested
+		if !payload.ExpiresAt.IsZero() {
+			t.Errorf("waiting request has expiry %v", payload.ExpiresAt)
+		}
+		time.Sleep(50 * time.Millisecond)
+		p.DeliverDecision(payload.ApprovalID, &ApprovalDecision{Decision: "allow"})
+	}()
+
+	body := `{"hook":{"hook_name":"PermissionRequest","tool_name":"Bash"},"meta":{"tmux_pane":"%1"}}`
+	rec := httptest.NewRecorder()
+	p.handleHook(rec, httptest.NewRequest(http.MethodPost, "/v1/hooks/claude", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"behavior":"allow"`) {
+		t.Fatalf("status=%d body=%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/hookauth"
+)
+
+func TestHooksSocketAttributesHooksToCallingPane(t *testing.T) {
+	dir, err := os.MkdirTemp("", "agentd-hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "run", "agentd.sock")
+
+	p := NewClaudeProvider(&config.ClaudeConfig{
+		HooksHTTPListen: config.HooksHTTPListenOff,
+		HooksSocket:     socketPath,
+		HooksSocketMode: "0660",
+	})
+	p.SetAuthenticator(&hookauth.Authenticator{
+		PeerSession: func(pid int) string {
+			if pid == os.Getpid() {
+				return "session-1"
+			}
+			return ""
+		},
+	})
+	sessions := make(chan string, 1)
+	p.SetHookHandler(func(payload ClaudeHookPayload) (*ApprovalDecision, error) {
+		sessions <- payload.Meta.ACSessionID
+		return nil, nil
+	})
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o660 {
+		t.Fatalf("socket mode=%v", info.Mode().Perm())
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+	body := `{"hook":{"hook_event_name":"PreToolUse"},"meta":{"ac_session_id":"session-2"}}`
+	resp, err := client.Post("http://agentd/v1/hooks/claude", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		t.Fatalf("status=%d", resp.StatusCode)
+	}
+	if got := <-sessions; got != "session-1" {
+		t.Fatalf("hook attributed to %q", got)
+	}
+}
+
+func TestHooksSocketRefusesLiveSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "agentd-hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "agentd.sock")
+	live, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+	if _, err := listenHooksSocket(socketPath, 0o600, ""); err == nil {
+		t.Fatal("took over a socket another process is serving")
+	}
+
+	live.(*net.UnixListener).SetUnlinkOnClose(false)
+	live.Close()
+	listener, err := listenHooksSocket(socketPath, 0o600, "")
+	if err != nil {
+		t.Fatalf("stale socket: %v", err)
+	}
+	listener.Close()
+}
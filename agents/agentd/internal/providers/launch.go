@@ -24,47 +24,41 @@ type LaunchSpec struct {
 
 type LaunchTemplates struct {
 	templates map[string]config.ProviderLaunchTemplate
+	names     map[string]string
 }
 
 func NewLaunchTemplates(cfg *config.Config) *LaunchTemplates {
-	codexPath := "codex"
-	if cfg != nil && strings.TrimSpace(cfg.Providers.Codex.ExecPath) != "" {
-		codexPath = cfg.Providers.Codex.ExecPath
-	}
-	shellPath := "/bin/bash"
-	if cfg != nil && strings.TrimSpace(cfg.Spawn.DefaultShell) != "" {
-		shellPath = cfg.Spawn.DefaultShell
-	}
-	templates := map[string]config.ProviderLaunchTemplate{
-		"claude_code": {
-			Argv:         []string{"claude"},
-			HeadlessArgv: []string{"claude", "-p", "--output-format", "stream-json", "--verbose", promptPlaceholder},
-		},
-		"codex": {
-			Argv:         []string{codexPath},
-			HeadlessArgv: []string{codexPath, "exec", "--json", promptPlaceholder},
-		},
-		"gemini_cli": {Argv: []string{"gemini"}},
-		"opencode":   {Argv: []string{"opencode"}},
-		"aider":      {Argv: []string{"aider"}},
-		"cursor":     {Argv: []string{"cursor"}},
-		"continue":   {Argv: []string{"continue"}},
-		"shell":      {Argv: []string{shellPath, "-i"}},
+	t := &LaunchTemplates{
+		templates: make(map[string]config.ProviderLaunchTemplate),
+		names:     make(map[string]string),
+	}
+	for _, def := range Definitions(cfg) {
+		if def.Launch == nil {
+			continue
+		}
+		t.templates[def.Name] = *def.Launch
+		t.names[def.Name] = def.Name
+		for _, alias := range def.Aliases {
+			alias = strings.ToLower(strings.TrimSpace(alias))
+			if _, taken := t.names[alias]; alias != "" && !taken {
+				t.names[alias] = def.Name
+			}
+		}
 	}
 	if cfg != nil {
 		for provider, override := range cfg.Providers.LaunchTemplates {
-			normalized := normalizeLaunchProvider(provider)
+			normalized := t.normalize(provider)
 			if normalized == "" {
 				continue
 			}
-			templates[normalized] = mergeTemplate(templates[normalized], override)
+			t.templates[normalized] = mergeTemplate(t.templates[normalized], override)
 		}
 	}
-	return &LaunchTemplates{templates: templates}
+	return t
 }
 
 func (t *LaunchTemplates) Interactive(provider string, flags []string, requestEnv map[string]string) (LaunchSpec, error) {
-	normalizedProvider := normalizeLaunchProvider(provider)
+	normalizedProvider := t.normalize(provider)
 	template, ok := t.templates[normalizedProvider]
 	if !ok {
 		return LaunchSpec{}, fmt.Errorf("unsupported provider %q", provider)
@@ -82,6 +76,12 @@ func (t *LaunchTemplates) Interactive(provider string, flags []string, requestEn
 	return LaunchSpec{Argv: argv, Env: env, Preamble: preamble}, nil
 }
 
+// bashCommandLineURL expands, inside PS0, to the command about to run
+// percent-encoded byte by byte for the cmdline_url parameter of OSC 133;C.
+const bashCommandLineURL = `$(LC_ALL=C; __ac_c=$(HISTTIMEFORMAT= builtin history 1); __ac_c=${__ac_c#*[0-9]  }; ` +
+	`for ((__ac_i=0; __ac_i<${#__ac_c}; __ac_i++)); do __ac_b=${__ac_c:__ac_i:1}; ` +
+	`case $__ac_b in [A-Za-z0-9._~-]) printf %s "$__ac_b";; *) printf %%%02X "'$__ac_b";; esac; done)`
+
 func bashCommandMarkEnv(existing map[string]string) map[string]string {
 	const passthroughStart = "\x1bPtmux;\x1b"
 	const passthroughEnd = "\x1b\\"
@@ -92,12 +92,12 @@ func bashCommandMarkEnv(existing map[string]string) map[string]string {
 	return map[string]string{
 		"AC_COMMAND_MARKS": "osc133",
 		"PROMPT_COMMAND":   promptCommand,
-		"PS0":              passthroughStart + "\x1b]133;C\a" + passthroughEnd,
+		"PS0":              passthroughStart + "\x1b]133;C;cmdline_url=" + bashCommandLineURL + "\a" + passthroughEnd,
 	}
 }
 
 func (t *LaunchTemplates) Headless(provider, prompt string, requestEnv map[string]string) (LaunchSpec, error) {
-	template, ok := t.templates[normalizeLaunchProvider(provider)]
+	template, ok := t.templates[t.normalize(provider)]
 	if !ok {
 		return LaunchSpec{}, fmt.Errorf("unsupported provider %q", provider)
 	}
@@ -194,18 +194,8 @@ func sortedEnv(env map[string]string) []string {
 	return items
 }
 
-func normalizeLaunchProvider(provider string) string {
-	provider = strings.ToLower(strings.TrimSpace(provider))
-	switch provider {
-	case "claude", "claude_code":
-		return "claude_code"
-	case "gemini", "gemini_cli":
-		return "gemini_cli"
-	case "codex", "opencode", "aider", "cursor", "continue", "shell":
-		return provider
-	default:
-		return ""
-	}
+func (t *LaunchTemplates) normalize(provider string) string {
+	return t.names[strings.ToLower(strings.TrimSpace(provider))]
 }
 
 func mergeTemplate(base, override config.ProviderLaunchTemplate) config.ProviderLaunchTemplate {
@@ -85,6 +85,9 @@ func TestOwnedBashShellLaunchEnablesOSC133Passthrough(t *testing.T) {
 	if !strings.Contains(spec.Env["PROMPT_COMMAND"], "]133;A") || !strings.Contains(spec.Env["PROMPT_COMMAND"], "history -a") {
 		t.Fatalf("prompt command=%q", spec.Env["PROMPT_COMMAND"])
 	}
+	if !strings.Contains(spec.Env["PS0"], "]133;C;cmdline_url=$(") {
+		t.Fatalf("PS0 does not report the command line: %q", spec.Env["PS0"])
+	}
 	command, err := spec.ShellCommand()
 	if err != nil {
 		t.Fatal(err)
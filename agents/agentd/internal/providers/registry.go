@@ -0,0 +1,420 @@
+package providers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agent-command/agentd/internal/config"
+	"github.com/agent-command/agentd/internal/usage"
+)
+
+var screenStatuses = map[string]bool{
+	"RUNNING":              true,
+	"IDLE":                 true,
+	"WAITING_FOR_INPUT":    true,
+	"WAITING_FOR_APPROVAL": true,
+}
+
+var usagePatternGroups = map[string]bool{
+	"input_tokens":       true,
+	"output_tokens":      true,
+	"total_tokens":       true,
+	"cache_read_tokens":  true,
+	"cache_write_tokens": true,
+	"cost_usd":           true,
+}
+
+// Registry answers provider questions — which provider a pane runs, how to
+// approve it, what its screen says — from provider definitions rather than
+// per-name code.
+type Registry struct {
+	providers []*registeredProvider
+	byName    map[string]*registeredProvider
+}
+
+type registeredProvider struct {
+	def         config.ProviderDefinition
+	interactive bool
+	status      []statusPattern
+	usage       []*regexp.Regexp
+}
+
+type statusPattern struct {
+	status  string
+	pattern *regexp.Regexp
+}
+
+// PaneSignals is what provider detection looks at in a pane.
+type PaneSignals struct {
+	Command    string
+	Title      string
+	WindowName string
+	// HasDescendant reports whether a process with one of the names runs
+	// below the pane's shell. It may be nil.
+	HasDescendant func(names []string) bool
+}
+
+// BuiltinDefinitions returns the providers agentd knows without
+// configuration, in detection order. Legacy per-provider settings (codex
+// exec path, approval keys, default shell) are folded in.
+func BuiltinDefinitions(cfg *config.Config) []config.ProviderDefinition {
+	codexPath := "codex"
+	shellPath := "/bin/bash"
+	var claudeAllow, claudeDeny, codexAllow, codexDeny []string
+	if cfg != nil {
+		if strings.TrimSpace(cfg.Providers.Codex.ExecPath) != "" {
+			codexPath = cfg.Providers.Codex.ExecPath
+		}
+		if strings.TrimSpace(cfg.Spawn.DefaultShell) != "" {
+			shellPath = cfg.Spawn.DefaultShell
+		}
+		claudeAllow, claudeDeny = cfg.Providers.Claude.ApprovalAllowKeys, cfg.Providers.Claude.ApprovalDenyKeys
+		codexAllow, codexDeny = cfg.Providers.Codex.ApprovalAllowKeys, cfg.Providers.Codex.ApprovalDenyKeys
+	}
+	interactive := true
+	agent := func(name string, launch []string) config.ProviderDefinition {
+		return config.ProviderDefinition{
+			Name:        name,
+			Detect:      config.ProviderDetectionRule{Commands: []string{launch[0]}},
+			Interactive: &interactive,
+			Launch:      &config.ProviderLaunchTemplate{Argv: launch},
+		}
+	}
+
+	claude := agent("claude_code", []string{"claude"})
+	claude.Aliases = []string{"claude"}
+	claude.Launch.HeadlessArgv = []string{"claude", "-p", "--output-format", "stream-json", "--verbose", promptPlaceholder}
+	claude.ApprovalAllowKeys, claude.ApprovalDenyKeys = claudeAllow, claudeDeny
+
+	codex := agent("codex", []string{codexPath})
+	codex.Detect.Commands = []string{"codex"}
+	codex.Launch.HeadlessArgv = []string{codexPath, "exec", "--json", promptPlaceholder}
+	codex.ApprovalAllowKeys, codex.ApprovalDenyKeys = codexAllow, codexDeny
+
+	gemini := agent("gemini_cli", []string{"gemini"})
+	gemini.Aliases = []string{"gemini"}
+	gemini.Detect.Titles = []string{"gemini"}
+	// The gemini launcher is a node script, so the pane command is often
+	// just "node".
+	gemini.Detect.Descendants = []string{"gemini"}
+
+	notInteractive := false
+	return []config.ProviderDefinition{
+		claude,
+		codex,
+		gemini,
+		agent("opencode", []string{"opencode"}),
+		agent("cursor", []string{"cursor"}),
+		agent("aider", []string{"aider"}),
+		agent("continue", []string{"continue"}),
+		{
+			Name:        "shell",
+			Interactive: &notInteractive,
+			Launch:      &config.ProviderLaunchTemplate{Argv: []string{shellPath, "-i"}},
+		},
+		// Panes running something unrecognised; never spawned.
+		{Name: "unknown", Interactive: &interactive},
+	}
+}
+
+// Definitions merges configured definitions over the built-ins. Configured
+// providers are detected first, so a specific new CLI is not claimed by a
+// broader built-in rule.
+func Definitions(cfg *config.Config) []config.ProviderDefinition {
+	builtins := BuiltinDefinitions(cfg)
+	if cfg == nil || len(cfg.Providers.Definitions) == 0 {
+		return builtins
+	}
+	index := make(map[string]int, len(builtins))
+	for i, def := range builtins {
+		index[def.Name] = i
+	}
+	var merged []config.ProviderDefinition
+	used := make(map[string]bool)
+	for _, def := range cfg.Providers.Definitions {
+		name := strings.ToLower(strings.TrimSpace(def.Name))
+		if name == "" || used[name] {
+			continue
+		}
+		used[name] = true
+		def.Name = name
+		if i, ok := index[name]; ok {
+			def = mergeDefinition(builtins[i], def)
+		}
+		merged = append(merged, def)
+	}
+	for _, def := range builtins {
+		if !used[def.Name] {
+			merged = append(merged, def)
+		}
+	}
+	return merged
+}
+
+func mergeDefinition(base, override config.ProviderDefinition) config.ProviderDefinition {
+	merged := base
+	if override.Aliases != nil {
+		merged.Aliases = override.Aliases
+	}
+	if override.Detect.Commands != nil {
+		merged.Detect.Commands = override.Detect.Commands
+	}
+	if override.Detect.Titles != nil {
+		merged.Detect.Titles = override.Detect.Titles
+	}
+	if override.Detect.Descendants != nil {
+		merged.Detect.Descendants = override.Detect.Descendants
+	}
+	if override.Interactive != nil {
+		merged.Interactive = override.Interactive
+	}
+	if override.ApprovalAllowKeys != nil {
+		merged.ApprovalAllowKeys = override.ApprovalAllowKeys
+	}
+	if override.ApprovalDenyKeys != nil {
+		merged.ApprovalDenyKeys = override.ApprovalDenyKeys
+	}
+	if override.Launch != nil {
+		launch := mergeTemplate(config.ProviderLaunchTemplate{}, *override.Launch)
+		if base.Launch != nil {
+			launch = mergeTemplate(*base.Launch, *override.Launch)
+		}
+		merged.Launch = &launch
+	}
+	if override.StatusPatterns != nil {
+		merged.StatusPatterns = override.StatusPatterns
+	}
+	if override.UsagePatterns != nil {
+		merged.UsagePatterns = override.UsagePatterns
+	}
+	return merged
+}
+
+// NewRegistry builds the registry for cfg, rejecting invalid patterns and
+// statuses.
+func NewRegistry(cfg *config.Config) (*Registry, error) {
+	registry := &Registry{byName: make(map[string]*registeredProvider)}
+	for _, def := range Definitions(cfg) {
+		provider := &registeredProvider{def: def, interactive: def.Interactive != nil && *def.Interactive}
+		for _, rule := range def.StatusPatterns {
+			status := strings.ToUpper(strings.TrimSpace(rule.Status))
+			if !screenStatuses[status] {
+				return nil, fmt.Errorf("provider %s: unsupported status %q", def.Name, rule.Status)
+			}
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("provider %s: status pattern: %w", def.Name, err)
+			}
+			provider.status = append(provider.status, statusPattern{status: status, pattern: pattern})
+		}
+		for _, raw := range def.UsagePatterns {
+			pattern, err := regexp.Compile(raw)
+			if err != nil {
+				return nil, fmt.Errorf("provider %s: usage pattern: %w", def.Name, err)
+			}
+			for _, group := range pattern.SubexpNames()[1:] {
+				if group != "" && !usagePatternGroups[group] {
+					return nil, fmt.Errorf("provider %s: usage pattern group %q is not supported", def.Name, group)
+				}
+			}
+			provider.usage = append(provider.usage, pattern)
+		}
+		registry.providers = append(registry.providers, provider)
+		registry.byName[def.Name] = provider
+	}
+	for _, provider := range registry.providers {
+		for _, alias := range provider.def.Aliases {
+			alias = strings.ToLower(strings.TrimSpace(alias))
+			if _, taken := registry.byName[alias]; alias != "" && !taken {
+				registry.byName[alias] = provider
+			}
+		}
+	}
+	return registry, nil
+}
+
+// Normalize returns the canonical name for a provider name or alias, or ""
+// when it is not defined.
+func (r *Registry) Normalize(name string) string {
+	if provider := r.lookup(name); provider != nil {
+		return provider.def.Name
+	}
+	return ""
+}
+
+// Names returns the defined providers in detection order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for _, provider := range r.providers {
+		names = append(names, provider.def.Name)
+	}
+	return names
+}
+
+// Detect returns the provider whose rules match the pane, or "". Command
+// rules are checked for every provider before titles, and titles before
+// descendant processes.
+func (r *Registry) Detect(signals PaneSignals) string {
+	command := strings.ToLower(strings.TrimSpace(signals.Command))
+	if command != "" {
+		for _, provider := range r.providers {
+			if containsAny(command, provider.def.Detect.Commands) {
+				return provider.def.Name
+			}
+		}
+	}
+	title := strings.ToLower(strings.TrimSpace(signals.Title))
+	windowName := strings.ToLower(strings.TrimSpace(signals.WindowName))
+	for _, provider := range r.providers {
+		if containsAny(title, provider.def.Detect.Titles) || containsAny(windowName, provider.def.Detect.Titles) {
+			return provider.def.Name
+		}
+	}
+	if signals.HasDescendant != nil {
+		for _, provider := range r.providers {
+			if len(provider.def.Detect.Descendants) > 0 && signals.HasDescendant(provider.def.Detect.Descendants) {
+				return provider.def.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Interactive reports whether a quiet session of the provider is waiting for
+// input rather than idle.
+func (r *Registry) Interactive(name string) bool {
+	provider := r.lookup(name)
+	return provider != nil && provider.interactive
+}
+
+// ApprovalKeys returns the keystrokes that answer the provider's approval
+// prompt, or nil when it defines none.
+func (r *Registry) ApprovalKeys(name string, allow bool) []string {
+	provider := r.lookup(name)
+	if provider == nil {
+		return nil
+	}
+	if allow {
+		return provider.def.ApprovalAllowKeys
+	}
+	return provider.def.ApprovalDenyKeys
+}
+
+// HasStatusPatterns reports whether the provider's status is read from its
+// screen.
+func (r *Registry) HasStatusPatterns(name string) bool {
+	provider := r.lookup(name)
+	return provider != nil && len(provider.status) > 0
+}
+
+// ScreenStatus matches the provider's status patterns against screen text.
+// The second result is false when the provider has no status patterns, in
+// which case the caller keeps its own heuristics.
+func (r *Registry) ScreenStatus(name, screen string) (string, bool) {
+	provider := r.lookup(name)
+	if provider == nil || len(provider.status) == 0 {
+		return "", false
+	}
+	for _, rule := range provider.status {
+		if rule.pattern.MatchString(screen) {
+			return rule.status, true
+		}
+	}
+	return "", true
+}
+
+// ParseUsage extracts session usage from pane text using the provider's
+// usage patterns, falling back to the built-in parsers.
+func (r *Registry) ParseUsage(name, text string) *usage.SessionUsage {
+	provider := r.lookup(name)
+	if provider == nil || len(provider.usage) == 0 {
+		return usage.ParseUsageFromText(name, text)
+	}
+	lines := strings.Split(text, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		for _, pattern := range provider.usage {
+			if parsed := usageFromMatch(pattern, line); parsed != nil {
+				parsed.Provider = provider.def.Name
+				parsed.RawLine = line
+				parsed.ReportedAt = time.Now().UTC()
+				return parsed
+			}
+		}
+	}
+	return nil
+}
+
+func usageFromMatch(pattern *regexp.Regexp, line string) *usage.SessionUsage {
+	match := pattern.FindStringSubmatch(line)
+	if match == nil {
+		return nil
+	}
+	parsed := &usage.SessionUsage{}
+	found := false
+	for i, group := range pattern.SubexpNames() {
+		if i == 0 || group == "" || match[i] == "" {
+			continue
+		}
+		value := strings.ReplaceAll(match[i], ",", "")
+		if group == "cost_usd" {
+			if cost, err := strconv.ParseFloat(strings.TrimPrefix(value, "$"), 64); err == nil {
+				cents := int(cost * 100)
+				parsed.CostCents = &cents
+				found = true
+			}
+			continue
+		}
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		switch group {
+		case "input_tokens":
+			parsed.InputTokens = &count
+		case "output_tokens":
+			parsed.OutputTokens = &count
+		case "total_tokens":
+			parsed.TotalTokens = &count
+		case "cache_read_tokens":
+			parsed.CacheReadTokens = &count
+		case "cache_write_tokens":
+			parsed.CacheWriteTokens = &count
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	if parsed.TotalTokens == nil && parsed.InputTokens != nil && parsed.OutputTokens != nil {
+		total := *parsed.InputTokens + *parsed.OutputTokens
+		parsed.TotalTokens = &total
+	}
+	return parsed
+}
+
+func (r *Registry) lookup(name string) *registeredProvider {
+	if r == nil {
+		return nil
+	}
+	return r.byName[strings.ToLower(strings.TrimSpace(name))]
+}
+
+func containsAny(value string, needles []string) bool {
+	if value == "" {
+		return false
+	}
+	for _, needle := range needles {
+		needle = strings.ToLower(strings.TrimSpace(needle))
+		if needle != "" && strings.Contains(value, needle) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package providers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agent-command/agentd/internal/config"
+)
+
+func TestRegistryBuiltinsMatchLegacyDetection(t *testing.T) {
+	registry, err := NewRegistry(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	descendants := func(want string) func([]string) bool {
+		return func(names []string) bool {
+			for _, name := range names {
+				if name == want {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	cases := []struct {
+		signals PaneSignals
+		want    string
+	}{
+		{PaneSignals{Command: "claude"}, "claude_code"},
+		{PaneSignals{Command: "/opt/bin/Codex"}, "codex"},
+		{PaneSignals{Command: "node", Title: "Gemini CLI"}, "gemini_cli"},
+		{PaneSignals{Command: "node", WindowName: "gemini"}, "gemini_cli"},
+		{PaneSignals{Command: "node", HasDescendant: descendants("gemini")}, "gemini_cli"},
+		{PaneSignals{Command: "aider"}, "aider"},
+		{PaneSignals{Command: "bash"}, ""},
+	}
+	for _, tc := range cases {
+		if got := registry.Detect(tc.signals); got != tc.want {
+			t.Errorf("Detect(%+v)=%q, want %q", tc.signals, got, tc.want)
+		}
+	}
+	if registry.Normalize("Gemini") != "gemini_cli" || registry.Normalize("claude") != "claude_code" || registry.Normalize("nope") != "" {
+		t.Fatal("aliases were not normalized")
+	}
+	if !registry.Interactive("codex") || registry.Interactive("shell") || !registry.Interactive("unknown") {
+		t.Fatal("interactive flags differ from the built-in providers")
+	}
+}
+
+func TestRegistryAddsConfiguredProviderWithoutCode(t *testing.T) {
+	interactive := true
+	cfg := &config.Config{Providers: config.ProvidersConfig{
+		Codex: config.CodexConfig{ApprovalAllowKeys: []string{"a", "Enter"}},
+		Definitions: []config.ProviderDefinition{
+			{
+				Name:              "Goose",
+				Aliases:           []string{"goose-cli"},
+				Detect:            config.ProviderDetectionRule{Commands: []string{"claude-goose"}},
+				Interactive:       &interactive,
+				ApprovalAllowKeys: []string{"1"},
+				Launch:            &config.ProviderLaunchTemplate{Argv: []string{"goose", "session"}, HeadlessArgv: []string{"goose", "run", "-t", "{{prompt}}"}},
+				StatusPatterns: []config.ProviderStatusPattern{
+					{Status: "waiting_for_approval", Pattern: `(?m)^Allow\?`},
+					{Status: "RUNNING", Pattern: `Thinking`},
+				},
+				UsagePatterns: []string{`(?P<input_tokens>[0-9,]+) in / (?P<output_tokens>[0-9,]+) out, \$(?P<cost_usd>[0-9.]+)`},
+			},
+			{Name: "codex", ApprovalDenyKeys: []string{"d"}},
+		},
+	}}
+	registry, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Configured providers are checked before the built-in "claude" rule.
+	if got := registry.Detect(PaneSignals{Command: "claude-goose"}); got != "goose" {
+		t.Fatalf("detected %q", got)
+	}
+	if registry.Normalize("goose-cli") != "goose" || !registry.Interactive("goose") {
+		t.Fatal("configured provider is not registered")
+	}
+	if got := registry.ApprovalKeys("goose", true); strings.Join(got, ",") != "1" {
+		t.Fatalf("allow keys=%v", got)
+	}
+	// Overriding a built-in keeps the fields the entry does not set.
+	if got := registry.ApprovalKeys("codex", true); strings.Join(got, ",") != "a,Enter" {
+		t.Fatalf("codex allow keys=%v", got)
+	}
+	if got := registry.ApprovalKeys("codex", false); strings.Join(got, ",") != "d" {
+		t.Fatalf("codex deny keys=%v", got)
+	}
+
+	if status, ok := registry.ScreenStatus("goose", "ran ls\nAllow?\n"); !ok || status != "WAITING_FOR_APPROVAL" {
+		t.Fatalf("screen status=%q ok=%v", status, ok)
+	}
+	if status, ok := registry.ScreenStatus("goose", "$ "); !ok || status != "" {
+		t.Fatalf("unmatched screen status=%q ok=%v", status, ok)
+	}
+	if _, ok := registry.ScreenStatus("claude_code", "Allow?"); ok {
+		t.Fatal("provider without patterns reported a screen status")
+	}
+
+	usage := registry.ParseUsage("goose", "old 1 in / 1 out, $0.01\n1,200 in / 300 out, $0.42\n\n")
+	if usage == nil || *usage.InputTokens != 1200 || *usage.OutputTokens != 300 || *usage.TotalTokens != 1500 || *usage.CostCents != 42 {
+		t.Fatalf("usage=%+v", usage)
+	}
+
+	spec, err := NewLaunchTemplates(cfg).Headless("goose-cli", "fix it", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(spec.Argv, "|"); got != "goose|run|-t|fix it" {
+		t.Fatalf("headless argv=%q", got)
+	}
+}
+
+func TestRegistryRejectsInvalidDefinitions(t *testing.T) {
+	for _, def := range []config.ProviderDefinition{
+		{Name: "x", StatusPatterns: []config.ProviderStatusPattern{{Status: "BUSY", Pattern: "x"}}},
+		{Name: "x", StatusPatterns: []config.ProviderStatusPattern{{Status: "IDLE", Pattern: "("}}},
+		{Name: "x", UsagePatterns: []string{`(?P<tokens>\d+)`}},
+	} {
+		cfg := &config.Config{Providers: config.ProvidersConfig{Definitions: []config.ProviderDefinition{def}}}
+		if _, err := NewRegistry(cfg); err == nil {
+			t.Errorf("definition %+v was accepted", def)
+		}
+	}
+}
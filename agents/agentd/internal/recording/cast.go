@@ -0,0 +1,177 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultCastCols = 80
+	defaultCastRows = 24
+)
+
+var ErrEmptyRange = errors.New("no recorded output in range")
+
+// CastOptions selects the window exported as an asciinema v2 cast. Zero times
+// leave that side open.
+type CastOptions struct {
+	Since         time.Time
+	Until         time.Time
+	Title         string
+	IdleTimeLimit float64
+}
+
+// CastStats summarizes a written cast.
+type CastStats struct {
+	StartedAt time.Time
+	Duration  time.Duration
+	Events    int
+	Cols      int
+	Rows      int
+}
+
+type castHeader struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp"`
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	Env           map[string]string `json:"env"`
+}
+
+// ExportCast writes a session's recorded output in the window as an asciinema
+// v2 cast: a JSON header line followed by one [time, code, data] line per
+// event. Output events are "o"; pane size changes inside the window become "r"
+// events so players reflow at the same points the pane did.
+func (r *Recorder) ExportCast(w io.Writer, sessionID string, opts CastOptions) (CastStats, error) {
+	sizes, err := r.Sizes(sessionID)
+	if err != nil {
+		return CastStats{}, err
+	}
+	query := Query{Since: opts.Since, Until: opts.Until, MaxBytes: MaxReadBytes}
+	page, err := r.Read(sessionID, query)
+	if err != nil {
+		return CastStats{}, err
+	}
+	if len(page.Chunks) == 0 {
+		return CastStats{}, ErrEmptyRange
+	}
+
+	start := page.Chunks[0].At
+	initial := Size{Cols: defaultCastCols, Rows: defaultCastRows}
+	nextSize := 0
+	for nextSize < len(sizes) && !sizes[nextSize].At.After(start) {
+		initial = sizes[nextSize]
+		nextSize++
+	}
+	if initial.At.IsZero() && nextSize < len(sizes) {
+		// Sizes are only sampled once recording starts, so the first known
+		// size is a better guess than a fixed default.
+		initial = sizes[nextSize]
+		nextSize++
+	}
+
+	buffered := bufio.NewWriter(w)
+	stats := CastStats{StartedAt: start, Cols: initial.Cols, Rows: initial.Rows}
+	header, err := json.Marshal(castHeader{
+		Version:       2,
+		Width:         initial.Cols,
+		Height:        initial.Rows,
+		Timestamp:     start.Unix(),
+		IdleTimeLimit: opts.IdleTimeLimit,
+		Title:         opts.Title,
+		Env:           map[string]string{"TERM": "xterm-256color"},
+	})
+	if err != nil {
+		return CastStats{}, err
+	}
+	buffered.Write(header)
+	buffered.WriteByte('\n')
+
+	writeEvent := func(at time.Time, code, data string) error {
+		elapsed := at.Sub(start)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		line, err := json.Marshal([]any{elapsed.Seconds(), code, data})
+		if err != nil {
+			return err
+		}
+		buffered.Write(line)
+		if err := buffered.WriteByte('\n'); err != nil {
+			return err
+		}
+		stats.Events++
+		if elapsed > stats.Duration {
+			stats.Duration = elapsed
+		}
+		return nil
+	}
+
+	// A chunk boundary can split a multi-byte character; the incomplete tail
+	// is carried into the next event so the cast stays valid UTF-8.
+	var pending []byte
+	var lastAt time.Time
+	for {
+		for _, chunk := range page.Chunks {
+			for nextSize < len(sizes) && !sizes[nextSize].At.After(chunk.At) {
+				size := sizes[nextSize]
+				nextSize++
+				if err := writeEvent(size.At, "r", fmt.Sprintf("%dx%d", size.Cols, size.Rows)); err != nil {
+					return CastStats{}, err
+				}
+			}
+			data := append(pending, chunk.Data...)
+			complete := completeUTF8Prefix(data)
+			pending = append([]byte(nil), data[complete:]...)
+			if complete > 0 {
+				text := strings.ToValidUTF8(string(data[:complete]), "�")
+				if err := writeEvent(chunk.At, "o", text); err != nil {
+					return CastStats{}, err
+				}
+			}
+			lastAt = chunk.At
+		}
+		if !page.HasMore {
+			break
+		}
+		query.StartOffset = page.NextOffset
+		if page, err = r.Read(sessionID, query); err != nil {
+			return CastStats{}, err
+		}
+	}
+	if len(pending) > 0 {
+		if err := writeEvent(lastAt, "o", strings.ToValidUTF8(string(pending), "�")); err != nil {
+			return CastStats{}, err
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		return CastStats{}, err
+	}
+	return stats, nil
+}
+
+// completeUTF8Prefix returns the length of data without a trailing incomplete
+// UTF-8 sequence. Invalid bytes count as complete so they are not held back.
+func completeUTF8Prefix(data []byte) int {
+	for back := 1; back <= utf8.UTFMax-1 && back <= len(data); back++ {
+		b := data[len(data)-back]
+		if b < utf8.RuneSelf {
+			return len(data)
+		}
+		if utf8.RuneStart(b) {
+			if !utf8.FullRune(data[len(data)-back:]) {
+				return len(data) - back
+			}
+			return len(data)
+		}
+	}
+	return len(data)
+}
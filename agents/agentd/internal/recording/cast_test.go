@@ -0,0 +1,106 @@
+package recording
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportCastWritesHeaderOutputAndResizeEvents(t *testing.T) {
+	recorder := newTestRecorder(t, 0, 0)
+	base := time.Date(2026, 7, 23, 12, 0, 0, 0, time.UTC)
+	if err := recorder.Resize("s1", base.Add(-time.Minute), 120, 40); err != nil {
+		t.Fatal(err)
+	}
+	check := "✓"
+	steps := []struct {
+		at   time.Duration
+		data string
+	}{
+		{0, "$ make\r\n"},
+		{time.Second, "ok " + check[:1]},
+		{1500 * time.Millisecond, check[1:] + "\r\n"},
+	}
+	for _, step := range steps {
+		if err := recorder.Append("s1", base.Add(step.at), []byte(step.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Resize("s1", base.Add(1200*time.Millisecond), 100, 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Resize("s1", base.Add(1300*time.Millisecond), 100, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	stats, err := recorder.ExportCast(&out, "s1", CastOptions{Title: "build"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var header castHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != 2 || header.Width != 120 || header.Height != 40 || header.Timestamp != base.Unix() || header.Title != "build" {
+		t.Fatalf("header=%+v", header)
+	}
+	want := []string{
+		`[0,"o","$ make\r\n"]`,
+		`[1,"o","ok "]`,
+		`[1.2,"r","100x30"]`,
+		`[1.5,"o","✓\r\n"]`,
+	}
+	if got := lines[1:]; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events:\n%s", strings.Join(got, "\n"))
+	}
+	if stats.Events != 4 || stats.Duration != 1500*time.Millisecond {
+		t.Fatalf("stats=%+v", stats)
+	}
+}
+
+func TestExportCastEmptyWindow(t *testing.T) {
+	recorder := newTestRecorder(t, 0, 0)
+	base := time.Date(2026, 7, 23, 12, 0, 0, 0, time.UTC)
+	if err := recorder.Append("s1", base, []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := recorder.ExportCast(&bytes.Buffer{}, "s1", CastOptions{Since: base.Add(time.Hour)})
+	if !errors.Is(err, ErrEmptyRange) {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestReadOnlyRecorderSeesLiveActiveSegment(t *testing.T) {
+	dir := t.TempDir()
+	writer, err := New(Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+	if err := writer.Append("s1", time.Now(), []byte("live")); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := New(Config{Dir: dir, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := reader.Read("s1", Query{})
+	if err != nil || joinChunks(result.Chunks) != "live" || result.RetainedEnd != 4 {
+		t.Fatalf("result=%+v err=%v", result, err)
+	}
+	if err := reader.Append("s1", time.Now(), []byte("x")); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("append err=%v", err)
+	}
+	reader.Close()
+	if err := writer.Append("s1", time.Now(), []byte("!")); err != nil {
+		t.Fatal(err)
+	}
+	if result, _ := reader.Read("s1", Query{}); joinChunks(result.Chunks) != "live!" {
+		t.Fatalf("reader did not see new output: %+v", result)
+	}
+}
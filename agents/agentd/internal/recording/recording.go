@@ -0,0 +1,787 @@
+// Package recording persists raw pane output so it outlives tmux scrollback.
+//
+// Each session records into its own directory as a series of segments. The
+// active segment is an uncompressed append-only file so it can be read while
+// it grows; once it reaches the segment size it is gzip-compressed and renamed
+// with its offset and time range, which doubles as the index. Every write is
+// framed with its arrival time so output can be looked up by time or by byte
+// offset into the session's output stream.
+package recording
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultSegmentBytes    int64 = 1 << 20  // 1 MiB raw output per segment
+	DefaultMaxSessionBytes int64 = 64 << 20 // 64 MiB on disk per session
+	DefaultMaxReadBytes          = 256 << 10
+	MaxReadBytes                 = 1 << 20
+
+	activeSegmentName = "active.rec"
+	pipeName          = "output.pipe"
+	segmentSuffix     = ".rec.gz"
+	frameHeaderBytes  = 12
+	activeHeaderBytes = 8
+	maxFrameBytes     = 1 << 20
+)
+
+var (
+	ErrNotFound     = errors.New("no recording for session")
+	ErrInvalidRange = errors.New("invalid recording range")
+	ErrReadOnly     = errors.New("recorder is read-only")
+)
+
+type Config struct {
+	Dir             string
+	SegmentBytes    int64
+	MaxSessionBytes int64
+	// ReadOnly opens recordings written by another process (the daemon) for
+	// reading. The active segment is read as-is and never repaired or rotated.
+	ReadOnly bool
+}
+
+// Chunk is one write from the pane, in arrival order.
+type Chunk struct {
+	At     time.Time
+	Offset int64
+	Data   []byte
+}
+
+// Query selects output by time, by byte offset, or both. Zero values leave
+// that side of the range open.
+type Query struct {
+	Since       time.Time
+	Until       time.Time
+	StartOffset int64
+	EndOffset   int64
+	MaxBytes    int
+}
+
+type Result struct {
+	Chunks []Chunk
+	// RetainedStart and RetainedEnd bound the offsets still on disk; output
+	// before RetainedStart was rotated away by the size cap.
+	RetainedStart int64
+	RetainedEnd   int64
+	HasMore       bool
+	NextOffset    int64
+}
+
+type segment struct {
+	path        string
+	startOffset int64
+	endOffset   int64
+	startAt     time.Time
+	endAt       time.Time
+	size        int64
+}
+
+type sessionRecorder struct {
+	mu           sync.Mutex
+	dir          string
+	segments     []segment
+	active       *os.File
+	activePath   string
+	activeStart  int64
+	activeAt     time.Time
+	activeLastAt time.Time
+	activeBytes  int64
+	activeSize   int64
+	offset       int64
+	sizes        []Size
+	stop         chan struct{}
+	stopped      chan struct{}
+}
+
+type Recorder struct {
+	mu              sync.Mutex
+	dir             string
+	segmentBytes    int64
+	maxSessionBytes int64
+	readOnly        bool
+	sessions        map[string]*sessionRecorder
+}
+
+func New(cfg Config) (*Recorder, error) {
+	if strings.TrimSpace(cfg.Dir) == "" {
+		return nil, fmt.Errorf("recording dir is required")
+	}
+	if !cfg.ReadOnly {
+		if err := os.MkdirAll(cfg.Dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SegmentBytes <= 0 {
+		cfg.SegmentBytes = DefaultSegmentBytes
+	}
+	if cfg.MaxSessionBytes <= 0 {
+		cfg.MaxSessionBytes = DefaultMaxSessionBytes
+	}
+	return &Recorder{
+		dir:             cfg.Dir,
+		segmentBytes:    cfg.SegmentBytes,
+		maxSessionBytes: cfg.MaxSessionBytes,
+		readOnly:        cfg.ReadOnly,
+		sessions:        make(map[string]*sessionRecorder),
+	}, nil
+}
+
+// Start creates the session's FIFO and begins recording whatever is written to
+// it. The returned path is the pipe-pane target. Starting an already recording
+// session returns the existing FIFO.
+func (r *Recorder) Start(sessionID string) (string, error) {
+	session, err := r.session(sessionID, true)
+	if err != nil {
+		return "", err
+	}
+	fifoPath := filepath.Join(session.dir, pipeName)
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.stop != nil {
+		return fifoPath, nil
+	}
+	_ = os.Remove(fifoPath)
+	if err := syscall.Mkfifo(fifoPath, 0600); err != nil {
+		return "", fmt.Errorf("create recording pipe: %w", err)
+	}
+	session.stop = make(chan struct{})
+	session.stopped = make(chan struct{})
+	go r.readPipe(sessionID, session, fifoPath, session.stop, session.stopped)
+	return fifoPath, nil
+}
+
+// Stop ends recording for a session and compresses its active segment. The
+// recording stays readable.
+func (r *Recorder) Stop(sessionID string) {
+	if r.readOnly {
+		return
+	}
+	session, err := r.session(sessionID, false)
+	if err != nil {
+		return
+	}
+	session.mu.Lock()
+	stop, stopped := session.stop, session.stopped
+	session.stop, session.stopped = nil, nil
+	session.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-stopped
+		_ = os.Remove(filepath.Join(session.dir, pipeName))
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if err := r.finalizeActiveLocked(session); err != nil {
+		log.Printf("Failed to finalize recording for %s: %v", sessionID, err)
+	}
+}
+
+// Recording reports whether output is currently being captured for a session.
+func (r *Recorder) Recording(sessionID string) bool {
+	r.mu.Lock()
+	session := r.sessions[sessionID]
+	r.mu.Unlock()
+	if session == nil {
+		return false
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.stop != nil
+}
+
+// Close stops every active recording.
+func (r *Recorder) Close() {
+	r.mu.Lock()
+	ids := make([]string, 0, len(r.sessions))
+	for id := range r.sessions {
+		ids = append(ids, id)
+	}
+	r.mu.Unlock()
+	for _, id := range ids {
+		r.Stop(id)
+	}
+}
+
+// Append records data that arrived at the given time.
+func (r *Recorder) Append(sessionID string, at time.Time, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	session, err := r.session(sessionID, true)
+	if err != nil {
+		return err
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	for len(data) > 0 {
+		part := data[:min(len(data), maxFrameBytes)]
+		data = data[len(part):]
+		if err := r.appendLocked(session, at, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read returns recorded chunks matching the query, oldest first. Chunks that
+// straddle an offset bound are trimmed to it.
+//
+// The segment list is snapshotted under the session lock and read outside
+// it, so a long read never stalls recording. Output recorded after the
+// snapshot is left for the next read.
+func (r *Recorder) Read(sessionID string, query Query) (Result, error) {
+	if query.MaxBytes <= 0 {
+		query.MaxBytes = DefaultMaxReadBytes
+	}
+	if query.MaxBytes > MaxReadBytes {
+		return Result{}, fmt.Errorf("%w: max_bytes must be at most %d", ErrInvalidRange, MaxReadBytes)
+	}
+	if query.StartOffset < 0 || (query.EndOffset != 0 && query.EndOffset < query.StartOffset) {
+		return Result{}, fmt.Errorf("%w: offsets must satisfy 0 <= start <= end", ErrInvalidRange)
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && query.Until.Before(query.Since) {
+		return Result{}, fmt.Errorf("%w: until is before since", ErrInvalidRange)
+	}
+	session, err := r.session(sessionID, false)
+	if err != nil {
+		return Result{}, err
+	}
+	session.mu.Lock()
+	segments := append([]segment(nil), session.segments...)
+	snapshotEnd := session.offset
+	result := Result{RetainedStart: session.offset, RetainedEnd: session.offset}
+	if len(segments) > 0 {
+		result.RetainedStart = segments[0].startOffset
+	} else if session.activePath != "" {
+		result.RetainedStart = session.activeStart
+	}
+	// The open descriptor keeps reading the same file if the active segment
+	// is finalized and removed meanwhile.
+	var active *os.File
+	if session.activePath != "" {
+		active, err = os.Open(session.activePath)
+	}
+	session.mu.Unlock()
+	if err != nil {
+		return Result{}, err
+	}
+	if active != nil {
+		defer active.Close()
+	}
+
+	used := 0
+	visit := func(chunk Chunk) bool {
+		if chunk.Offset >= snapshotEnd {
+			return false
+		}
+		end := chunk.Offset + int64(len(chunk.Data))
+		if query.EndOffset != 0 && chunk.Offset >= query.EndOffset {
+			return false
+		}
+		if end <= query.StartOffset {
+			return true
+		}
+		if !query.Since.IsZero() && chunk.At.Before(query.Since) {
+			return true
+		}
+		if !query.Until.IsZero() && !chunk.At.Before(query.Until) {
+			return false
+		}
+		if chunk.Offset < query.StartOffset {
+			chunk.Data = chunk.Data[query.StartOffset-chunk.Offset:]
+			chunk.Offset = query.StartOffset
+		}
+		if query.EndOffset != 0 && end > query.EndOffset {
+			chunk.Data = chunk.Data[:query.EndOffset-chunk.Offset]
+		}
+		if remaining := query.MaxBytes - used; len(chunk.Data) > remaining {
+			if remaining > 0 {
+				chunk.Data = chunk.Data[:remaining]
+				result.Chunks = append(result.Chunks, chunk)
+				used += remaining
+			}
+			result.HasMore = true
+			result.NextOffset = chunk.Offset + int64(len(chunk.Data))
+			return false
+		}
+		result.Chunks = append(result.Chunks, chunk)
+		used += len(chunk.Data)
+		return true
+	}
+
+	for _, seg := range segments {
+		if seg.endOffset <= query.StartOffset ||
+			(query.EndOffset != 0 && seg.startOffset >= query.EndOffset) ||
+			(!query.Since.IsZero() && seg.endAt.Before(query.Since)) {
+			continue
+		}
+		if !query.Until.IsZero() && !seg.startAt.Before(query.Until) {
+			return result, nil
+		}
+		more, err := readSegment(seg.path, visit)
+		if os.IsNotExist(err) {
+			// Dropped by the size cap since the snapshot.
+			continue
+		}
+		if err != nil {
+			return Result{}, err
+		}
+		if !more {
+			return result, nil
+		}
+	}
+	if active != nil {
+		if _, _, err := scanFrames(bufio.NewReader(active), visit); err != nil {
+			return Result{}, err
+		}
+	}
+	return result, nil
+}
+
+// PruneOlderThan deletes recordings of sessions that are not recording and
+// whose newest output predates the cutoff.
+func (r *Recorder) PruneOlderThan(cutoff time.Time) error {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || r.Recording(entry.Name()) {
+			continue
+		}
+		dir := filepath.Join(r.dir, entry.Name())
+		newest, err := newestModTime(dir)
+		if err != nil || newest.After(cutoff) {
+			continue
+		}
+		r.mu.Lock()
+		delete(r.sessions, entry.Name())
+		r.mu.Unlock()
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newestModTime(dir string) (time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var newest time.Time
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return newest, nil
+}
+
+// Dir returns the directory holding a session's segments.
+func (r *Recorder) Dir(sessionID string) string {
+	return filepath.Join(r.dir, sessionID)
+}
+
+func (r *Recorder) session(sessionID string, create bool) (*sessionRecorder, error) {
+	if sessionID == "" || strings.ContainsAny(sessionID, `/\`) || sessionID == "." || sessionID == ".." {
+		return nil, fmt.Errorf("invalid session id")
+	}
+	if create && r.readOnly {
+		return nil, ErrReadOnly
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session := r.sessions[sessionID]; session != nil {
+		return session, nil
+	}
+	dir := filepath.Join(r.dir, sessionID)
+	if _, err := os.Stat(dir); err != nil {
+		if !create {
+			return nil, ErrNotFound
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+	session, err := openSession(dir, r.readOnly)
+	if err != nil {
+		return nil, err
+	}
+	// Another process owns a read-only view's files, so it is reopened on
+	// every call rather than cached stale.
+	if !r.readOnly {
+		r.sessions[sessionID] = session
+	}
+	return session, nil
+}
+
+// openSession rebuilds the segment index from file names and reopens an active
+// segment left by a previous run, dropping a partially written final frame.
+func openSession(dir string, readOnly bool) (*sessionRecorder, error) {
+	session := &sessionRecorder{dir: dir}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), segmentSuffix) {
+			continue
+		}
+		seg, ok := parseSegmentName(entry.Name())
+		if !ok {
+			continue
+		}
+		seg.path = filepath.Join(dir, entry.Name())
+		if info, err := entry.Info(); err == nil {
+			seg.size = info.Size()
+		}
+		session.segments = append(session.segments, seg)
+	}
+	sort.Slice(session.segments, func(i, j int) bool {
+		return session.segments[i].startOffset < session.segments[j].startOffset
+	})
+	if n := len(session.segments); n > 0 {
+		session.offset = session.segments[n-1].endOffset
+	}
+
+	if err := session.loadSizes(); err != nil {
+		log.Printf("Ignoring unreadable recording sizes in %s: %v", dir, err)
+	}
+
+	activePath := filepath.Join(dir, activeSegmentName)
+	if _, err := os.Stat(activePath); err == nil {
+		if readOnly {
+			if err := session.inspectActive(activePath); err != nil {
+				log.Printf("Skipping unreadable recording segment %s: %v", activePath, err)
+			}
+			return session, nil
+		}
+		if err := session.recoverActive(activePath); err != nil {
+			log.Printf("Discarding unreadable recording segment %s: %v", activePath, err)
+			_ = os.Remove(activePath)
+		}
+	}
+	return session, nil
+}
+
+// inspectActive indexes an active segment that another process is still
+// writing, without modifying it.
+func (s *sessionRecorder) inspectActive(activePath string) error {
+	file, err := os.Open(activePath)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, activeHeaderBytes)
+	_, err = io.ReadFull(file, header)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	start := int64(binary.BigEndian.Uint64(header))
+	var rawBytes int64
+	if _, err := scanActive(activePath, func(chunk Chunk) bool {
+		rawBytes += int64(len(chunk.Data))
+		return true
+	}); err != nil {
+		return err
+	}
+	s.activePath = activePath
+	s.activeStart = start
+	s.offset = start + rawBytes
+	return nil
+}
+
+func (s *sessionRecorder) recoverActive(activePath string) error {
+	file, err := os.OpenFile(activePath, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, activeHeaderBytes)
+	if _, err := io.ReadFull(file, header); err != nil {
+		file.Close()
+		return err
+	}
+	start := int64(binary.BigEndian.Uint64(header))
+	var firstAt, lastAt time.Time
+	var rawBytes int64
+	validSize, err := scanActive(activePath, func(chunk Chunk) bool {
+		if firstAt.IsZero() {
+			firstAt = chunk.At
+		}
+		lastAt = chunk.At
+		rawBytes += int64(len(chunk.Data))
+		return true
+	})
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(validSize, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	s.active = file
+	s.activePath = activePath
+	s.activeStart = start
+	s.activeAt = firstAt
+	s.activeLastAt = lastAt
+	s.activeBytes = rawBytes
+	s.activeSize = validSize
+	s.offset = start + rawBytes
+	return nil
+}
+
+func (r *Recorder) appendLocked(session *sessionRecorder, at time.Time, data []byte) error {
+	if session.active == nil {
+		file, err := os.OpenFile(filepath.Join(session.dir, activeSegmentName), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		header := make([]byte, activeHeaderBytes)
+		binary.BigEndian.PutUint64(header, uint64(session.offset))
+		if _, err := file.Write(header); err != nil {
+			file.Close()
+			return err
+		}
+		session.active = file
+		session.activePath = file.Name()
+		session.activeStart = session.offset
+		session.activeAt = at
+		session.activeBytes = 0
+		session.activeSize = activeHeaderBytes
+	}
+	if session.activeAt.IsZero() {
+		session.activeAt = at
+	}
+	frame := make([]byte, frameHeaderBytes+len(data))
+	binary.BigEndian.PutUint64(frame[0:8], uint64(at.UnixNano()))
+	binary.BigEndian.PutUint32(frame[8:12], uint32(len(data)))
+	copy(frame[frameHeaderBytes:], data)
+	if _, err := session.active.Write(frame); err != nil {
+		return err
+	}
+	session.activeBytes += int64(len(data))
+	session.activeSize += int64(len(frame))
+	session.activeLastAt = at
+	session.offset += int64(len(data))
+	if session.activeBytes >= r.segmentBytes {
+		return r.finalizeActiveLocked(session)
+	}
+	return nil
+}
+
+// finalizeActiveLocked compresses the active segment into an indexed segment
+// and enforces the per-session size cap by dropping the oldest segments.
+func (r *Recorder) finalizeActiveLocked(session *sessionRecorder) error {
+	if session.active == nil {
+		return nil
+	}
+	activePath := session.activePath
+	if err := session.active.Close(); err != nil {
+		return err
+	}
+	session.active = nil
+	session.activePath = ""
+	if session.activeBytes == 0 {
+		return os.Remove(activePath)
+	}
+	seg := segment{
+		startOffset: session.activeStart,
+		endOffset:   session.activeStart + session.activeBytes,
+		startAt:     session.activeAt,
+		endAt:       session.activeLastAt,
+	}
+	seg.path = filepath.Join(session.dir, seg.name())
+	if err := compressFile(activePath, seg.path); err != nil {
+		return err
+	}
+	if info, err := os.Stat(seg.path); err == nil {
+		seg.size = info.Size()
+	}
+	if err := os.Remove(activePath); err != nil {
+		return err
+	}
+	session.segments = append(session.segments, seg)
+
+	var total int64
+	for _, existing := range session.segments {
+		total += existing.size
+	}
+	for total > r.maxSessionBytes && len(session.segments) > 1 {
+		oldest := session.segments[0]
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= oldest.size
+		session.segments = session.segments[1:]
+	}
+	return nil
+}
+
+func (r *Recorder) readPipe(sessionID string, session *sessionRecorder, fifoPath string, stop, stopped chan struct{}) {
+	defer close(stopped)
+	buf := make([]byte, 32*1024)
+	for {
+		// Opened read/write so the open does not block before tmux starts
+		// writing and EOF is not seen between pipe-pane restarts.
+		pipe, err := os.OpenFile(fifoPath, os.O_RDWR, 0)
+		if err != nil {
+			log.Printf("Failed to open recording pipe for %s: %v", sessionID, err)
+			return
+		}
+		for {
+			select {
+			case <-stop:
+				_ = pipe.Close()
+				return
+			default:
+			}
+			_ = pipe.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, err := pipe.Read(buf)
+			if n > 0 {
+				if appendErr := r.Append(sessionID, time.Now(), buf[:n]); appendErr != nil {
+					log.Printf("Failed to record output for %s: %v", sessionID, appendErr)
+				}
+			}
+			if err != nil {
+				if os.IsTimeout(err) {
+					continue
+				}
+				_ = pipe.Close()
+				time.Sleep(100 * time.Millisecond)
+				break
+			}
+		}
+	}
+}
+
+func (s segment) name() string {
+	return fmt.Sprintf("seg-%020d-%020d-%d-%d%s",
+		s.startOffset, s.endOffset, s.startAt.UnixNano(), s.endAt.UnixNano(), segmentSuffix)
+}
+
+func parseSegmentName(name string) (segment, bool) {
+	var seg segment
+	var startAt, endAt int64
+	trimmed := strings.TrimSuffix(name, segmentSuffix)
+	if _, err := fmt.Sscanf(trimmed, "seg-%d-%d-%d-%d", &seg.startOffset, &seg.endOffset, &startAt, &endAt); err != nil {
+		return segment{}, false
+	}
+	seg.startAt = time.Unix(0, startAt)
+	seg.endAt = time.Unix(0, endAt)
+	return seg, seg.endOffset >= seg.startOffset
+}
+
+func compressFile(sourcePath, targetPath string) error {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	tmpPath := targetPath + ".tmp"
+	target, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(target)
+	if _, err := io.Copy(writer, source); err != nil {
+		writer.Close()
+		target.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		target.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := target.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, targetPath)
+}
+
+func readSegment(path string, visit func(Chunk) bool) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+	more, _, err := scanFrames(bufio.NewReader(reader), visit)
+	return more, err
+}
+
+// scanActive walks an active segment and returns the size of its valid prefix.
+func scanActive(path string, visit func(Chunk) bool) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	_, size, err := scanFrames(bufio.NewReader(file), visit)
+	return size, err
+}
+
+// scanFrames reads the offset header and frames of a segment. A truncated
+// trailing frame ends the scan without error.
+func scanFrames(reader io.Reader, visit func(Chunk) bool) (bool, int64, error) {
+	header := make([]byte, activeHeaderBytes)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return true, 0, fmt.Errorf("read segment header: %w", err)
+	}
+	offset := int64(binary.BigEndian.Uint64(header))
+	size := int64(activeHeaderBytes)
+	frameHeader := make([]byte, frameHeaderBytes)
+	for {
+		if _, err := io.ReadFull(reader, frameHeader); err != nil {
+			return true, size, nil
+		}
+		length := binary.BigEndian.Uint32(frameHeader[8:12])
+		if length > maxFrameBytes {
+			return true, size, nil
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return true, size, nil
+		}
+		chunk := Chunk{
+			At:     time.Unix(0, int64(binary.BigEndian.Uint64(frameHeader[0:8]))),
+			Offset: offset,
+			Data:   data,
+		}
+		offset += int64(length)
+		size += int64(frameHeaderBytes) + int64(length)
+		if !visit(chunk) {
+			return false, size, nil
+		}
+	}
+}
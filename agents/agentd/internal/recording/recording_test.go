@@ -0,0 +1,241 @@
+package recording
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadByOffsetAcrossRotatedSegments(t *testing.T) {
+	recorder := newTestRecorder(t, 8, 1<<20)
+	base := time.Date(2026, 7, 23, 12, 0, 0, 0, time.UTC)
+	for index, part := range []string{"hello ", "world ", "from ", "agentd"} {
+		if err := recorder.Append("s1", base.Add(time.Duration(index)*time.Second), []byte(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	segments, _ := filepath.Glob(filepath.Join(recorder.Dir("s1"), "*"+segmentSuffix))
+	if len(segments) < 2 {
+		t.Fatalf("expected rotated segments, got %v", segments)
+	}
+	result, err := recorder.Read("s1", Query{StartOffset: 3, EndOffset: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := joinChunks(result.Chunks); got != "lo world fro" {
+		t.Fatalf("offset read=%q", got)
+	}
+	if result.Chunks[0].Offset != 3 || result.RetainedStart != 0 || result.RetainedEnd != 23 {
+		t.Fatalf("result=%+v", result)
+	}
+}
+
+func TestReadDuringAppendsSeesContiguousOutput(t *testing.T) {
+	recorder := newTestRecorder(t, 64, 1<<20)
+	base := time.Date(2026, 7, 23, 12, 0, 0, 0, time.UTC)
+	done := make(chan error, 1)
+	go func() {
+		for index := 0; index < 500; index++ {
+			if err := recorder.Append("s1", base.Add(time.Duration(index)*time.Millisecond), []byte("0123456789")); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	for reading := true; reading; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			reading = false
+		default:
+		}
+		result, err := recorder.Read("s1", Query{MaxBytes: MaxReadBytes})
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		next := result.RetainedStart
+		for _, chunk := range result.Chunks {
+			if chunk.Offset != next {
+				t.Fatalf("chunk offset=%d want %d", chunk.Offset, next)
+			}
+			next += int64(len(chunk.Data))
+		}
+		if next != result.RetainedEnd {
+			t.Fatalf("read ended at %d, snapshot at %d", next, result.RetainedEnd)
+		}
+	}
+}
+
+func TestReadByTimeRange(t *testing.T) {
+	recorder := newTestRecorder(t, 1<<20, 1<<20)
+	base := time.Date(2026, 7, 23, 12, 0, 0, 0, time.UTC)
+	for index, part := range []string{"a", "b", "c", "d"} {
+		if err := recorder.Append("s1", base.Add(time.Duration(index)*time.Minute), []byte(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := recorder.Read("s1", Query{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := joinChunks(result.Chunks); got != "bc" {
+		t.Fatalf("time read=%q", got)
+	}
+	if !result.Chunks[0].At.Equal(base.Add(time.Minute)) {
+		t.Fatalf("chunk time=%s", result.Chunks[0].At)
+	}
+}
+
+func TestReadPagesWithMaxBytes(t *testing.T) {
+	recorder := newTestRecorder(t, 1<<20, 1<<20)
+	if err := recorder.Append("s1", time.Now(), []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	first, err := recorder.Read("s1", Query{MaxBytes: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joinChunks(first.Chunks) != "0123" || !first.HasMore || first.NextOffset != 4 {
+		t.Fatalf("first=%+v", first)
+	}
+	second, err := recorder.Read("s1", Query{StartOffset: first.NextOffset, MaxBytes: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joinChunks(second.Chunks) != "456789" || second.HasMore {
+		t.Fatalf("second=%+v", second)
+	}
+}
+
+func TestSizeCapDropsOldestSegments(t *testing.T) {
+	recorder := newTestRecorder(t, 4, 1)
+	for _, part := range []string{"aaaa", "bbbb", "cccc"} {
+		if err := recorder.Append("s1", time.Now(), []byte(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := recorder.Read("s1", Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joinChunks(result.Chunks) != "cccc" || result.RetainedStart != 8 || result.RetainedEnd != 12 {
+		t.Fatalf("result=%+v", result)
+	}
+}
+
+func TestReopenRecoversActiveSegmentAndOffsets(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := New(Config{Dir: dir, SegmentBytes: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{"abcdef", "gh"} {
+		if err := recorder.Append("s1", time.Now(), []byte(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Simulate a crash mid-frame.
+	activePath := filepath.Join(dir, "s1", activeSegmentName)
+	file, err := os.OpenFile(activePath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte{0, 1, 2})
+	file.Close()
+
+	reopened, err := New(Config{Dir: dir, SegmentBytes: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Append("s1", time.Now(), []byte("ij")); err != nil {
+		t.Fatal(err)
+	}
+	result, err := reopened.Read("s1", Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joinChunks(result.Chunks) != "abcdefghij" || result.RetainedEnd != 10 {
+		t.Fatalf("result=%+v", result)
+	}
+}
+
+func TestReadUnknownSessionAndInvalidRange(t *testing.T) {
+	recorder := newTestRecorder(t, 0, 0)
+	if _, err := recorder.Read("missing", Query{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing err=%v", err)
+	}
+	if _, err := recorder.Read("s1", Query{StartOffset: 5, EndOffset: 2}); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("range err=%v", err)
+	}
+	if _, err := recorder.Read("../x", Query{}); err == nil {
+		t.Fatal("path traversal accepted")
+	}
+}
+
+func TestStartRecordsFIFOOutput(t *testing.T) {
+	recorder := newTestRecorder(t, 0, 0)
+	fifoPath, err := recorder.Start("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := os.OpenFile(fifoPath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.Write([]byte("piped output")); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		result, err := recorder.Read("s1", Query{})
+		if err == nil && joinChunks(result.Chunks) == "piped output" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("recorded=%+v err=%v", result, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	recorder.Stop("s1")
+	if recorder.Recording("s1") {
+		t.Fatal("still recording after stop")
+	}
+	if _, err := os.Stat(fifoPath); !os.IsNotExist(err) {
+		t.Fatalf("fifo left behind: %v", err)
+	}
+	result, err := recorder.Read("s1", Query{})
+	if err != nil || joinChunks(result.Chunks) != "piped output" {
+		t.Fatalf("after stop=%+v err=%v", result, err)
+	}
+}
+
+func newTestRecorder(t *testing.T, segmentBytes, maxSessionBytes int64) *Recorder {
+	t.Helper()
+	recorder, err := New(Config{Dir: t.TempDir(), SegmentBytes: segmentBytes, MaxSessionBytes: maxSessionBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(recorder.Close)
+	return recorder
+}
+
+func joinChunks(chunks []Chunk) string {
+	var builder strings.Builder
+	for _, chunk := range chunks {
+		builder.Write(chunk.Data)
+	}
+	return builder.String()
+}
@@ -0,0 +1,78 @@
+package recording
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const sizesName = "sizes.log"
+
+// Size is the pane size in effect from At until the next change.
+type Size struct {
+	At   time.Time
+	Cols int
+	Rows int
+}
+
+// Resize records the pane size output is rendered at. Repeating the current
+// size is a no-op, so callers can report it on every poll.
+func (r *Recorder) Resize(sessionID string, at time.Time, cols, rows int) error {
+	if cols <= 0 || rows <= 0 {
+		return nil
+	}
+	session, err := r.session(sessionID, true)
+	if err != nil {
+		return err
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if n := len(session.sizes); n > 0 && session.sizes[n-1].Cols == cols && session.sizes[n-1].Rows == rows {
+		return nil
+	}
+	file, err := os.OpenFile(filepath.Join(session.dir, sizesName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintf(file, "%d %d %d\n", at.UnixNano(), cols, rows); err != nil {
+		return err
+	}
+	session.sizes = append(session.sizes, Size{At: at, Cols: cols, Rows: rows})
+	return nil
+}
+
+// Sizes returns the recorded size changes for a session, oldest first.
+func (r *Recorder) Sizes(sessionID string) ([]Size, error) {
+	session, err := r.session(sessionID, false)
+	if err != nil {
+		return nil, err
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return append([]Size(nil), session.sizes...), nil
+}
+
+func (s *sessionRecorder) loadSizes() error {
+	file, err := os.Open(filepath.Join(s.dir, sizesName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var nanos int64
+		var size Size
+		if _, err := fmt.Sscanf(scanner.Text(), "%d %d %d", &nanos, &size.Cols, &size.Rows); err != nil {
+			continue
+		}
+		size.At = time.Unix(0, nanos)
+		s.sizes = append(s.sizes, size)
+	}
+	return scanner.Err()
+}
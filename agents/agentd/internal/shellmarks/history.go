@@ -0,0 +1,128 @@
+package shellmarks
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultHistoryLimit is the number of finished commands kept per pane.
+const DefaultHistoryLimit = 200
+
+// State is what the shell is doing according to the latest mark.
+type State string
+
+const (
+	// StateUnknown means no mark has been seen; callers fall back to
+	// heuristics.
+	StateUnknown State = ""
+	StateIdle    State = "idle"
+	StateRunning State = "running"
+)
+
+// Command is one command the shell ran. ExitCode is nil while the command is
+// running, and when the shell did not report a status.
+type Command struct {
+	Seq        int64
+	Command    string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	ExitCode   *int
+}
+
+func (c Command) Running() bool {
+	return c.FinishedAt.IsZero()
+}
+
+func (c Command) Duration(now time.Time) time.Duration {
+	if c.Running() {
+		return now.Sub(c.StartedAt)
+	}
+	return c.FinishedAt.Sub(c.StartedAt)
+}
+
+// History turns the marks in one pane's output into a command history. It is
+// safe for concurrent use.
+type History struct {
+	mu       sync.Mutex
+	parser   Parser
+	limit    int
+	state    State
+	running  *Command
+	finished []Command
+	nextSeq  int64
+}
+
+func NewHistory(limit int) *History {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	return &History{limit: limit, nextSeq: 1}
+}
+
+// Write feeds output observed at the given time and returns the commands that
+// started or finished in it, in order.
+func (h *History) Write(at time.Time, data []byte) []Command {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var changed []Command
+	for _, mark := range h.parser.Feed(data) {
+		switch mark.Kind {
+		case CommandStart:
+			// A missing D mark means the previous status was lost, not that
+			// the command is still running.
+			if h.running != nil {
+				changed = append(changed, h.finishLocked(at, nil))
+			}
+			h.running = &Command{Seq: h.nextSeq, Command: mark.CommandLine, StartedAt: at}
+			h.nextSeq++
+			h.state = StateRunning
+			changed = append(changed, *h.running)
+		case CommandEnd:
+			// The prompt hook reports a status after every prompt, including
+			// empty lines, so a D without a running command is ignored.
+			if h.running != nil {
+				changed = append(changed, h.finishLocked(at, mark.ExitCode))
+			}
+			h.state = StateIdle
+		case PromptStart, PromptEnd:
+			if h.running != nil {
+				changed = append(changed, h.finishLocked(at, nil))
+			}
+			h.state = StateIdle
+		}
+	}
+	return changed
+}
+
+func (h *History) finishLocked(at time.Time, exitCode *int) Command {
+	command := *h.running
+	command.FinishedAt = at
+	command.ExitCode = exitCode
+	h.running = nil
+	h.finished = append(h.finished, command)
+	if len(h.finished) > h.limit {
+		h.finished = append([]Command(nil), h.finished[len(h.finished)-h.limit:]...)
+	}
+	return command
+}
+
+func (h *History) State() State {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.state
+}
+
+// Commands returns up to limit of the most recent commands, oldest first,
+// including the running one. A limit of zero returns everything retained.
+func (h *History) Commands(limit int) []Command {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	commands := append([]Command(nil), h.finished...)
+	if h.running != nil {
+		commands = append(commands, *h.running)
+	}
+	if limit > 0 && len(commands) > limit {
+		commands = commands[len(commands)-limit:]
+	}
+	return commands
+}
@@ -0,0 +1,194 @@
+// Package shellmarks follows OSC 133 semantic prompt marks in pane output to
+// recover shell command boundaries, command lines and exit statuses.
+package shellmarks
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// MarkKind is the OSC 133 mark letter.
+type MarkKind byte
+
+const (
+	PromptStart  MarkKind = 'A'
+	PromptEnd    MarkKind = 'B'
+	CommandStart MarkKind = 'C'
+	CommandEnd   MarkKind = 'D'
+)
+
+// Mark is one OSC 133 sequence. CommandLine comes from the cmdline or
+// cmdline_url parameter of a C mark; ExitCode from a D mark.
+type Mark struct {
+	Kind        MarkKind
+	CommandLine string
+	ExitCode    *int
+}
+
+// maxSequenceBytes bounds a buffered OSC or DCS sequence. Longer sequences
+// are not marks and are skipped.
+const maxSequenceBytes = 8 << 10
+
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateOSC
+	stateOSCEscape
+	stateDCS
+	stateDCSEscape
+)
+
+// Parser extracts marks from a byte stream, carrying partial sequences across
+// writes. It understands bare OSC 133 and marks wrapped in tmux passthrough
+// (ESC P tmux; ... ESC \ with inner escapes doubled), which is how shells
+// inside tmux emit them. The zero value is ready to use.
+type Parser struct {
+	state    parserState
+	buf      []byte
+	overflow bool
+}
+
+// Feed consumes output and returns the marks completed in it.
+func (p *Parser) Feed(data []byte) []Mark {
+	var marks []Mark
+	for _, b := range data {
+		switch p.state {
+		case stateGround:
+			if b == 0x1b {
+				p.state = stateEscape
+			}
+		case stateEscape:
+			p.escape(b)
+		case stateOSC:
+			switch b {
+			case 0x07:
+				marks = p.appendOSC(marks, p.buf)
+				p.state = stateGround
+			case 0x1b:
+				p.state = stateOSCEscape
+			case 0x18, 0x1a:
+				p.state = stateGround
+			default:
+				p.collect(b)
+			}
+		case stateOSCEscape:
+			if b == '\\' {
+				marks = p.appendOSC(marks, p.buf)
+				p.state = stateGround
+				continue
+			}
+			// An unterminated OSC cut short by another escape sequence.
+			p.escape(b)
+		case stateDCS:
+			switch b {
+			case 0x1b:
+				p.state = stateDCSEscape
+			case 0x18, 0x1a:
+				p.state = stateGround
+			default:
+				p.collect(b)
+			}
+		case stateDCSEscape:
+			if b == '\\' {
+				marks = p.appendPassthrough(marks)
+				p.state = stateGround
+				continue
+			}
+			// Inside tmux passthrough every inner ESC is doubled.
+			p.collect(0x1b)
+			p.collect(b)
+			p.state = stateDCS
+		}
+	}
+	return marks
+}
+
+func (p *Parser) escape(b byte) {
+	switch b {
+	case ']':
+		p.begin(stateOSC)
+	case 'P':
+		p.begin(stateDCS)
+	case 0x1b:
+		p.state = stateEscape
+	default:
+		p.state = stateGround
+	}
+}
+
+func (p *Parser) begin(state parserState) {
+	p.state = state
+	p.buf = p.buf[:0]
+	p.overflow = false
+}
+
+func (p *Parser) collect(b byte) {
+	if len(p.buf) >= maxSequenceBytes {
+		p.overflow = true
+		return
+	}
+	p.buf = append(p.buf, b)
+}
+
+func (p *Parser) appendPassthrough(marks []Mark) []Mark {
+	if p.overflow {
+		return marks
+	}
+	body, ok := strings.CutPrefix(string(p.buf), "tmux;")
+	if !ok {
+		return marks
+	}
+	body = strings.ReplaceAll(body, "\x1b\x1b", "\x1b")
+	var inner Parser
+	return append(marks, inner.Feed([]byte(body))...)
+}
+
+func (p *Parser) appendOSC(marks []Mark, body []byte) []Mark {
+	if p.overflow {
+		return marks
+	}
+	mark, ok := parseMark(string(body))
+	if !ok {
+		return marks
+	}
+	return append(marks, mark)
+}
+
+func parseMark(body string) (Mark, bool) {
+	rest, ok := strings.CutPrefix(body, "133;")
+	if !ok || rest == "" {
+		return Mark{}, false
+	}
+	fields := strings.Split(rest, ";")
+	if len(fields[0]) != 1 {
+		return Mark{}, false
+	}
+	mark := Mark{Kind: MarkKind(fields[0][0])}
+	switch mark.Kind {
+	case PromptStart, PromptEnd:
+	case CommandStart:
+		for _, field := range fields[1:] {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "cmdline":
+				mark.CommandLine = value
+			case "cmdline_url":
+				if decoded, err := url.PathUnescape(value); err == nil {
+					mark.CommandLine = decoded
+				}
+			}
+		}
+	case CommandEnd:
+		if len(fields) > 1 {
+			if code, err := strconv.Atoi(fields[1]); err == nil {
+				mark.ExitCode = &code
+			}
+		}
+	default:
+		return Mark{}, false
+	}
+	return mark, true
+}
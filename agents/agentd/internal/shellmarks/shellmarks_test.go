@@ -0,0 +1,93 @@
+package shellmarks
+
+import (
+	"testing"
+	"time"
+)
+
+// passthrough wraps a sequence the way the bash integration does inside tmux.
+func passthrough(sequence string) string {
+	doubled := ""
+	for _, r := range sequence {
+		if r == 0x1b {
+			doubled += "\x1b"
+		}
+		doubled += string(r)
+	}
+	return "\x1bPtmux;" + doubled + "\x1b\\"
+}
+
+func TestParserReadsBareAndPassthroughMarksAcrossWrites(t *testing.T) {
+	stream := "\x1b]133;A\x07$ " +
+		passthrough("\x1b]133;C;cmdline_url=make%20test%20%7C%20tee%20log\x07") +
+		"ok\r\n" +
+		passthrough("\x1b]133;D;2\x07") +
+		"\x1b]0;title\x1b\\" +
+		"\x1b]133;C;cmdline=ls\x1b\\"
+
+	var parser Parser
+	var marks []Mark
+	for i := 0; i < len(stream); i += 3 {
+		end := min(i+3, len(stream))
+		marks = append(marks, parser.Feed([]byte(stream[i:end]))...)
+	}
+
+	if len(marks) != 4 {
+		t.Fatalf("marks=%+v, want 4", marks)
+	}
+	if marks[0].Kind != PromptStart {
+		t.Fatalf("first mark=%+v", marks[0])
+	}
+	if marks[1].Kind != CommandStart || marks[1].CommandLine != "make test | tee log" {
+		t.Fatalf("command start=%+v", marks[1])
+	}
+	if marks[2].Kind != CommandEnd || marks[2].ExitCode == nil || *marks[2].ExitCode != 2 {
+		t.Fatalf("command end=%+v", marks[2])
+	}
+	if marks[3].Kind != CommandStart || marks[3].CommandLine != "ls" {
+		t.Fatalf("ST-terminated mark=%+v", marks[3])
+	}
+}
+
+func TestHistoryTracksCommandLifecycle(t *testing.T) {
+	history := NewHistory(2)
+	base := time.Date(2026, 7, 20, 12, 0, 0, 0, time.UTC)
+	if history.State() != StateUnknown {
+		t.Fatalf("initial state=%q", history.State())
+	}
+
+	// The first prompt reports the status of nothing; it must not create a
+	// command.
+	if changed := history.Write(base, []byte("\x1b]133;D;0\x07\x1b]133;A\x07$ ")); len(changed) != 0 {
+		t.Fatalf("prompt produced commands: %+v", changed)
+	}
+	if history.State() != StateIdle {
+		t.Fatalf("state at prompt=%q", history.State())
+	}
+
+	started := history.Write(base.Add(time.Second), []byte("\x1b]133;C;cmdline_url=sleep%205\x07"))
+	if len(started) != 1 || !started[0].Running() || started[0].Seq != 1 {
+		t.Fatalf("started=%+v", started)
+	}
+	if history.State() != StateRunning {
+		t.Fatalf("state while running=%q", history.State())
+	}
+
+	finished := history.Write(base.Add(6*time.Second), []byte("\x1b]133;D;130\x07\x1b]133;A\x07"))
+	if len(finished) != 1 || finished[0].Running() || *finished[0].ExitCode != 130 {
+		t.Fatalf("finished=%+v", finished)
+	}
+	if got := finished[0].Duration(time.Time{}); got != 5*time.Second {
+		t.Fatalf("duration=%s", got)
+	}
+
+	history.Write(base.Add(7*time.Second), []byte("\x1b]133;C;cmdline=a\x07\x1b]133;D;0\x07"))
+	history.Write(base.Add(8*time.Second), []byte("\x1b]133;C;cmdline=b\x07"))
+	commands := history.Commands(0)
+	if len(commands) != 3 || commands[0].Command != "sleep 5" || commands[1].Command != "a" || !commands[2].Running() {
+		t.Fatalf("commands=%+v, want 2 retained plus running", commands)
+	}
+	if last := history.Commands(1); len(last) != 1 || last[0].Command != "b" {
+		t.Fatalf("limited commands=%+v", last)
+	}
+}
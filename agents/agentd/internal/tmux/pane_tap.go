@@ -0,0 +1,81 @@
+package tmux
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
+)
+
+// PaneTap is a FIFO that pipe-pane output is teed into, with a goroutine
+// handing everything read from it to a callback.
+type PaneTap struct {
+	path    string
+	onData  func([]byte)
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewPaneTap creates the FIFO at path and starts reading it.
+func NewPaneTap(path string, onData func([]byte)) (*PaneTap, error) {
+	_ = os.Remove(path)
+	if err := syscall.Mkfifo(path, 0600); err != nil {
+		return nil, fmt.Errorf("create pane tap: %w", err)
+	}
+	tap := &PaneTap{
+		path:    path,
+		onData:  onData,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go tap.readLoop()
+	return tap, nil
+}
+
+// Path is the FIFO to pass to PipeMux.
+func (t *PaneTap) Path() string {
+	return t.path
+}
+
+// Close stops reading and removes the FIFO.
+func (t *PaneTap) Close() {
+	close(t.stop)
+	<-t.stopped
+	_ = os.Remove(t.path)
+}
+
+func (t *PaneTap) readLoop() {
+	defer close(t.stopped)
+	buf := make([]byte, 32*1024)
+	for {
+		// Opened read/write so the open does not block before tmux starts
+		// writing and EOF is not seen between pipe-pane restarts.
+		pipe, err := os.OpenFile(t.path, os.O_RDWR, 0)
+		if err != nil {
+			log.Printf("Failed to open pane tap %s: %v", t.path, err)
+			return
+		}
+		for {
+			select {
+			case <-t.stop:
+				_ = pipe.Close()
+				return
+			default:
+			}
+			_ = pipe.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, err := pipe.Read(buf)
+			if n > 0 {
+				t.onData(buf[:n])
+			}
+			if err != nil {
+				if os.IsTimeout(err) {
+					continue
+				}
+				_ = pipe.Close()
+				time.Sleep(100 * time.Millisecond)
+				break
+			}
+		}
+	}
+}
@@ -16,12 +16,18 @@ type PipeMux struct {
 }
 
 type pipeState struct {
-	paneID   string
-	logPath  string
-	fifoPath string
-	wantLog  bool
-	wantFIFO bool
-	lastCmd  string
+	paneID     string
+	logPath    string
+	fifoPath   string
+	recordPath string
+	screenPath string
+	marksPath  string
+	wantLog    bool
+	wantFIFO   bool
+	wantRecord bool
+	wantScreen bool
+	wantMarks  bool
+	lastCmd    string
 }
 
 func NewPipeMux(client *Client, logDir string) *PipeMux {
@@ -62,6 +68,60 @@ func (m *PipeMux) SetTerminal(paneID, fifoPath string, enabled bool) error {
 	return m.applyLocked(state)
 }
 
+// SetRecording adds or removes the continuous recording FIFO as a pipe-pane
+// target. tmux allows a single pipe per pane, so it is teed alongside the
+// console log and terminal FIFO.
+func (m *PipeMux) SetRecording(paneID, fifoPath string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := m.getState(paneID)
+	state.wantRecord = enabled
+	if enabled {
+		if fifoPath == "" {
+			return fmt.Errorf("fifo path is required for recording")
+		}
+		state.recordPath = fifoPath
+	}
+
+	return m.applyLocked(state)
+}
+
+// SetScreen adds or removes the screen tracker FIFO as a pipe-pane target.
+func (m *PipeMux) SetScreen(paneID, fifoPath string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := m.getState(paneID)
+	state.wantScreen = enabled
+	if enabled {
+		if fifoPath == "" {
+			return fmt.Errorf("fifo path is required for screen tracking")
+		}
+		state.screenPath = fifoPath
+	}
+
+	return m.applyLocked(state)
+}
+
+// SetCommandMarks adds or removes the shell command mark FIFO as a pipe-pane
+// target.
+func (m *PipeMux) SetCommandMarks(paneID, fifoPath string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := m.getState(paneID)
+	state.wantMarks = enabled
+	if enabled {
+		if fifoPath == "" {
+			return fmt.Errorf("fifo path is required for command marks")
+		}
+		state.marksPath = fifoPath
+	}
+
+	return m.applyLocked(state)
+}
+
 func (m *PipeMux) getState(paneID string) *pipeState {
 	state, ok := m.panes[paneID]
 	if !ok {
@@ -92,19 +152,30 @@ func (m *PipeMux) applyLocked(state *pipeState) error {
 }
 
 func buildPipeCmd(state *pipeState) string {
-	switch {
-	case state.wantLog && state.wantFIFO:
-		logPath := shellEscape(state.logPath)
-		fifoPath := shellEscape(state.fifoPath)
-		return fmt.Sprintf("command -v stdbuf >/dev/null 2>&1 && stdbuf -o0 tee -a %s %s >/dev/null || tee -a %s %s >/dev/null", logPath, fifoPath, logPath, fifoPath)
-	case state.wantLog:
-		logPath := shellEscape(state.logPath)
-		return fmt.Sprintf("command -v stdbuf >/dev/null 2>&1 && stdbuf -o0 cat >> %s || cat >> %s", logPath, logPath)
-	case state.wantFIFO:
-		fifoPath := shellEscape(state.fifoPath)
-		return fmt.Sprintf("command -v stdbuf >/dev/null 2>&1 && stdbuf -o0 cat >> %s || cat >> %s", fifoPath, fifoPath)
-	default:
+	var targets []string
+	if state.wantLog {
+		targets = append(targets, shellEscape(state.logPath))
+	}
+	if state.wantFIFO {
+		targets = append(targets, shellEscape(state.fifoPath))
+	}
+	if state.wantRecord {
+		targets = append(targets, shellEscape(state.recordPath))
+	}
+	if state.wantScreen {
+		targets = append(targets, shellEscape(state.screenPath))
+	}
+	if state.wantMarks {
+		targets = append(targets, shellEscape(state.marksPath))
+	}
+	switch len(targets) {
+	case 0:
 		return ""
+	case 1:
+		return fmt.Sprintf("command -v stdbuf >/dev/null 2>&1 && stdbuf -o0 cat >> %s || cat >> %s", targets[0], targets[0])
+	default:
+		joined := strings.Join(targets, " ")
+		return fmt.Sprintf("command -v stdbuf >/dev/null 2>&1 && stdbuf -o0 tee -a %s >/dev/null || tee -a %s >/dev/null", joined, joined)
 	}
 }
 
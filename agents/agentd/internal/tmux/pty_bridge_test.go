@@ -1134,6 +1134,80 @@ func TestViewerPTYBridgeCoalescesReadsBeforeFanout(t *testing.T) {
 	}
 }
 
+func TestTerminalFlowControlPausesOnlyTheSlowViewer(t *testing.T) {
+	runner := newFakeTmuxRunner()
+	runner.outputs["display-message -p -t %7 #{session_name}\t#{window_index}\t#{pane_index}"] = []byte("agents\t2\t1\n")
+	runner.outputs["list-clients -t ac-view-slow -F #{client_name}"] = []byte("/dev/pts/9\n")
+	var mu sync.Mutex
+	received := make(map[string]int)
+	manager := newTerminalManagerWithRunner(nil, runner, t.TempDir())
+	manager.SetOutputHandler(func(channelID, encoded string) {
+		mu.Lock()
+		received[channelID] += encodedOutputLen(encoded)
+		mu.Unlock()
+	})
+	defer manager.Close()
+	receivedBy := func(channelID string, want int) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for {
+			mu.Lock()
+			got := received[channelID]
+			mu.Unlock()
+			if got == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s received %d bytes, want %d", channelID, got, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	slow, err := manager.AttachWithOptions("slow", "%7", AttachOptions{SessionID: "session-1", FlowWindow: 1})
+	if err != nil {
+		t.Fatalf("attach slow viewer: %v", err)
+	}
+	if slow.FlowWindow != minTerminalFlowWindow {
+		t.Fatalf("flow window=%d, want %d", slow.FlowWindow, minTerminalFlowWindow)
+	}
+	fast, err := manager.AttachWithOptions("fast", "%7", AttachOptions{SessionID: "session-1"})
+	if err != nil {
+		t.Fatalf("attach fast viewer: %v", err)
+	}
+	if fast.FlowWindow != 0 {
+		t.Fatalf("unrequested flow window=%d", fast.FlowWindow)
+	}
+	slowPTY, fastPTY := runner.processes[0], runner.processes[1]
+
+	slowPTY.Feed(make([]byte, minTerminalFlowWindow))
+	receivedBy("slow", minTerminalFlowWindow)
+	// The read already in progress when credit runs out completes and is held
+	// back; the one after it stays in the PTY.
+	slowPTY.Feed([]byte("held"))
+	slowPTY.Feed([]byte("more"))
+	for range 3 {
+		fastPTY.Feed(make([]byte, minTerminalFlowWindow))
+	}
+	receivedBy("fast", 3*minTerminalFlowWindow)
+
+	time.Sleep(viewerStallRedrawAfter + 50*time.Millisecond)
+	if pending := len(slowPTY.reads); pending != 1 {
+		t.Fatalf("slow viewer PTY has %d unread chunks, want reads paused with 1", pending)
+	}
+	receivedBy("slow", minTerminalFlowWindow)
+	if err := manager.Ack("slow", minTerminalFlowWindow); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	receivedBy("slow", minTerminalFlowWindow+len("heldmore"))
+	if !runner.hasRun([]string{"refresh-client", "-t", "/dev/pts/9"}) {
+		t.Fatal("stalled viewer was not repainted after catching up")
+	}
+	if err := manager.Ack("fast", 1); err == nil {
+		t.Fatal("ack accepted for a viewer without flow control")
+	}
+}
+
 func TestTerminalResumeTokenSurvivesManagerRestartAndSeedsCapture(t *testing.T) {
 	runner := newFakeTmuxRunner()
 	targetKey := "display-message -p -t %7 #{session_name}\t#{window_index}\t#{pane_index}"
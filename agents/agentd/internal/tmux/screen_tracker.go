@@ -0,0 +1,158 @@
+package tmux
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/agent-command/agentd/internal/vt"
+)
+
+// ScreenTracker keeps a VT screen model per pane, fed by pipe-pane output, so
+// attach can paint the current screen immediately and callers can read the
+// visible text without a capture-pane round trip.
+type ScreenTracker struct {
+	mu      sync.Mutex
+	runner  TmuxRunner
+	baseDir string
+	panes   map[string]*trackedScreen
+}
+
+type trackedScreen struct {
+	screen *vt.Screen
+	tap    *PaneTap
+}
+
+func NewScreenTracker(client *Client, baseDir string) *ScreenTracker {
+	return newScreenTrackerWithRunner(newExecTmuxRunner(client), baseDir)
+}
+
+func newScreenTrackerWithRunner(runner TmuxRunner, baseDir string) *ScreenTracker {
+	dir := filepath.Join(baseDir, "screens")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Printf("Failed to create screen dir: %v", err)
+	}
+	return &ScreenTracker{
+		runner:  runner,
+		baseDir: dir,
+		panes:   make(map[string]*trackedScreen),
+	}
+}
+
+// Track starts modelling a pane and returns the FIFO its output must be piped
+// into. The model is seeded from capture-pane so it is correct before the
+// first byte arrives. Tracking an already tracked pane returns its FIFO.
+func (t *ScreenTracker) Track(paneID string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tracked := t.panes[paneID]; tracked != nil {
+		return tracked.tap.Path(), nil
+	}
+	screen, err := t.seed(paneID)
+	if err != nil {
+		return "", err
+	}
+	fifoPath := filepath.Join(t.baseDir, "pane_"+strings.TrimPrefix(paneID, "%")+".screen")
+	tap, err := NewPaneTap(fifoPath, func(data []byte) { screen.Write(data) })
+	if err != nil {
+		return "", err
+	}
+	t.panes[paneID] = &trackedScreen{screen: screen, tap: tap}
+	return fifoPath, nil
+}
+
+// seed builds a screen from the pane's current contents, cursor and
+// alternate-screen state.
+func (t *ScreenTracker) seed(paneID string) (*vt.Screen, error) {
+	described, err := t.runner.Output("display-message", "-p", "-t", paneID,
+		"#{pane_width}\t#{pane_height}\t#{cursor_x}\t#{cursor_y}\t#{alternate_on}")
+	if err != nil {
+		return nil, fmt.Errorf("describe pane %s: %w", paneID, err)
+	}
+	fields := strings.Split(strings.TrimSpace(string(described)), "\t")
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("unexpected pane description %q for %s", strings.TrimSpace(string(described)), paneID)
+	}
+	values := make([]int, 5)
+	for i, field := range fields {
+		values[i], _ = strconv.Atoi(field)
+	}
+	cols, rows, cursorX, cursorY, alternate := values[0], values[1], values[2], values[3], values[4] == 1
+
+	capture, err := t.runner.Output("capture-pane", "-p", "-e", "-t", paneID)
+	if err != nil {
+		return nil, fmt.Errorf("capture pane %s: %w", paneID, err)
+	}
+	screen := vt.New(cols, rows)
+	var seed strings.Builder
+	if alternate {
+		seed.WriteString("\x1b[?1049h")
+	}
+	for row, line := range strings.Split(strings.TrimRight(string(capture), "\n"), "\n") {
+		if row >= rows {
+			break
+		}
+		fmt.Fprintf(&seed, "\x1b[%d;1H%s\x1b[0m", row+1, line)
+	}
+	fmt.Fprintf(&seed, "\x1b[%d;%dH", cursorY+1, cursorX+1)
+	screen.Write([]byte(seed.String()))
+	return screen, nil
+}
+
+// Untrack stops modelling a pane. The caller removes the pipe-pane target.
+func (t *ScreenTracker) Untrack(paneID string) {
+	t.mu.Lock()
+	tracked := t.panes[paneID]
+	delete(t.panes, paneID)
+	t.mu.Unlock()
+	if tracked != nil {
+		tracked.tap.Close()
+	}
+}
+
+// Resize follows a pane size change.
+func (t *ScreenTracker) Resize(paneID string, cols, rows int) {
+	if screen := t.Screen(paneID); screen != nil && cols > 0 && rows > 0 {
+		screen.Resize(cols, rows)
+	}
+}
+
+// Screen returns the model for a tracked pane, or nil.
+func (t *ScreenTracker) Screen(paneID string) *vt.Screen {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tracked := t.panes[paneID]; tracked != nil {
+		return tracked.screen
+	}
+	return nil
+}
+
+// Snapshot returns escape sequences that repaint the pane's current screen.
+func (t *ScreenTracker) Snapshot(paneID string) ([]byte, bool) {
+	screen := t.Screen(paneID)
+	if screen == nil {
+		return nil, false
+	}
+	return screen.Snapshot(), true
+}
+
+// Tracked returns the IDs of tracked panes.
+func (t *ScreenTracker) Tracked() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make([]string, 0, len(t.panes))
+	for id := range t.panes {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (t *ScreenTracker) Close() {
+	for _, paneID := range t.Tracked() {
+		t.Untrack(paneID)
+	}
+}
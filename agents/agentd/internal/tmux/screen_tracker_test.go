@@ -0,0 +1,103 @@
+package tmux
+
+import (
+	"encoding/base64"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const screenDescribeKey = "display-message -p -t %7 #{pane_width}\t#{pane_height}\t#{cursor_x}\t#{cursor_y}\t#{alternate_on}"
+
+func TestScreenTrackerSeedsFromCaptureAndFollowsPipedOutput(t *testing.T) {
+	runner := newFakeTmuxRunner()
+	runner.outputs[screenDescribeKey] = []byte("20\t4\t2\t1\t0\n")
+	runner.outputs["capture-pane -p -e -t %7"] = []byte("$ ls\n$ \n")
+	tracker := newScreenTrackerWithRunner(runner, t.TempDir())
+	defer tracker.Close()
+
+	fifoPath, err := tracker.Track("%7")
+	if err != nil {
+		t.Fatalf("Track: %v", err)
+	}
+	screen := tracker.Screen("%7")
+	if screen == nil {
+		t.Fatal("pane is not tracked")
+	}
+	if got := screen.Lines(); got[0] != "$ ls" || got[1] != "$" {
+		t.Fatalf("seeded lines=%q", got)
+	}
+	if row, col, _ := screen.Cursor(); row != 1 || col != 2 {
+		t.Fatalf("seeded cursor=%d,%d, want 1,2", row, col)
+	}
+
+	pipe, err := os.OpenFile(fifoPath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open screen pipe: %v", err)
+	}
+	if _, err := pipe.Write([]byte("pwd\r\n/home\r\n$ ")); err != nil {
+		t.Fatalf("write screen pipe: %v", err)
+	}
+	pipe.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		lines := screen.Lines()
+		if lines[1] == "$ pwd" && lines[2] == "/home" && lines[3] == "$" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("lines after output=%q", lines)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tracker.Untrack("%7")
+	if tracker.Screen("%7") != nil {
+		t.Fatal("pane still tracked after Untrack")
+	}
+	if _, err := os.Stat(fifoPath); !os.IsNotExist(err) {
+		t.Fatalf("screen pipe not removed: %v", err)
+	}
+}
+
+func TestTerminalAttachPaintsTrackedScreenSnapshot(t *testing.T) {
+	runner := newFakeTmuxRunner()
+	runner.outputs["display-message -p -t %7 #{session_name}\t#{window_index}\t#{pane_index}"] = []byte("agents\t2\t1\n")
+	runner.outputs[screenDescribeKey] = []byte("20\t4\t0\t0\t1\n")
+	runner.outputs["capture-pane -p -e -t %7"] = []byte("top - load 0.1\n")
+	tracker := newScreenTrackerWithRunner(runner, t.TempDir())
+	defer tracker.Close()
+	if _, err := tracker.Track("%7"); err != nil {
+		t.Fatalf("Track: %v", err)
+	}
+
+	output := make(chan string, 1)
+	manager := newTerminalManagerWithRunner(nil, runner, t.TempDir())
+	manager.SetScreenSource(tracker)
+	manager.SetOutputHandler(func(channelID, encoded string) {
+		select {
+		case output <- encoded:
+		default:
+		}
+	})
+	defer manager.Close()
+	if _, err := manager.AttachWithOptions("channel-1", "%7", AttachOptions{SessionID: "session-1"}); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+
+	select {
+	case encoded := <-output:
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Fatalf("decode snapshot: %v", err)
+		}
+		got := string(decoded)
+		if !strings.HasPrefix(got, "\x1b[?1049h") || !strings.Contains(got, "top - load 0.1") {
+			t.Fatalf("attach snapshot=%q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for attach snapshot")
+	}
+}
@@ -65,6 +65,16 @@ type TerminalManager struct {
 	onOutput         TerminalHandler
 	onStatus         func(channelID string, status string, message string)
 	onAudit          func(TerminalAuditEvent)
+	onClipboard      func(channelID string, clipboard TerminalClipboard)
+	onLink           func(channelID string, link TerminalLink)
+	passthroughSet   bool
+	screens          ScreenSource
+}
+
+// ScreenSource provides a repaint of a pane's current screen so a viewer sees
+// correct contents before tmux redraws the attached client.
+type ScreenSource interface {
+	Snapshot(paneID string) ([]byte, bool)
 }
 
 // TerminalAuditEvent is emitted for security-relevant viewer lifecycle changes.
@@ -137,6 +147,9 @@ type AttachOptions struct {
 	Rows        int
 	ResumeToken string
 	Letterbox   bool
+	// FlowWindow opts the viewer into credit-based flow control: at most this
+	// many output bytes are in flight before it acknowledges some.
+	FlowWindow int
 }
 
 // AttachResult describes the selected terminal transport and viewer role.
@@ -147,6 +160,9 @@ type AttachResult struct {
 	ReadOnly    bool
 	ResumeToken string
 	Resumed     bool
+	// FlowWindow is the window in effect, or zero when flow control is off
+	// because it was not requested or the transport does not support it.
+	FlowWindow int
 }
 
 // NewTerminalManager creates a new terminal manager
@@ -307,6 +323,25 @@ func (m *TerminalManager) SetAuditHandler(handler func(TerminalAuditEvent)) {
 	m.onAudit = handler
 }
 
+// SetClipboardHandler sets the handler for OSC 52 clipboard writes from
+// viewed panes. Leaving it unset drops them.
+func (m *TerminalManager) SetClipboardHandler(handler func(channelID string, clipboard TerminalClipboard)) {
+	m.onClipboard = handler
+}
+
+// SetLinkHandler sets the handler for OSC 8 hyperlinks in viewer output.
+func (m *TerminalManager) SetLinkHandler(handler func(channelID string, link TerminalLink)) {
+	m.onLink = handler
+}
+
+// SetScreenSource sets where attach snapshots come from. Without one, only
+// resumed viewers are seeded, from capture-pane.
+func (m *TerminalManager) SetScreenSource(source ScreenSource) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.screens = source
+}
+
 // MarkChannelsStale records that the control-plane connection owning the
 // current channel IDs is gone. A valid resume attach may then supersede its
 // prior channel without allowing an active connection to steal the token.
@@ -408,7 +443,12 @@ func (m *TerminalManager) attachViewerPTY(channelID, paneID string, opts AttachO
 		}
 	}
 	var initialOutput []byte
-	if resumed {
+	if m.screens != nil {
+		if snapshot, ok := m.screens.Snapshot(paneID); ok {
+			initialOutput = snapshot
+		}
+	}
+	if initialOutput == nil && resumed {
 		capture, err := m.runner.Output("capture-pane", "-p", "-e", "-t", paneID)
 		if err != nil {
 			return AttachResult{}, fmt.Errorf("capture pane for terminal resume: %w", err)
@@ -432,6 +472,7 @@ func (m *TerminalManager) attachViewerPTY(channelID, paneID string, opts AttachO
 	if resumeToken == "" {
 		resumeToken = uuid.NewString()
 	}
+	m.configurePassthroughLocked()
 	if previous != nil && previous.bridge != nil && previous.bridge.viewSession == viewerSessionName(channelID) {
 		previous.bridge.close(false)
 		previous.bridge = nil
@@ -445,8 +486,11 @@ func (m *TerminalManager) attachViewerPTY(channelID, paneID string, opts AttachO
 		Letterbox:     opts.Letterbox,
 		ResumeToken:   resumeToken,
 		InitialOutput: initialOutput,
+		FlowWindow:    opts.FlowWindow,
 		OnOutput:      m.onOutput,
 		OnStatus:      m.onStatus,
+		OnClipboard:   m.onClipboard,
+		OnLink:        m.onLink,
 	})
 	if err != nil {
 		if m.paneController[paneID] == channelID {
@@ -481,7 +525,7 @@ func (m *TerminalManager) attachViewerPTY(channelID, paneID string, opts AttachO
 
 	m.emitAudit(TerminalAuditEvent{Action: "attach", ChannelID: channelID, SessionID: opts.SessionID, PaneID: paneID})
 
-	return AttachResult{First: true, PTY: true, ReadOnly: readonly, ResumeToken: resumeToken, Resumed: resumed}, nil
+	return AttachResult{First: true, PTY: true, ReadOnly: readonly, ResumeToken: resumeToken, Resumed: resumed, FlowWindow: bridge.flowWindow}, nil
 }
 
 func (m *TerminalManager) supersedeStaleViewerLocked(viewer *terminalViewer) {
@@ -1227,6 +1271,22 @@ func (m *TerminalManager) TakeControl(channelID string) error {
 // ErrReadOnly indicates the channel is read-only and cannot send input.
 var ErrReadOnly = errors.New("terminal is read-only")
 
+// Ack returns flow-control credit to a viewer that attached with a flow
+// window. Only per-viewer PTY channels support flow control.
+func (m *TerminalManager) Ack(channelID string, bytes int) error {
+	m.mu.RLock()
+	viewer := m.viewerByChannel[channelID]
+	var bridge *viewerPTYBridge
+	if viewer != nil {
+		bridge = viewer.bridge
+	}
+	m.mu.RUnlock()
+	if bridge == nil {
+		return fmt.Errorf("channel %s has no flow-controlled viewer", channelID)
+	}
+	return bridge.Ack(channelID, bytes)
+}
+
 // Resize sends resize signal to terminal
 func (m *TerminalManager) Resize(channelID string, cols, rows int) error {
 	m.mu.RLock()
@@ -1321,6 +1381,34 @@ func (m *TerminalManager) IsPTYMode(channelID string) bool {
 	return m.channelToPTY[channelID]
 }
 
+// configurePassthroughLocked lets clipboard writes and hyperlinks reach
+// viewer clients. tmux ignores application OSC 52 under the default
+// set-clipboard "external" and only forwards OSC 8 to terminals with the
+// hyperlinks feature. An explicit set-clipboard off is left alone. It runs
+// once, on the first viewer attach with a handler set.
+func (m *TerminalManager) configurePassthroughLocked() {
+	if m.passthroughSet || (m.onClipboard == nil && m.onLink == nil) {
+		return
+	}
+	m.passthroughSet = true
+	if m.onClipboard != nil {
+		current, err := m.runner.Output("show-options", "-s", "-v", "set-clipboard")
+		if err == nil && strings.TrimSpace(string(current)) == "external" {
+			if err := m.runner.Run("set-option", "-s", "set-clipboard", "on"); err != nil {
+				log.Printf("Failed to enable tmux clipboard passthrough: %v", err)
+			}
+		}
+	}
+	if m.onLink != nil {
+		features, err := m.runner.Output("show-options", "-s", "-v", "terminal-features")
+		if err == nil && !strings.Contains(string(features), "hyperlinks") {
+			// Older tmux has no hyperlinks feature and rejects it; links then
+			// simply do not reach viewers.
+			_ = m.runner.Run("set-option", "-s", "-a", "terminal-features", "xterm-256color:hyperlinks")
+		}
+	}
+}
+
 func (m *TerminalManager) replaceViewerBridge(viewer *terminalViewer, readonly bool) error {
 	size := TerminalSize{Cols: 80, Rows: 24}
 	if viewer.bridge != nil {
@@ -1337,6 +1425,8 @@ func (m *TerminalManager) replaceViewerBridge(viewer *terminalViewer, readonly b
 		ResumeToken: viewer.resumeToken,
 		OnOutput:    m.onOutput,
 		OnStatus:    m.onStatus,
+		OnClipboard: m.onClipboard,
+		OnLink:      m.onLink,
 	})
 	if err != nil {
 		viewer.bridge = nil
@@ -1,6 +1,14 @@
 package tmux
 
-import "sync"
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	minTerminalFlowWindow = 4 << 10
+	maxTerminalFlowWindow = 16 << 20
+)
 
 type outputRing struct {
 	mu       sync.Mutex
@@ -28,6 +36,22 @@ func (r *outputRing) Push(chunk string) {
 	r.items = append(r.items, chunk)
 }
 
+// Pop removes the oldest chunk and returns it with the number of chunks
+// dropped since the last Pop or Drain.
+func (r *outputRing) Pop() (string, bool, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dropped := r.dropped
+	r.dropped = 0
+	if len(r.items) == 0 {
+		return "", false, dropped
+	}
+	chunk := r.items[0]
+	copy(r.items, r.items[1:])
+	r.items = r.items[:len(r.items)-1]
+	return chunk, true, dropped
+}
+
 func (r *outputRing) Drain() ([]string, int) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -38,6 +62,9 @@ func (r *outputRing) Drain() ([]string, int) {
 	return items, dropped
 }
 
+// terminalOutputChannel delivers output to one viewer. With a flow window it
+// stops delivering once the viewer has that many unacknowledged bytes, and
+// resumes as terminal.ack messages return credit.
 type terminalOutputChannel struct {
 	id       string
 	ring     *outputRing
@@ -46,6 +73,13 @@ type terminalOutputChannel struct {
 	closeOne sync.Once
 	onOutput func(channelID, encoded string)
 	onLag    func(channelID string, dropped int)
+
+	creditMu sync.Mutex
+	window   int
+	unacked  int
+	// credited is closed while the channel has credit and replaced when it
+	// runs out, so readers can wait for it without polling.
+	credited chan struct{}
 }
 
 func newTerminalOutputChannel(
@@ -61,24 +95,94 @@ func newTerminalOutputChannel(
 		done:     make(chan struct{}),
 		onOutput: onOutput,
 		onLag:    onLag,
+		credited: make(chan struct{}),
 	}
+	close(channel.credited)
 	go channel.run()
 	return channel
 }
 
-func (c *terminalOutputChannel) Enqueue(encoded string) {
-	select {
-	case <-c.done:
+// SetFlowWindow enables credit-based flow control with the given window in
+// decoded output bytes, clamped to a sane range. Zero disables it.
+func (c *terminalOutputChannel) SetFlowWindow(window int) int {
+	if window > 0 {
+		window = max(minTerminalFlowWindow, min(window, maxTerminalFlowWindow))
+	} else {
+		window = 0
+	}
+	c.creditMu.Lock()
+	c.window = window
+	c.updateCreditLocked()
+	c.creditMu.Unlock()
+	c.signal()
+	return window
+}
+
+// Ack returns credit for bytes the viewer has consumed.
+func (c *terminalOutputChannel) Ack(bytes int) {
+	if bytes <= 0 {
 		return
+	}
+	c.creditMu.Lock()
+	c.unacked = max(0, c.unacked-bytes)
+	c.updateCreditLocked()
+	c.creditMu.Unlock()
+	c.signal()
+}
+
+// Credited returns a channel that is closed while output may be delivered.
+func (c *terminalOutputChannel) Credited() <-chan struct{} {
+	c.creditMu.Lock()
+	defer c.creditMu.Unlock()
+	return c.credited
+}
+
+func (c *terminalOutputChannel) hasCredit() bool {
+	c.creditMu.Lock()
+	defer c.creditMu.Unlock()
+	return c.window == 0 || c.unacked < c.window
+}
+
+func (c *terminalOutputChannel) charge(bytes int) {
+	c.creditMu.Lock()
+	defer c.creditMu.Unlock()
+	if c.window == 0 {
+		return
+	}
+	c.unacked += bytes
+	c.updateCreditLocked()
+}
+
+func (c *terminalOutputChannel) updateCreditLocked() {
+	select {
+	case <-c.credited:
+		if c.window > 0 && c.unacked >= c.window {
+			c.credited = make(chan struct{})
+		}
 	default:
+		if c.window == 0 || c.unacked < c.window {
+			close(c.credited)
+		}
 	}
-	c.ring.Push(encoded)
+}
+
+func (c *terminalOutputChannel) signal() {
 	select {
 	case c.wake <- struct{}{}:
 	default:
 	}
 }
 
+func (c *terminalOutputChannel) Enqueue(encoded string) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	c.ring.Push(encoded)
+	c.signal()
+}
+
 func (c *terminalOutputChannel) Close() {
 	c.closeOne.Do(func() { close(c.done) })
 }
@@ -89,11 +193,17 @@ func (c *terminalOutputChannel) run() {
 		case <-c.done:
 			return
 		case <-c.wake:
-			chunks, dropped := c.ring.Drain()
-			if dropped > 0 && c.onLag != nil {
-				c.onLag(c.id, dropped)
-			}
-			for _, chunk := range chunks {
+			// A chunk is sent whole once any credit remains, so the window can
+			// be overshot by at most one chunk.
+			for c.hasCredit() {
+				chunk, ok, dropped := c.ring.Pop()
+				if dropped > 0 && c.onLag != nil {
+					c.onLag(c.id, dropped)
+				}
+				if !ok {
+					break
+				}
+				c.charge(encodedOutputLen(chunk))
 				if c.onOutput != nil {
 					c.onOutput(c.id, chunk)
 				}
@@ -102,6 +212,12 @@ func (c *terminalOutputChannel) run() {
 	}
 }
 
+// encodedOutputLen is the decoded size of a base64 output chunk, which is
+// what viewers acknowledge.
+func encodedOutputLen(encoded string) int {
+	return len(encoded)/4*3 - (len(encoded) - len(strings.TrimRight(encoded, "=")))
+}
+
 type terminalFanout struct {
 	mu       sync.RWMutex
 	channels map[string]*terminalOutputChannel
@@ -145,6 +261,50 @@ func (f *terminalFanout) Detach(channelID string) {
 	}
 }
 
+// SetFlowWindow enables flow control for one channel and returns the window
+// in effect, or zero if the channel is not attached.
+func (f *terminalFanout) SetFlowWindow(channelID string, window int) int {
+	f.mu.RLock()
+	channel := f.channels[channelID]
+	f.mu.RUnlock()
+	if channel == nil {
+		return 0
+	}
+	return channel.SetFlowWindow(window)
+}
+
+func (f *terminalFanout) Ack(channelID string, bytes int) bool {
+	f.mu.RLock()
+	channel := f.channels[channelID]
+	f.mu.RUnlock()
+	if channel == nil {
+		return false
+	}
+	channel.Ack(bytes)
+	return true
+}
+
+// WaitForCredit blocks until every attached channel has credit, returning
+// false if stop closes first. A fanout is only paused on when it feeds a
+// single viewer; a shared source must never wait on its slowest reader.
+func (f *terminalFanout) WaitForCredit(stop <-chan struct{}) bool {
+	f.mu.RLock()
+	channels := make([]*terminalOutputChannel, 0, len(f.channels))
+	for _, channel := range f.channels {
+		channels = append(channels, channel)
+	}
+	f.mu.RUnlock()
+	for _, channel := range channels {
+		select {
+		case <-channel.Credited():
+		case <-channel.done:
+		case <-stop:
+			return false
+		}
+	}
+	return true
+}
+
 func (f *terminalFanout) Broadcast(data []byte) {
 	f.mu.RLock()
 	channels := make([]*terminalOutputChannel, 0, len(f.channels))
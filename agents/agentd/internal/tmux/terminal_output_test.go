@@ -1,6 +1,7 @@
 package tmux
 
 import (
+	"encoding/base64"
 	"reflect"
 	"sync"
 	"testing"
@@ -94,3 +95,47 @@ func TestTerminalOutputChannelReportsLagAfterDropping(t *testing.T) {
 		t.Fatal("timed out waiting for lag notification")
 	}
 }
+
+func TestTerminalOutputChannelHoldsOutputUntilAcked(t *testing.T) {
+	delivered := make(chan string, 4)
+	channel := newTerminalOutputChannel("viewer", 8, func(_ string, encoded string) {
+		delivered <- encoded
+	}, nil)
+	defer channel.Close()
+	if window := channel.SetFlowWindow(1); window != minTerminalFlowWindow {
+		t.Fatalf("window=%d, want clamp to %d", window, minTerminalFlowWindow)
+	}
+
+	full := base64.StdEncoding.EncodeToString(make([]byte, minTerminalFlowWindow))
+	channel.Enqueue(full)
+	select {
+	case <-delivered:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for in-window output")
+	}
+	select {
+	case <-channel.Credited():
+		t.Fatal("channel still has credit after filling its window")
+	default:
+	}
+
+	channel.Enqueue("aGk=")
+	select {
+	case encoded := <-delivered:
+		t.Fatalf("delivered %q without credit", encoded)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	channel.Ack(minTerminalFlowWindow)
+	select {
+	case encoded := <-delivered:
+		if encoded != "aGk=" {
+			t.Fatalf("delivered=%q after ack", encoded)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for output after ack")
+	}
+	if got := encodedOutputLen("aGk="); got != 2 {
+		t.Fatalf("decoded length=%d, want 2", got)
+	}
+}
@@ -0,0 +1,273 @@
+package tmux
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"strings"
+)
+
+const (
+	// OSC 52 payloads are base64 text; anything larger is dropped rather than
+	// buffered without bound.
+	maxClipboardSequenceBytes = 1 << 20
+	maxLinkSequenceBytes      = 8 * 1024
+	maxLinkTextBytes          = 512
+)
+
+// TerminalClipboard is an OSC 52 clipboard write from a pane.
+type TerminalClipboard struct {
+	// Selection is the OSC 52 selection parameter, e.g. "c" or "p".
+	Selection string
+	// Data is the base64 clipboard contents as the application sent them.
+	Data string
+}
+
+// TerminalLink is an OSC 8 hyperlink and the text printed inside it.
+type TerminalLink struct {
+	URI  string
+	ID   string
+	Text string
+}
+
+var linkSchemes = map[string]bool{"http": true, "https": true, "file": true, "mailto": true}
+
+type sequenceState int
+
+const (
+	sequenceGround sequenceState = iota
+	sequenceEscape
+	sequenceCSI
+	sequenceOSC
+	sequenceOSCEscape
+)
+
+// terminalSequenceFilter pulls OSC 52 and OSC 8 sequences out of a viewer's
+// output stream. Clipboard writes are removed so the browser terminal never
+// acts on them itself; hyperlinks are left in place and also reported with
+// the text they wrap. Sequences may be split across reads.
+type terminalSequenceFilter struct {
+	state sequenceState
+	// held is output withheld while an OSC's number is still unknown, so a
+	// clipboard write can be removed whole.
+	held      []byte
+	body      []byte
+	number    string
+	numbered  bool
+	swallow   bool
+	overflow  bool
+	link      *TerminalLink
+	linkText  []byte
+	clipboard []TerminalClipboard
+	links     []TerminalLink
+}
+
+// Filter returns the output to forward and the sequences completed by data.
+func (f *terminalSequenceFilter) Filter(data []byte) ([]byte, []TerminalClipboard, []TerminalLink) {
+	out := make([]byte, 0, len(data))
+	for _, c := range data {
+		out = f.step(out, c)
+	}
+	clipboard, links := f.clipboard, f.links
+	f.clipboard, f.links = nil, nil
+	return out, clipboard, links
+}
+
+func (f *terminalSequenceFilter) step(out []byte, c byte) []byte {
+	switch f.state {
+	case sequenceGround:
+		if c == 0x1b {
+			f.state = sequenceEscape
+			f.held = append(f.held[:0], c)
+			return out
+		}
+		if f.link != nil && (c >= 0x20 && c != 0x7f) && len(f.linkText) < maxLinkTextBytes {
+			f.linkText = append(f.linkText, c)
+		}
+		return append(out, c)
+	case sequenceEscape:
+		switch c {
+		case ']':
+			f.state = sequenceOSC
+			f.held = append(f.held, c)
+			f.body = f.body[:0]
+			f.number = ""
+			f.numbered = false
+			f.swallow = false
+			f.overflow = false
+			return out
+		case '[':
+			f.state = sequenceCSI
+		default:
+			f.state = sequenceGround
+		}
+		out = append(out, f.held...)
+		f.held = f.held[:0]
+		return append(out, c)
+	case sequenceCSI:
+		if c >= 0x40 && c <= 0x7e {
+			f.state = sequenceGround
+		}
+		return append(out, c)
+	case sequenceOSC:
+		switch c {
+		case 0x07:
+			return f.finishOSC(out, []byte{c})
+		case 0x1b:
+			f.state = sequenceOSCEscape
+			return f.oscByte(out, c, false)
+		case 0x18, 0x1a:
+			// CAN and SUB abort the sequence.
+			f.state = sequenceGround
+			f.swallow = false
+			return f.release(out, c)
+		}
+		return f.oscByte(out, c, true)
+	case sequenceOSCEscape:
+		if c == '\\' {
+			return f.finishOSC(out, []byte{c})
+		}
+		// An ESC that is not ST ends the OSC and starts a new sequence. A
+		// passed-through OSC has already forwarded that ESC.
+		passed := f.numbered && !f.swallow
+		f.state = sequenceGround
+		f.swallow = false
+		if !f.numbered {
+			out = append(out, f.held[:len(f.held)-1]...)
+		}
+		f.held = f.held[:0]
+		if !passed {
+			f.state = sequenceEscape
+			f.held = append(f.held, 0x1b)
+		}
+		return f.step(out, c)
+	}
+	return append(out, c)
+}
+
+// oscByte handles one byte of an OSC body. The number is read up to the
+// first ';', after which the sequence is either swallowed (OSC 52) or
+// passed through.
+func (f *terminalSequenceFilter) oscByte(out []byte, c byte, body bool) []byte {
+	if !f.numbered {
+		f.held = append(f.held, c)
+		if c == ';' {
+			f.numbered = true
+			f.swallow = f.number == "52"
+			if f.swallow {
+				f.held = f.held[:0]
+			} else {
+				out = append(out, f.held...)
+				f.held = f.held[:0]
+			}
+		} else if body {
+			f.number += string(c)
+			if len(f.number) > 4 {
+				// Not a sequence of interest; stop holding output.
+				f.numbered = true
+				out = append(out, f.held...)
+				f.held = f.held[:0]
+			}
+		}
+		return out
+	}
+	if body && (f.number == "52" || f.number == "8") {
+		limit := maxLinkSequenceBytes
+		if f.number == "52" {
+			limit = maxClipboardSequenceBytes
+		}
+		if len(f.body) < limit {
+			f.body = append(f.body, c)
+		} else {
+			f.overflow = true
+		}
+	}
+	if f.swallow {
+		return out
+	}
+	return append(out, c)
+}
+
+func (f *terminalSequenceFilter) finishOSC(out []byte, terminator []byte) []byte {
+	f.state = sequenceGround
+	swallowed := f.swallow
+	f.swallow = false
+	if !f.numbered {
+		// Terminated before any ';': nothing of interest.
+		return f.release(out, terminator...)
+	}
+	if !f.overflow {
+		switch f.number {
+		case "52":
+			f.clipboardWrite(string(f.body))
+		case "8":
+			f.hyperlink(string(f.body))
+		}
+	}
+	if swallowed {
+		return out
+	}
+	return append(out, terminator...)
+}
+
+// release forwards held bytes plus extra when a sequence turns out not to be
+// one the filter removes.
+func (f *terminalSequenceFilter) release(out []byte, extra ...byte) []byte {
+	if f.swallow {
+		return out
+	}
+	out = append(out, f.held...)
+	f.held = f.held[:0]
+	return append(out, extra...)
+}
+
+func (f *terminalSequenceFilter) clipboardWrite(body string) {
+	selection, data, ok := strings.Cut(body, ";")
+	if !ok || data == "?" {
+		// Clipboard reads are never answered.
+		return
+	}
+	if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+		return
+	}
+	if selection == "" {
+		selection = "s0"
+	}
+	f.clipboard = append(f.clipboard, TerminalClipboard{Selection: selection, Data: data})
+}
+
+func (f *terminalSequenceFilter) hyperlink(body string) {
+	params, uri, ok := strings.Cut(body, ";")
+	if !ok {
+		return
+	}
+	f.closeLink()
+	if uri == "" {
+		return
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil || !linkSchemes[strings.ToLower(parsed.Scheme)] {
+		return
+	}
+	link := &TerminalLink{URI: uri}
+	for _, param := range strings.Split(params, ":") {
+		if value, ok := strings.CutPrefix(param, "id="); ok {
+			link.ID = value
+		}
+	}
+	f.link = link
+	f.linkText = f.linkText[:0]
+}
+
+// closeLink reports the open hyperlink, if any, with the text printed since
+// it was opened.
+func (f *terminalSequenceFilter) closeLink() {
+	if f.link == nil {
+		return
+	}
+	link := *f.link
+	link.Text = string(bytes.ToValidUTF8(f.linkText, nil))
+	f.links = append(f.links, link)
+	f.link = nil
+	f.linkText = f.linkText[:0]
+}
@@ -0,0 +1,87 @@
+package tmux
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTerminalSequenceFilterExtractsClipboardAndLinksAcrossReads(t *testing.T) {
+	stream := "\x1b[1mbuild\x1b[0m " +
+		"\x1b]52;c;aGVsbG8=\x07" +
+		"\x1b]8;id=b42;https://example.com/42\x1b\\#\x1b[32m42\x1b[0m\x1b]8;;\x1b\\" +
+		"\x1b]52;c;?\x07" +
+		"\x1b]8;;javascript:alert(1)\x07x\x1b]8;;\x07" +
+		"\x1b]0;title\x07done"
+	want := "\x1b[1mbuild\x1b[0m " +
+		"\x1b]8;id=b42;https://example.com/42\x1b\\#\x1b[32m42\x1b[0m\x1b]8;;\x1b\\" +
+		"\x1b]8;;javascript:alert(1)\x07x\x1b]8;;\x07" +
+		"\x1b]0;title\x07done"
+
+	var filter terminalSequenceFilter
+	var output []byte
+	var clipboard []TerminalClipboard
+	var links []TerminalLink
+	for i := 0; i < len(stream); i += 3 {
+		out, clips, found := filter.Filter([]byte(stream[i:min(i+3, len(stream))]))
+		output = append(output, out...)
+		clipboard = append(clipboard, clips...)
+		links = append(links, found...)
+	}
+
+	if string(output) != want {
+		t.Fatalf("output=%q\nwant   %q", output, want)
+	}
+	if !reflect.DeepEqual(clipboard, []TerminalClipboard{{Selection: "c", Data: "aGVsbG8="}}) {
+		t.Fatalf("clipboard=%+v, want one write and no read", clipboard)
+	}
+	if !reflect.DeepEqual(links, []TerminalLink{{URI: "https://example.com/42", ID: "b42", Text: "#42"}}) {
+		t.Fatalf("links=%+v", links)
+	}
+}
+
+func TestTerminalViewerForwardsSequencesAndConfiguresPassthrough(t *testing.T) {
+	runner := newFakeTmuxRunner()
+	runner.outputs["display-message -p -t %7 #{session_name}\t#{window_index}\t#{pane_index}"] = []byte("agents\t2\t1\n")
+	runner.outputs["show-options -s -v set-clipboard"] = []byte("external\n")
+	runner.outputs["show-options -s -v terminal-features"] = []byte("xterm*:clipboard:ccolour:cstyle:focus:title\n")
+	clipboard := make(chan TerminalClipboard, 1)
+	links := make(chan TerminalLink, 1)
+	manager := newTerminalManagerWithRunner(nil, runner, t.TempDir())
+	manager.SetOutputHandler(func(string, string) {})
+	manager.SetClipboardHandler(func(channelID string, write TerminalClipboard) { clipboard <- write })
+	manager.SetLinkHandler(func(channelID string, link TerminalLink) { links <- link })
+	defer manager.Close()
+
+	if _, err := manager.AttachWithOptions("viewer", "%7", AttachOptions{SessionID: "session-1"}); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+	if _, err := manager.AttachWithOptions("second", "%7", AttachOptions{SessionID: "session-1"}); err != nil {
+		t.Fatalf("attach second viewer: %v", err)
+	}
+	setClipboard := []string{"set-option", "-s", "set-clipboard", "on"}
+	if runner.runIndex(setClipboard) < 0 || runner.runIndex(setClipboard) != runner.lastRunIndex(setClipboard) {
+		t.Fatal("set-clipboard was not enabled exactly once")
+	}
+	if !runner.hasRun([]string{"set-option", "-s", "-a", "terminal-features", "xterm-256color:hyperlinks"}) {
+		t.Fatal("hyperlinks terminal feature was not added")
+	}
+
+	runner.processes[0].Feed([]byte("\x1b]52;c;aGk=\x07\x1b]8;;https://example.com\x07site\x1b]8;;\x07"))
+	select {
+	case write := <-clipboard:
+		if write.Selection != "c" || write.Data != "aGk=" {
+			t.Fatalf("clipboard=%+v", write)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("clipboard write was not forwarded")
+	}
+	select {
+	case link := <-links:
+		if link.URI != "https://example.com" || link.Text != "site" {
+			t.Fatalf("link=%+v", link)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("link was not forwarded")
+	}
+}
@@ -20,6 +20,9 @@ const viewerSessionPrefix = "ac-view-"
 const (
 	defaultTerminalBufferChunks = 64
 	defaultPTYCoalesceDelay     = 16 * time.Millisecond
+	// A viewer held back by flow control for longer than this is repainted
+	// when it catches up; tmux skips output for clients that stop draining.
+	viewerStallRedrawAfter = 500 * time.Millisecond
 )
 
 // TerminalSize is the PTY size requested by a terminal viewer.
@@ -107,10 +110,15 @@ type viewerPTYOptions struct {
 	Letterbox     bool
 	ResumeToken   string
 	InitialOutput []byte
+	FlowWindow    int
 	BufferChunks  int
 	CoalesceDelay time.Duration
 	OnOutput      func(channelID, encoded string)
 	OnStatus      func(channelID, status, message string)
+	// OnClipboard receives OSC 52 writes, which are always removed from the
+	// output; without a handler they are dropped.
+	OnClipboard func(channelID string, clipboard TerminalClipboard)
+	OnLink      func(channelID string, link TerminalLink)
 }
 
 type viewerPTYBridge struct {
@@ -131,6 +139,10 @@ type viewerPTYBridge struct {
 	size         TerminalSize
 	process      PTYProcess
 	fanout       *terminalFanout
+	flowWindow   int
+	sequences    terminalSequenceFilter
+	onClipboard  func(channelID string, clipboard TerminalClipboard)
+	onLink       func(channelID string, link TerminalLink)
 	closed       chan struct{}
 	mu           sync.RWMutex
 	closeOnce    sync.Once
@@ -226,6 +238,10 @@ func newViewerPTYBridge(runner TmuxRunner, opts viewerPTYOptions) (*viewerPTYBri
 		},
 	)
 	fanout.Attach(opts.ChannelID)
+	flowWindow := 0
+	if opts.FlowWindow > 0 {
+		flowWindow = fanout.SetFlowWindow(opts.ChannelID, opts.FlowWindow)
+	}
 	if len(opts.InitialOutput) > 0 {
 		fanout.Broadcast(opts.InitialOutput)
 	}
@@ -243,6 +259,9 @@ func newViewerPTYBridge(runner TmuxRunner, opts viewerPTYOptions) (*viewerPTYBri
 		size:         size,
 		process:      process,
 		fanout:       fanout,
+		flowWindow:   flowWindow,
+		onClipboard:  opts.OnClipboard,
+		onLink:       opts.OnLink,
 		closed:       make(chan struct{}),
 	}
 	cleanupGroup = false
@@ -429,6 +448,45 @@ func (b *viewerPTYBridge) State() (TerminalViewerState, error) {
 	}, nil
 }
 
+// Ack returns flow-control credit for output the viewer has consumed.
+func (b *viewerPTYBridge) Ack(channelID string, bytes int) error {
+	if b.flowWindow == 0 {
+		return fmt.Errorf("flow control is not enabled for channel %s", channelID)
+	}
+	if !b.fanout.Ack(channelID, bytes) {
+		return fmt.Errorf("channel %s not found", channelID)
+	}
+	return nil
+}
+
+// waitForCredit pauses PTY reads while the viewer is out of credit. The
+// viewer owns this PTY, so pausing it leaves other viewers of the pane
+// untouched; tmux stops rendering into the blocked client, and a long stall
+// ends with a full repaint instead of the output the viewer missed.
+func (b *viewerPTYBridge) waitForCredit() bool {
+	if b.flowWindow == 0 {
+		return true
+	}
+	started := time.Now()
+	if !b.fanout.WaitForCredit(b.closed) {
+		return false
+	}
+	if time.Since(started) > viewerStallRedrawAfter {
+		b.redraw()
+	}
+	return true
+}
+
+func (b *viewerPTYBridge) redraw() {
+	clients, err := b.runner.Output("list-clients", "-t", b.viewSession, "-F", "#{client_name}")
+	if err != nil {
+		return
+	}
+	for _, client := range strings.Fields(string(clients)) {
+		_ = b.runner.Run("refresh-client", "-t", client)
+	}
+}
+
 func (b *viewerPTYBridge) DetachChannel(channelID string) {
 	if b.fanout != nil {
 		b.fanout.Detach(channelID)
@@ -468,6 +526,9 @@ func (b *viewerPTYBridge) readLoop(delay time.Duration) {
 		defer close(reads)
 		buffer := make([]byte, 4096)
 		for {
+			if !b.waitForCredit() {
+				return
+			}
 			n, err := b.process.Read(buffer)
 			if n > 0 {
 				chunk := append([]byte(nil), buffer[:n]...)
@@ -509,7 +570,14 @@ func (b *viewerPTYBridge) readLoop(delay time.Duration) {
 				b.close(false)
 				return
 			}
-			pending = append(pending, chunk...)
+			output, clipboard, links := b.sequences.Filter(chunk)
+			pending = append(pending, output...)
+			if len(clipboard) > 0 || len(links) > 0 {
+				// Side messages follow the output that precedes them, so a
+				// link arrives after its text.
+				flush()
+				b.emitSequences(clipboard, links)
+			}
 			if timer == nil {
 				timer = time.NewTimer(delay)
 				timerC = timer.C
@@ -522,6 +590,19 @@ func (b *viewerPTYBridge) readLoop(delay time.Duration) {
 	}
 }
 
+func (b *viewerPTYBridge) emitSequences(clipboard []TerminalClipboard, links []TerminalLink) {
+	if b.onClipboard != nil {
+		for _, write := range clipboard {
+			b.onClipboard(b.channelID, write)
+		}
+	}
+	if b.onLink != nil {
+		for _, link := range links {
+			b.onLink(b.channelID, link)
+		}
+	}
+}
+
 func (b *viewerPTYBridge) waitForExit() {
 	if b.process == nil {
 		return
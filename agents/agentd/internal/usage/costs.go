@@ -0,0 +1,483 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const costLedgerFile = "usage-costs.json"
+
+// costLedgerSaveDelay batches the ledger writes of spend accruing in quick
+// succession.
+const costLedgerSaveDelay = 5 * time.Second
+
+// costLedgerWeeks is how many weeks of spend the ledger keeps.
+const costLedgerWeeks = 53
+
+type sessionCost struct {
+	provider string
+	model    string
+	group    string
+	repo     string
+	// tokens are the last cumulative counts seen, so each report is
+	// priced by what it adds. tokensSeen is set once counts are reported.
+	tokens     Tokens
+	tokensSeen bool
+	cents      float64
+	priced     bool
+	// reported is set once the provider reports the session's cost,
+	// which then replaces the estimate. lastReported is the cumulative
+	// cost it last reported, so each report accrues what it adds.
+	reported     bool
+	lastReported float64
+}
+
+type weeklyKey struct {
+	week string
+	repo string
+}
+
+// SessionCost is a session's estimated cost so far.
+type SessionCost struct {
+	SessionID string `json:"session_id"`
+	Provider  string `json:"provider"`
+	Model     string `json:"model,omitempty"`
+	GroupID   string `json:"group_id,omitempty"`
+	Repo      string `json:"repo,omitempty"`
+	Tokens    Tokens `json:"tokens"`
+	CostCents int    `json:"cost_cents"`
+	// Reported is true when the cost came from the provider rather than
+	// the price table.
+	Reported bool `json:"reported,omitempty"`
+}
+
+// GroupCost is the spend of a group's sessions since agentd started,
+// including sessions that have since ended.
+type GroupCost struct {
+	GroupID   string `json:"group_id"`
+	CostCents int    `json:"cost_cents"`
+}
+
+// WeeklyCost is a repository's spend in an ISO week, e.g. "2026-W07".
+type WeeklyCost struct {
+	Week      string  `json:"week"`
+	Repo      string  `json:"repo"`
+	CostCents float64 `json:"cost_cents"`
+}
+
+// Spend is what a session used since its previous report, along with its
+// totals so far. Tokens count input, output and cache tokens alike.
+type Spend struct {
+	SessionID     string
+	GroupID       string
+	Tokens        int
+	Cents         float64
+	SessionTokens int
+	SessionCents  float64
+	// Backlog marks usage from before agentd started, which counts toward
+	// the session only.
+	Backlog bool
+}
+
+// CostReport is a snapshot of the tracker's cost totals.
+type CostReport struct {
+	Sessions []SessionCost `json:"sessions"`
+	Groups   []GroupCost   `json:"groups"`
+	Weekly   []WeeklyCost  `json:"weekly"`
+}
+
+// SetPricing sets the price table used to estimate costs from tokens.
+func (t *UsageTracker) SetPricing(table *PriceTable) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pricing = table
+}
+
+// SetAttribution sets how a session's spend is attributed to a group and
+// repository. It is consulted each time spend accrues.
+func (t *UsageTracker) SetAttribution(attribution func(sessionID string) (group, repo string)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attribution = attribution
+}
+
+// SetSpendListener sets a function called with each session's new spend,
+// outside the tracker's lock.
+func (t *UsageTracker) SetSpendListener(listener func(Spend)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spendListener = listener
+}
+
+// OpenCostLedger loads the weekly spend ledger from stateDir and keeps it
+// there as spend accrues.
+func (t *UsageTracker) OpenCostLedger(stateDir string) error {
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	path := filepath.Join(stateDir, costLedgerFile)
+	entries, err := readCostLedger(path)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ledgerPath = path
+	for _, entry := range entries {
+		t.weekly[weeklyKey{week: entry.Week, repo: entry.Repo}] += entry.CostCents
+	}
+	return nil
+}
+
+// ReadCostLedger returns the weekly spend recorded in stateDir, oldest
+// week first.
+func ReadCostLedger(stateDir string) ([]WeeklyCost, error) {
+	return readCostLedger(filepath.Join(stateDir, costLedgerFile))
+}
+
+func readCostLedger(path string) ([]WeeklyCost, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cost ledger: %w", err)
+	}
+	var entries []WeeklyCost
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse cost ledger: %w", err)
+	}
+	return entries, nil
+}
+
+// SetModel records the model a session is using. Tokens reported after
+// this are priced at its rate.
+func (t *UsageTracker) SetModel(sessionID, model string) {
+	model = strings.TrimSpace(model)
+	if sessionID == "" || model == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sessionCostLocked(sessionID, "").model = model
+}
+
+// RecordTokens prices a session's cumulative token counts and returns its
+// estimated cost in cents, or nil while no price is known for it. As with
+// RecordCost, the first counts after agentd starts count toward the session
+// only, and counts that go down are taken as a new conversation and priced
+// in full.
+func (t *UsageTracker) RecordTokens(sessionID, provider string, tokens Tokens) *int {
+	return t.recordTokens(sessionID, provider, tokens, false, true)
+}
+
+// RecordJobTokens is RecordTokens for a job agentd started, whose first
+// counts are all new spend.
+func (t *UsageTracker) RecordJobTokens(sessionID, provider string, tokens Tokens) *int {
+	return t.recordTokens(sessionID, provider, tokens, false, false)
+}
+
+// recordTokens prices tokens. backlog spend counts toward the session only,
+// and with firstIsBacklog so does the session's first report.
+func (t *UsageTracker) recordTokens(sessionID, provider string, tokens Tokens, backlog, firstIsBacklog bool) *int {
+	group, repo := t.attribute(sessionID)
+	t.mu.Lock()
+	cost := t.sessionCostLocked(sessionID, provider)
+	cost.group, cost.repo = group, repo
+	if firstIsBacklog && !cost.tokensSeen {
+		backlog = true
+	}
+	cost.tokensSeen = true
+
+	delta := Tokens{
+		Input:      tokens.Input - cost.tokens.Input,
+		Output:     tokens.Output - cost.tokens.Output,
+		CacheRead:  tokens.CacheRead - cost.tokens.CacheRead,
+		CacheWrite: tokens.CacheWrite - cost.tokens.CacheWrite,
+	}
+	if delta.Input < 0 || delta.Output < 0 || delta.CacheRead < 0 || delta.CacheWrite < 0 {
+		delta = tokens
+	}
+	cost.tokens = tokens
+	cents := 0.0
+	if !cost.reported {
+		model := cost.model
+		if model == "" {
+			model = t.pricing.DefaultModel(cost.provider)
+		}
+		if price, ok := t.pricing.Lookup(model); ok {
+			cost.priced = true
+			cents = price.CostCents(delta)
+			if backlog {
+				cost.cents += cents
+			} else {
+				t.accrueLocked(cost, cents)
+			}
+		}
+	}
+	var result *int
+	if cost.priced {
+		rounded := int(math.Round(cost.cents))
+		result = &rounded
+	}
+	spend := t.spendLocked(sessionID, cost, delta.total(), cents, backlog)
+	t.mu.Unlock()
+	t.notifySpend(spend)
+	return result
+}
+
+// RecordCost takes a session's cumulative cost as reported by the provider,
+// in cents, and returns the session's cost rounded. Token reports no longer
+// change it. The first report after agentd starts may include spend from
+// before it, which an earlier run will have counted, so it counts toward
+// the session only. A cost that goes down is taken as a new conversation,
+// as after /clear, and counted in full.
+func (t *UsageTracker) RecordCost(sessionID, provider string, cents float64) int {
+	return t.recordCost(sessionID, provider, cents, true)
+}
+
+// RecordJobCost is RecordCost for a job agentd started, whose first report
+// is all new spend.
+func (t *UsageTracker) RecordJobCost(sessionID, provider string, cents float64) int {
+	return t.recordCost(sessionID, provider, cents, false)
+}
+
+func (t *UsageTracker) recordCost(sessionID, provider string, cents float64, firstIsBacklog bool) int {
+	group, repo := t.attribute(sessionID)
+	t.mu.Lock()
+	cost := t.sessionCostLocked(sessionID, provider)
+	cost.group, cost.repo = group, repo
+	var delta float64
+	switch {
+	case !cost.reported:
+		// The first report replaces any estimate.
+		delta = cents - cost.cents
+	case cents < cost.lastReported:
+		delta = cents
+	default:
+		delta = cents - cost.lastReported
+	}
+	backlog := firstIsBacklog && !cost.reported
+	cost.reported = true
+	cost.priced = true
+	cost.lastReported = cents
+	if backlog {
+		cost.cents += delta
+	} else {
+		t.accrueLocked(cost, delta)
+	}
+	rounded := int(math.Round(cost.cents))
+	spend := t.spendLocked(sessionID, cost, 0, delta, backlog)
+	t.mu.Unlock()
+	t.notifySpend(spend)
+	return rounded
+}
+
+// Costs returns the current per-session, per-group and weekly totals.
+func (t *UsageTracker) Costs() CostReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := CostReport{
+		Sessions: []SessionCost{},
+		Groups:   []GroupCost{},
+		Weekly:   t.weeklyLocked(),
+	}
+	for sessionID, cost := range t.costs {
+		if !cost.priced {
+			continue
+		}
+		report.Sessions = append(report.Sessions, SessionCost{
+			SessionID: sessionID,
+			Provider:  cost.provider,
+			Model:     cost.model,
+			GroupID:   cost.group,
+			Repo:      cost.repo,
+			Tokens:    cost.tokens,
+			CostCents: int(math.Round(cost.cents)),
+			Reported:  cost.reported,
+		})
+	}
+	sort.Slice(report.Sessions, func(i, j int) bool {
+		return report.Sessions[i].SessionID < report.Sessions[j].SessionID
+	})
+	for group, cents := range t.groupCents {
+		report.Groups = append(report.Groups, GroupCost{GroupID: group, CostCents: int(math.Round(cents))})
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		return report.Groups[i].GroupID < report.Groups[j].GroupID
+	})
+	return report
+}
+
+func (t *UsageTracker) attribute(sessionID string) (string, string) {
+	t.mu.Lock()
+	attribution := t.attribution
+	t.mu.Unlock()
+	if attribution == nil {
+		return "", ""
+	}
+	return attribution(sessionID)
+}
+
+func (t *UsageTracker) sessionCostLocked(sessionID, provider string) *sessionCost {
+	cost, ok := t.costs[sessionID]
+	if !ok {
+		cost = &sessionCost{}
+		t.costs[sessionID] = cost
+	}
+	if provider != "" {
+		cost.provider = provider
+	}
+	return cost
+}
+
+func (t *UsageTracker) spendLocked(sessionID string, cost *sessionCost, tokens int, cents float64, backlog bool) *Spend {
+	if t.spendListener == nil || (tokens == 0 && cents == 0) {
+		return nil
+	}
+	return &Spend{
+		SessionID:     sessionID,
+		GroupID:       cost.group,
+		Tokens:        tokens,
+		Cents:         cents,
+		SessionTokens: cost.tokens.total(),
+		SessionCents:  cost.cents,
+		Backlog:       backlog,
+	}
+}
+
+func (t *UsageTracker) notifySpend(spend *Spend) {
+	if spend == nil {
+		return
+	}
+	t.mu.Lock()
+	listener := t.spendListener
+	t.mu.Unlock()
+	if listener != nil {
+		listener(*spend)
+	}
+}
+
+// accrueLocked adds cents to a session and to its group's and repository's
+// totals. cents is negative when a reported cost corrects an estimate.
+func (t *UsageTracker) accrueLocked(cost *sessionCost, cents float64) {
+	if cents == 0 {
+		return
+	}
+	cost.cents += cents
+	t.groupCents[cost.group] += cents
+	year, week := t.now().ISOWeek()
+	t.weekly[weeklyKey{week: fmt.Sprintf("%04d-W%02d", year, week), repo: cost.repo}] += cents
+	if t.ledgerPath != "" && t.ledgerTimer == nil {
+		t.ledgerTimer = time.AfterFunc(costLedgerSaveDelay, func() {
+			// Losing the ledger only loses history; spend keeps accruing.
+			_ = t.FlushCostLedger()
+		})
+	}
+}
+
+// FlushCostLedger saves spend accrued since the ledger was last written.
+// Saves otherwise trail accrual by a few seconds, so agentd flushes on
+// shutdown.
+func (t *UsageTracker) FlushCostLedger() error {
+	t.ledgerSaveMu.Lock()
+	defer t.ledgerSaveMu.Unlock()
+	t.mu.Lock()
+	if t.ledgerTimer == nil {
+		t.mu.Unlock()
+		return nil
+	}
+	t.ledgerTimer.Stop()
+	t.ledgerTimer = nil
+	path := t.ledgerPath
+	entries := t.weeklyLocked()
+	t.mu.Unlock()
+	return writeCostLedger(path, entries)
+}
+
+// weeklyLocked returns the weekly totals oldest first, dropping weeks the
+// ledger no longer keeps.
+func (t *UsageTracker) weeklyLocked() []WeeklyCost {
+	year, week := t.now().AddDate(0, 0, -7*costLedgerWeeks).ISOWeek()
+	oldest := fmt.Sprintf("%04d-W%02d", year, week)
+	entries := []WeeklyCost{}
+	for key, cents := range t.weekly {
+		if key.week < oldest {
+			delete(t.weekly, key)
+			continue
+		}
+		entries = append(entries, WeeklyCost{Week: key.week, Repo: key.repo, CostCents: math.Round(cents*100) / 100})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Week != entries[j].Week {
+			return entries[i].Week < entries[j].Week
+		}
+		return entries[i].Repo < entries[j].Repo
+	})
+	return entries
+}
+
+func writeCostLedger(path string, entries []WeeklyCost) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write cost ledger: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace cost ledger: %w", err)
+	}
+	return nil
+}
+
+func (u *SessionUsage) tokens() (Tokens, bool) {
+	if u.InputTokens == nil && u.OutputTokens == nil && u.CacheReadTokens == nil && u.CacheWriteTokens == nil {
+		return Tokens{}, false
+	}
+	var tokens Tokens
+	if u.InputTokens != nil {
+		tokens.Input = *u.InputTokens
+	}
+	if u.OutputTokens != nil {
+		tokens.Output = *u.OutputTokens
+	}
+	if u.CacheReadTokens != nil {
+		tokens.CacheRead = *u.CacheReadTokens
+	}
+	if u.CacheWriteTokens != nil {
+		tokens.CacheWrite = *u.CacheWriteTokens
+	}
+	return tokens, true
+}
+
+var (
+	modelLinePattern = regexp.MustCompile(`(?im)^[\s│|>*•]*model:\s*(.+)$`)
+	modelIDPattern   = regexp.MustCompile(`(?i)^[a-z][a-z0-9]*(?:[-._/:][a-z0-9]+)*$`)
+)
+
+// ParseModelFromText returns the model ID from the last "Model:" line in
+// text, as shown by the providers' status screens, e.g. "Model: gpt-5-codex
+// (reasoning high)" or "Model: sonnet (claude-sonnet-4-5-20250929)". Only a
+// word containing both letters and digits is taken for an ID, so aliases
+// such as "sonnet" give way to the full ID beside them.
+func ParseModelFromText(text string) string {
+	matches := modelLinePattern.FindAllStringSubmatch(text, -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		for _, field := range strings.Fields(matches[i][1]) {
+			field = strings.Trim(field, "()[],")
+			if modelIDPattern.MatchString(field) && strings.ContainsAny(field, "0123456789") {
+				return field
+			}
+		}
+	}
+	return ""
+}
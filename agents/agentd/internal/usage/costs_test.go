@@ -0,0 +1,191 @@
+package usage
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPriceTableLookup(t *testing.T) {
+	table := NewPriceTable(map[string]Price{
+		"claude-sonnet-4*":   {Input: 3, Output: 15},
+		"claude-sonnet-4-5*": {Input: 4, Output: 20},
+		"gpt-5-codex":        {Input: 1.25, Output: 10},
+	}, map[string]string{"codex": "gpt-5-codex"})
+
+	for model, want := range map[string]float64{
+		"claude-sonnet-4-20250514":   3,
+		"claude-sonnet-4-5-20250929": 4,
+		"GPT-5-Codex":                1.25,
+	} {
+		price, ok := table.Lookup(model)
+		if !ok || price.Input != want {
+			t.Fatalf("Lookup(%q) = %+v, %v; want input %v", model, price, ok, want)
+		}
+	}
+	if _, ok := table.Lookup("gpt-5-codex-mini"); ok {
+		t.Fatalf("an exact key matched a longer model ID")
+	}
+	if got := table.DefaultModel("codex"); got != "gpt-5-codex" {
+		t.Fatalf("DefaultModel = %q", got)
+	}
+
+	price := Price{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+	cents := price.CostCents(Tokens{Input: 1_000_000, Output: 100_000, CacheRead: 1_000_000, CacheWrite: 100_000})
+	if math.Abs(cents-(300+150+30+37.5)) > 1e-9 {
+		t.Fatalf("CostCents = %v", cents)
+	}
+}
+
+func TestUsageTrackerAccruesCosts(t *testing.T) {
+	tracker := NewUsageTracker()
+	tracker.now = func() time.Time { return time.Date(2026, 2, 11, 12, 0, 0, 0, time.UTC) }
+	tracker.SetPricing(NewPriceTable(map[string]Price{
+		"claude-sonnet-4*": {Input: 3, Output: 15},
+		"claude-opus-4*":   {Input: 15, Output: 75},
+	}, map[string]string{"claude_code": "claude-sonnet-4"}))
+	tracker.SetAttribution(func(sessionID string) (string, string) {
+		return "fleet", "/src/" + sessionID
+	})
+	stateDir := t.TempDir()
+	if err := tracker.OpenCostLedger(stateDir); err != nil {
+		t.Fatalf("OpenCostLedger: %v", err)
+	}
+
+	// The first counts a session reports are its baseline, which may
+	// predate agentd.
+	tracker.RecordTokens("a", "claude_code", Tokens{})
+	// Priced at the provider's default model until one is reported.
+	if got := deref(t, "cost", tracker.RecordTokens("a", "claude_code", Tokens{Input: 1_000_000})); got != 300 {
+		t.Fatalf("default model cost = %d", got)
+	}
+	// Only what the next report adds is priced, at the new model's rate.
+	tracker.SetModel("a", "claude-opus-4-1")
+	if got := deref(t, "cost", tracker.RecordTokens("a", "claude_code", Tokens{Input: 1_000_000, Output: 100_000})); got != 1050 {
+		t.Fatalf("cost after model change = %d", got)
+	}
+	// A reported cost replaces the estimate. The first report may predate
+	// agentd, so only what later reports add counts toward the totals.
+	if got := tracker.RecordCost("b", "claude_code", 500); got != 500 {
+		t.Fatalf("reported cost = %d", got)
+	}
+	if got := tracker.RecordCost("b", "claude_code", 700); got != 700 {
+		t.Fatalf("reported cost = %d", got)
+	}
+	// A cost that drops, as after /clear, starts a new conversation.
+	if got := tracker.RecordCost("b", "claude_code", 300); got != 1000 {
+		t.Fatalf("cost after reset = %d", got)
+	}
+	if got := deref(t, "cost", tracker.RecordTokens("b", "claude_code", Tokens{Output: 1_000_000})); got != 1000 {
+		t.Fatalf("tokens changed a reported cost: %d", got)
+	}
+	// Unpriced models report no cost.
+	if got := tracker.RecordTokens("c", "codex", Tokens{Input: 10}); got != nil {
+		t.Fatalf("unpriced cost = %d", *got)
+	}
+
+	report := tracker.Costs()
+	if len(report.Sessions) != 2 || report.Sessions[0].Model != "claude-opus-4-1" || !report.Sessions[1].Reported {
+		t.Fatalf("sessions = %+v", report.Sessions)
+	}
+	if len(report.Groups) != 1 || report.Groups[0] != (GroupCost{GroupID: "fleet", CostCents: 1550}) {
+		t.Fatalf("groups = %+v", report.Groups)
+	}
+
+	// Group totals outlive the session; the weekly ledger outlives agentd.
+	tracker.RemoveSession("a")
+	if groups := tracker.Costs().Groups; groups[0].CostCents != 1550 {
+		t.Fatalf("groups after removal = %+v", groups)
+	}
+	// Saves are batched until the delay passes or the ledger is flushed.
+	if weekly, err := ReadCostLedger(stateDir); err != nil || weekly != nil {
+		t.Fatalf("ledger saved before flush = %+v, %v", weekly, err)
+	}
+	if err := tracker.FlushCostLedger(); err != nil {
+		t.Fatalf("FlushCostLedger: %v", err)
+	}
+	weekly, err := ReadCostLedger(stateDir)
+	if err != nil {
+		t.Fatalf("ReadCostLedger: %v", err)
+	}
+	want := []WeeklyCost{
+		{Week: "2026-W07", Repo: "/src/a", CostCents: 1050},
+		{Week: "2026-W07", Repo: "/src/b", CostCents: 500},
+	}
+	if len(weekly) != len(want) || weekly[0] != want[0] || weekly[1] != want[1] {
+		t.Fatalf("ledger = %+v", weekly)
+	}
+	reopened := NewUsageTracker()
+	reopened.now = tracker.now
+	if err := reopened.OpenCostLedger(stateDir); err != nil {
+		t.Fatalf("OpenCostLedger: %v", err)
+	}
+	if got := reopened.Costs().Weekly; len(got) != 2 || got[0] != want[0] {
+		t.Fatalf("reopened weekly = %+v", got)
+	}
+}
+
+func TestParseAndCheckChangedEstimatesCost(t *testing.T) {
+	tracker := NewUsageTracker()
+	tracker.SetPricing(NewPriceTable(map[string]Price{"gpt-5*": {Input: 1, Output: 10}}, nil))
+	tracker.SetParser(func(provider, text string) *SessionUsage {
+		input, output := 1_000_000, 100_000
+		return &SessionUsage{Provider: provider, InputTokens: &input, OutputTokens: &output}
+	})
+
+	usage := tracker.ParseAndCheckChanged("s", "codex", "│ Model: gpt-5-codex (reasoning high) │")
+	if usage == nil || deref(t, "cost", usage.CostCents) != 200 {
+		t.Fatalf("usage = %+v", usage)
+	}
+}
+
+func TestParseModelFromText(t *testing.T) {
+	for text, want := range map[string]string{
+		"  Model:            gpt-5-codex (reasoning medium, summaries auto)": "gpt-5-codex",
+		"Model: sonnet (claude-sonnet-4-5-20250929)":                         "claude-sonnet-4-5-20250929",
+		"Model: opus\nsome output\n│  Model: claude-opus-4-1":                "claude-opus-4-1",
+		"Model: Default (recommended)":                                       "",
+		"no model line":                                                      "",
+	} {
+		if got := ParseModelFromText(text); got != want {
+			t.Fatalf("ParseModelFromText(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestSpendListenerReportsEachSessionsNewSpend(t *testing.T) {
+	tracker := NewUsageTracker()
+	tracker.SetPricing(NewPriceTable(map[string]Price{"gpt-5*": {Input: 1, Output: 10}}, map[string]string{"codex": "gpt-5"}))
+	tracker.SetAttribution(func(sessionID string) (string, string) { return "fleet", "" })
+	var spends []Spend
+	tracker.SetSpendListener(func(spend Spend) { spends = append(spends, spend) })
+
+	tracker.RecordTokens("a", "codex", Tokens{Input: 1_000_000})
+	tracker.RecordTokens("a", "codex", Tokens{Input: 1_000_000, Output: 100_000})
+	tracker.RecordTokens("a", "codex", Tokens{Input: 1_000_000, Output: 100_000})
+	tracker.RecordJobTokens("job-tokens", "codex", Tokens{Output: 1000})
+	tracker.RecordCost("b", "codex", 50)
+	tracker.RecordJobCost("job", "codex", 20)
+	tracker.RecordTranscriptUsage("old", "codex", &SessionUsage{}, Tokens{Output: 10}, true)
+
+	want := []Spend{
+		{SessionID: "a", GroupID: "fleet", Tokens: 1_000_000, Cents: 100, SessionTokens: 1_000_000, SessionCents: 100, Backlog: true},
+		{SessionID: "a", GroupID: "fleet", Tokens: 100_000, Cents: 100, SessionTokens: 1_100_000, SessionCents: 200},
+		{SessionID: "job-tokens", GroupID: "fleet", Tokens: 1000, Cents: 1, SessionTokens: 1000, SessionCents: 1},
+		{SessionID: "b", GroupID: "fleet", Cents: 50, SessionCents: 50, Backlog: true},
+		{SessionID: "job", GroupID: "fleet", Cents: 20, SessionCents: 20},
+		{SessionID: "old", GroupID: "fleet", Tokens: 10, Cents: 0.01, SessionTokens: 10, SessionCents: 0.01, Backlog: true},
+	}
+	if len(spends) != len(want) {
+		t.Fatalf("spends = %+v", spends)
+	}
+	for i := range want {
+		if math.Abs(spends[i].Cents-want[i].Cents) > 1e-9 || math.Abs(spends[i].SessionCents-want[i].SessionCents) > 1e-9 {
+			t.Fatalf("spend %d = %+v, want %+v", i, spends[i], want[i])
+		}
+		spends[i].Cents, spends[i].SessionCents = want[i].Cents, want[i].SessionCents
+		if spends[i] != want[i] {
+			t.Fatalf("spend %d = %+v, want %+v", i, spends[i], want[i])
+		}
+	}
+}
@@ -4,12 +4,22 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Usage sources.
+const (
+	// SourceScreen is usage scraped from the pane.
+	SourceScreen = "screen"
+	// SourceTranscript is usage read from the session's transcript.
+	SourceTranscript = "transcript"
+)
+
 // SessionUsage holds parsed token usage for a session
 type SessionUsage struct {
 	Provider         string
+	Source           string
 	InputTokens      *int
 	OutputTokens     *int
 	TotalTokens      *int
@@ -41,26 +51,117 @@ type SessionUsage struct {
 	ReportedAt              time.Time
 }
 
-// UsageTracker tracks per-session usage and emits on changes
+// UsageTracker tracks per-session usage and emits on changes. It also
+// accrues estimated costs; see costs.go.
 type UsageTracker struct {
+	mu        sync.Mutex
 	lastUsage map[string]*SessionUsage // session_id -> last known usage
+	parse     func(provider, text string) *SessionUsage
+	// Sessions with a transcript report usage from both it and the
+	// screen; the last of each is kept so either can be merged into the
+	// other. See RecordTranscriptUsage.
+	screenUsage     map[string]*SessionUsage
+	transcriptUsage map[string]*SessionUsage
+
+	pricing     *PriceTable
+	attribution func(sessionID string) (group, repo string)
+	costs       map[string]*sessionCost
+	groupCents  map[string]float64
+	weekly      map[weeklyKey]float64
+	ledgerPath  string
+	// ledgerTimer is set while accrued spend waits to be saved; see
+	// FlushCostLedger. ledgerSaveMu orders the writes.
+	ledgerTimer  *time.Timer
+	ledgerSaveMu sync.Mutex
+	now          func() time.Time
+
+	spendListener func(Spend)
 }
 
 // NewUsageTracker creates a new usage tracker
 func NewUsageTracker() *UsageTracker {
 	return &UsageTracker{
-		lastUsage: make(map[string]*SessionUsage),
+		lastUsage:       make(map[string]*SessionUsage),
+		screenUsage:     make(map[string]*SessionUsage),
+		transcriptUsage: make(map[string]*SessionUsage),
+		costs:           make(map[string]*sessionCost),
+		groupCents:      make(map[string]float64),
+		weekly:          make(map[weeklyKey]float64),
+		now:             time.Now,
 	}
 }
 
+// SetParser replaces ParseUsageFromText, e.g. with provider-defined patterns.
+func (t *UsageTracker) SetParser(parse func(provider, text string) *SessionUsage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.parse = parse
+}
+
 // ParseAndCheckChanged parses snapshot text for usage and returns usage if changed
-// Returns nil if no usage found or if unchanged from last parse
+// Returns nil if no usage found or if unchanged from last parse. Once a
+// session reports usage from its transcript, the screen only fills in what
+// the transcript lacks, such as account limits.
 func (t *UsageTracker) ParseAndCheckChanged(sessionID, provider, snapshotText string) *SessionUsage {
-	usage := ParseUsageFromText(provider, snapshotText)
+	t.mu.Lock()
+	parse := t.parse
+	transcript := t.transcriptUsage[sessionID]
+	t.mu.Unlock()
+	if parse == nil {
+		parse = ParseUsageFromText
+	}
+	usage := parse(provider, snapshotText)
 	if usage == nil {
 		return nil
 	}
+	if usage.Source == "" {
+		usage.Source = SourceScreen
+	}
+	if model := ParseModelFromText(snapshotText); model != "" {
+		t.SetModel(sessionID, model)
+	}
+	if usage.CostCents != nil {
+		cost := t.RecordCost(sessionID, provider, float64(*usage.CostCents))
+		usage.CostCents = &cost
+	}
+	if tokens, ok := usage.tokens(); ok && transcript == nil {
+		// Tokens are still counted once a cost is reported; only the
+		// estimate stops.
+		if cost := t.RecordTokens(sessionID, provider, tokens); cost != nil {
+			usage.CostCents = cost
+		}
+	}
 
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.screenUsage[sessionID] = usage
+	if transcript != nil {
+		merged := mergeTranscriptUsage(transcript, usage)
+		if usage.CostCents != nil {
+			merged.CostCents = usage.CostCents
+		}
+		usage = merged
+	}
+	return t.changedLocked(sessionID, usage)
+}
+
+// RecordTranscriptUsage records usage read from a session's transcript,
+// pricing its tokens, and returns it merged with the session's screen
+// usage if that changed what was last reported. backlog marks usage that
+// was already in the transcript when agentd first read it: it counts
+// toward the session's cost but not the group and weekly totals, which an
+// earlier agentd run will have counted.
+func (t *UsageTracker) RecordTranscriptUsage(sessionID, provider string, transcript *SessionUsage, tokens Tokens, backlog bool) *SessionUsage {
+	if cost := t.recordTokens(sessionID, provider, tokens, backlog, false); cost != nil {
+		transcript.CostCents = cost
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.transcriptUsage[sessionID] = transcript
+	return t.changedLocked(sessionID, mergeTranscriptUsage(transcript, t.screenUsage[sessionID]))
+}
+
+func (t *UsageTracker) changedLocked(sessionID string, usage *SessionUsage) *SessionUsage {
 	// Check if this is different from last known usage
 	last := t.lastUsage[sessionID]
 	if last != nil && usageEqual(last, usage) {
@@ -72,9 +173,29 @@ func (t *UsageTracker) ParseAndCheckChanged(sessionID, provider, snapshotText st
 	return usage
 }
 
+// mergeTranscriptUsage fills the gaps in transcript usage from the screen.
+// The screen's token counts are dropped: they are rounded or partial where
+// the transcript's are exact.
+func mergeTranscriptUsage(transcript, screen *SessionUsage) *SessionUsage {
+	merged := *transcript
+	if screen != nil {
+		rest := *screen
+		rest.InputTokens, rest.OutputTokens, rest.TotalTokens = nil, nil, nil
+		rest.CacheReadTokens, rest.CacheWriteTokens = nil, nil
+		mergeUsage(&merged, &rest)
+		merged.RawLine = screen.RawLine
+	}
+	return &merged
+}
+
 // RemoveSession cleans up tracking for a removed session
 func (t *UsageTracker) RemoveSession(sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	delete(t.lastUsage, sessionID)
+	delete(t.screenUsage, sessionID)
+	delete(t.transcriptUsage, sessionID)
+	delete(t.costs, sessionID)
 }
 
 // ParseUsageFromText extracts token usage from console output text
@@ -0,0 +1,102 @@
+package usage
+
+import (
+	"sort"
+	"strings"
+)
+
+// Price is a model's price in USD per million tokens.
+type Price struct {
+	Input      float64
+	Output     float64
+	CacheRead  float64
+	CacheWrite float64
+}
+
+// Tokens are token counts by kind. Input excludes cache reads and writes,
+// which are priced separately.
+type Tokens struct {
+	Input      int `json:"input"`
+	Output     int `json:"output"`
+	CacheRead  int `json:"cache_read"`
+	CacheWrite int `json:"cache_write"`
+}
+
+func (t Tokens) total() int {
+	return t.Input + t.Output + t.CacheRead + t.CacheWrite
+}
+
+// CostCents returns what tokens cost at p, in cents.
+func (p Price) CostCents(tokens Tokens) float64 {
+	usd := float64(tokens.Input)*p.Input +
+		float64(tokens.Output)*p.Output +
+		float64(tokens.CacheRead)*p.CacheRead +
+		float64(tokens.CacheWrite)*p.CacheWrite
+	return usd / 1e6 * 100
+}
+
+// PriceTable looks up model prices by ID. Keys ending in "*" match any ID
+// with that prefix; an exact key wins over a prefix, and a longer prefix
+// over a shorter one. Matching ignores case.
+type PriceTable struct {
+	exact         map[string]Price
+	prefixes      []pricePrefix
+	defaultModels map[string]string
+}
+
+type pricePrefix struct {
+	prefix string
+	price  Price
+}
+
+// NewPriceTable builds a table from model prices and the model assumed for
+// each provider's sessions until one reports its own.
+func NewPriceTable(models map[string]Price, defaultModels map[string]string) *PriceTable {
+	table := &PriceTable{
+		exact:         make(map[string]Price),
+		defaultModels: make(map[string]string, len(defaultModels)),
+	}
+	for key, price := range models {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if prefix, ok := strings.CutSuffix(key, "*"); ok {
+			table.prefixes = append(table.prefixes, pricePrefix{prefix: prefix, price: price})
+		} else {
+			table.exact[key] = price
+		}
+	}
+	sort.Slice(table.prefixes, func(i, j int) bool {
+		return len(table.prefixes[i].prefix) > len(table.prefixes[j].prefix)
+	})
+	for provider, model := range defaultModels {
+		table.defaultModels[provider] = strings.TrimSpace(model)
+	}
+	return table
+}
+
+// Lookup returns the price for model.
+func (t *PriceTable) Lookup(model string) (Price, bool) {
+	if t == nil {
+		return Price{}, false
+	}
+	model = strings.ToLower(strings.TrimSpace(model))
+	if model == "" {
+		return Price{}, false
+	}
+	if price, ok := t.exact[model]; ok {
+		return price, true
+	}
+	for _, entry := range t.prefixes {
+		if strings.HasPrefix(model, entry.prefix) {
+			return entry.price, true
+		}
+	}
+	return Price{}, false
+}
+
+// DefaultModel returns the model assumed for provider's sessions.
+func (t *PriceTable) DefaultModel(provider string) string {
+	if t == nil {
+		return ""
+	}
+	return t.defaultModels[provider]
+}
@@ -0,0 +1,240 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// TranscriptUsage accumulates a session's usage from its transcript, fed
+// one JSONL record at a time: a Claude Code project transcript, whose
+// assistant messages carry message.usage, or a Codex rollout, whose
+// token_count events carry running totals. Unlike the screen, these give
+// exact counts that survive CLI redesigns.
+type TranscriptUsage struct {
+	provider string
+
+	// Claude repeats a message's usage on each of its content block
+	// records, so usage is kept per message and summed.
+	messages map[string]Tokens
+	claude   Tokens
+
+	// Codex reports running totals, with cached input inside input.
+	codexInput  int
+	codexCached int
+	codexOutput int
+	codexTotal  int
+
+	contextUsed   int
+	contextWindow int
+	fiveHourUsed  *float64
+	weeklyUsed    *float64
+	model         string
+	seen          bool
+}
+
+// NewTranscriptUsage returns an empty accumulator for provider's transcript
+// format; "codex" reads rollouts and anything else Claude transcripts.
+func NewTranscriptUsage(provider string) *TranscriptUsage {
+	return &TranscriptUsage{provider: provider, messages: make(map[string]Tokens)}
+}
+
+// Add reads one transcript record and reports whether it changed usage or
+// the model.
+func (u *TranscriptUsage) Add(line []byte) bool {
+	// Most records are conversation content; skip them unparsed.
+	if u.provider == "codex" {
+		if !bytes.Contains(line, []byte(`"token_count"`)) && !bytes.Contains(line, []byte(`"turn_context"`)) {
+			return false
+		}
+	} else if !bytes.Contains(line, []byte(`"usage"`)) {
+		return false
+	}
+	var record map[string]any
+	if err := json.Unmarshal(line, &record); err != nil {
+		return false
+	}
+	if u.provider == "codex" {
+		return u.addCodex(record)
+	}
+	return u.addClaude(record)
+}
+
+func (u *TranscriptUsage) addClaude(record map[string]any) bool {
+	if recordString(record, "type") != "assistant" {
+		return false
+	}
+	message, _ := record["message"].(map[string]any)
+	usage, ok := message["usage"].(map[string]any)
+	if !ok {
+		return false
+	}
+	tokens := Tokens{
+		Input:      recordInt(usage, "input_tokens"),
+		Output:     recordInt(usage, "output_tokens"),
+		CacheRead:  recordInt(usage, "cache_read_input_tokens"),
+		CacheWrite: recordInt(usage, "cache_creation_input_tokens"),
+	}
+	key := recordString(message, "id")
+	if key == "" {
+		key = recordString(record, "uuid")
+	}
+	previous := u.messages[key]
+	u.messages[key] = tokens
+	u.claude.Input += tokens.Input - previous.Input
+	u.claude.Output += tokens.Output - previous.Output
+	u.claude.CacheRead += tokens.CacheRead - previous.CacheRead
+	u.claude.CacheWrite += tokens.CacheWrite - previous.CacheWrite
+
+	// Subagent turns cost tokens but do not fill the main context.
+	if sidechain, _ := record["isSidechain"].(bool); !sidechain {
+		u.contextUsed = tokens.Input + tokens.CacheRead + tokens.CacheWrite + tokens.Output
+		// Claude writes "<synthetic>" for messages it made up itself.
+		if model := recordString(message, "model"); model != "" && model != "<synthetic>" {
+			u.model = model
+		}
+	}
+	u.seen = true
+	return true
+}
+
+func (u *TranscriptUsage) addCodex(record map[string]any) bool {
+	payload, _ := record["payload"].(map[string]any)
+	switch recordString(record, "type") {
+	case "turn_context":
+		model := recordString(payload, "model")
+		if model == "" || model == u.model {
+			return false
+		}
+		u.model = model
+		return true
+	case "event_msg":
+		if recordString(payload, "type") != "token_count" {
+			return false
+		}
+	default:
+		return false
+	}
+
+	changed := false
+	if info, ok := payload["info"].(map[string]any); ok {
+		if total, ok := info["total_token_usage"].(map[string]any); ok {
+			u.codexInput = recordInt(total, "input_tokens")
+			u.codexCached = recordInt(total, "cached_input_tokens")
+			u.codexOutput = recordInt(total, "output_tokens")
+			u.codexTotal = recordInt(total, "total_tokens")
+			u.seen = true
+			changed = true
+		}
+		if last, ok := info["last_token_usage"].(map[string]any); ok {
+			u.contextUsed = recordInt(last, "total_tokens")
+			if u.contextUsed == 0 {
+				u.contextUsed = recordInt(last, "input_tokens") + recordInt(last, "output_tokens")
+			}
+		}
+		if window := recordInt(info, "model_context_window"); window > 0 {
+			u.contextWindow = window
+		}
+	}
+	if limits, ok := payload["rate_limits"].(map[string]any); ok {
+		for _, key := range []string{"primary", "secondary"} {
+			limit, ok := limits[key].(map[string]any)
+			if !ok {
+				continue
+			}
+			used, ok := limit["used_percent"].(float64)
+			if !ok {
+				continue
+			}
+			switch recordInt(limit, "window_minutes") {
+			case 5 * 60:
+				u.fiveHourUsed = &used
+			case 7 * 24 * 60:
+				u.weeklyUsed = &used
+			}
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Model returns the model the transcript last named, if any.
+func (u *TranscriptUsage) Model() string {
+	return u.model
+}
+
+// Tokens returns the cumulative counts to price, with cache reads apart
+// from input.
+func (u *TranscriptUsage) Tokens() Tokens {
+	if u.provider == "codex" {
+		return Tokens{Input: u.codexInput - u.codexCached, Output: u.codexOutput, CacheRead: u.codexCached}
+	}
+	return u.claude
+}
+
+// Usage returns the accumulated usage, or nil before any usage record.
+// Token fields follow the provider's own convention, as job streams
+// report them: Codex input includes cached input and Claude's does not.
+func (u *TranscriptUsage) Usage() *SessionUsage {
+	if !u.seen {
+		return nil
+	}
+	usage := &SessionUsage{Provider: u.provider, Source: SourceTranscript, ReportedAt: time.Now().UTC()}
+	if u.provider == "codex" {
+		total := u.codexTotal
+		if total == 0 {
+			total = u.codexInput + u.codexOutput
+		}
+		usage.InputTokens = intPtr(u.codexInput)
+		usage.OutputTokens = intPtr(u.codexOutput)
+		usage.TotalTokens = intPtr(total)
+		if u.codexCached > 0 {
+			usage.CacheReadTokens = intPtr(u.codexCached)
+		}
+	} else {
+		usage.InputTokens = intPtr(u.claude.Input)
+		usage.OutputTokens = intPtr(u.claude.Output)
+		usage.TotalTokens = intPtr(u.claude.Input + u.claude.Output + u.claude.CacheRead + u.claude.CacheWrite)
+		if u.claude.CacheRead > 0 {
+			usage.CacheReadTokens = intPtr(u.claude.CacheRead)
+		}
+		if u.claude.CacheWrite > 0 {
+			usage.CacheWriteTokens = intPtr(u.claude.CacheWrite)
+		}
+	}
+	if u.contextUsed > 0 {
+		usage.ContextUsedTokens = intPtr(u.contextUsed)
+	}
+	if u.contextWindow > 0 {
+		usage.ContextTotalTokens = intPtr(u.contextWindow)
+		left := math.Max(0, 100*(1-float64(u.contextUsed)/float64(u.contextWindow)))
+		left = math.Round(left*10) / 10
+		usage.ContextLeftPercent = &left
+	}
+	if u.fiveHourUsed != nil {
+		left := 100 - *u.fiveHourUsed
+		usage.FiveHourLeftPercent = &left
+	}
+	if u.weeklyUsed != nil {
+		used := *u.weeklyUsed
+		left := 100 - used
+		usage.WeeklyUtilizationPercent = &used
+		usage.WeeklyLeftPercent = &left
+	}
+	return usage
+}
+
+func recordString(value map[string]any, key string) string {
+	text, _ := value[key].(string)
+	return text
+}
+
+func recordInt(value map[string]any, key string) int {
+	number, _ := value[key].(float64)
+	return int(number)
+}
+
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,111 @@
+package usage
+
+import "testing"
+
+func TestTranscriptUsageClaude(t *testing.T) {
+	transcript := NewTranscriptUsage("claude_code")
+	for _, line := range []string{
+		`{"type":"user","message":{"role":"user","content":"hi"}}`,
+		`{"type":"assistant","message":{"id":"msg_1","model":"claude-sonnet-4-5-20250929","content":[{"type":"text","text":"a"}],"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":100,"cache_creation_input_tokens":20}}}`,
+		// The same message again for its next content block.
+		`{"type":"assistant","message":{"id":"msg_1","model":"claude-sonnet-4-5-20250929","content":[{"type":"tool_use"}],"usage":{"input_tokens":10,"output_tokens":7,"cache_read_input_tokens":100,"cache_creation_input_tokens":20}}}`,
+		`{"type":"assistant","isSidechain":true,"message":{"id":"msg_2","model":"claude-haiku-4-5","usage":{"input_tokens":1000,"output_tokens":50}}}`,
+		`{"type":"assistant","message":{"id":"msg_3","model":"<synthetic>","usage":{"input_tokens":3,"output_tokens":1,"cache_read_input_tokens":130}}}`,
+		`not json "usage"`,
+	} {
+		transcript.Add([]byte(line))
+	}
+
+	usage := transcript.Usage()
+	if usage == nil || usage.Source != SourceTranscript {
+		t.Fatalf("usage=%+v", usage)
+	}
+	if deref(t, "input", usage.InputTokens) != 1013 || deref(t, "output", usage.OutputTokens) != 58 ||
+		deref(t, "cache read", usage.CacheReadTokens) != 230 || deref(t, "cache write", usage.CacheWriteTokens) != 20 ||
+		deref(t, "total", usage.TotalTokens) != 1321 {
+		t.Fatalf("usage=%+v", usage)
+	}
+	// Context comes from the last main-thread message.
+	if deref(t, "context", usage.ContextUsedTokens) != 134 || usage.ContextTotalTokens != nil {
+		t.Fatalf("context=%v/%v", usage.ContextUsedTokens, usage.ContextTotalTokens)
+	}
+	if transcript.Model() != "claude-sonnet-4-5-20250929" {
+		t.Fatalf("model=%q", transcript.Model())
+	}
+	if got := transcript.Tokens(); got != (Tokens{Input: 1013, Output: 58, CacheRead: 230, CacheWrite: 20}) {
+		t.Fatalf("tokens=%+v", got)
+	}
+}
+
+func TestTranscriptUsageCodex(t *testing.T) {
+	transcript := NewTranscriptUsage("codex")
+	if transcript.Usage() != nil {
+		t.Fatalf("usage before any record")
+	}
+	for _, line := range []string{
+		`{"type":"session_meta","payload":{"cwd":"/src"}}`,
+		`{"type":"turn_context","payload":{"model":"gpt-5-codex"}}`,
+		`{"type":"event_msg","payload":{"type":"token_count","info":null,"rate_limits":{"primary":{"used_percent":12.5,"window_minutes":300},"secondary":{"used_percent":40,"window_minutes":10080}}}}`,
+		`{"type":"event_msg","payload":{"type":"token_count","info":{"total_token_usage":{"input_tokens":5000,"cached_input_tokens":4000,"output_tokens":300,"reasoning_output_tokens":100,"total_tokens":5300},"last_token_usage":{"input_tokens":3000,"cached_input_tokens":2500,"output_tokens":200,"total_tokens":3200},"model_context_window":272000}}}`,
+	} {
+		transcript.Add([]byte(line))
+	}
+
+	usage := transcript.Usage()
+	if usage == nil {
+		t.Fatal("no usage")
+	}
+	if deref(t, "input", usage.InputTokens) != 5000 || deref(t, "output", usage.OutputTokens) != 300 ||
+		deref(t, "cache read", usage.CacheReadTokens) != 4000 || deref(t, "total", usage.TotalTokens) != 5300 {
+		t.Fatalf("usage=%+v", usage)
+	}
+	if deref(t, "context used", usage.ContextUsedTokens) != 3200 || deref(t, "context total", usage.ContextTotalTokens) != 272000 ||
+		deref(t, "context left", usage.ContextLeftPercent) != 98.8 {
+		t.Fatalf("context=%+v", usage)
+	}
+	if deref(t, "five hour", usage.FiveHourLeftPercent) != 87.5 || deref(t, "weekly", usage.WeeklyLeftPercent) != 60 {
+		t.Fatalf("limits=%+v", usage)
+	}
+	if transcript.Model() != "gpt-5-codex" || transcript.Tokens() != (Tokens{Input: 1000, Output: 300, CacheRead: 4000}) {
+		t.Fatalf("model=%q tokens=%+v", transcript.Model(), transcript.Tokens())
+	}
+}
+
+func TestTranscriptUsageTakesPrecedenceOverScreen(t *testing.T) {
+	tracker := NewUsageTracker()
+	tracker.SetPricing(NewPriceTable(map[string]Price{"gpt-5*": {Input: 1, Output: 10}}, map[string]string{"codex": "gpt-5"}))
+	screenInput := 999
+	weekly := 35.0
+	tracker.SetParser(func(provider, text string) *SessionUsage {
+		return &SessionUsage{Provider: provider, InputTokens: &screenInput, WeeklyUtilizationPercent: &weekly, RawLine: text}
+	})
+
+	transcript := NewTranscriptUsage("codex")
+	transcript.Add([]byte(`{"type":"event_msg","payload":{"type":"token_count","info":{"total_token_usage":{"input_tokens":1000000,"output_tokens":100000}}}}`))
+	reported := tracker.RecordTranscriptUsage("s", "codex", transcript.Usage(), transcript.Tokens(), false)
+	if reported == nil || deref(t, "cost", reported.CostCents) != 200 {
+		t.Fatalf("transcript usage=%+v", reported)
+	}
+
+	// The screen adds what the transcript lacks and is not priced.
+	merged := tracker.ParseAndCheckChanged("s", "codex", "weekly 35% used")
+	if merged == nil || merged.Source != SourceTranscript || deref(t, "input", merged.InputTokens) != 1000000 ||
+		deref(t, "weekly", merged.WeeklyUtilizationPercent) != 35 || deref(t, "cost", merged.CostCents) != 200 {
+		t.Fatalf("merged=%+v", merged)
+	}
+	if tracker.ParseAndCheckChanged("s", "codex", "weekly 35% used") != nil {
+		t.Fatal("unchanged screen reported again")
+	}
+	if again := tracker.RecordTranscriptUsage("s", "codex", transcript.Usage(), transcript.Tokens(), false); again != nil {
+		t.Fatalf("unchanged transcript reported again: %+v", again)
+	}
+
+	// A backlog is priced for the session only.
+	backlog := tracker.RecordTranscriptUsage("old", "codex", transcript.Usage(), transcript.Tokens(), true)
+	if backlog == nil || deref(t, "backlog cost", backlog.CostCents) != 200 {
+		t.Fatalf("backlog usage=%+v", backlog)
+	}
+	if groups := tracker.Costs().Groups; len(groups) != 1 || groups[0].CostCents != 200 {
+		t.Fatalf("backlog counted toward group totals: %+v", groups)
+	}
+}
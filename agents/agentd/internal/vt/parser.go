@@ -0,0 +1,513 @@
+package vt
+
+import (
+	"strconv"
+	"strings"
+)
+
+type parserState uint8
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateEscapeIntermediate
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	stateString
+	stateStringEscape
+)
+
+// maxOSCBytes bounds a single OSC payload so a missing terminator cannot grow
+// memory without limit.
+const maxOSCBytes = 4096
+
+type parser struct {
+	state         parserState
+	private       byte
+	params        []int
+	param         int
+	hasParam      bool
+	intermediates []byte
+	osc           []byte
+}
+
+func (p *parser) clear() {
+	p.private = 0
+	p.params = p.params[:0]
+	p.param = 0
+	p.hasParam = false
+	p.intermediates = p.intermediates[:0]
+}
+
+// param returns parameter i, or def when it is missing or zero.
+func (p *parser) get(i, def int) int {
+	if i < len(p.params) && p.params[i] != 0 {
+		return p.params[i]
+	}
+	return def
+}
+
+func (s *Screen) feed(b byte) {
+	p := &s.parser
+	switch p.state {
+	case stateGround:
+		s.ground(b)
+	case stateEscape:
+		s.escape(b)
+	case stateEscapeIntermediate:
+		if b >= 0x20 && b <= 0x2f {
+			p.intermediates = append(p.intermediates, b)
+			return
+		}
+		s.escapeDispatch(b)
+		p.state = stateGround
+	case stateCSI:
+		s.csi(b)
+	case stateOSC:
+		switch {
+		case b == 0x07:
+			s.oscDispatch()
+			p.state = stateGround
+		case b == 0x1b:
+			p.state = stateOSCEscape
+		case len(p.osc) < maxOSCBytes:
+			p.osc = append(p.osc, b)
+		}
+	case stateOSCEscape:
+		if b == '\\' {
+			s.oscDispatch()
+			p.state = stateGround
+			return
+		}
+		p.state = stateEscape
+		p.clear()
+		s.escape(b)
+	case stateString:
+		if b == 0x1b {
+			p.state = stateStringEscape
+		} else if b == 0x07 {
+			p.state = stateGround
+		}
+	case stateStringEscape:
+		if b == '\\' {
+			p.state = stateGround
+		} else {
+			p.state = stateString
+		}
+	}
+}
+
+func (s *Screen) ground(b byte) {
+	if b >= 0x80 || len(s.utf8Buffer) > 0 {
+		if b < 0x80 {
+			// An ASCII byte in the middle of a sequence ends it.
+			s.utf8Buffer = s.utf8Buffer[:0]
+			s.print(0xfffd)
+		} else {
+			if r, ok := s.decodeUTF8(b); ok {
+				s.print(r)
+			}
+			return
+		}
+	}
+	if b >= 0x20 && b != 0x7f {
+		s.print(rune(b))
+		return
+	}
+	s.control(b)
+}
+
+func (s *Screen) control(b byte) {
+	switch b {
+	case 0x08: // BS
+		if s.cur.col > 0 {
+			s.cur.col--
+		}
+		s.cur.wrapPending = false
+	case 0x09: // HT
+		col := s.cur.col + 1
+		for col < s.cols-1 && !s.tabs[col] {
+			col++
+		}
+		s.cur.col = min(col, s.cols-1)
+		s.cur.wrapPending = false
+	case 0x0a, 0x0b, 0x0c: // LF, VT, FF
+		s.lineFeed()
+		s.cur.wrapPending = false
+	case 0x0d: // CR
+		s.cur.col = 0
+		s.cur.wrapPending = false
+	case 0x0e, 0x0f: // SO, SI: G1 is never designated here
+	case 0x1b:
+		s.parser.state = stateEscape
+		s.parser.clear()
+	}
+}
+
+func (s *Screen) escape(b byte) {
+	p := &s.parser
+	switch {
+	case b == '[':
+		p.state = stateCSI
+		p.clear()
+	case b == ']':
+		p.state = stateOSC
+		p.osc = p.osc[:0]
+	case b == 'P' || b == 'X' || b == '^' || b == '_':
+		p.state = stateString
+	case b >= 0x20 && b <= 0x2f:
+		p.intermediates = append(p.intermediates, b)
+		p.state = stateEscapeIntermediate
+	case b < 0x20:
+		s.control(b)
+	default:
+		s.escapeDispatch(b)
+		if p.state == stateEscape {
+			p.state = stateGround
+		}
+	}
+}
+
+func (s *Screen) escapeDispatch(b byte) {
+	p := &s.parser
+	if len(p.intermediates) > 0 {
+		switch p.intermediates[0] {
+		case '(':
+			s.cur.lineDrawing = b == '0'
+		case '#':
+			if b == '8' { // DECALN
+				for row := range s.buf().lines {
+					for col := range s.buf().lines[row] {
+						s.buf().lines[row][col] = Cell{Ch: 'E'}
+					}
+				}
+			}
+		}
+		return
+	}
+	switch b {
+	case '7':
+		s.saved = s.cur
+	case '8':
+		s.cur = s.saved
+		s.cur.row = min(s.cur.row, s.rows-1)
+		s.cur.col = min(s.cur.col, s.cols-1)
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.cur.col = 0
+		s.lineFeed()
+	case 'M':
+		s.reverseIndex()
+	case 'H':
+		if s.cur.col < s.cols {
+			s.tabs[s.cur.col] = true
+		}
+	case 'c':
+		s.reset(s.cols, s.rows)
+	}
+	s.cur.wrapPending = false
+}
+
+func (s *Screen) csi(b byte) {
+	p := &s.parser
+	switch {
+	case b >= '0' && b <= '9':
+		p.param = min(p.param*10+int(b-'0'), 65535)
+		p.hasParam = true
+	case b == ';' || b == ':':
+		p.params = append(p.params, p.param)
+		p.param = 0
+		p.hasParam = false
+	case b >= '<' && b <= '?' && len(p.params) == 0 && !p.hasParam:
+		p.private = b
+	case b >= 0x20 && b <= 0x2f:
+		p.intermediates = append(p.intermediates, b)
+	case b >= 0x40 && b <= 0x7e:
+		if p.hasParam || len(p.params) > 0 {
+			p.params = append(p.params, p.param)
+		}
+		s.csiDispatch(b)
+		p.state = stateGround
+	case b == 0x1b:
+		p.state = stateEscape
+		p.clear()
+	case b < 0x20:
+		s.control(b)
+	}
+}
+
+func (s *Screen) csiDispatch(final byte) {
+	p := &s.parser
+	if p.private == '?' {
+		if final == 'h' || final == 'l' {
+			for _, mode := range p.params {
+				s.setPrivateMode(mode, final == 'h')
+			}
+		}
+		return
+	}
+	if p.private != 0 || len(p.intermediates) > 0 {
+		return
+	}
+	n := p.get(0, 1)
+	switch final {
+	case '@': // ICH
+		line := s.buf().lines[s.cur.row]
+		n = min(n, s.cols-s.cur.col)
+		copy(line[s.cur.col+n:], line[s.cur.col:])
+		s.eraseCells(s.cur.row, s.cur.col, s.cur.col+n)
+	case 'A':
+		s.cur.row = max(s.cur.row-n, s.regionTopFor(s.cur.row))
+	case 'B', 'e':
+		s.cur.row = min(s.cur.row+n, s.regionBottomFor(s.cur.row))
+	case 'C', 'a':
+		s.cur.col = min(s.cur.col+n, s.cols-1)
+	case 'D':
+		s.cur.col = max(s.cur.col-n, 0)
+	case 'E':
+		s.cur.row = min(s.cur.row+n, s.regionBottomFor(s.cur.row))
+		s.cur.col = 0
+	case 'F':
+		s.cur.row = max(s.cur.row-n, s.regionTopFor(s.cur.row))
+		s.cur.col = 0
+	case 'G', '`':
+		s.cur.col = clamp(n-1, 0, s.cols-1)
+	case 'H', 'f':
+		s.moveTo(p.get(0, 1)-1, p.get(1, 1)-1)
+	case 'd':
+		s.moveTo(n-1, s.cur.col)
+	case 'I':
+		for i := 0; i < n; i++ {
+			s.control(0x09)
+		}
+	case 'J':
+		s.eraseDisplay(p.get(0, 0))
+	case 'K':
+		switch p.get(0, 0) {
+		case 0:
+			s.eraseCells(s.cur.row, s.cur.col, s.cols)
+		case 1:
+			s.eraseCells(s.cur.row, 0, s.cur.col+1)
+		case 2:
+			s.eraseCells(s.cur.row, 0, s.cols)
+		}
+	case 'L', 'M':
+		if s.cur.row < s.top || s.cur.row > s.bottom {
+			break
+		}
+		top := s.top
+		s.top = s.cur.row
+		if final == 'L' {
+			s.scrollDown(n)
+		} else {
+			s.scrollUp(n)
+		}
+		s.top = top
+		s.cur.col = 0
+	case 'P': // DCH
+		line := s.buf().lines[s.cur.row]
+		n = min(n, s.cols-s.cur.col)
+		copy(line[s.cur.col:], line[s.cur.col+n:])
+		s.eraseCells(s.cur.row, s.cols-n, s.cols)
+	case 'S':
+		s.scrollUp(n)
+	case 'T':
+		s.scrollDown(n)
+	case 'X': // ECH
+		s.eraseCells(s.cur.row, s.cur.col, s.cur.col+n)
+	case 'b': // REP
+		if s.lastPrint != 0 {
+			for i := 0; i < min(n, s.cols*s.rows); i++ {
+				s.print(s.lastPrint)
+			}
+		}
+		return
+	case 'g':
+		switch p.get(0, 0) {
+		case 0:
+			s.tabs[s.cur.col] = false
+		case 3:
+			s.tabs = make([]bool, s.cols)
+		}
+	case 'm':
+		s.sgr()
+		return
+	case 'r':
+		top, bottom := p.get(0, 1)-1, p.get(1, s.rows)-1
+		if top < bottom && bottom < s.rows {
+			s.top, s.bottom = top, bottom
+			s.moveTo(0, 0)
+		}
+	case 's':
+		s.saved = s.cur
+	case 'u':
+		s.cur = s.saved
+		s.cur.row = min(s.cur.row, s.rows-1)
+		s.cur.col = min(s.cur.col, s.cols-1)
+	}
+	s.cur.wrapPending = false
+}
+
+// regionTopFor and regionBottomFor bound vertical cursor motion: inside the
+// scroll region the cursor stops at its margins, outside at the screen edge.
+func (s *Screen) regionTopFor(row int) int {
+	if row >= s.top {
+		return s.top
+	}
+	return 0
+}
+
+func (s *Screen) regionBottomFor(row int) int {
+	if row <= s.bottom {
+		return s.bottom
+	}
+	return s.rows - 1
+}
+
+func (s *Screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseCells(s.cur.row, s.cur.col, s.cols)
+		for row := s.cur.row + 1; row < s.rows; row++ {
+			s.eraseCells(row, 0, s.cols)
+		}
+	case 1:
+		for row := 0; row < s.cur.row; row++ {
+			s.eraseCells(row, 0, s.cols)
+		}
+		s.eraseCells(s.cur.row, 0, s.cur.col+1)
+	case 2, 3:
+		for row := 0; row < s.rows; row++ {
+			s.eraseCells(row, 0, s.cols)
+		}
+	}
+}
+
+func (s *Screen) setPrivateMode(mode int, on bool) {
+	switch mode {
+	case 1:
+		s.modes.AppCursorKeys = on
+	case 6:
+		s.cur.originMode = on
+		s.moveTo(0, 0)
+	case 7:
+		s.autowrap = on
+	case 25:
+		s.modes.CursorHidden = !on
+	case 47:
+		s.setAltScreen(on, false, false)
+	case 1047:
+		s.setAltScreen(on, false, on)
+	case 1049:
+		s.setAltScreen(on, true, on)
+	case 1000, 1002, 1003:
+		if on {
+			s.modes.MouseTracking = mode
+		} else if s.modes.MouseTracking == mode {
+			s.modes.MouseTracking = 0
+		}
+	case 1004:
+		s.modes.FocusEvents = on
+	case 1006:
+		s.modes.MouseSGR = on
+	case 2004:
+		s.modes.BracketedPaste = on
+	}
+}
+
+func (s *Screen) sgr() {
+	params := s.parser.params
+	if len(params) == 0 {
+		params = []int{0}
+	}
+	attr := &s.cur.attr
+	for i := 0; i < len(params); i++ {
+		switch code := params[i]; {
+		case code == 0:
+			*attr = Attr{}
+		case code == 1:
+			attr.Flags |= Bold
+		case code == 2:
+			attr.Flags |= Dim
+		case code == 3:
+			attr.Flags |= Italic
+		case code == 4:
+			attr.Flags |= Underline
+		case code == 5 || code == 6:
+			attr.Flags |= Blink
+		case code == 7:
+			attr.Flags |= Reverse
+		case code == 8:
+			attr.Flags |= Hidden
+		case code == 9:
+			attr.Flags |= Strike
+		case code == 21 || code == 22:
+			attr.Flags &^= Bold | Dim
+		case code == 23:
+			attr.Flags &^= Italic
+		case code == 24:
+			attr.Flags &^= Underline
+		case code == 25:
+			attr.Flags &^= Blink
+		case code == 27:
+			attr.Flags &^= Reverse
+		case code == 28:
+			attr.Flags &^= Hidden
+		case code == 29:
+			attr.Flags &^= Strike
+		case code >= 30 && code <= 37:
+			attr.FG = IndexedColor(uint8(code - 30))
+		case code == 39:
+			attr.FG = 0
+		case code >= 40 && code <= 47:
+			attr.BG = IndexedColor(uint8(code - 40))
+		case code == 49:
+			attr.BG = 0
+		case code >= 90 && code <= 97:
+			attr.FG = IndexedColor(uint8(code - 90 + 8))
+		case code >= 100 && code <= 107:
+			attr.BG = IndexedColor(uint8(code - 100 + 8))
+		case code == 38 || code == 48:
+			color, used := extendedColor(params[i+1:])
+			i += used
+			if code == 38 {
+				attr.FG = color
+			} else {
+				attr.BG = color
+			}
+		}
+	}
+}
+
+// extendedColor parses the arguments after 38 or 48 and returns how many it
+// consumed.
+func extendedColor(args []int) (Color, int) {
+	if len(args) == 0 {
+		return 0, 0
+	}
+	switch args[0] {
+	case 5:
+		if len(args) >= 2 {
+			return IndexedColor(uint8(args[1])), 2
+		}
+	case 2:
+		if len(args) >= 4 {
+			return RGBColor(uint8(args[1]), uint8(args[2]), uint8(args[3])), 4
+		}
+	}
+	return 0, len(args)
+}
+
+func (s *Screen) oscDispatch() {
+	payload := string(s.parser.osc)
+	s.parser.osc = s.parser.osc[:0]
+	code, text, ok := strings.Cut(payload, ";")
+	if !ok {
+		return
+	}
+	if number, err := strconv.Atoi(code); err == nil && (number == 0 || number == 2) {
+		s.title = text
+	}
+}
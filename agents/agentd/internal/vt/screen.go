@@ -0,0 +1,559 @@
+// Package vt is a small VT100/xterm screen model. It interprets the output of
+// a pane well enough to answer "what does the pane show right now" without
+// asking tmux: printable text with SGR attributes, cursor movement, erase and
+// insert/delete operations, scroll regions, the alternate screen, and the
+// modes a fresh terminal needs to behave like the one being mirrored.
+//
+// Sequences it does not understand are consumed and ignored, so unknown input
+// can degrade fidelity but never corrupts the parser state.
+package vt
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Color is a cell color. The zero value is the terminal default.
+type Color uint32
+
+const (
+	colorIndexed Color = 1 << 24
+	colorRGB     Color = 2 << 24
+	colorKind    Color = 0xff << 24
+)
+
+func IndexedColor(index uint8) Color { return colorIndexed | Color(index) }
+func RGBColor(r, g, b uint8) Color {
+	return colorRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+type Flags uint8
+
+const (
+	Bold Flags = 1 << iota
+	Dim
+	Italic
+	Underline
+	Blink
+	Reverse
+	Hidden
+	Strike
+)
+
+// Attr is the rendition a cell was written with.
+type Attr struct {
+	FG    Color
+	BG    Color
+	Flags Flags
+}
+
+// Cell holds one column. A zero Ch marks the second column of a wide rune.
+type Cell struct {
+	Ch   rune
+	Attr Attr
+}
+
+type cursor struct {
+	row, col    int
+	attr        Attr
+	wrapPending bool
+	originMode  bool
+	lineDrawing bool
+}
+
+type buffer struct {
+	lines [][]Cell
+}
+
+// Modes are the DEC private modes replayed by a snapshot.
+type Modes struct {
+	CursorHidden   bool
+	AppCursorKeys  bool
+	BracketedPaste bool
+	MouseTracking  int // 0, 1000, 1002, or 1003
+	MouseSGR       bool
+	FocusEvents    bool
+}
+
+// Screen is safe for concurrent use.
+type Screen struct {
+	mu         sync.Mutex
+	cols, rows int
+	primary    buffer
+	alternate  buffer
+	altActive  bool
+	cur        cursor
+	saved      cursor
+	altSaved   cursor
+	top        int
+	bottom     int
+	autowrap   bool
+	modes      Modes
+	tabs       []bool
+	title      string
+	parser     parser
+	lastPrint  rune
+	utf8Buffer []byte
+}
+
+// New returns a blank screen. Sizes below 1x1 are clamped.
+func New(cols, rows int) *Screen {
+	s := &Screen{}
+	s.reset(max(cols, 1), max(rows, 1))
+	return s
+}
+
+func (s *Screen) reset(cols, rows int) {
+	s.cols, s.rows = cols, rows
+	s.primary = newBuffer(cols, rows)
+	s.alternate = newBuffer(cols, rows)
+	s.altActive = false
+	s.cur = cursor{}
+	s.saved = cursor{}
+	s.altSaved = cursor{}
+	s.top, s.bottom = 0, rows-1
+	s.autowrap = true
+	s.modes = Modes{}
+	s.resetTabs()
+	s.parser = parser{}
+	s.utf8Buffer = nil
+}
+
+func newBuffer(cols, rows int) buffer {
+	lines := make([][]Cell, rows)
+	for i := range lines {
+		lines[i] = blankLine(cols, Attr{})
+	}
+	return buffer{lines: lines}
+}
+
+func blankLine(cols int, attr Attr) []Cell {
+	line := make([]Cell, cols)
+	for i := range line {
+		line[i] = Cell{Ch: ' ', Attr: Attr{BG: attr.BG}}
+	}
+	return line
+}
+
+func (s *Screen) resetTabs() {
+	s.tabs = make([]bool, s.cols)
+	for i := 8; i < s.cols; i += 8 {
+		s.tabs[i] = true
+	}
+}
+
+func (s *Screen) buf() *buffer {
+	if s.altActive {
+		return &s.alternate
+	}
+	return &s.primary
+}
+
+// Size returns the screen dimensions.
+func (s *Screen) Size() (cols, rows int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cols, s.rows
+}
+
+// Resize changes the dimensions, keeping the top-left content. When rows
+// shrink below the cursor the primary screen scrolls up so the cursor line
+// stays visible, as xterm does.
+func (s *Screen) Resize(cols, rows int) {
+	cols, rows = max(cols, 1), max(rows, 1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cols == s.cols && rows == s.rows {
+		return
+	}
+	shift := 0
+	if s.cur.row >= rows {
+		shift = s.cur.row - rows + 1
+	}
+	for _, b := range []*buffer{&s.primary, &s.alternate} {
+		lines := b.lines
+		if b == s.buf() && shift > 0 {
+			lines = lines[shift:]
+		}
+		resized := make([][]Cell, rows)
+		for i := range resized {
+			resized[i] = blankLine(cols, Attr{})
+			if i < len(lines) {
+				copy(resized[i], lines[i])
+			}
+		}
+		b.lines = resized
+	}
+	s.cols, s.rows = cols, rows
+	s.cur.row = min(s.cur.row-shift, rows-1)
+	s.cur.col = min(s.cur.col, cols-1)
+	s.cur.wrapPending = false
+	s.top, s.bottom = 0, rows-1
+	s.resetTabs()
+}
+
+// Write feeds terminal output to the model.
+func (s *Screen) Write(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range data {
+		s.feed(b)
+	}
+	return len(data), nil
+}
+
+// Cursor returns the cursor position (0-based) and whether it is visible.
+func (s *Screen) Cursor() (row, col int, visible bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur.row, s.cur.col, !s.modes.CursorHidden
+}
+
+// AltScreen reports whether the alternate screen is active.
+func (s *Screen) AltScreen() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.altActive
+}
+
+// Title returns the last window title set with OSC 0 or 2.
+func (s *Screen) Title() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.title
+}
+
+// Modes returns the tracked DEC private modes.
+func (s *Screen) Modes() Modes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modes
+}
+
+// Cell returns the cell at a position, or a blank for out-of-range positions.
+func (s *Screen) Cell(row, col int) Cell {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if row < 0 || row >= s.rows || col < 0 || col >= s.cols {
+		return Cell{Ch: ' '}
+	}
+	return s.buf().lines[row][col]
+}
+
+// Lines returns the visible text, one string per row with trailing blanks
+// removed.
+func (s *Screen) Lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := make([]string, s.rows)
+	var builder strings.Builder
+	for row, line := range s.buf().lines {
+		builder.Reset()
+		for _, cell := range line {
+			if cell.Ch == 0 {
+				continue
+			}
+			builder.WriteRune(cell.Ch)
+		}
+		lines[row] = strings.TrimRight(builder.String(), " ")
+	}
+	return lines
+}
+
+// Snapshot renders the screen as an escape sequence stream that repaints an
+// empty terminal of at least the same size into the same state: content and
+// attributes, scroll region, cursor, current rendition and input modes.
+func (s *Screen) Snapshot() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out strings.Builder
+	if s.altActive {
+		out.WriteString("\x1b[?1049h")
+	}
+	out.WriteString("\x1b[0m\x1b[r\x1b[H\x1b[2J")
+	current := Attr{}
+	for row, line := range s.buf().lines {
+		end := len(line)
+		for end > 0 && line[end-1].Ch == ' ' && line[end-1].Attr == (Attr{}) {
+			end--
+		}
+		if end == 0 {
+			continue
+		}
+		out.WriteString("\x1b[")
+		out.WriteString(strconv.Itoa(row + 1))
+		out.WriteString("H")
+		for _, cell := range line[:end] {
+			if cell.Ch == 0 {
+				continue
+			}
+			if cell.Attr != current {
+				out.WriteString(sgrFor(cell.Attr))
+				current = cell.Attr
+			}
+			out.WriteRune(cell.Ch)
+		}
+	}
+	if s.top != 0 || s.bottom != s.rows-1 {
+		out.WriteString("\x1b[" + strconv.Itoa(s.top+1) + ";" + strconv.Itoa(s.bottom+1) + "r")
+	}
+	if s.cur.originMode {
+		out.WriteString("\x1b[?6h")
+	}
+	if !s.autowrap {
+		out.WriteString("\x1b[?7l")
+	}
+	row := s.cur.row
+	if s.cur.originMode {
+		row -= s.top
+	}
+	out.WriteString("\x1b[" + strconv.Itoa(row+1) + ";" + strconv.Itoa(s.cur.col+1) + "H")
+	out.WriteString(sgrFor(s.cur.attr))
+	if s.modes.CursorHidden {
+		out.WriteString("\x1b[?25l")
+	} else {
+		out.WriteString("\x1b[?25h")
+	}
+	if s.modes.AppCursorKeys {
+		out.WriteString("\x1b[?1h")
+	}
+	if s.modes.BracketedPaste {
+		out.WriteString("\x1b[?2004h")
+	}
+	if s.modes.MouseTracking != 0 {
+		out.WriteString("\x1b[?" + strconv.Itoa(s.modes.MouseTracking) + "h")
+	}
+	if s.modes.MouseSGR {
+		out.WriteString("\x1b[?1006h")
+	}
+	if s.modes.FocusEvents {
+		out.WriteString("\x1b[?1004h")
+	}
+	return []byte(out.String())
+}
+
+var sgrFlagCodes = []struct {
+	flag Flags
+	code string
+}{
+	{Bold, "1"}, {Dim, "2"}, {Italic, "3"}, {Underline, "4"},
+	{Blink, "5"}, {Reverse, "7"}, {Hidden, "8"}, {Strike, "9"},
+}
+
+func sgrFor(attr Attr) string {
+	params := []string{"0"}
+	for _, entry := range sgrFlagCodes {
+		if attr.Flags&entry.flag != 0 {
+			params = append(params, entry.code)
+		}
+	}
+	params = append(params, colorParams(attr.FG, false)...)
+	params = append(params, colorParams(attr.BG, true)...)
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+func colorParams(color Color, background bool) []string {
+	base := 30
+	if background {
+		base = 40
+	}
+	switch color & colorKind {
+	case colorIndexed:
+		index := int(color & 0xff)
+		switch {
+		case index < 8:
+			return []string{strconv.Itoa(base + index)}
+		case index < 16:
+			return []string{strconv.Itoa(base + 60 + index - 8)}
+		default:
+			return []string{strconv.Itoa(base + 8), "5", strconv.Itoa(index)}
+		}
+	case colorRGB:
+		return []string{
+			strconv.Itoa(base + 8), "2",
+			strconv.Itoa(int(color >> 16 & 0xff)),
+			strconv.Itoa(int(color >> 8 & 0xff)),
+			strconv.Itoa(int(color & 0xff)),
+		}
+	}
+	return nil
+}
+
+// print writes one rune at the cursor, honouring deferred wrap.
+func (s *Screen) print(r rune) {
+	if s.cur.lineDrawing && r < 0x80 {
+		if mapped, ok := decSpecialGraphics[byte(r)]; ok {
+			r = mapped
+		}
+	}
+	width := runeWidth(r)
+	if width == 0 {
+		return
+	}
+	if s.cur.wrapPending || (width == 2 && s.cur.col == s.cols-1) {
+		if s.autowrap {
+			s.cur.col = 0
+			s.lineFeed()
+		} else if width == 2 {
+			return
+		}
+		s.cur.wrapPending = false
+	}
+	line := s.buf().lines[s.cur.row]
+	s.clearWide(line, s.cur.col)
+	line[s.cur.col] = Cell{Ch: r, Attr: s.cur.attr}
+	if width == 2 && s.cur.col+1 < s.cols {
+		s.clearWide(line, s.cur.col+1)
+		line[s.cur.col+1] = Cell{Ch: 0, Attr: s.cur.attr}
+	}
+	s.lastPrint = r
+	if s.cur.col+width >= s.cols {
+		s.cur.col = s.cols - 1
+		s.cur.wrapPending = true
+		return
+	}
+	s.cur.col += width
+}
+
+// clearWide blanks the other half of a wide rune about to be overwritten.
+func (s *Screen) clearWide(line []Cell, col int) {
+	if line[col].Ch == 0 && col > 0 {
+		line[col-1] = Cell{Ch: ' ', Attr: line[col-1].Attr}
+	}
+	if col+1 < len(line) && line[col+1].Ch == 0 && line[col].Ch != 0 && runeWidth(line[col].Ch) == 2 {
+		line[col+1] = Cell{Ch: ' ', Attr: line[col+1].Attr}
+	}
+}
+
+func (s *Screen) lineFeed() {
+	if s.cur.row == s.bottom {
+		s.scrollUp(1)
+	} else if s.cur.row < s.rows-1 {
+		s.cur.row++
+	}
+}
+
+func (s *Screen) reverseIndex() {
+	if s.cur.row == s.top {
+		s.scrollDown(1)
+	} else if s.cur.row > 0 {
+		s.cur.row--
+	}
+}
+
+func (s *Screen) scrollUp(n int) {
+	lines := s.buf().lines
+	n = min(n, s.bottom-s.top+1)
+	copy(lines[s.top:], lines[s.top+n:s.bottom+1])
+	for i := s.bottom - n + 1; i <= s.bottom; i++ {
+		lines[i] = blankLine(s.cols, s.cur.attr)
+	}
+}
+
+func (s *Screen) scrollDown(n int) {
+	lines := s.buf().lines
+	n = min(n, s.bottom-s.top+1)
+	copy(lines[s.top+n:s.bottom+1], lines[s.top:s.bottom+1-n])
+	for i := s.top; i < s.top+n; i++ {
+		lines[i] = blankLine(s.cols, s.cur.attr)
+	}
+}
+
+func (s *Screen) moveTo(row, col int) {
+	top, bottom := 0, s.rows-1
+	if s.cur.originMode {
+		top, bottom = s.top, s.bottom
+		row += s.top
+	}
+	s.cur.row = clamp(row, top, bottom)
+	s.cur.col = clamp(col, 0, s.cols-1)
+	s.cur.wrapPending = false
+}
+
+func (s *Screen) eraseCells(row, from, to int) {
+	line := s.buf().lines[row]
+	from, to = clamp(from, 0, s.cols), clamp(to, 0, s.cols)
+	for i := from; i < to; i++ {
+		line[i] = Cell{Ch: ' ', Attr: Attr{BG: s.cur.attr.BG}}
+	}
+}
+
+func (s *Screen) setAltScreen(on, saveCursor, clear bool) {
+	if on == s.altActive {
+		return
+	}
+	if on {
+		if saveCursor {
+			s.altSaved = s.cur
+		}
+		s.altActive = true
+		if clear {
+			s.alternate = newBuffer(s.cols, s.rows)
+		}
+		return
+	}
+	s.altActive = false
+	if saveCursor {
+		s.cur = s.altSaved
+		s.cur.row = min(s.cur.row, s.rows-1)
+		s.cur.col = min(s.cur.col, s.cols-1)
+	}
+}
+
+func clamp(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
+
+// runeWidth approximates wcwidth: combining marks take no column and East
+// Asian wide and emoji ranges take two.
+func runeWidth(r rune) int {
+	switch {
+	case r == 0:
+		return 0
+	case r >= 0x0300 && r <= 0x036f, r >= 0x200b && r <= 0x200f, r >= 0xfe00 && r <= 0xfe0f:
+		return 0
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r >= 0x20000 && r <= 0x3fffd:
+		return 2
+	}
+	return 1
+}
+
+var decSpecialGraphics = map[byte]rune{
+	'`': '◆', 'a': '▒', 'f': '°', 'g': '±', 'j': '┘', 'k': '┐', 'l': '┌',
+	'm': '└', 'n': '┼', 'o': '⎺', 'p': '⎻', 'q': '─', 'r': '⎼', 's': '⎽',
+	't': '├', 'u': '┤', 'v': '┴', 'w': '┬', 'x': '│', 'y': '≤', 'z': '≥',
+	'{': 'π', '|': '≠', '}': '£', '~': '·',
+}
+
+// decodeUTF8 accumulates bytes of a multi-byte rune. It returns the rune once
+// complete; invalid sequences decode to U+FFFD.
+func (s *Screen) decodeUTF8(b byte) (rune, bool) {
+	s.utf8Buffer = append(s.utf8Buffer, b)
+	if !utf8.FullRune(s.utf8Buffer) {
+		if len(s.utf8Buffer) >= utf8.UTFMax {
+			s.utf8Buffer = s.utf8Buffer[:0]
+			return utf8.RuneError, true
+		}
+		return 0, false
+	}
+	r, _ := utf8.DecodeRune(s.utf8Buffer)
+	s.utf8Buffer = s.utf8Buffer[:0]
+	return r, true
+}
@@ -0,0 +1,135 @@
+package vt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTracksTextCursorAndWrap(t *testing.T) {
+	screen := New(10, 3)
+	screen.Write([]byte("hello\r\nworld wide web"))
+
+	if got := screen.Lines(); strings.Join(got, "|") != "hello|world wide| web" {
+		t.Fatalf("lines=%q", got)
+	}
+	if row, col, visible := screen.Cursor(); row != 2 || col != 4 || !visible {
+		t.Fatalf("cursor=%d,%d visible=%v", row, col, visible)
+	}
+
+	screen.Write([]byte("\r\nscrolled"))
+	if got := screen.Lines(); strings.Join(got, "|") != "world wide| web|scrolled" {
+		t.Fatalf("after scroll=%q", got)
+	}
+}
+
+func TestCursorMovementEraseAndInsert(t *testing.T) {
+	screen := New(12, 4)
+	screen.Write([]byte("abcdefghij\x1b[1;3H\x1b[2P\x1b[1;1H\x1b[2@XY\x1b[2;5Hmid\x1b[2;2H\x1b[1K\x1b[4;1Hlast\x1b[3;1H\x1b[L"))
+
+	// The inserted line pushes "last" off the bottom of the screen.
+	if got := screen.Lines(); strings.Join(got, "|") != "XYabefghij|    mid||" {
+		t.Fatalf("lines=%q", got)
+	}
+}
+
+func TestScrollRegionAndReverseIndex(t *testing.T) {
+	screen := New(6, 5)
+	screen.Write([]byte("top\r\n1\r\n2\r\n3\r\nstatus"))
+	screen.Write([]byte("\x1b[2;4r\x1b[4;1H\r\n4"))
+	if got := screen.Lines(); strings.Join(got, "|") != "top|2|3|4|status" {
+		t.Fatalf("scroll region=%q", got)
+	}
+	screen.Write([]byte("\x1b[2;1H\x1bMnew"))
+	if got := screen.Lines(); strings.Join(got, "|") != "top|new|2|3|status" {
+		t.Fatalf("reverse index=%q", got)
+	}
+}
+
+func TestAlternateScreenRestoresPrimary(t *testing.T) {
+	screen := New(8, 2)
+	screen.Write([]byte("$ vim"))
+	screen.Write([]byte("\x1b[?1049h\x1b[H\x1b[2J~ editor"))
+	if !screen.AltScreen() || screen.Lines()[0] != "~ editor" {
+		t.Fatalf("alt=%v lines=%q", screen.AltScreen(), screen.Lines())
+	}
+	screen.Write([]byte("\x1b[?1049l"))
+	if screen.AltScreen() || screen.Lines()[0] != "$ vim" {
+		t.Fatalf("alt=%v lines=%q", screen.AltScreen(), screen.Lines())
+	}
+	if _, col, _ := screen.Cursor(); col != 5 {
+		t.Fatalf("cursor col=%d", col)
+	}
+}
+
+func TestSGRAndWideRunes(t *testing.T) {
+	screen := New(10, 1)
+	screen.Write([]byte("\x1b[1;31mA\x1b[38;2;1;2;3;48;5;200mB\x1b[0m日本"))
+
+	if cell := screen.Cell(0, 0); cell.Attr.Flags != Bold || cell.Attr.FG != IndexedColor(1) {
+		t.Fatalf("cell A=%+v", cell)
+	}
+	if cell := screen.Cell(0, 1); cell.Attr.FG != RGBColor(1, 2, 3) || cell.Attr.BG != IndexedColor(200) || cell.Attr.Flags != Bold {
+		t.Fatalf("cell B=%+v", cell)
+	}
+	if cell := screen.Cell(0, 3); cell.Ch != 0 {
+		t.Fatalf("wide continuation=%+v", cell)
+	}
+	if got := screen.Lines()[0]; got != "AB日本" {
+		t.Fatalf("line=%q", got)
+	}
+	if _, col, _ := screen.Cursor(); col != 6 {
+		t.Fatalf("cursor col=%d", col)
+	}
+}
+
+func TestSnapshotReplaysIntoEquivalentScreen(t *testing.T) {
+	source := New(20, 5)
+	source.Write([]byte("\x1b[?2004h\x1b[?1002h\x1b[?1006h\x1b]0;build\x07"))
+	source.Write([]byte("\x1b[32mok\x1b[0m plain\r\n\x1b[7mrev\x1b[0m ✓\r\n\x1b[2;4r\x1b[3;6H\x1b[?25l\x1b[4m"))
+
+	replica := New(20, 5)
+	replica.Write(source.Snapshot())
+
+	if strings.Join(replica.Lines(), "|") != strings.Join(source.Lines(), "|") {
+		t.Fatalf("replica=%q source=%q", replica.Lines(), source.Lines())
+	}
+	for row := 0; row < 5; row++ {
+		for col := 0; col < 20; col++ {
+			if replica.Cell(row, col) != source.Cell(row, col) {
+				t.Fatalf("cell %d,%d replica=%+v source=%+v", row, col, replica.Cell(row, col), source.Cell(row, col))
+			}
+		}
+	}
+	sRow, sCol, sVisible := source.Cursor()
+	rRow, rCol, rVisible := replica.Cursor()
+	if sRow != rRow || sCol != rCol || sVisible != rVisible {
+		t.Fatalf("cursor replica=%d,%d,%v source=%d,%d,%v", rRow, rCol, rVisible, sRow, sCol, sVisible)
+	}
+	if replica.Modes() != source.Modes() || source.Title() != "build" {
+		t.Fatalf("modes replica=%+v source=%+v title=%q", replica.Modes(), source.Modes(), source.Title())
+	}
+	replica.Write([]byte("x"))
+	if cell := replica.Cell(2, 5); cell.Attr.Flags != Underline {
+		t.Fatalf("current rendition not restored: %+v", cell)
+	}
+}
+
+func TestResizeKeepsCursorLineVisible(t *testing.T) {
+	screen := New(10, 4)
+	screen.Write([]byte("1\r\n2\r\n3\r\n4"))
+	screen.Resize(5, 2)
+	if got := screen.Lines(); strings.Join(got, "|") != "3|4" {
+		t.Fatalf("lines=%q", got)
+	}
+	if row, col, _ := screen.Cursor(); row != 1 || col != 1 {
+		t.Fatalf("cursor=%d,%d", row, col)
+	}
+}
+
+func TestUnknownSequencesAreConsumed(t *testing.T) {
+	screen := New(10, 1)
+	screen.Write([]byte("a\x1bP1$r0m\x1b\\b\x1b[>4;1mc\x1b]8;;http://x\x1b\\d\x1b[?u"))
+	if got := screen.Lines()[0]; got != "abcd" {
+		t.Fatalf("line=%q", got)
+	}
+}
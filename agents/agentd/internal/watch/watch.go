@@ -0,0 +1,376 @@
+// Package watch evaluates operator-defined output rules against pane output.
+//
+// Rules are plain regular expressions scoped to a set of sessions. The engine
+// remembers the last lines it saw for each session so only output that is new
+// since the previous evaluation can fire a rule; a broken test run that stays
+// on screen matches once, not on every snapshot tick.
+package watch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxContextLines bounds the surrounding lines attached to a match.
+	MaxContextLines = 20
+	// maxMatchesPerEvaluation stops a rule that matches every line of a large
+	// burst from flooding the event stream.
+	maxMatchesPerEvaluation = 20
+	maxPatternBytes         = 4096
+	maxLineBytes            = 4096
+)
+
+var (
+	ErrNotFound    = errors.New("watch not found")
+	ErrConfigWatch = errors.New("watch is defined in config and cannot be removed")
+)
+
+const (
+	SourceConfig  = "config"
+	SourceCommand = "command"
+)
+
+// Actions run when a rule matches, in addition to the watch.match event.
+type Actions struct {
+	SetStatus string   `json:"set_status,omitempty"`
+	Notify    bool     `json:"notify,omitempty"`
+	SendKeys  []string `json:"send_keys,omitempty"`
+}
+
+// Rule selects sessions and the output lines that should raise an event. Empty
+// scope fields match every session.
+type Rule struct {
+	ID           string   `json:"id"`
+	Pattern      string   `json:"pattern"`
+	Literal      bool     `json:"literal,omitempty"`
+	IgnoreCase   bool     `json:"ignore_case,omitempty"`
+	SessionIDs   []string `json:"session_ids,omitempty"`
+	GroupID      string   `json:"group_id,omitempty"`
+	Provider     string   `json:"provider,omitempty"`
+	ContextLines int      `json:"context_lines,omitempty"`
+	CooldownMs   int      `json:"cooldown_ms,omitempty"`
+	Actions      Actions  `json:"actions"`
+	Source       string   `json:"source"`
+}
+
+// Target identifies the session whose output is being evaluated.
+type Target struct {
+	SessionID string
+	PaneID    string
+	GroupID   string
+	Provider  string
+}
+
+type Match struct {
+	Rule      Rule
+	SessionID string
+	PaneID    string
+	Line      string
+	Before    []string
+	After     []string
+	MatchedAt time.Time
+}
+
+type compiledRule struct {
+	rule       Rule
+	matcher    *regexp.Regexp
+	sessionIDs map[string]bool
+}
+
+type Engine struct {
+	mu        sync.Mutex
+	rules     map[string]*compiledRule
+	previous  map[string][]string
+	lastFired map[string]time.Time
+	now       func() time.Time
+}
+
+func NewEngine() *Engine {
+	return &Engine{
+		rules:     make(map[string]*compiledRule),
+		previous:  make(map[string][]string),
+		lastFired: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// Add validates and installs a rule, replacing any rule with the same ID. A
+// missing ID is generated.
+func (e *Engine) Add(rule Rule) (Rule, error) {
+	compiled, err := compileRule(rule)
+	if err != nil {
+		return Rule{}, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if existing := e.rules[compiled.rule.ID]; existing != nil &&
+		existing.rule.Source == SourceConfig &&
+		compiled.rule.Source != SourceConfig {
+		return Rule{}, fmt.Errorf("watch %q is defined in config", compiled.rule.ID)
+	}
+	e.rules[compiled.rule.ID] = compiled
+	return compiled.rule, nil
+}
+
+// Remove deletes a command-registered rule. Config rules live as long as the
+// config file that declares them.
+func (e *Engine) Remove(id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	existing := e.rules[id]
+	if existing == nil {
+		return ErrNotFound
+	}
+	if existing.rule.Source == SourceConfig {
+		return ErrConfigWatch
+	}
+	delete(e.rules, id)
+	for key := range e.lastFired {
+		if strings.HasPrefix(key, id+"\x00") {
+			delete(e.lastFired, key)
+		}
+	}
+	return nil
+}
+
+// Rules returns every installed rule ordered by ID.
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rules := make([]Rule, 0, len(e.rules))
+	for _, compiled := range e.rules {
+		rules = append(rules, compiled.rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// Evaluate compares lines with the previous evaluation for the same session
+// and returns matches among the new lines. The first evaluation of a session
+// only records a baseline so history already on screen never fires.
+func (e *Engine) Evaluate(target Target, lines []string) []Match {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	lines = trimTrailingBlank(lines)
+	previous, seen := e.previous[target.SessionID]
+	e.previous[target.SessionID] = lines
+	if !seen || len(e.rules) == 0 {
+		return nil
+	}
+	fresh := NewLines(previous, lines)
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	now := e.now()
+	ids := make([]string, 0, len(e.rules))
+	for id := range e.rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var matches []Match
+	for _, id := range ids {
+		compiled := e.rules[id]
+		if !compiled.appliesTo(target) {
+			continue
+		}
+		firedKey := id + "\x00" + target.SessionID
+		ruleMatches := 0
+		for _, index := range fresh {
+			if !compiled.matcher.MatchString(lines[index]) {
+				continue
+			}
+			if cooldown := time.Duration(compiled.rule.CooldownMs) * time.Millisecond; cooldown > 0 {
+				if last, ok := e.lastFired[firedKey]; ok && now.Sub(last) < cooldown {
+					break
+				}
+			}
+			e.lastFired[firedKey] = now
+			contextLines := compiled.rule.ContextLines
+			matches = append(matches, Match{
+				Rule:      compiled.rule,
+				SessionID: target.SessionID,
+				PaneID:    target.PaneID,
+				Line:      truncateLine(lines[index]),
+				Before:    truncateLines(lines[max(0, index-contextLines):index]),
+				After:     truncateLines(lines[index+1 : min(len(lines), index+1+contextLines)]),
+				MatchedAt: now,
+			})
+			ruleMatches++
+			if ruleMatches >= maxMatchesPerEvaluation {
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// Forget drops the baseline and cooldown state of a session that has gone away.
+func (e *Engine) Forget(sessionID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.previous, sessionID)
+	for key := range e.lastFired {
+		if strings.HasSuffix(key, "\x00"+sessionID) {
+			delete(e.lastFired, key)
+		}
+	}
+}
+
+// NewLines returns the indices of lines in current that were not present in
+// previous. Appended output is found by aligning the tail of previous with the
+// head of current; the final previous line may still have been growing (a
+// partially printed line), so it only needs to be a prefix. When the captures
+// do not overlap (the screen was cleared or a full-screen program redrew), a
+// line is new only if it was not already on screen.
+func NewLines(previous, current []string) []int {
+	if len(previous) == 0 {
+		return lineRange(0, len(current))
+	}
+	last := len(previous) - 1
+	for drop := 0; drop < len(previous); drop++ {
+		overlap := len(previous) - drop
+		if overlap > len(current) {
+			continue
+		}
+		if !linesEqual(previous[drop:last], current[:overlap-1]) {
+			continue
+		}
+		tail := current[overlap-1]
+		if tail == previous[last] {
+			return lineRange(overlap, len(current))
+		}
+		if previous[last] != "" && strings.HasPrefix(tail, previous[last]) {
+			return lineRange(overlap-1, len(current))
+		}
+	}
+
+	onScreen := make(map[string]int, len(previous))
+	for _, line := range previous {
+		onScreen[line]++
+	}
+	var fresh []int
+	for index, line := range current {
+		if onScreen[line] > 0 {
+			onScreen[line]--
+			continue
+		}
+		fresh = append(fresh, index)
+	}
+	return fresh
+}
+
+func lineRange(start, end int) []int {
+	if start >= end {
+		return nil
+	}
+	indices := make([]int, 0, end-start)
+	for index := start; index < end; index++ {
+		indices = append(indices, index)
+	}
+	return indices
+}
+
+func trimTrailingBlank(lines []string) []string {
+	end := len(lines)
+	for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+		end--
+	}
+	return lines[:end]
+}
+
+func linesEqual(left, right []string) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for index := range left {
+		if left[index] != right[index] {
+			return false
+		}
+	}
+	return true
+}
+
+func compileRule(rule Rule) (*compiledRule, error) {
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	if rule.Pattern == "" {
+		return nil, fmt.Errorf("pattern is required")
+	}
+	if len(rule.Pattern) > maxPatternBytes {
+		return nil, fmt.Errorf("pattern exceeds %d bytes", maxPatternBytes)
+	}
+	if rule.ContextLines < 0 || rule.ContextLines > MaxContextLines {
+		return nil, fmt.Errorf("context_lines must be between 0 and %d", MaxContextLines)
+	}
+	if rule.CooldownMs < 0 {
+		return nil, fmt.Errorf("cooldown_ms must be nonnegative")
+	}
+	expression := rule.Pattern
+	if rule.Literal {
+		expression = regexp.QuoteMeta(expression)
+	}
+	if rule.IgnoreCase {
+		expression = "(?i)" + expression
+	}
+	matcher, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	rule.ID = strings.TrimSpace(rule.ID)
+	if rule.ID == "" {
+		rule.ID = uuid.NewString()
+	}
+	if rule.Source == "" {
+		rule.Source = SourceCommand
+	}
+	rule.GroupID = strings.TrimSpace(rule.GroupID)
+	rule.Provider = strings.TrimSpace(rule.Provider)
+	compiled := &compiledRule{rule: rule, matcher: matcher}
+	if len(rule.SessionIDs) > 0 {
+		compiled.sessionIDs = make(map[string]bool, len(rule.SessionIDs))
+		for _, sessionID := range rule.SessionIDs {
+			compiled.sessionIDs[sessionID] = true
+		}
+	}
+	return compiled, nil
+}
+
+func (r *compiledRule) appliesTo(target Target) bool {
+	if r.sessionIDs != nil && !r.sessionIDs[target.SessionID] {
+		return false
+	}
+	if r.rule.GroupID != "" && r.rule.GroupID != target.GroupID {
+		return false
+	}
+	if r.rule.Provider != "" && r.rule.Provider != target.Provider {
+		return false
+	}
+	return true
+}
+
+func truncateLines(lines []string) []string {
+	if len(lines) == 0 {
+		return nil
+	}
+	truncated := make([]string, len(lines))
+	for index, line := range lines {
+		truncated[index] = truncateLine(line)
+	}
+	return truncated
+}
+
+func truncateLine(line string) string {
+	if len(line) <= maxLineBytes {
+		return line
+	}
+	return strings.ToValidUTF8(line[:maxLineBytes], "")
+}
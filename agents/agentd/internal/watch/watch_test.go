@@ -0,0 +1,129 @@
+package watch
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewLinesAlignsAppendedOutput(t *testing.T) {
+	cases := []struct {
+		name     string
+		previous []string
+		current  []string
+		want     []int
+	}{
+		{"unchanged", []string{"a", "b"}, []string{"a", "b"}, nil},
+		{"appended", []string{"a", "b"}, []string{"a", "b", "c"}, []int{2}},
+		{"scrolled", []string{"a", "b", "c"}, []string{"b", "c", "d", "e"}, []int{2, 3}},
+		{"partial line grew", []string{"a", "FA"}, []string{"a", "FAIL", "b"}, []int{1, 2}},
+		{"redrawn screen", []string{"x", "FAIL", "y"}, []string{"FAIL", "z", "FAIL"}, []int{1, 2}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NewLines(tc.previous, tc.current); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("NewLines=%v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateFiresOnlyForNewOutput(t *testing.T) {
+	engine := NewEngine()
+	if _, err := engine.Add(Rule{ID: "fail", Pattern: `FAIL|panic:`, ContextLines: 1}); err != nil {
+		t.Fatal(err)
+	}
+	target := Target{SessionID: "s1", PaneID: "%1"}
+
+	if matches := engine.Evaluate(target, []string{"old FAIL", "$ "}); len(matches) != 0 {
+		t.Fatalf("baseline fired: %+v", matches)
+	}
+	matches := engine.Evaluate(target, []string{"old FAIL", "$ go test", "--- FAIL: TestX", "ok", "", ""})
+	if len(matches) != 1 {
+		t.Fatalf("matches=%+v", matches)
+	}
+	match := matches[0]
+	if match.Rule.ID != "fail" ||
+		match.SessionID != "s1" ||
+		match.Line != "--- FAIL: TestX" ||
+		strings.Join(match.Before, "|") != "$ go test" ||
+		strings.Join(match.After, "|") != "ok" {
+		t.Fatalf("match=%+v", match)
+	}
+	if matches := engine.Evaluate(target, []string{"old FAIL", "$ go test", "--- FAIL: TestX", "ok"}); len(matches) != 0 {
+		t.Fatalf("unchanged output fired again: %+v", matches)
+	}
+}
+
+func TestEvaluateRespectsScopeAndCooldown(t *testing.T) {
+	engine := NewEngine()
+	now := time.Date(2026, 7, 23, 12, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+	if _, err := engine.Add(Rule{ID: "codex", Pattern: "error", Provider: "codex", CooldownMs: 60_000}); err != nil {
+		t.Fatal(err)
+	}
+	codex := Target{SessionID: "s1", Provider: "codex"}
+	claude := Target{SessionID: "s2", Provider: "claude_code"}
+	engine.Evaluate(codex, nil)
+	engine.Evaluate(claude, nil)
+
+	if matches := engine.Evaluate(claude, []string{"error"}); len(matches) != 0 {
+		t.Fatalf("out-of-scope session fired: %+v", matches)
+	}
+	if matches := engine.Evaluate(codex, []string{"error 1", "error 2"}); len(matches) != 1 {
+		t.Fatalf("cooldown should keep one match: %+v", matches)
+	}
+	now = now.Add(30 * time.Second)
+	if matches := engine.Evaluate(codex, []string{"error 1", "error 2", "error 3"}); len(matches) != 0 {
+		t.Fatalf("fired during cooldown: %+v", matches)
+	}
+	now = now.Add(31 * time.Second)
+	if matches := engine.Evaluate(codex, []string{"error 1", "error 2", "error 3", "error 4"}); len(matches) != 1 {
+		t.Fatalf("did not fire after cooldown: %+v", matches)
+	}
+}
+
+func TestRemoveKeepsConfigRules(t *testing.T) {
+	engine := NewEngine()
+	if _, err := engine.Add(Rule{ID: "cfg", Pattern: "x", Source: SourceConfig}); err != nil {
+		t.Fatal(err)
+	}
+	added, err := engine.Add(Rule{Pattern: "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.ID == "" || added.Source != SourceCommand {
+		t.Fatalf("added=%+v", added)
+	}
+	if _, err := engine.Add(Rule{ID: "cfg", Pattern: "z"}); err == nil {
+		t.Fatal("command rule replaced a config rule")
+	}
+	if err := engine.Remove("cfg"); !errors.Is(err, ErrConfigWatch) {
+		t.Fatalf("remove config rule err=%v", err)
+	}
+	if err := engine.Remove(added.ID); err != nil {
+		t.Fatalf("remove command rule: %v", err)
+	}
+	if err := engine.Remove(added.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("remove missing rule err=%v", err)
+	}
+	if rules := engine.Rules(); len(rules) != 1 || rules[0].ID != "cfg" {
+		t.Fatalf("rules=%+v", rules)
+	}
+}
+
+func TestAddRejectsInvalidRules(t *testing.T) {
+	engine := NewEngine()
+	for _, rule := range []Rule{
+		{Pattern: ""},
+		{Pattern: "("},
+		{Pattern: "x", ContextLines: MaxContextLines + 1},
+		{Pattern: "x", CooldownMs: -1},
+	} {
+		if _, err := engine.Add(rule); err == nil {
+			t.Fatalf("rule %+v accepted", rule)
+		}
+	}
+}
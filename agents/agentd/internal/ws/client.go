@@ -416,6 +416,8 @@ func (c *Client) SendHello(payload any) error {
 func isVolatile(msgType string) bool {
 	switch msgType {
 	case protocol.TypeTerminalOutput,
+		protocol.TypeTerminalClipboard,
+		protocol.TypeTerminalLink,
 		protocol.TypeTerminalLag,
 		protocol.TypeTerminalAttached,
 		protocol.TypeTerminalDetached,
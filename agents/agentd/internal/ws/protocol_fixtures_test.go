@@ -72,8 +72,10 @@ func TestProtocolFixtureMatrixRoundTripsProductionTypes(t *testing.T) {
 		protocol.TypeMCPGetProjectConfig, protocol.TypeMCPUpdateProject, protocol.TypeMCPServers,
 		protocol.TypeMCPConfig, protocol.TypeMCPProjectConfig, protocol.TypeMCPUpdateResult,
 		protocol.TypeTerminalAttach, protocol.TypeTerminalInput, protocol.TypeTerminalResize,
+		protocol.TypeTerminalAck,
 		protocol.TypeTerminalNavigate,
 		protocol.TypeTerminalDetach, protocol.TypeTerminalControl, protocol.TypeTerminalOutput,
+		protocol.TypeTerminalClipboard, protocol.TypeTerminalLink,
 		protocol.TypeTerminalNavigationResult,
 		protocol.TypeTerminalAttached, protocol.TypeTerminalDetached, protocol.TypeTerminalError,
 		protocol.TypeTerminalReadOnly, protocol.TypeTerminalLag, protocol.TypeTerminalAudit,
@@ -86,8 +88,8 @@ func TestProtocolFixtureMatrixRoundTripsProductionTypes(t *testing.T) {
 	}
 
 	wantCommands := []string{
-		"send_input", "send_keys", "interrupt", "kill_session", "adopt_pane", "rename_session",
-		"spawn_session", "spawn_job", "fork", "console.subscribe", "console.unsubscribe",
+		"send_input", "broadcast_input", "send_keys", "interrupt", "kill_session", "adopt_pane", "rename_session",
+		"spawn_session", "spawn_job", "cancel_job", "fork", "console.subscribe", "console.unsubscribe",
 		"capture_pane", "capture_transcript", "copy_to_session", "list_directory",
 		"new_window", "kill_window", "rename_window", "split_pane",
 		"select_window", "select_pane", "resize_pane", "zoom_pane",
@@ -154,12 +156,18 @@ func fixtureMessageTarget(t *testing.T, messageType string, agentMessage bool) a
 		return &protocol.ServerMessage[protocol.TerminalInputPayload]{}
 	case protocol.TypeTerminalResize:
 		return &protocol.ServerMessage[protocol.TerminalResizePayload]{}
+	case protocol.TypeTerminalAck:
+		return &protocol.ServerMessage[protocol.TerminalAckPayload]{}
 	case protocol.TypeTerminalNavigate:
 		return &protocol.ServerMessage[protocol.TerminalNavigatePayload]{}
 	case protocol.TypeTerminalDetach:
 		return &protocol.ServerMessage[protocol.TerminalChannelPayload]{}
 	case protocol.TypeTerminalOutput:
 		return &protocol.AgentMessage[protocol.TerminalOutputPayload]{}
+	case protocol.TypeTerminalClipboard:
+		return &protocol.AgentMessage[protocol.TerminalClipboardPayload]{}
+	case protocol.TypeTerminalLink:
+		return &protocol.AgentMessage[protocol.TerminalLinkPayload]{}
 	case protocol.TypeTerminalNavigationResult:
 		return &protocol.ServerMessage[protocol.TerminalNavigationResultPayload]{}
 	case protocol.TypeTerminalAudit:
@@ -185,6 +193,8 @@ func commandPayloadTarget(t *testing.T, commandType string) any {
 	switch commandType {
 	case "send_input":
 		return &protocol.SendInputPayload{}
+	case "broadcast_input":
+		return &protocol.BroadcastInputPayload{}
 	case "send_keys":
 		return &protocol.SendKeysPayload{}
 	case "interrupt", "kill_session":
@@ -213,6 +223,8 @@ func commandPayloadTarget(t *testing.T, commandType string) any {
 		return &protocol.SpawnSessionPayload{}
 	case "spawn_job":
 		return &protocol.SpawnJobPayload{}
+	case "cancel_job":
+		return &protocol.CancelJobPayload{}
 	case "fork":
 		return &protocol.ForkPayload{}
 	case "console.subscribe":
@@ -227,6 +239,8 @@ func commandPayloadTarget(t *testing.T, commandType string) any {
 		return &protocol.CopyToSessionPayload{}
 	case "list_directory":
 		return &protocol.ListDirectoryPayload{}
+	case "set_budget":
+		return &protocol.SetBudgetPayload{}
 	default:
 		t.Fatalf("fixture uses unregistered command type %q", commandType)
 		return nil
@@ -0,0 +1,6 @@
+-- Migration 040: QUEUED session status for host-local headless job queues
+-- agentd reports spawn_job sessions as QUEUED while they wait for a run slot.
+-- Note: ALTER TYPE ADD VALUE cannot be run in a transaction block before
+-- PostgreSQL 12; on older servers execute this file directly with psql.
+
+ALTER TYPE session_status ADD VALUE IF NOT EXISTS 'QUEUED';
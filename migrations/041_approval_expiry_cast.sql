@@ -0,0 +1,11 @@
+-- Migration 041: tolerant parsing of host-reported approval expiries
+-- requested_payload.expires_at comes from agentd. A malformed value must
+-- read as no expiry rather than abort the approval timeout sweep.
+
+CREATE OR REPLACE FUNCTION try_timestamptz(value TEXT) RETURNS TIMESTAMPTZ AS $$
+BEGIN
+  RETURN value::TIMESTAMPTZ;
+EXCEPTION WHEN others THEN
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql STABLE;
@@ -7,7 +7,9 @@ export type ControlPlaneAuthMode = 'session' | 'operator';
 
 export interface RuntimeConfig {
   agentdUrl: string;
+  agentdSocket?: string;
   sessionId?: string;
+  sessionSecret?: string;
   controlPlaneUrl?: string;
   controlPlaneToken?: string;
   controlPlaneAuthMode?: ControlPlaneAuthMode;
@@ -16,6 +18,8 @@ export interface RuntimeConfig {
 interface ConfigFile {
   agentd_url?: string;
   agentdUrl?: string;
+  agentd_socket?: string;
+  agentdSocket?: string;
   control_plane_url?: string;
   controlPlaneUrl?: string;
   control_plane_token?: string;
@@ -99,6 +103,7 @@ export async function loadRuntimeConfig(
     ?? optional(file.control_plane_url)
     ?? optional(file.controlPlaneUrl);
   const sessionId = optional(env.AC_SESSION_ID);
+  const sessionSecret = optional(env.AC_SESSION_SECRET);
   const controlPlaneToken = optional(env.AC_CONTROL_PLANE_TOKEN)
     ?? optional(file.control_plane_token)
     ?? optional(file.controlPlaneToken)
@@ -116,7 +121,11 @@ export async function loadRuntimeConfig(
         ?? optional(file.agentdUrl)
         ?? 'http://127.0.0.1:7777',
     ),
+    agentdSocket: optional(env.AC_AGENTD_SOCKET)
+      ?? optional(file.agentd_socket)
+      ?? optional(file.agentdSocket),
     sessionId,
+    sessionSecret,
     controlPlaneUrl: controlPlaneUrl ? trimTrailingSlash(controlPlaneUrl) : undefined,
     controlPlaneToken,
     controlPlaneAuthMode: requestedAuthMode,
@@ -1,5 +1,53 @@
+import { request as httpRequest } from 'node:http';
+
 export type Fetch = typeof globalThis.fetch;
 
+/**
+ * Returns a fetch that sends every request over the Unix socket at socketPath,
+ * keeping the URL's path and query. agentd serves the orchestrator API on
+ * such a socket when providers.claude.hooks_socket is configured.
+ */
+export function unixSocketFetch(socketPath: string): Fetch {
+  return (async (input: string | URL | Request, init?: RequestInit): Promise<Response> => {
+    const url = new URL(input instanceof Request ? input.url : String(input));
+    const headers: Record<string, string> = {};
+    new Headers(init?.headers).forEach((value, key) => {
+      headers[key] = value;
+    });
+    const body = typeof init?.body === 'string' ? init.body : undefined;
+    if (body !== undefined) headers['content-length'] = String(Buffer.byteLength(body));
+
+    return new Promise<Response>((resolvePromise, reject) => {
+      const req = httpRequest({
+        socketPath,
+        path: `${url.pathname}${url.search}`,
+        method: init?.method ?? 'GET',
+        headers,
+      }, (res) => {
+        const chunks: Buffer[] = [];
+        res.on('data', (chunk: Buffer) => chunks.push(chunk));
+        res.on('error', reject);
+        res.on('end', () => {
+          const responseHeaders = new Headers();
+          for (const [key, value] of Object.entries(res.headers)) {
+            if (typeof value === 'string') responseHeaders.set(key, value);
+            else if (Array.isArray(value)) value.forEach((item) => responseHeaders.append(key, item));
+          }
+          const status = res.statusCode ?? 500;
+          resolvePromise(new Response(status === 204 ? null : new Uint8Array(Buffer.concat(chunks)), {
+            status,
+            statusText: res.statusMessage,
+            headers: responseHeaders,
+          }));
+        });
+      });
+      req.on('error', reject);
+      init?.signal?.addEventListener('abort', () => req.destroy(new Error('request aborted')));
+      req.end(body);
+    });
+  }) as Fetch;
+}
+
 export class ApiError extends Error {
   constructor(
     readonly status: number,
@@ -1,9 +1,11 @@
+import { createHmac } from 'node:crypto';
 import { resolveControlPlaneAuthMode, type RuntimeConfig } from './config.js';
 import {
   FeatureUnavailableError,
   isMissingRoute,
   requestJson,
   requiresSessionOrServiceAuth,
+  unixSocketFetch,
   type Fetch,
 } from './http.js';
 
@@ -528,13 +530,31 @@ export class AgentCommandClient {
     if (!this.config.sessionId) {
       throw new Error('AC_SESSION_ID is required for local agentd operations');
     }
-    return requestJson<T>(this.fetch, `${this.config.agentdUrl}${path}`, {
+    const url = `${this.config.agentdUrl}${path}`;
+    const fetch = this.config.agentdSocket ? unixSocketFetch(this.config.agentdSocket) : this.fetch;
+    return requestJson<T>(fetch, url, {
       method,
-      headers: { 'X-AC-Session-Id': this.config.sessionId },
+      headers: {
+        'X-AC-Session-Id': this.config.sessionId,
+        ...this.localSignature(url, method, body),
+      },
       body,
     });
   }
 
+  // agentd authenticates local requests signed with the pane's session
+  // secret: an HMAC-SHA256 over the timestamp, method, request path and body.
+  private localSignature(url: string, method: string, body?: unknown): Record<string, string> {
+    if (!this.config.sessionSecret) return {};
+    const { pathname, search } = new URL(url);
+    const timestamp = String(Math.floor(Date.now() / 1000));
+    const signature = createHmac('sha256', this.config.sessionSecret)
+      .update(`${timestamp}\n${method}\n${pathname}${search}\n`)
+      .update(body === undefined ? '' : JSON.stringify(body))
+      .digest('hex');
+    return { 'X-AC-Timestamp': timestamp, 'X-AC-Signature': signature };
+  }
+
 
   private async controlRequest<T>(
     path: string,
@@ -1,3 +1,8 @@
+import { createHmac } from 'node:crypto';
+import { mkdtemp, rm } from 'node:fs/promises';
+import { createServer } from 'node:http';
+import { tmpdir } from 'node:os';
+import { join } from 'node:path';
 import { describe, expect, it, vi } from 'vitest';
 import { runCli, type CliDependencies } from '../src/cli.js';
 
@@ -154,6 +159,64 @@ describe('ac CLI', () => {
     expect(JSON.parse(fixture.stdout.join(''))).toEqual({ ok: true });
   });
 
+  it('signs local requests with the session secret', async () => {
+    const fetch = vi.fn<typeof globalThis.fetch>(async (_input, init) => {
+      const headers = new Headers(init?.headers);
+      const timestamp = headers.get('X-AC-Timestamp') ?? '';
+      const expected = createHmac('sha256', 'pane-secret')
+        .update(`${timestamp}\nPOST\n/v1/agent/send\n${String(init?.body)}`)
+        .digest('hex');
+      expect(headers.get('X-AC-Session-Id')).toBe('parent-session');
+      expect(headers.get('X-AC-Signature')).toBe(expected);
+      return jsonResponse({ ok: true });
+    });
+    const fixture = dependencies(fetch);
+    fixture.dependencies.env = {
+      ...fixture.dependencies.env,
+      AC_SESSION_SECRET: 'pane-secret',
+    };
+
+    const exitCode = await runCli(['--json', 'send', 'worker', 'status?'], fixture.dependencies);
+
+    expect(exitCode).toBe(0);
+    expect(fetch).toHaveBeenCalledOnce();
+  });
+
+  it('reaches agentd over its Unix socket when AC_AGENTD_SOCKET is set', async () => {
+    const dir = await mkdtemp(join(tmpdir(), 'ac-cli-'));
+    const socketPath = join(dir, 'agentd.sock');
+    const server = createServer((req, res) => {
+      res.setHeader('content-type', 'application/json');
+      res.end(JSON.stringify({
+        sessions: [{
+          session_id: req.headers['x-ac-session-id'],
+          provider: 'codex',
+          status: 'running',
+          name: req.url,
+          child_session_ids: [],
+        }],
+      }));
+    });
+    await new Promise<void>((resolveListen) => server.listen(socketPath, resolveListen));
+    try {
+      const fetch = vi.fn<typeof globalThis.fetch>();
+      const fixture = dependencies(fetch);
+      fixture.dependencies.env = {
+        ...fixture.dependencies.env,
+        AC_AGENTD_SOCKET: socketPath,
+      };
+
+      const exitCode = await runCli(['ls'], fixture.dependencies);
+
+      expect(exitCode).toBe(0);
+      expect(fetch).not.toHaveBeenCalled();
+      expect(fixture.stdout.join('')).toBe('/v1/agent/sessions [running] (parent-session)\n');
+    } finally {
+      await new Promise((resolveClose) => server.close(resolveClose));
+      await rm(dir, { recursive: true, force: true });
+    }
+  });
+
   it('kills a local session tree', async () => {
     const fetch = vi.fn<typeof globalThis.fetch>(async (input, init) => {
       expect(String(input)).toBe('http://127.0.0.1:7777/v1/agent/kill');
@@ -150,6 +150,8 @@ export type ProviderUsageReport = z.infer<typeof ProviderUsageReportSchema>;
 export const SessionUsageSummarySchema = z.object({
   session_id: z.string().uuid(),
   provider: z.string(),
+  // Where the figures came from: screen, transcript or job.
+  source: z.string().optional(),
   input_tokens: z.number().int().optional(),
   output_tokens: z.number().int().optional(),
   total_tokens: z.number().int().optional(),
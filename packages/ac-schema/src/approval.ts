@@ -135,5 +135,9 @@ export const ApprovalRequestedPayloadSchema = z.object({
   details: z.record(z.string(), z.unknown()),
   approval_type: ApprovalTypeSchema.default('binary'),
   input_schema: ApprovalInputSchema.optional(),
+  expires_at: z.string().datetime({ offset: true }).optional(),
+  // The host's approval wait fallback; 'wait' requests have no expires_at
+  // and are never timed out by age.
+  fallback: z.enum(['prompt', 'deny', 'allow', 'wait']).optional(),
 });
 export type ApprovalRequestedPayload = z.infer<typeof ApprovalRequestedPayloadSchema>;
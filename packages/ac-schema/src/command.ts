@@ -219,12 +219,25 @@ export type DashboardSpawnResponse = z.infer<typeof DashboardSpawnResponseSchema
 // Spawn job command payload
 export const SpawnJobPayloadSchema = z.object({
   provider: SessionProviderSchema,
+  // The job's spend counts toward this group's budgets.
+  group_id: z.string().uuid().optional(),
   cwd: z.string(),
   prompt: z.string(),
   env: z.record(z.string(), z.string()).optional(),
+  // The job is stopped as if by cancel_job once it has run this long.
+  timeout_ms: z.number().int().nonnegative().optional(),
+  // Higher priorities leave the host's job queue first.
+  priority: z.number().int().optional(),
 });
 export type SpawnJobPayload = z.infer<typeof SpawnJobPayloadSchema>;
 
+// Stop a running or queued headless job. The process group gets SIGTERM,
+// then SIGKILL after grace_ms (default 5000).
+export const CancelJobPayloadSchema = z.object({
+  grace_ms: z.number().int().nonnegative().optional(),
+});
+export type CancelJobPayload = z.infer<typeof CancelJobPayloadSchema>;
+
 // Console subscribe payload
 export const ConsoleSubscribePayloadSchema = z.object({
   subscription_id: z.string().uuid(),
@@ -279,9 +292,137 @@ export const CaptureTranscriptPayloadSchema = z.object({
     .max(TRANSCRIPT_MAX_PAGE_SIZE)
     .default(TRANSCRIPT_DEFAULT_PAGE_SIZE),
   before_entry: z.number().int().nonnegative().optional(),
+  // normalized returns provider-neutral entries; include_raw attaches each
+  // provider record to its entry.
+  format: z.enum(['raw', 'normalized']).optional(),
+  include_raw: z.boolean().optional(),
 });
 export type CaptureTranscriptPayload = z.infer<typeof CaptureTranscriptPayloadSchema>;
 
+// Search managed pane scrollback on a host. Empty filters search every pane;
+// search_id and after_match page through an earlier result instead.
+export const SearchPanesPayloadSchema = z
+  .object({
+    pattern: z.string().max(4096).optional(),
+    literal: z.boolean().optional(),
+    ignore_case: z.boolean().optional(),
+    session_ids: z.array(z.string().uuid()).optional(),
+    group_id: z.string().uuid().optional(),
+    provider: SessionProviderSchema.optional(),
+    context_lines: z.number().int().nonnegative().max(20).optional(),
+    page_size: z.number().int().positive().max(1000).optional(),
+    search_id: z.string().min(1).optional(),
+    after_match: z.number().int().nonnegative().optional(),
+  })
+  .refine((payload) => (payload.search_id === undefined) === (payload.after_match === undefined), {
+    message: 'search_id and after_match are required together',
+  })
+  .refine((payload) => payload.search_id !== undefined || Boolean(payload.pattern), {
+    message: 'pattern is required for a new search',
+  });
+export type SearchPanesPayload = z.infer<typeof SearchPanesPayloadSchema>;
+
+// Output watch rule registered at runtime. The actions run on the host when a
+// new line of pane output matches; a watch.match event is always recorded.
+export const RegisterWatchPayloadSchema = z.object({
+  watch_id: z.string().min(1).optional(),
+  pattern: z.string().min(1),
+  literal: z.boolean().optional(),
+  ignore_case: z.boolean().optional(),
+  session_ids: z.array(z.string().uuid()).optional(),
+  group_id: z.string().uuid().optional(),
+  provider: SessionProviderSchema.optional(),
+  context_lines: z.number().int().nonnegative().max(20).optional(),
+  cooldown_ms: z.number().int().nonnegative().optional(),
+  set_status: z.enum(['RUNNING', 'IDLE', 'WAITING_FOR_INPUT', 'ERROR']).optional(),
+  notify: z.boolean().optional(),
+  send_keys: z.array(z.string()).optional(),
+});
+export type RegisterWatchPayload = z.infer<typeof RegisterWatchPayloadSchema>;
+
+export const RemoveWatchPayloadSchema = z.object({
+  watch_id: z.string().min(1),
+});
+export type RemoveWatchPayload = z.infer<typeof RemoveWatchPayloadSchema>;
+
+// Token or cost budget. A session budget without session_id covers each
+// session on its own; a group budget without group_id covers each group.
+// Group and host spend start again each UTC day. Setting an existing
+// budget_id replaces it.
+export const SetBudgetPayloadSchema = z.object({
+  budget_id: z.string().min(1).optional(),
+  scope: z.enum(['session', 'group', 'host']),
+  session_id: z.string().uuid().optional(),
+  group_id: z.string().min(1).optional(),
+  unit: z.enum(['tokens', 'cents']),
+  limit: z.number().int().positive(),
+  soft_percent: z.number().min(0).max(100).optional(),
+  action: z.enum(['interrupt', 'pause']).optional(),
+});
+export type SetBudgetPayload = z.infer<typeof SetBudgetPayloadSchema>;
+
+export const RemoveBudgetPayloadSchema = z.object({
+  budget_id: z.string().min(1),
+});
+export type RemoveBudgetPayload = z.infer<typeof RemoveBudgetPayloadSchema>;
+
+// Page of a pane's recorded output, by byte offset or time range. The result
+// reports next_offset when max_bytes cut the page short.
+export const ReadRecordingPayloadSchema = z
+  .object({
+    since: z.string().datetime({ offset: true }).optional(),
+    until: z.string().datetime({ offset: true }).optional(),
+    start_offset: z.number().int().nonnegative().optional(),
+    end_offset: z.number().int().nonnegative().optional(),
+    max_bytes: z.number().int().positive().max(1024 * 1024).optional(),
+  })
+  .refine(
+    (payload) =>
+      payload.end_offset === undefined || (payload.start_offset ?? 0) <= payload.end_offset,
+    { message: 'end_offset must not be before start_offset' }
+  );
+export type ReadRecordingPayload = z.infer<typeof ReadRecordingPayloadSchema>;
+
+// Export of a pane's recording as an asciicast v2 file. publish stores it on
+// the host's file bridge instead of returning it inline.
+export const ExportCastPayloadSchema = z.object({
+  since: z.string().datetime({ offset: true }).optional(),
+  until: z.string().datetime({ offset: true }).optional(),
+  title: z.string().max(256).optional(),
+  idle_time_limit: z.number().nonnegative().optional(),
+  publish: z.boolean().optional(),
+});
+export type ExportCastPayload = z.infer<typeof ExportCastPayloadSchema>;
+
+// The visible screen as agentd's terminal emulator last rendered it.
+export const ReadScreenPayloadSchema = z.object({
+  include_ansi: z.boolean().optional(),
+});
+export type ReadScreenPayload = z.infer<typeof ReadScreenPayloadSchema>;
+
+// Recent commands of a shell session, from its OSC 133 command marks.
+export const ListCommandsPayloadSchema = z.object({
+  limit: z.number().int().positive().optional(),
+});
+export type ListCommandsPayload = z.infer<typeof ListCommandsPayloadSchema>;
+
+// Types the same text into several sessions. Every live session matching
+// group_id and provider is a target, narrowed to session_ids when given.
+export const BroadcastInputPayloadSchema = z
+  .object({
+    session_ids: z.array(z.string().uuid()).optional(),
+    group_id: z.string().uuid().optional(),
+    provider: SessionProviderSchema.optional(),
+    text: z.string(),
+    enter: z.boolean().optional(),
+  })
+  .refine(
+    (payload) =>
+      (payload.session_ids?.length ?? 0) > 0 || payload.group_id !== undefined || payload.provider !== undefined,
+    { message: 'session_ids, group_id, or provider is required' }
+  );
+export type BroadcastInputPayload = z.infer<typeof BroadcastInputPayloadSchema>;
+
 export const ScrollbackRequestSchema = z
   .object({
     mode: CaptureModeSchema,
@@ -446,6 +587,7 @@ export const TranscriptResultSchema = z.object({
   total_entries: z.number().int().nonnegative(),
   // Informational passthrough — a future agentd source value must not 5xx the route.
   source: z.string(),
+  format: z.string().optional(),
 });
 export type TranscriptResult = z.infer<typeof TranscriptResultSchema>;
 
@@ -834,12 +976,26 @@ export const CommandPayloadSchema = z.discriminatedUnion('type', [
   z.object({ type: z.literal('rename_session'), payload: RenameSessionPayloadSchema }),
   z.object({ type: z.literal('spawn_session'), payload: SpawnSessionPayloadSchema }),
   z.object({ type: z.literal('spawn_job'), payload: SpawnJobPayloadSchema }),
+  z.object({ type: z.literal('cancel_job'), payload: CancelJobPayloadSchema.optional() }),
   z.object({ type: z.literal('fork'), payload: ForkPayloadSchema }),
   z.object({ type: z.literal('console.subscribe'), payload: ConsoleSubscribePayloadSchema }),
   z.object({ type: z.literal('console.unsubscribe'), payload: ConsoleUnsubscribePayloadSchema }),
   z.object({ type: z.literal('copy_to_session'), payload: CopyToSessionPayloadSchema }),
   z.object({ type: z.literal('capture_pane'), payload: CapturePanePayloadSchema }),
   z.object({ type: z.literal('capture_transcript'), payload: CaptureTranscriptPayloadSchema }),
+  z.object({ type: z.literal('search_panes'), payload: SearchPanesPayloadSchema }),
+  z.object({ type: z.literal('register_watch'), payload: RegisterWatchPayloadSchema }),
+  z.object({ type: z.literal('remove_watch'), payload: RemoveWatchPayloadSchema }),
+  z.object({ type: z.literal('list_watches'), payload: z.object({}).optional() }),
+  z.object({ type: z.literal('set_budget'), payload: SetBudgetPayloadSchema }),
+  z.object({ type: z.literal('remove_budget'), payload: RemoveBudgetPayloadSchema }),
+  z.object({ type: z.literal('list_budgets'), payload: z.object({}).optional() }),
+  z.object({ type: z.literal('get_usage_costs'), payload: z.object({}).optional() }),
+  z.object({ type: z.literal('read_recording'), payload: ReadRecordingPayloadSchema }),
+  z.object({ type: z.literal('export_cast'), payload: ExportCastPayloadSchema }),
+  z.object({ type: z.literal('read_screen'), payload: ReadScreenPayloadSchema.optional() }),
+  z.object({ type: z.literal('list_commands'), payload: ListCommandsPayloadSchema.optional() }),
+  z.object({ type: z.literal('broadcast_input'), payload: BroadcastInputPayloadSchema }),
   z.object({ type: z.literal('list_directory'), payload: ListDirectoryPayloadSchema }),
   z.object({ type: z.literal('acp_status'), payload: ACPStatusPayloadSchema }),
   z.object({ type: z.literal('acp_action'), payload: ACPAgentActionSchema }),
@@ -33,6 +33,7 @@ export const SessionStatusSchema = z.enum([
   'WAITING_FOR_APPROVAL',
   'ERROR',
   'DONE',
+  'QUEUED',
 ]);
 export type SessionStatus = z.infer<typeof SessionStatusSchema>;
 
@@ -60,6 +61,20 @@ export const CommandTypeSchema = z.enum([
   'copy_to_session',
   'capture_pane',
   'capture_transcript',
+  'search_panes',
+  'register_watch',
+  'remove_watch',
+  'list_watches',
+  'set_budget',
+  'remove_budget',
+  'list_budgets',
+  'get_usage_costs',
+  'read_recording',
+  'export_cast',
+  'read_screen',
+  'list_commands',
+  'broadcast_input',
+  'cancel_job',
   'list_directory',
   'list_listening_ports',
   'list_drop_files',
@@ -80,11 +95,15 @@ export type CommandType = z.infer<typeof CommandTypeSchema>;
 export const EventTypeSchema = z.enum([
   'approval.requested',
   'approval.decided',
+  'approval.policy',
+  'approval.reminder',
+  'approval.expired',
   'command.completed',
   'claude.hook',
   'claude.event',
   'codex.hook',
   'codex.event',
+  'gemini.hook',
   'workshop.pre_tool_use',
   'workshop.post_tool_use',
   'workshop.user_prompt_submit',
@@ -97,5 +116,11 @@ export const EventTypeSchema = z.enum([
   'workshop.pre_compact',
   'orchestrator.report',
   'terminal.audit',
+  'watch.match',
+  'shell.command',
+  'input.broadcast',
+  'job.stderr',
+  'budget.warning',
+  'budget.exceeded',
 ]);
 export type EventType = z.infer<typeof EventTypeSchema>;
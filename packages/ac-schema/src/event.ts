@@ -91,6 +91,34 @@ export const ApprovalDecidedEventPayloadSchema = z.object({
   decided_by_user_id: z.string().uuid(),
 }).passthrough();
 
+// Recorded when a host approval policy rule answers a request without
+// escalating it.
+export const ApprovalPolicyEventPayloadSchema = z.object({
+  approval_id: z.string().min(1),
+  provider: SessionProviderSchema,
+  tool_name: z.string(),
+  rule: z.string().min(1),
+  decision: z.enum(['allow', 'deny']),
+  command: z.string().optional(),
+  paths: z.array(z.string()).optional(),
+}).passthrough();
+
+export const ApprovalReminderEventPayloadSchema = z.object({
+  approval_id: z.string().min(1),
+  provider: SessionProviderSchema.or(z.literal('')),
+  reminder: z.number().int().positive(),
+  expires_at: z.string().datetime({ offset: true }).optional(),
+}).passthrough();
+
+// Recorded when a blocked approval's wait runs out on the host. The fallback
+// says how the hook was answered: allow or deny, or prompt when the CLI was
+// left to ask in the pane.
+export const ApprovalExpiredEventPayloadSchema = z.object({
+  approval_id: z.string().min(1),
+  provider: SessionProviderSchema.or(z.literal('')),
+  fallback: z.enum(['prompt', 'allow', 'deny']),
+}).passthrough();
+
 export const CommandCompletedEventPayloadSchema = z.object({
   cmd_id: z.string().min(1),
   ok: z.boolean(),
@@ -110,14 +138,77 @@ export const TerminalAuditEventPayloadSchema = z.object({
   previous_controller_channel_id: z.string().uuid().optional(),
 }).passthrough();
 
+// Recorded when new pane output matches an output watch rule. notify asks the
+// control plane to alert the operator.
+export const WatchMatchEventPayloadSchema = z.object({
+  watch_id: z.string().min(1),
+  pattern: z.string(),
+  source: z.enum(['config', 'command']),
+  pane_id: z.string(),
+  line: z.string(),
+  before: z.array(z.string()).nullable().optional(),
+  after: z.array(z.string()).nullable().optional(),
+  notify: z.boolean().optional(),
+  matched_at: z.string().datetime({ offset: true }),
+}).passthrough();
+
+// Recorded when a shell session starts or finishes a command, from the OSC 133
+// marks its prompt emits.
+export const ShellCommandEventPayloadSchema = z.object({
+  seq: z.number().int().positive(),
+  command: z.string(),
+  state: z.enum(['running', 'finished']),
+  started_at: z.string().datetime({ offset: true }),
+  finished_at: z.string().datetime({ offset: true }).optional(),
+  duration_ms: z.number().int().nonnegative(),
+  exit_code: z.number().int().optional(),
+  pane_id: z.string().optional(),
+}).passthrough();
+
+// Recorded on each target session of a broadcast_input command.
+export const InputBroadcastEventPayloadSchema = z.object({
+  broadcast_id: z.string().uuid(),
+  cmd_id: z.string(),
+  text: z.string(),
+  enter: z.boolean(),
+  target_count: z.number().int().positive(),
+  delivered: z.boolean(),
+  error: z.string().optional(),
+}).passthrough();
+
+// One line a headless job wrote to stderr.
+export const JobStderrEventPayloadSchema = z.object({
+  line: z.string(),
+}).passthrough();
+
+// A budget's spend crossed its soft (warning) or hard (exceeded) limit.
+// subject is the session, group or UTC day the spend was measured for;
+// session_ids lists the sessions an exceeded budget holds.
+export const BudgetEventPayloadSchema = z.object({
+  budget_id: z.string().min(1),
+  scope: z.enum(['session', 'group', 'host']),
+  unit: z.enum(['tokens', 'cents']),
+  limit: z.number().int().positive(),
+  spent: z.number().nonnegative(),
+  threshold: z.number().nonnegative(),
+  level: z.enum(['soft', 'hard']),
+  subject: z.string(),
+  action: z.enum(['interrupt', 'pause']),
+  session_ids: z.array(z.string()).nullable().optional(),
+}).passthrough();
+
 export const EventPayloadSchemaRegistry = {
   'approval.requested': ApprovalRequestedPayloadSchema.passthrough(),
   'approval.decided': ApprovalDecidedEventPayloadSchema,
+  'approval.policy': ApprovalPolicyEventPayloadSchema,
+  'approval.reminder': ApprovalReminderEventPayloadSchema,
+  'approval.expired': ApprovalExpiredEventPayloadSchema,
   'command.completed': CommandCompletedEventPayloadSchema,
   'claude.hook': HookEventPayloadSchema,
   'claude.event': ProviderStreamEventPayloadSchema,
   'codex.hook': HookEventPayloadSchema,
   'codex.event': CodexEventPayloadSchema,
+  'gemini.hook': HookEventPayloadSchema,
   'workshop.pre_tool_use': WorkshopToolPayloadSchema,
   'workshop.post_tool_use': WorkshopToolPayloadSchema.extend({
     success: z.boolean(),
@@ -141,6 +232,12 @@ export const EventPayloadSchemaRegistry = {
   }),
   'orchestrator.report': AutomationRunReportRequestSchema.passthrough(),
   'terminal.audit': TerminalAuditEventPayloadSchema,
+  'watch.match': WatchMatchEventPayloadSchema,
+  'shell.command': ShellCommandEventPayloadSchema,
+  'input.broadcast': InputBroadcastEventPayloadSchema,
+  'job.stderr': JobStderrEventPayloadSchema,
+  'budget.warning': BudgetEventPayloadSchema,
+  'budget.exceeded': BudgetEventPayloadSchema,
 } satisfies Record<EventType, z.ZodTypeAny>;
 
 export type EventPayloadValidation =
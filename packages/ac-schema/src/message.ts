@@ -117,6 +117,32 @@ export const TerminalOutputMessageSchema = AgentMessageEnvelopeSchema.extend({
 });
 export type TerminalOutputMessage = z.infer<typeof TerminalOutputMessageSchema>;
 
+// OSC 52 clipboard write from the viewed pane. data is base64, exactly as the
+// application sent it.
+export const TerminalClipboardMessageSchema = AgentMessageEnvelopeSchema.extend({
+  type: z.literal('terminal.clipboard'),
+  payload: z.object({
+    channel_id: z.string().uuid(),
+    pane_id: z.string().min(1).optional(),
+    selection: z.string(),
+    data: z.string(),
+  }),
+});
+export type TerminalClipboardMessage = z.infer<typeof TerminalClipboardMessageSchema>;
+
+// OSC 8 hyperlink printed in the viewed pane and the text it covers.
+export const TerminalLinkMessageSchema = AgentMessageEnvelopeSchema.extend({
+  type: z.literal('terminal.link'),
+  payload: z.object({
+    channel_id: z.string().uuid(),
+    pane_id: z.string().min(1).optional(),
+    uri: z.string().min(1),
+    link_id: z.string().optional(),
+    text: z.string().optional(),
+  }),
+});
+export type TerminalLinkMessage = z.infer<typeof TerminalLinkMessageSchema>;
+
 // Terminal lifecycle status is live channel state, not a durable host event.
 // It bypasses the durable cursor so attach/control/error signals cannot wait
 // behind persisted inventory or terminal repaint traffic.
@@ -141,6 +167,8 @@ export const TerminalStatusMessageSchema = ServerMessageEnvelopeSchema.extend({
     resumed: z.boolean().optional(),
     resume_token: z.string().min(1).optional(),
     dropped: z.number().int().positive().optional(),
+    // Window the agent granted on attach, after clamping the requested one.
+    flow_window: z.number().int().positive().optional(),
   }),
 });
 export type TerminalStatusMessage = z.infer<typeof TerminalStatusMessageSchema>;
@@ -291,6 +319,8 @@ export const AgentMessageSchema = z.discriminatedUnion('type', [
   CommandResultMessageSchema,
   ConsoleChunkMessageSchema,
   TerminalOutputMessageSchema,
+  TerminalClipboardMessageSchema,
+  TerminalLinkMessageSchema,
   TerminalStatusMessageSchema,
   TerminalNavigationResultMessageSchema,
   TerminalAuditMessageSchema,
@@ -354,6 +384,8 @@ export const TerminalAttachMessageSchema = ServerMessageEnvelopeSchema.extend({
     rows: TerminalDimensionSchema.optional(),
     resume_token: z.string().min(1).optional(),
     letterbox: z.boolean().optional(),
+    // Opts into credit-based flow control, in decoded output bytes.
+    flow_window: z.number().int().positive().optional(),
   }),
 });
 export type TerminalAttachMessage = z.infer<typeof TerminalAttachMessageSchema>;
@@ -435,6 +467,16 @@ export const TerminalControlMessageSchema = ServerMessageEnvelopeSchema.extend({
 });
 export type TerminalControlMessage = z.infer<typeof TerminalControlMessageSchema>;
 
+// Returns flow-control credit for output the viewer has rendered.
+export const TerminalAckMessageSchema = ServerMessageEnvelopeSchema.extend({
+  type: z.literal('terminal.ack'),
+  payload: z.object({
+    channel_id: z.string().uuid(),
+    bytes: z.number().int().positive(),
+  }),
+});
+export type TerminalAckMessage = z.infer<typeof TerminalAckMessageSchema>;
+
 // Approvals decision
 export const ApprovalsDecisionMessageSchema = ServerMessageEnvelopeSchema.extend({
   type: z.literal('approvals.decision'),
@@ -452,6 +494,7 @@ export const ServerToAgentMessageSchema = z.discriminatedUnion('type', [
   TerminalNavigateMessageSchema,
   TerminalDetachMessageSchema,
   TerminalControlMessageSchema,
+  TerminalAckMessageSchema,
   ApprovalsDecisionMessageSchema,
 ]);
 export type ServerToAgentMessage = z.infer<typeof ServerToAgentMessageSchema>;
@@ -71,6 +71,37 @@ export const SessionMetadataSchema = z.object({
   claude_session_id: z.string().optional(),
   codex_thread_id: z.string().optional(),
   status_detail: z.string().nullable().optional(),
+  // Name of a host config-defined provider; the session's provider is
+  // 'unknown' on the wire.
+  provider_name: z.string().min(1).optional(),
+  // How a headless job ended; reason is exited, cancelled, timeout,
+  // start_failed or budget_exceeded.
+  job: z.object({
+    reason: z.string().optional(),
+    started_at: z.string().datetime({ offset: true }).optional(),
+    finished_at: z.string().datetime({ offset: true }).optional(),
+    duration_ms: z.number().int().nonnegative().optional(),
+    exit_code: z.number().int().optional(),
+    signal: z.string().optional(),
+    stderr_dropped_lines: z.number().int().nonnegative().optional(),
+    error: z.string().optional(),
+    // Queue state; the position and length are dropped once the job starts.
+    queue_position: z.number().int().positive().optional(),
+    queue_length: z.number().int().positive().optional(),
+    priority: z.number().int().optional(),
+    enqueued_at: z.string().datetime({ offset: true }).optional(),
+    queued_ms: z.number().int().nonnegative().optional(),
+  }).optional(),
+  // The budget over its hard limit that holds the session's prompts;
+  // cleared when the hold lifts.
+  budget: z.object({
+    budget_id: z.string().min(1),
+    scope: z.enum(['session', 'group', 'host']),
+    unit: z.enum(['tokens', 'cents']),
+    limit: z.number().int().positive(),
+    spent: z.number().nonnegative(),
+    action: z.enum(['interrupt', 'pause']),
+  }).nullable().optional(),
   git_status: z.object({
     branch: z.string().optional(),
     upstream: z.string().optional(),
@@ -87,6 +118,8 @@ export const SessionMetadataSchema = z.object({
     reason: z.string().optional(),
     tool: z.string().optional(),
     summary: z.string().optional(),
+    // When the blocked hook stops waiting; absent when it waits indefinitely.
+    expires_at: z.string().datetime({ offset: true }).optional(),
   }).nullable().optional(),
   memory_bootstrap: z.object({
     sent_at: z.string().datetime({ offset: true }),
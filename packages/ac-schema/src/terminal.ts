@@ -26,6 +26,13 @@ export const BrowserTerminalDetachMessageSchema = z.object({
   type: z.literal('detach'),
 });
 
+// Credit for decoded output bytes the browser has rendered, sent only when
+// the attach negotiated a flow window.
+export const BrowserTerminalAckMessageSchema = z.object({
+  type: z.literal('ack'),
+  bytes: z.number().int().positive(),
+});
+
 export const BrowserTerminalNavigateMessageSchema = z.discriminatedUnion('op', [
   z.object({
     type: z.literal('navigate'),
@@ -69,6 +76,7 @@ export const BrowserTerminalClientMessageSchema = z.union([
   BrowserTerminalControlMessageSchema,
   BrowserTerminalDetachMessageSchema,
   BrowserTerminalNavigateMessageSchema,
+  BrowserTerminalAckMessageSchema,
 ]);
 export type BrowserTerminalClientMessage = z.infer<typeof BrowserTerminalClientMessageSchema>;
 
@@ -85,6 +93,7 @@ export const BrowserTerminalAttachedMessageSchema = z.object({
   readonly: z.boolean().optional(),
   resumed: z.boolean().optional(),
   resume_token: z.string().min(1).optional(),
+  flow_window: z.number().int().positive().optional(),
 });
 
 const BrowserTerminalSimpleStatusMessageSchema = z.object({
@@ -103,6 +112,19 @@ export const BrowserTerminalLagMessageSchema = z.object({
   dropped: z.number().int().positive().optional(),
 });
 
+export const BrowserTerminalClipboardMessageSchema = z.object({
+  type: z.literal('clipboard'),
+  selection: z.string(),
+  data: z.string(),
+});
+
+export const BrowserTerminalLinkMessageSchema = z.object({
+  type: z.literal('link'),
+  uri: z.string().min(1),
+  link_id: z.string().optional(),
+  text: z.string().optional(),
+});
+
 export const BrowserTerminalNavigationResultMessageSchema = z.discriminatedUnion('ok', [
   z.object({
     type: z.literal('navigation_result'),
@@ -132,6 +154,8 @@ export const BrowserTerminalServerMessageSchema = z.union([
   BrowserTerminalSimpleStatusMessageSchema,
   BrowserTerminalIdleTimeoutMessageSchema,
   BrowserTerminalLagMessageSchema,
+  BrowserTerminalClipboardMessageSchema,
+  BrowserTerminalLinkMessageSchema,
   BrowserTerminalNavigationResultMessageSchema,
 ]);
 export type BrowserTerminalServerMessage = z.infer<typeof BrowserTerminalServerMessageSchema>;
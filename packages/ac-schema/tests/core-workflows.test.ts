@@ -11,6 +11,7 @@ import {
   ScrollbackRequestSchema,
   ScrollbackResultSchema,
   ServerToUIMessageSchema,
+  SessionMetadataSchema,
   TmuxPaneIdentitySchema,
   UpsertAutomationAgentSchema,
   UpsertMemoryEntrySchema,
@@ -226,6 +227,181 @@ describe('tmux schemas', () => {
     ).toBe(true);
   });
 
+  it('accepts normalized transcript captures', () => {
+    expect(
+      CaptureTranscriptPayloadSchema.parse({ format: 'normalized', include_raw: true })
+    ).toEqual({ page_size: 200, format: 'normalized', include_raw: true });
+    expect(CaptureTranscriptPayloadSchema.safeParse({ format: 'html' }).success).toBe(false);
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'capture_transcript',
+        payload: { page_size: 50, format: 'normalized', include_raw: true },
+      }).success
+    ).toBe(true);
+  });
+
+  it('accepts host-wide scrollback searches', () => {
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'search_panes',
+        payload: { pattern: 'panic:', provider: 'codex', context_lines: 2 },
+      }).success
+    ).toBe(true);
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'search_panes',
+        payload: { search_id: 'search-1', after_match: 50 },
+      }).success
+    ).toBe(true);
+    expect(CommandPayloadSchema.safeParse({ type: 'search_panes', payload: {} }).success).toBe(false);
+    expect(
+      CommandPayloadSchema.safeParse({ type: 'search_panes', payload: { search_id: 'search-1' } }).success
+    ).toBe(false);
+  });
+
+  it('accepts output watch commands', () => {
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'register_watch',
+        payload: { watch_id: 'tests-failed', pattern: 'FAIL', set_status: 'ERROR', notify: true },
+      }).success
+    ).toBe(true);
+    expect(
+      CommandPayloadSchema.safeParse({ type: 'register_watch', payload: { watch_id: 'empty' } }).success
+    ).toBe(false);
+    expect(
+      CommandPayloadSchema.safeParse({ type: 'remove_watch', payload: { watch_id: 'tests-failed' } }).success
+    ).toBe(true);
+    expect(CommandPayloadSchema.safeParse({ type: 'list_watches', payload: {} }).success).toBe(true);
+  });
+
+  it('accepts recording reads by offset or time range', () => {
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'read_recording',
+        payload: { start_offset: 1024, max_bytes: 65536 },
+      }).success
+    ).toBe(true);
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'read_recording',
+        payload: { since: '2026-07-23T12:00:00Z', until: '2026-07-23T13:00:00Z' },
+      }).success
+    ).toBe(true);
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'read_recording',
+        payload: { start_offset: 20, end_offset: 10 },
+      }).success
+    ).toBe(false);
+  });
+
+  it('accepts asciicast exports', () => {
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'export_cast',
+        payload: { title: 'deploy', idle_time_limit: 2, publish: true },
+      }).success
+    ).toBe(true);
+    expect(
+      CommandPayloadSchema.safeParse({ type: 'export_cast', payload: { idle_time_limit: -1 } }).success
+    ).toBe(false);
+  });
+
+  it('accepts emulated screen reads', () => {
+    expect(
+      CommandPayloadSchema.safeParse({ type: 'read_screen', payload: { include_ansi: true } }).success
+    ).toBe(true);
+    expect(CommandPayloadSchema.safeParse({ type: 'read_screen' }).success).toBe(true);
+  });
+
+  it('accepts shell command history reads', () => {
+    expect(
+      CommandPayloadSchema.safeParse({ type: 'list_commands', payload: { limit: 20 } }).success
+    ).toBe(true);
+    expect(
+      CommandPayloadSchema.safeParse({ type: 'list_commands', payload: { limit: 0 } }).success
+    ).toBe(false);
+  });
+
+  it('requires a target for broadcast input', () => {
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'broadcast_input',
+        payload: { provider: 'claude_code', text: 'run the tests', enter: true },
+      }).success
+    ).toBe(true);
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'broadcast_input',
+        payload: { session_ids: [], text: 'run the tests' },
+      }).success
+    ).toBe(false);
+  });
+
+  it('accepts headless job limits, cancellation and outcomes', () => {
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'spawn_job',
+        payload: { provider: 'codex', cwd: '/srv/app', prompt: 'Run the gate', timeout_ms: 60000, priority: 5 },
+      }).success
+    ).toBe(true);
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'spawn_job',
+        payload: { provider: 'codex', cwd: '/srv/app', prompt: 'Run the gate', timeout_ms: -1 },
+      }).success
+    ).toBe(false);
+    expect(
+      CommandPayloadSchema.safeParse({ type: 'cancel_job', payload: { grace_ms: 2000 } }).success
+    ).toBe(true);
+    expect(CommandPayloadSchema.safeParse({ type: 'cancel_job' }).success).toBe(true);
+    expect(
+      CommandPayloadSchema.safeParse({ type: 'cancel_job', payload: { grace_ms: -1 } }).success
+    ).toBe(false);
+
+    const job = {
+      reason: 'cancelled',
+      started_at: '2026-10-18T10:00:00.123456789Z',
+      finished_at: '2026-10-18T10:01:00Z',
+      duration_ms: 59877,
+      exit_code: -1,
+      signal: 'SIGTERM',
+    };
+    expect(SessionMetadataSchema.parse({ job })).toEqual({ job });
+    const queued = {
+      queue_position: 2,
+      queue_length: 3,
+      priority: 5,
+      enqueued_at: '2026-10-18T09:59:00.5Z',
+    };
+    expect(SessionMetadataSchema.parse({ job: queued })).toEqual({ job: queued });
+    expect(SessionMetadataSchema.safeParse({ job: { queue_position: 0 } }).success).toBe(false);
+  });
+
+  it('validates budget commands and the budget hold in session metadata', () => {
+    expect(
+      CommandPayloadSchema.safeParse({
+        type: 'set_budget',
+        payload: { budget_id: 'fleet', scope: 'group', group_id: 'fleet', unit: 'cents', limit: 100, soft_percent: 50 },
+      }).success
+    ).toBe(true);
+    expect(
+      CommandPayloadSchema.safeParse({ type: 'set_budget', payload: { scope: 'day', unit: 'cents', limit: 100 } }).success
+    ).toBe(false);
+    expect(
+      CommandPayloadSchema.safeParse({ type: 'set_budget', payload: { scope: 'host', unit: 'tokens', limit: 0 } }).success
+    ).toBe(false);
+    expect(CommandPayloadSchema.safeParse({ type: 'remove_budget', payload: { budget_id: 'fleet' } }).success).toBe(true);
+    expect(CommandPayloadSchema.safeParse({ type: 'remove_budget', payload: {} }).success).toBe(false);
+    expect(CommandPayloadSchema.safeParse({ type: 'list_budgets' }).success).toBe(true);
+    expect(CommandPayloadSchema.safeParse({ type: 'get_usage_costs' }).success).toBe(true);
+
+    const budget = { budget_id: 'fleet', scope: 'group', unit: 'cents', limit: 100, spent: 120.5, action: 'pause' };
+    expect(SessionMetadataSchema.parse({ budget })).toEqual({ budget });
+    expect(SessionMetadataSchema.parse({ budget: null })).toEqual({ budget: null });
+  });
+
   it('enforces tmux window and pane command optionality', () => {
     expect(CommandPayloadSchema.safeParse({ type: 'new_window', payload: {} }).success).toBe(true);
     expect(
@@ -20,6 +20,11 @@ describe('event payload registry', () => {
       type: 'turn.completed',
       usage: { input_tokens: 12 },
     }).status).toBe('valid');
+    expect(validateEventPayload('gemini.hook', {
+      hook_name: 'BeforeTool',
+      hook_data: { tool_name: 'run_shell_command' },
+      tool_name: 'run_shell_command',
+    }).status).toBe('valid');
     expect(validateEventPayload('workshop.subagent_start', {
       sessionId,
       provider: 'codex',
@@ -33,6 +38,82 @@ describe('event payload registry', () => {
       reason: 'Run command?',
       details: {},
     }).status).toBe('valid');
+    expect(validateEventPayload('approval.policy', {
+      approval_id: '22222222-2222-4222-8222-222222222222',
+      provider: 'claude_code',
+      tool_name: 'Read',
+      rule: 'read-in-repo',
+      decision: 'allow',
+      paths: ['src/index.ts'],
+    }).status).toBe('valid');
+    expect(validateEventPayload('approval.reminder', {
+      approval_id: '22222222-2222-4222-8222-222222222222',
+      provider: 'claude_code',
+      reminder: 2,
+      expires_at: '2026-01-02T03:04:05Z',
+    }).status).toBe('valid');
+    expect(validateEventPayload('approval.expired', {
+      approval_id: '22222222-2222-4222-8222-222222222222',
+      provider: 'codex',
+      fallback: 'deny',
+    }).status).toBe('valid');
+    expect(validateEventPayload('watch.match', {
+      watch_id: 'tests-failed',
+      pattern: 'FAIL',
+      source: 'command',
+      pane_id: '%3',
+      line: 'FAIL internal/tmux',
+      before: ['ok internal/watch'],
+      after: null,
+      actions: { set_status: 'ERROR', notify: true },
+      notify: true,
+      matched_at: '2026-01-02T03:04:05Z',
+    }).status).toBe('valid');
+    expect(validateEventPayload('shell.command', {
+      seq: 3,
+      command: 'go test ./...',
+      state: 'finished',
+      started_at: '2026-01-02T03:04:05.123456789Z',
+      finished_at: '2026-01-02T03:04:09Z',
+      duration_ms: 3877,
+      exit_code: 1,
+      pane_id: '%3',
+    }).status).toBe('valid');
+    expect(validateEventPayload('input.broadcast', {
+      broadcast_id: '33333333-3333-4333-8333-333333333333',
+      cmd_id: '01HZXBROADCASTINPUTTEST0000000',
+      text: 'run the tests and report',
+      enter: true,
+      target_count: 3,
+      delivered: false,
+      error: 'budget fleet exceeded',
+    }).status).toBe('valid');
+    expect(validateEventPayload('job.stderr', {
+      line: 'error: rate limited, retrying in 30s',
+    }).status).toBe('valid');
+    expect(validateEventPayload('budget.exceeded', {
+      budget_id: 'fleet',
+      scope: 'group',
+      unit: 'cents',
+      limit: 100,
+      spent: 120.5,
+      threshold: 100,
+      level: 'hard',
+      subject: 'fleet',
+      action: 'interrupt',
+      session_ids: ['a'],
+    }).status).toBe('valid');
+    expect(validateEventPayload('budget.warning', {
+      budget_id: 'daily',
+      scope: 'host',
+      unit: 'tokens',
+      limit: 1000,
+      spent: 800,
+      threshold: 800,
+      level: 'hard-ish',
+      subject: '2026-10-18',
+      action: 'pause',
+    }).status).toBe('invalid');
     expect(validateEventPayload('orchestrator.report', {
       outcome: 'succeeded',
       summary: 'Gate passed',
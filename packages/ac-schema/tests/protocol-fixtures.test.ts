@@ -24,6 +24,8 @@ describe('protocol fixtures', () => {
     'agent-hello.json',
     'sessions-upsert-tmux.json',
     'terminal-output.json',
+    'terminal-clipboard.json',
+    'terminal-link.json',
     'terminal-navigation-result.json',
     'commands-result.json',
     'commands-result-capture-transcript.json',
@@ -47,6 +49,8 @@ describe('protocol fixtures', () => {
   it.each([
     'terminal-attach.json',
     'terminal-attach-letterbox.json',
+    'terminal-attach-flow-control.json',
+    'terminal-ack.json',
     'terminal-input.json',
     'terminal-navigate.json',
     'terminal-navigate-scroll.json',
@@ -54,6 +58,11 @@ describe('protocol fixtures', () => {
     'terminal-viewer-state.json',
     'commands-dispatch-send-input.json',
     'commands-dispatch-capture-transcript.json',
+    'commands-dispatch-capture-transcript-normalized.json',
+    'commands-dispatch-broadcast-input.json',
+    'commands-dispatch-spawn-job.json',
+    'commands-dispatch-cancel-job.json',
+    'commands-dispatch-set-budget.json',
   ])('validates server-to-agent message fixture %s', (name) => {
     expect(ServerToAgentMessageSchema.safeParse(readFixture(name)).success).toBe(true);
   });
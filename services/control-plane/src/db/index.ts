@@ -1106,6 +1106,10 @@ export async function markApprovalTimedOut(approvalId: string): Promise<Approval
   return result.rows[0] || null;
 }
 
+// Time out pending approvals whose host-reported expires_at has passed.
+// Approvals without a valid expires_at (Notification and screen-detected
+// requests, older hosts, hosts that crashed mid-wait) time out timeoutMs
+// after they were requested, except those whose host waits for a decision.
 export async function markExpiredApprovalsTimedOut(
   timeoutMs: number
 ): Promise<{ approvals: Approval[]; sessions: Session[] }> {
@@ -1115,7 +1119,11 @@ export async function markExpiredApprovalsTimedOut(
        SET timed_out_at = NOW()
        WHERE decision IS NULL
          AND timed_out_at IS NULL
-         AND ts_requested <= NOW() - ($1::double precision * INTERVAL '1 millisecond')
+         AND COALESCE(
+           try_timestamptz(requested_payload->>'expires_at') <= NOW(),
+           COALESCE(requested_payload->>'fallback', '') <> 'wait'
+             AND ts_requested <= NOW() - ($1::double precision * INTERVAL '1 millisecond')
+         )
        RETURNING *
      ), updated_sessions AS (
        UPDATE sessions AS session
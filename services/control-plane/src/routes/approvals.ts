@@ -145,9 +145,18 @@ export function registerApprovalRoutes(app: FastifyInstance): void {
       if (approval.timed_out_at) {
         return reply.status(409).send({ error: 'Approval is no longer active' });
       }
-      const expiresAt = new Date(
-        new Date(approval.ts_requested).getTime() + config.APPROVAL_TIMEOUT_MS
-      );
+      // The host's hook stops waiting at expires_at. A wait-fallback approval
+      // waits until decided, and its decision gets the outbox default
+      // lifetime; any other approval without a valid expiry lapses
+      // APPROVAL_TIMEOUT_MS after it was requested, as the sweep treats it.
+      const requestedExpiry = typeof approval.requested_payload.expires_at === 'string'
+        ? new Date(approval.requested_payload.expires_at)
+        : null;
+      const expiresAt = requestedExpiry && !Number.isNaN(requestedExpiry.getTime())
+        ? requestedExpiry
+        : approval.requested_payload.fallback === 'wait'
+          ? new Date(Date.now() + config.APPROVAL_TIMEOUT_MS)
+          : new Date(new Date(approval.ts_requested).getTime() + config.APPROVAL_TIMEOUT_MS);
       if (expiresAt.getTime() <= Date.now()) {
         return reply.status(409).send({ error: 'Approval is no longer active' });
       }
@@ -624,6 +624,10 @@ export function registerSessionRoutes(app: FastifyInstance): void {
               ...(body.data.before_entry !== undefined
                 ? { before_entry: body.data.before_entry }
                 : {}),
+              ...(body.data.format !== undefined ? { format: body.data.format } : {}),
+              ...(body.data.include_raw !== undefined
+                ? { include_raw: body.data.include_raw }
+                : {}),
             },
           }
         );
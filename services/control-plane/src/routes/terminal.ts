@@ -6,6 +6,8 @@ import {
   TerminalDimensionSchema,
   type BrowserTerminalNavigationResultMessage,
   type BrowserTerminalServerMessage,
+  type TerminalClipboardMessage,
+  type TerminalLinkMessage,
   type TerminalNavigationResultMessage,
 } from '@agent-command/schema';
 import { randomUUID } from 'crypto';
@@ -106,6 +108,41 @@ export function handleTerminalOutput(
   }
 }
 
+// Called by agent WebSocket handler when the viewed pane writes the clipboard
+// or prints a hyperlink. Both go to the browser as JSON even on binary
+// channels.
+export function handleTerminalClipboard(
+  payload: TerminalClipboardMessage['payload']
+): boolean {
+  return sendTerminalJson(payload.channel_id, {
+    type: 'clipboard',
+    selection: payload.selection,
+    data: payload.data,
+  });
+}
+
+export function handleTerminalLink(payload: TerminalLinkMessage['payload']): boolean {
+  return sendTerminalJson(payload.channel_id, {
+    type: 'link',
+    uri: payload.uri,
+    ...(payload.link_id ? { link_id: payload.link_id } : {}),
+    ...(payload.text ? { text: payload.text } : {}),
+  });
+}
+
+function sendTerminalJson(channelId: string, message: BrowserTerminalServerMessage): boolean {
+  const channel = activeChannels.get(channelId);
+  if (!channel) return false;
+
+  try {
+    channel.uiSocket.send(JSON.stringify(message));
+    resetIdleTimeout(channelId);
+    return true;
+  } catch {
+    return false;
+  }
+}
+
 export function handleTerminalNavigationResult(
   payload: TerminalNavigationResultMessage['payload'],
   sourceHostId: string
@@ -162,6 +199,7 @@ export function handleTerminalStatus(
     resumed?: boolean;
     resume_token?: string;
     dropped?: number;
+    flow_window?: number;
   } = {}
 ): void {
   const channel = activeChannels.get(channelId);
@@ -336,6 +374,13 @@ export function registerTerminalRoutes(app: FastifyInstance): void {
       const resumeToken = url.searchParams.get('resume_token') || undefined;
       const letterbox =
         url.searchParams.get('letterbox') === '1' && parsedCols.success && parsedRows.success;
+      // A browser that acks rendered output can ask for a flow window; the
+      // agent clamps it and reports the granted window on attached.
+      const parsedFlowWindow = z
+        .number()
+        .int()
+        .positive()
+        .safeParse(Number(url.searchParams.get('flow_window')));
 
       const user = await authenticateBrowserWebSocket(app, socket, request);
       if (!user) return;
@@ -484,6 +529,15 @@ export function registerTerminalRoutes(app: FastifyInstance): void {
               break;
             }
 
+            case 'ack':
+              pubsub.sendToAgent(channel.hostId, {
+                v: 1,
+                type: 'terminal.ack',
+                ts: new Date().toISOString(),
+                payload: { channel_id: activeChannelId, bytes: message.bytes },
+              });
+              break;
+
             case 'detach':
               detachChannel(activeChannelId, 'client_request');
               break;
@@ -524,6 +578,7 @@ export function registerTerminalRoutes(app: FastifyInstance): void {
             : {}),
           ...(resumeToken ? { resume_token: resumeToken } : {}),
           ...(letterbox ? { letterbox: true } : {}),
+          ...(parsedFlowWindow.success ? { flow_window: parsedFlowWindow.data } : {}),
         },
       });
 
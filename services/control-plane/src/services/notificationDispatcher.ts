@@ -11,6 +11,12 @@ interface AttentionPayload {
   capture_hash?: string;
 }
 
+interface WatchMatchPayload {
+  watch_id: string;
+  line: string;
+  matched_at: string;
+}
+
 interface DispatcherDependencies {
   webPush: { send(notification: WebPushNotification): Promise<unknown> };
   openClaw: {
@@ -94,7 +100,12 @@ export function createNotificationDispatcher(dependencies: DispatcherDependencie
   }
 
   return {
-    async notifyApproval(approval: Approval, session: Session, actionable = true): Promise<void> {
+    async notifyApproval(
+      approval: Approval,
+      session: Session,
+      actionable = true,
+      reminder = 0
+    ): Promise<void> {
       const url = link(dependencies.baseUrl, '/tmux', {
         host_id: session.host_id,
         session_id: session.id,
@@ -113,8 +124,9 @@ export function createNotificationDispatcher(dependencies: DispatcherDependencie
         provider: approval.provider,
         eventType: 'approval.requested',
         openClawEventType: 'approvals',
-        dedupeKey: `approval:${approval.id}`,
-        title: 'Approval required',
+        // Each reminder is its own notification; the original stays deduped.
+        dedupeKey: reminder > 0 ? `approval:${approval.id}:reminder:${reminder}` : `approval:${approval.id}`,
+        title: reminder > 0 ? 'Approval still waiting' : 'Approval required',
         body: `${session.title || session.id.slice(0, 8)} needs approval for ${tool}.`,
         url,
         sessionId: session.id,
@@ -150,6 +162,27 @@ export function createNotificationDispatcher(dependencies: DispatcherDependencie
       });
     },
 
+    async notifyWatchMatch(session: Session, match: WatchMatchPayload): Promise<void> {
+      const url = link(dependencies.baseUrl, '/tmux', {
+        host_id: session.host_id,
+        session_id: session.id,
+        mode: 'terminal',
+        attach: '1',
+      });
+      await dispatch({
+        preferredUserId: session.user_id,
+        provider: session.provider,
+        eventType: 'watch.match',
+        openClawEventType: 'watch_match',
+        dedupeKey: `watch:${match.watch_id}:${session.id}:${match.matched_at}`,
+        title: `Watch ${match.watch_id} matched`,
+        body: `${session.title || session.id.slice(0, 8)}: ${match.line}`,
+        url,
+        sessionId: session.id,
+        actionable: true,
+      });
+    },
+
     async notifyGovernance(approval: GovernanceApproval): Promise<void> {
       if (approval.status !== 'pending') return;
       const url = link(dependencies.baseUrl, '/orchestrator', {
@@ -686,6 +686,23 @@ class PubSub {
     }
   }
 
+  // Re-notify for an approval the agent reports is still waiting
+  publishApprovalReminder(approval: Approval, session: Session, reminder: number): void {
+    const actionable = isActionableApproval(approval);
+    void notificationDispatcher.notifyApproval(approval, session, actionable, reminder).catch((error) => {
+      console.error('[pubsub] Failed to send approval reminder:', error);
+    });
+  }
+
+  publishWatchMatch(
+    session: Session,
+    match: { watch_id: string; line: string; matched_at: string }
+  ): void {
+    void notificationDispatcher.notifyWatchMatch(session, match).catch((error) => {
+      console.error('[pubsub] Failed to send watch notification:', error);
+    });
+  }
+
   // Publish approval updated
   publishApprovalUpdated(
     approvalId: string,
@@ -26,6 +26,8 @@ import {
   isOutboxCommandId,
 } from '../services/commandRouter.js';
 import {
+  handleTerminalClipboard,
+  handleTerminalLink,
   handleTerminalNavigationResult,
   handleTerminalOutput,
   handleTerminalStatus,
@@ -350,6 +352,18 @@ async function processAgentMessage(
       break;
     }
 
+    case 'terminal.clipboard': {
+      handleTerminalClipboard(message.payload);
+      sendAck(socket, seq, 'ok');
+      break;
+    }
+
+    case 'terminal.link': {
+      handleTerminalLink(message.payload);
+      sendAck(socket, seq, 'ok');
+      break;
+    }
+
     case 'terminal.attached':
     case 'terminal.detached':
     case 'terminal.error':
@@ -482,6 +496,7 @@ function deliverTerminalStatus(message: TerminalStatusMessage): void {
     ...(payload.resumed !== undefined ? { resumed: payload.resumed } : {}),
     ...(payload.resume_token ? { resume_token: payload.resume_token } : {}),
     ...(payload.dropped !== undefined ? { dropped: payload.dropped } : {}),
+    ...(payload.flow_window !== undefined ? { flow_window: payload.flow_window } : {}),
   });
 }
 
@@ -777,6 +792,41 @@ async function handleEventsAppend(
       }
     }
 
+    // The host stopped waiting for a decision, so the approval can no
+    // longer be answered from the dashboard.
+    if (payload.event_type === 'approval.expired' && validation.status === 'valid') {
+      const expired = await db.markApprovalTimedOut(String(payload.payload.approval_id));
+      if (expired) {
+        pubsub.publishApprovalTimedOut(expired);
+      }
+    }
+
+    if (payload.event_type === 'approval.reminder' && validation.status === 'valid') {
+      const approvalId = String(payload.payload.approval_id);
+      const [approval, session] = await Promise.all([
+        db.getApprovalById(approvalId),
+        db.getSessionById(payload.session_id),
+      ]);
+      if (approval && session && !approval.decision && !approval.timed_out_at) {
+        pubsub.publishApprovalReminder(approval, session, Number(payload.payload.reminder));
+      }
+    }
+
+    if (
+      payload.event_type === 'watch.match' &&
+      validation.status === 'valid' &&
+      payload.payload.notify === true
+    ) {
+      const session = await db.getSessionById(payload.session_id);
+      if (session) {
+        pubsub.publishWatchMatch(session, {
+          watch_id: String(payload.payload.watch_id),
+          line: String(payload.payload.line),
+          matched_at: String(payload.payload.matched_at),
+        });
+      }
+    }
+
     // Record token usage if present
     const tokenUsage = extractTokenUsage(payload.payload);
     if (tokenUsage) {
@@ -51,6 +51,7 @@ async function buildServer(
   handleTerminalStatus: ReturnType<typeof vi.fn>;
   handleTerminalNavigationResult: ReturnType<typeof vi.fn>;
   createAuditLog: ReturnType<typeof vi.fn>;
+  markApprovalTimedOut: ReturnType<typeof vi.fn>;
 }> {
   vi.resetModules();
   const upsertSession = vi.fn(async (upsertHostId: string, input: Record<string, unknown>) => ({
@@ -77,6 +78,7 @@ async function buildServer(
     }),
   };
   const createAuditLog = vi.fn(async () => undefined);
+  const markApprovalTimedOut = vi.fn(async (id: string) => ({ id, session_id: sessionId }));
   vi.doMock('../src/db/index.js', () => ({
     pool,
     validateAgentToken: vi.fn(async () => hostId),
@@ -85,6 +87,7 @@ async function buildServer(
     updateHostAckedSeq: vi.fn(async () => undefined),
     insertEvent,
     createAuditLog,
+    markApprovalTimedOut,
     upsertSession,
     getSessionById: vi.fn(async (id: string) => id === sessionId ? { id, host_id: hostId } : null),
   }));
@@ -107,6 +110,8 @@ async function buildServer(
   const handleTerminalStatus = vi.fn();
   const handleTerminalNavigationResult = vi.fn();
   vi.doMock('../src/routes/terminal.js', () => ({
+    handleTerminalClipboard: vi.fn(),
+    handleTerminalLink: vi.fn(),
     handleTerminalNavigationResult,
     handleTerminalOutput: vi.fn(),
     handleTerminalStatus,
@@ -149,6 +154,7 @@ async function buildServer(
     handleTerminalStatus,
     handleTerminalNavigationResult,
     createAuditLog,
+    markApprovalTimedOut,
   };
 }
 
@@ -693,6 +699,48 @@ describe('agent websocket ingest', () => {
     await app.close();
   });
 
+  it('times out approvals whose host wait expired', async () => {
+    const approvalId = '66666666-6666-4666-8666-666666666666';
+    const payload = { approval_id: approvalId, provider: 'codex', fallback: 'prompt' };
+    const insertEvent = vi.fn(async () => ({
+      id: 10,
+      session_id: sessionId,
+      ts: new Date().toISOString(),
+      type: 'approval.expired',
+      payload,
+    }));
+    const { app, url, markApprovalTimedOut, publishToUI } = await buildServer(insertEvent);
+    const socket = new WebSocket(`${url}/v1/agent/connect`, {
+      headers: { Authorization: 'Bearer test-agent-token' },
+    });
+    await new Promise<void>((resolve) => socket.once('open', resolve));
+    socket.send(JSON.stringify(hello()));
+    await waitForMessage(socket);
+
+    socket.send(JSON.stringify({
+      v: 1,
+      type: 'events.append',
+      ts: new Date().toISOString(),
+      seq: 2,
+      payload: {
+        session_id: sessionId,
+        event_type: 'approval.expired',
+        payload,
+      },
+    }));
+
+    await expect(waitForMessage(socket)).resolves.toMatchObject({
+      payload: { ack_seq: 2, status: 'ok' },
+    });
+    expect(markApprovalTimedOut).toHaveBeenCalledWith(approvalId);
+    expect(publishToUI).toHaveBeenCalledWith(expect.objectContaining({
+      type: 'approvals.updated',
+      payload: expect.objectContaining({ approval_id: approvalId, timed_out: true }),
+    }));
+    socket.close();
+    await app.close();
+  });
+
   it('delivers queued commands after initial inventory and before its acknowledgement', async () => {
     const queued = {
       cmd_id: '55555555-5555-4555-8555-555555555555',
@@ -780,6 +828,47 @@ describe('agent websocket ingest', () => {
     await app.close();
   });
 
+  it('accepts sessions of host config-defined providers under their wire name', async () => {
+    const { app, url, upsertSession } = await buildServer(vi.fn(async () => undefined));
+    const socket = new WebSocket(`${url}/v1/agent/connect`, {
+      headers: { Authorization: 'Bearer test-agent-token' },
+    });
+    await new Promise<void>((resolve) => socket.once('open', resolve));
+    socket.send(JSON.stringify(hello()));
+    await waitForMessage(socket);
+
+    socket.send(JSON.stringify({
+      v: 1,
+      type: 'sessions.upsert',
+      ts: new Date().toISOString(),
+      seq: 2,
+      payload: {
+        sessions: [
+          {
+            id: sessionId,
+            kind: 'tmux_pane',
+            provider: 'unknown',
+            status: 'RUNNING',
+            metadata: { provider_name: 'goose' },
+          },
+        ],
+      },
+    }));
+
+    await expect(waitForMessage(socket)).resolves.toMatchObject({
+      payload: { ack_seq: 2, status: 'ok' },
+    });
+    expect(upsertSession).toHaveBeenCalledWith(
+      hostId,
+      expect.objectContaining({
+        provider: 'unknown',
+        metadata: expect.objectContaining({ provider_name: 'goose' }),
+      })
+    );
+    socket.close();
+    await app.close();
+  });
+
   it('persists and publishes forked and parent-stamped edges from session inventory', async () => {
     const {
       app,
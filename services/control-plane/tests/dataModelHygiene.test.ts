@@ -64,6 +64,9 @@ describe('data model hygiene', () => {
     expect(sql).toContain('WITH timed_out AS');
     expect(sql).toContain('updated_sessions AS');
     expect(sql).toContain("THEN 'IDLE'::session_status");
+    expect(sql).toContain("try_timestamptz(requested_payload->>'expires_at') <= NOW()");
+    expect(sql).toContain("COALESCE(requested_payload->>'fallback', '') <> 'wait'");
+    expect(sql).not.toContain('::timestamptz');
     expect(query.mock.calls[0]?.[1]).toEqual([600_000]);
   });
 });
@@ -70,6 +70,54 @@ describe('notification dispatcher', () => {
     );
   });
 
+  it('sends approval reminders as separate notifications', async () => {
+    const approval: Approval = {
+      id: '44444444-4444-4444-8444-444444444444',
+      session_id: sessionId,
+      provider: 'claude_code',
+      ts_requested: now,
+      requested_payload: { tool_name: 'Bash' },
+    };
+    const { dispatcher, webPush } = harness();
+
+    await dispatcher.notifyApproval(approval, session, true, 2);
+
+    expect(webPush.send).toHaveBeenCalledWith(
+      expect.objectContaining({
+        eventType: 'approval.requested',
+        dedupeKey: `approval:${approval.id}:reminder:2`,
+        title: 'Approval still waiting',
+      })
+    );
+  });
+
+  it('sends output watch matches with the matched line', async () => {
+    const { dispatcher, webPush, openClaw } = harness();
+
+    await dispatcher.notifyWatchMatch(session, {
+      watch_id: 'tests-failed',
+      line: 'FAIL internal/tmux',
+      matched_at: '2026-07-19T16:01:00Z',
+    });
+
+    expect(webPush.send).toHaveBeenCalledWith(
+      expect.objectContaining({
+        eventType: 'watch.match',
+        dedupeKey: `watch:tests-failed:${sessionId}:2026-07-19T16:01:00Z`,
+        title: 'Watch tests-failed matched',
+        body: 'Wave 3 refactor: FAIL internal/tmux',
+        url: expect.stringContaining('/tmux?'),
+      })
+    );
+    expect(openClaw.queueNotification).toHaveBeenCalledWith(
+      userId,
+      'watch_match',
+      'codex',
+      expect.stringContaining('FAIL internal/tmux'),
+      expect.objectContaining({ sessionId })
+    );
+  });
+
   it('sends snapshot/status attention and run failures to the orchestrator surfaces', async () => {
     const { dispatcher, webPush, recipients } = harness();
     await dispatcher.notifyAttention(session, {
@@ -129,11 +129,24 @@ async function buildServer(options: {
   agentSend: ReturnType<typeof vi.fn>;
   createAuditLog: ReturnType<typeof vi.fn>;
   handleTerminalOutput: (channelId: string, data: string, encoding?: 'base64' | 'utf8') => boolean;
+  handleTerminalClipboard: (payload: { channel_id: string; selection: string; data: string }) => boolean;
+  handleTerminalLink: (payload: {
+    channel_id: string;
+    uri: string;
+    link_id?: string;
+    text?: string;
+  }) => boolean;
   handleTerminalStatus: (
     channelId: string,
     status: string,
     message?: string,
-    details?: { readonly?: boolean; resumed?: boolean; resume_token?: string; dropped?: number }
+    details?: {
+      readonly?: boolean;
+      resumed?: boolean;
+      resume_token?: string;
+      dropped?: number;
+      flow_window?: number;
+    }
   ) => void;
   handleTerminalNavigationResult: (payload: {
     channel_id: string;
@@ -168,6 +181,8 @@ async function buildServer(options: {
 
   const {
     registerTerminalRoutes,
+    handleTerminalClipboard,
+    handleTerminalLink,
     handleTerminalNavigationResult,
     handleTerminalOutput,
     handleTerminalStatus,
@@ -191,6 +206,8 @@ async function buildServer(options: {
     agentSend,
     createAuditLog,
     handleTerminalOutput,
+    handleTerminalClipboard,
+    handleTerminalLink,
     handleTerminalStatus: handleTerminalStatus as never,
     handleTerminalNavigationResult,
   };
@@ -250,6 +267,50 @@ describe('terminal websocket route', () => {
     await app.close();
   });
 
+  it('relays clipboard writes and hyperlinks as JSON on binary channels', async () => {
+    const { app, baseWsUrl, agentSend, handleTerminalClipboard, handleTerminalLink } =
+      await buildServer();
+    const token = await sign('operator');
+    const socket = browserWebSocket(`${baseWsUrl}/v1/ui/terminal/${sessionId}?token=${token}`);
+
+    await waitForOpen(socket);
+    await eventually(() => {
+      expect(agentSend).toHaveBeenCalledWith(expect.stringContaining('terminal.attach'));
+    });
+    const channelId = agentSend.mock.calls
+      .map(([raw]) => JSON.parse(String(raw)) as { type: string; payload: { channel_id: string } })
+      .find((message) => message.type === 'terminal.attach')!.payload.channel_id;
+    socket.send(JSON.stringify({ type: 'hello', binary: true }));
+    socket.send(JSON.stringify({ type: 'resize', cols: 100, rows: 30 }));
+    await eventually(() => {
+      expect(agentSend.mock.calls.some(([raw]) => JSON.parse(String(raw)).type === 'terminal.resize')).toBe(true);
+    });
+
+    const clipboardMessage = waitForMessage(socket);
+    expect(handleTerminalClipboard({ channel_id: channelId, selection: 'c', data: 'aGVsbG8=' })).toBe(true);
+    const clipboard = await clipboardMessage;
+    expect(clipboard.isBinary).toBe(false);
+    expect(JSON.parse(clipboard.data.toString())).toEqual({
+      type: 'clipboard',
+      selection: 'c',
+      data: 'aGVsbG8=',
+    });
+
+    const linkMessage = waitForMessage(socket);
+    expect(
+      handleTerminalLink({ channel_id: channelId, uri: 'https://example.com/build/42', text: 'build #42' })
+    ).toBe(true);
+    expect(JSON.parse((await linkMessage).data.toString())).toEqual({
+      type: 'link',
+      uri: 'https://example.com/build/42',
+      text: 'build #42',
+    });
+    expect(handleTerminalLink({ channel_id: '44444444-4444-4444-8444-444444444444', uri: 'https://example.com' })).toBe(false);
+
+    socket.close();
+    await app.close();
+  });
+
   it('buffers hello and resize frames sent while authentication is still initializing', async () => {
     const { app, baseWsUrl, agentSend, handleTerminalOutput } = await buildServer({
       sessionDelayMs: 75,
@@ -344,6 +405,45 @@ describe('terminal websocket route', () => {
     await app.close();
   });
 
+  it('negotiates a flow window and relays output credit to the agent', async () => {
+    const { app, baseWsUrl, agentSend, handleTerminalStatus } = await buildServer();
+    const token = await sign('operator');
+    const socket = browserWebSocket(
+      `${baseWsUrl}/v1/ui/terminal/${sessionId}?token=${token}&flow_window=262144`
+    );
+
+    await waitForOpen(socket);
+    await eventually(() => {
+      expect(agentSend).toHaveBeenCalledWith(expect.stringContaining('terminal.attach'));
+    });
+    const sent = () =>
+      agentSend.mock.calls.map(
+        ([raw]) => JSON.parse(String(raw)) as { type: string; payload: Record<string, unknown> }
+      );
+    const attach = sent().find((message) => message.type === 'terminal.attach');
+    expect(attach?.payload).toMatchObject({ flow_window: 262144 });
+
+    const attachedMessage = waitForMessage(socket);
+    handleTerminalStatus(String(attach?.payload.channel_id), 'attached', undefined, {
+      flow_window: 262144,
+    });
+    expect(JSON.parse((await attachedMessage).data.toString())).toEqual({
+      type: 'attached',
+      flow_window: 262144,
+    });
+
+    socket.send(JSON.stringify({ type: 'ack', bytes: 65536 }));
+    await eventually(() => {
+      expect(sent().find((message) => message.type === 'terminal.ack')?.payload).toEqual({
+        channel_id: attach?.payload.channel_id,
+        bytes: 65536,
+      });
+    });
+
+    socket.close();
+    await app.close();
+  });
+
   it('retires a stale browser channel before reattaching its resume token', async () => {
     const { app, baseWsUrl, agentSend, handleTerminalStatus } = await buildServer();
     const token = await sign('operator');